@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Vigencia de los certificados generados para el laboratorio
+const (
+	vigenciaCA     = 5 * 365 * 24 * time.Hour
+	vigenciaModulo = 365 * 24 * time.Hour
+)
+
+func main() {
+	directorio := flag.String("dir", "certs", "directorio donde se guardan la CA y los certificados")
+	modulos := flag.String("modulos", "kernel,memoria,cpu,io", "módulos para los que se genera certificado, separados por coma")
+	hosts := flag.String("hosts", "127.0.0.1,localhost", "IPs y nombres de las VMs donde corren los módulos, separados por coma")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Uso: %s [-dir certs] [-modulos kernel,memoria,cpu,io] [-hosts 127.0.0.1,localhost]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Ejemplo: %s -hosts 127.0.0.1,192.168.1.10,192.168.1.11\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Si el directorio ya tiene una CA se reutiliza, así se pueden agregar módulos después.\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := os.MkdirAll(*directorio, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error al crear %s: %v\n", *directorio, err)
+		os.Exit(1)
+	}
+
+	ca, claveCA, err := cargarOCrearCA(*directorio)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error con la CA: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, modulo := range separar(*modulos) {
+		if err := crearCertificadoModulo(*directorio, modulo, separar(*hosts), ca, claveCA); err != nil {
+			fmt.Fprintf(os.Stderr, "Error con el certificado de %s: %v\n", modulo, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Certificado de %s: %s\n", modulo, filepath.Join(*directorio, modulo+".crt"))
+	}
+
+	fmt.Printf("\nEn la configuración de cada módulo:\n")
+	fmt.Printf("  \"TLS_CA\": %q, \"TLS_CERTIFICADO\": \"%s/<modulo>.crt\", \"TLS_CLAVE\": \"%s/<modulo>.key\"\n",
+		filepath.Join(*directorio, "ca.crt"), *directorio, *directorio)
+}
+
+// cargarOCrearCA reutiliza ca.crt y ca.key si existen; si no, crea una CA nueva
+func cargarOCrearCA(directorio string) (*x509.Certificate, crypto.Signer, error) {
+	rutaCert := filepath.Join(directorio, "ca.crt")
+	rutaClave := filepath.Join(directorio, "ca.key")
+
+	if _, err := os.Stat(rutaCert); err == nil {
+		ca, clave, err := leerPar(rutaCert, rutaClave)
+		if err == nil {
+			fmt.Printf("CA existente: %s\n", rutaCert)
+		}
+		return ca, clave, err
+	}
+
+	clave, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	plantilla := &x509.Certificate{
+		SerialNumber:          nuevoSerial(),
+		Subject:               pkix.Name{CommonName: "CA del laboratorio de Sistemas Operativos"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(vigenciaCA),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, plantilla, plantilla, clave.Public(), clave)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := guardarPar(rutaCert, rutaClave, der, clave); err != nil {
+		return nil, nil, err
+	}
+	fmt.Printf("CA creada: %s\n", rutaCert)
+
+	ca, err := x509.ParseCertificate(der)
+	return ca, clave, err
+}
+
+// crearCertificadoModulo emite un certificado que sirve al módulo como servidor y como
+// cliente, válido para todos los hosts indicados
+func crearCertificadoModulo(directorio, modulo string, hosts []string, ca *x509.Certificate, claveCA crypto.Signer) error {
+	clave, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	plantilla := &x509.Certificate{
+		SerialNumber: nuevoSerial(),
+		Subject:      pkix.Name{CommonName: modulo},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(vigenciaModulo),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			plantilla.IPAddresses = append(plantilla.IPAddresses, ip)
+		} else {
+			plantilla.DNSNames = append(plantilla.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, plantilla, ca, clave.Public(), claveCA)
+	if err != nil {
+		return err
+	}
+	return guardarPar(filepath.Join(directorio, modulo+".crt"), filepath.Join(directorio, modulo+".key"), der, clave)
+}
+
+// guardarPar escribe el certificado y su clave en PEM; la clave solo la lee el dueño
+func guardarPar(rutaCert, rutaClave string, der []byte, clave *ecdsa.PrivateKey) error {
+	claveDER, err := x509.MarshalPKCS8PrivateKey(clave)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(rutaCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(rutaClave, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: claveDER}), 0600)
+}
+
+// leerPar lee un certificado y su clave PKCS#8 en PEM
+func leerPar(rutaCert, rutaClave string) (*x509.Certificate, crypto.Signer, error) {
+	certPEM, err := os.ReadFile(rutaCert)
+	if err != nil {
+		return nil, nil, err
+	}
+	clavePEM, err := os.ReadFile(rutaClave)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bloqueCert, _ := pem.Decode(certPEM)
+	bloqueClave, _ := pem.Decode(clavePEM)
+	if bloqueCert == nil || bloqueClave == nil {
+		return nil, nil, errors.New("la CA existente no está en formato PEM")
+	}
+
+	cert, err := x509.ParseCertificate(bloqueCert.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	clave, err := x509.ParsePKCS8PrivateKey(bloqueClave.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	firmante, ok := clave.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("tipo de clave no soportado en %s", rutaClave)
+	}
+	return cert, firmante, nil
+}
+
+// nuevoSerial genera un número de serie aleatorio de 128 bits
+func nuevoSerial() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(err)
+	}
+	return serial
+}
+
+// separar divide una lista separada por comas descartando los vacíos
+func separar(lista string) []string {
+	var valores []string
+	for _, valor := range strings.Split(lista, ",") {
+		if valor = strings.TrimSpace(valor); valor != "" {
+			valores = append(valores, valor)
+		}
+	}
+	return valores
+}
@@ -1,18 +1,52 @@
 package main
 
+import "github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+
 type CPUConfig struct {
 	PortCPU          int    `json:"PUERTO_CPU"`
-	IPCPU            string `json:"IP_CPU"`
-	IPMemory         string `json:"IP_MEMORIA"`
+	IPCPU            string `json:"IP_CPU" default:"127.0.0.1"`
+	IPMemory         string `json:"IP_MEMORIA" default:"127.0.0.1"`
 	PortMemory       int    `json:"PUERTO_MEMORIA"`
-	IPKernel         string `json:"IP_KERNEL"`
+	IPKernel         string `json:"IP_KERNEL" default:"127.0.0.1"`
 	PortKernel       int    `json:"PUERTO_KERNEL"`
 	TLBEntries       int    `json:"ENTRADAS_TLB"`
-	TLBReplacement   string `json:"REEMPLAZO_TLB"`
+	TLBReplacement   string `json:"REEMPLAZO_TLB" default:"FIFO"`
 	CacheEntries     int    `json:"ENTRADAS_CACHE"`
-	CacheReplacement string `json:"REEMPLAZO_CACHE"`
+	CacheReplacement string `json:"REEMPLAZO_CACHE" default:"CLOCK"`
 	CacheDelay       int    `json:"RETARDO_CACHE"`
-	LogLevel         string `json:"LOG_LEVEL"`
+	LogLevel         string `json:"LOG_LEVEL" default:"INFO"`
+	LatidoMs         int    `json:"LATIDO_MS,omitempty" default:"1000"` // 0: no envía latidos al Kernel
+
+	Timeouts   utils.TimeoutsPorDestino `json:"TIMEOUTS,omitempty"`
+	Transporte string                   `json:"TRANSPORTE,omitempty"`
+	TrazasPath string                   `json:"TRAZAS_PATH,omitempty"`
+	// SecretoCompartido firma y verifica los mensajes entre módulos; vacío no se firma
+	SecretoCompartido string `json:"SECRETO_COMPARTIDO,omitempty" sensible:"true"`
+	// TokenAdmin autentica la API REST; vacío la deja abierta solo si no hay secreto
+	TokenAdmin string `json:"TOKEN_ADMIN,omitempty" sensible:"true"`
+
+	utils.OpcionesLog
+	utils.OpcionesTLS
+}
+
+// ValidarConfig aplica las reglas de la CPU (ver utils.ConfigValidable)
+func (c *CPUConfig) ValidarConfig(r *utils.ReporteConfig) {
+	r.Direccion("IP_CPU", c.IPCPU, "PUERTO_CPU", c.PortCPU)
+	r.Direccion("IP_MEMORIA", c.IPMemory, "PUERTO_MEMORIA", c.PortMemory)
+	r.Direccion("IP_KERNEL", c.IPKernel, "PUERTO_KERNEL", c.PortKernel)
+	r.DireccionesDistintas("PUERTO_CPU", c.IPCPU, c.PortCPU, c.IPMemory, c.PortMemory)
+	r.DireccionesDistintas("PUERTO_CPU", c.IPCPU, c.PortCPU, c.IPKernel, c.PortKernel)
+	r.NoNegativo("ENTRADAS_TLB", c.TLBEntries)
+	r.UnoDe("REEMPLAZO_TLB", c.TLBReplacement, "FIFO", "LRU")
+	r.NoNegativo("ENTRADAS_CACHE", c.CacheEntries)
+	r.UnoDe("REEMPLAZO_CACHE", c.CacheReplacement, "CLOCK", "CLOCK-M")
+	r.NoNegativo("RETARDO_CACHE", c.CacheDelay)
+	r.NoNegativo("LATIDO_MS", c.LatidoMs)
+	r.NivelLog(c.LogLevel)
+	r.OpcionesLog(c.OpcionesLog)
+	r.Comunicacion(c.Transporte, c.Timeouts)
+	r.Credenciales(c.SecretoCompartido, c.TokenAdmin)
+	r.OpcionesTLS(c.OpcionesTLS)
 }
 
 var config *CPUConfig
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"sync"
 
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
@@ -96,23 +97,23 @@ func inicializarCache() {
 }
 
 // Implementar ciclo de instrucción completo
-func ejecutarCiclo(pid, pc int) (int, string, map[string]interface{}) {
+func ejecutarCiclo(ctx context.Context, pid, pc int) (int, string, *utils.ParametrosSyscall) {
 	procesoEnEjecucion = pid
 
 	// Fetch
-	instruccion := fetch(pid, pc)
+	instruccion := fetch(ctx, pid, pc)
 	if instruccion == "" {
-		return pc, "ERROR", nil
+		return pc, utils.MotivoError, nil
 	}
 
 	// Decode y Execute
-	siguientePC, motivo, parametrosSyscall := decodeAndExecute(pid, pc, instruccion)
+	siguientePC, motivo, parametrosSyscall := decodeAndExecute(ctx, pid, pc, instruccion)
 
 	// Check Interrupt
 	if checkInterrupt(pid) {
 		limpiarEstructurasPorPID(pid)
 		procesoEnEjecucion = -1
-		return siguientePC, "INTERRUPTED", nil
+		return siguientePC, utils.MotivoInterrumpido, nil
 	}
 
 	// Si el PC no fue modificado por GOTO, incrementar
@@ -158,11 +159,12 @@ func limpiarEstructurasPorPID(pid int) {
 		}
 	}
 
-	// Limpiar cache y actualizar páginas modificadas
+	// Limpiar cache; las páginas modificadas se actualizan en memoria en un solo lote
+	var modificadas []CacheEntry
 	for i := range cacheEntries {
 		if cacheEntries[i].PID == pid {
 			if cacheEntries[i].Modified {
-				actualizarMemoria(pid, cacheEntries[i].PageNumber)
+				modificadas = append(modificadas, cacheEntries[i])
 			}
 			cacheEntries[i] = CacheEntry{
 				PageNumber: -1,
@@ -174,5 +176,37 @@ func limpiarEstructurasPorPID(pid int) {
 		}
 	}
 
+	actualizarMemoriaEnLote(pid, modificadas)
+
 	utils.InfoLog.Info("Estructuras TLB y Cache limpiadas", "pid", pid)
 }
+
+// interrumpirProcesoEnEjecucion pide desalojar el proceso en ejecución para que
+// vuelva al Kernel antes de detener la CPU
+func interrumpirProcesoEnEjecucion() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if procesoEnEjecucion < 0 {
+		return
+	}
+	interrupcionPendiente = true
+	pidInterrumpido = procesoEnEjecucion
+	utils.InfoLog.Info("Desalojando proceso por detención de la CPU", "pid", procesoEnEjecucion)
+}
+
+// vaciarCache escribe en Memoria las páginas modificadas que quedaron en cache
+func vaciarCache() {
+	mutex.Lock()
+	pids := make(map[int]bool)
+	for _, entrada := range cacheEntries {
+		if entrada.PID >= 0 {
+			pids[entrada.PID] = true
+		}
+	}
+	mutex.Unlock()
+
+	for pid := range pids {
+		limpiarEstructurasPorPID(pid)
+	}
+}
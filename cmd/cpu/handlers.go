@@ -1,60 +1,60 @@
 package main
 
 import (
-	"fmt"
-	"time"
-
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
 )
 
 // Registrar todos los handlers de mensajes
 func RegistrarHandlers() {
-	modulo.RegistrarHandler(fmt.Sprintf("%d", utils.MensajeHandshake), "handshake", manejarHandshake)
-	modulo.RegistrarHandler(fmt.Sprintf("%d", utils.MensajeOperacion), "EJECUTAR_PROCESO", manejarEjecutar)
-	modulo.RegistrarHandler(fmt.Sprintf("%d", utils.MensajeEjecutar), "default", manejarEjecutar)
-	modulo.RegistrarHandler(fmt.Sprintf("%d", utils.MensajeInterrupcion), "INTERRUPCION", manejarInterrupcion)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeHandshake, "handshake", manejarHandshake)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeOperacion, "EJECUTAR_PROCESO", manejarEjecutar)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeEjecutar, "default", manejarEjecutar)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeInterrupcion, "INTERRUPCION", manejarInterrupcion)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeConsultarTLB, "default", manejarConsultarTLB)
+
+	modulo.RegistrarRuta("GET /cpu/tlb", utils.MensajeConsultarTLB, "default", nil)
+
+	// Solo el Kernel despacha procesos e interrumpe a la CPU
+	modulo.Usar(
+		utils.Recuperacion(),
+		utils.Temporizacion(umbralMensajeLento),
+		utils.Auditoria(),
+		utils.Autorizacion(utils.Permisos{
+			utils.MensajeOperacion:    {"Kernel"},
+			utils.MensajeEjecutar:     {"Kernel"},
+			utils.MensajeInterrupcion: {"Kernel"},
+		}),
+	)
 
 	utils.InfoLog.Info("Handlers registrados correctamente")
 }
 
-func manejarHandshake(msg *utils.Mensaje) (interface{}, error) {
+func manejarHandshake(msg *utils.Mensaje, solicitud utils.SolicitudHandshake) (utils.RespuestaHandshake, error) {
 	utils.InfoLog.Info("Handshake recibido", "origen", msg.Origen)
-	return map[string]interface{}{"status": "OK"}, nil
+	return utils.RespuestaHandshake{Status: "OK", Version: utils.VersionProtocolo}, nil
 }
 
 // Handler para ejecutar instrucción
-func manejarEjecutar(msg *utils.Mensaje) (interface{}, error) {
-	datos := msg.Datos.(map[string]interface{})
-	pid, okPid := datos["pid"].(float64)
-	pc, okPc := datos["pc"].(float64)
-
-	if !okPid || !okPc {
-		utils.ErrorLog.Error("Formato de mensaje incorrecto", "datos", fmt.Sprintf("%v", datos))
-		return map[string]interface{}{
-			"error": "Formato de mensaje incorrecto",
-		}, nil
-	}
-
-	pidInt := int(pid)
-	pcInt := int(pc)
+func manejarEjecutar(msg *utils.Mensaje, solicitud utils.SolicitudEjecucion) (utils.RespuestaEjecucion, error) {
+	pidInt := solicitud.PID
+	pcInt := solicitud.PC
 
 	utils.InfoLog.Info("Proceso recibido para ejecutar", "pid", pidInt, "pc", pcInt)
 
 	// Ejecutar ciclo de instrucción
-	siguientePC, motivo, parametrosSyscall := ejecutarCiclo(pidInt, pcInt)
+	siguientePC, motivo, parametrosSyscall := ejecutarCiclo(msg.Context(), pidInt, pcInt)
 
 	// Preparar respuesta
-	respuesta := map[string]interface{}{
-		"pid": pidInt,
-		"pc":  siguientePC,
+	respuesta := utils.RespuestaEjecucion{
+		PID: pidInt,
+		PC:  siguientePC,
 	}
 
-	// Agregar motivo de retorno si existe
+	// Agregar motivo de retorno si existe: el proceso vuelve al Kernel y la CPU queda libre
 	if motivo != "" {
-		respuesta["motivo_retorno"] = motivo
-		if parametrosSyscall != nil {
-			respuesta["parametros"] = parametrosSyscall
-		}
+		respuesta.MotivoRetorno = motivo
+		respuesta.Parametros = parametrosSyscall
+		modulo.Publicar(utils.TemaCPULibre, utils.DatosCPULibre{CPU: identificador, PID: pidInt, Motivo: motivo})
 	}
 
 	utils.InfoLog.Info("Proceso devuelto al Kernel", "pid", pidInt, "pc", siguientePC, "motivo", motivo)
@@ -63,18 +63,8 @@ func manejarEjecutar(msg *utils.Mensaje) (interface{}, error) {
 }
 
 // Handler para interrupciones
-func manejarInterrupcion(msg *utils.Mensaje) (interface{}, error) {
-	datos := msg.Datos.(map[string]interface{})
-	pid, ok := datos["pid"].(float64)
-
-	if !ok {
-		utils.ErrorLog.Error("Formato de interrupción incorrecto", "datos", fmt.Sprintf("%v", datos))
-		return map[string]interface{}{
-			"error": "Formato de interrupción incorrecto",
-		}, nil
-	}
-
-	pidInt := int(pid)
+func manejarInterrupcion(msg *utils.Mensaje, solicitud utils.SolicitudInterrupcion) (utils.RespuestaEstado, error) {
+	pidInt := solicitud.PID
 
 	mutex.Lock()
 	interrupcionPendiente = true
@@ -83,23 +73,25 @@ func manejarInterrupcion(msg *utils.Mensaje) (interface{}, error) {
 
 	utils.InfoLog.Info("Interrupción configurada", "pid", pidInt)
 
-	return map[string]interface{}{"ok": true}, nil
+	return utils.RespuestaOK(""), nil
 }
 
-func conectarConReintentos(c *utils.HTTPClient, nombreModulo string, datosHandshake map[string]interface{}) {
-	utils.InfoLog.Info("Iniciando conexión", "destino", nombreModulo)
+// manejarConsultarTLB devuelve las traducciones guardadas en la TLB, sin las entradas vacías
+func manejarConsultarTLB(msg *utils.Mensaje, _ utils.SinDatos) (utils.RespuestaTLB, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
 
-	for i := 1; ; i++ {
-		_, err := c.EnviarHTTPMensaje(utils.MensajeHandshake, "handshake", datosHandshake)
-		if err == nil {
-			utils.InfoLog.Info("Conexión establecida", "destino", nombreModulo)
-			return
+	respuesta := utils.RespuestaTLB{
+		CPU:       identificador,
+		Capacidad: config.TLBEntries,
+		Algoritmo: config.TLBReplacement,
+		Entradas:  make([]utils.EntradaTLB, 0, len(tlbEntries)),
+	}
+	for _, entrada := range tlbEntries {
+		if entrada.PageNumber == -1 {
+			continue
 		}
-
-		utils.InfoLog.Warn("Reintentando conexión",
-			"destino", nombreModulo,
-			"intento", i,
-			"próximo_en", "2s")
-		time.Sleep(2 * time.Second)
+		respuesta.Entradas = append(respuesta.Entradas, utils.EntradaTLB{PID: entrada.PID, Pagina: entrada.PageNumber, Marco: entrada.FrameNumber})
 	}
+	return respuesta, nil
 }
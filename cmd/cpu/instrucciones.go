@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -9,29 +10,24 @@ import (
 )
 
 // Fetch: Obtener instrucción desde memoria
-func fetch(pid, pc int) string {
-	utils.InfoLog.Info(fmt.Sprintf("PID: %d - FETCH - PC: %d", pid, pc))
+func fetch(ctx context.Context, pid, pc int) string {
+	utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - FETCH - PC: %d", pid, pc))
 
-	params := map[string]interface{}{
-		"pid": pid,
-		"pc":  pc,
-	}
+	ctx, span := utils.IniciarSpan(ctx, "fetch", "pid", pid, "pc", pc)
+	defer span.Finalizar()
+
+	solicitud := utils.SolicitudInstruccion{PID: pid, PC: pc}
 
-	respuesta, err := memoriaClient.EnviarHTTPMensaje(utils.MensajeFetch, "FETCH", params)
+	respuesta, err := utils.EnviarConContexto[utils.SolicitudInstruccion, utils.RespuestaInstruccion](ctx, memoriaClient, utils.MensajeFetch, "FETCH", solicitud)
 	if err != nil {
+		span.RegistrarError(err)
 		utils.ErrorLog.Error("Error al solicitar instrucción a memoria", "error", err)
 		return ""
 	}
 
-	respuestaMap, ok := respuesta.(map[string]interface{})
-	if !ok {
-		utils.ErrorLog.Error("Formato de respuesta incorrecto", "respuesta", fmt.Sprintf("%v", respuesta))
-		return ""
-	}
-
-	instruccion, ok := respuestaMap["instruccion"].(string)
-	if !ok {
-		utils.ErrorLog.Error("Formato de instrucción incorrecto", "respuesta", fmt.Sprintf("%v", respuestaMap))
+	instruccion := respuesta.Instruccion
+	if instruccion == "" {
+		utils.ErrorLog.Error("Memoria devolvió una instrucción vacía", "pid", pid, "pc", pc)
 		return ""
 	}
 
@@ -40,11 +36,11 @@ func fetch(pid, pc int) string {
 }
 
 // Decode y Execute: Interpretar y ejecutar instrucción
-func decodeAndExecute(pid, pc int, instruccion string) (int, string, map[string]interface{}) {
+func decodeAndExecute(ctx context.Context, pid, pc int, instruccion string) (int, string, *utils.ParametrosSyscall) {
 	partes := strings.Fields(instruccion)
 	if len(partes) == 0 {
 		utils.ErrorLog.Error("Instrucción vacía", "pid", pid, "pc", pc)
-		return pc, "ERROR", nil
+		return pc, utils.MotivoError, nil
 	}
 
 	operacion := partes[0]
@@ -56,9 +52,9 @@ func decodeAndExecute(pid, pc int, instruccion string) (int, string, map[string]
 		argsString = strings.Join(parametros, " ")
 	}
 
-	utils.InfoLog.Info(fmt.Sprintf("PID: %d - Ejecutando: %s %s", pid, operacion, argsString))
+	utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Ejecutando: %s %s", pid, operacion, argsString))
 
-	parametrosSyscall := make(map[string]interface{})
+	parametrosSyscall := &utils.ParametrosSyscall{}
 	motivoRetorno := ""
 	siguientePC := pc
 
@@ -71,14 +67,14 @@ func decodeAndExecute(pid, pc int, instruccion string) (int, string, map[string]
 			direccion, err := strconv.Atoi(parametros[0])
 			if err != nil {
 				utils.ErrorLog.Error("Error en dirección WRITE", "error", err)
-				motivoRetorno = "ERROR"
+				motivoRetorno = utils.MotivoError
 				break
 			}
 			datos := parametros[1]
-			escribirEnMemoria(pid, direccion, datos)
+			escribirEnMemoria(ctx, pid, direccion, datos)
 		} else {
 			utils.ErrorLog.Error("WRITE: parámetros insuficientes", "parametros", parametros)
-			motivoRetorno = "ERROR"
+			motivoRetorno = utils.MotivoError
 		}
 
 	case "READ":
@@ -87,13 +83,13 @@ func decodeAndExecute(pid, pc int, instruccion string) (int, string, map[string]
 			tamano, err2 := strconv.Atoi(parametros[1])
 			if err1 != nil || err2 != nil {
 				utils.ErrorLog.Error("Error en parámetros READ", "err1", err1, "err2", err2)
-				motivoRetorno = "ERROR"
+				motivoRetorno = utils.MotivoError
 				break
 			}
-			leerDeMemoria(pid, direccion, tamano)
+			leerDeMemoria(ctx, pid, direccion, tamano)
 		} else {
 			utils.ErrorLog.Error("READ: parámetros insuficientes", "parametros", parametros)
-			motivoRetorno = "ERROR"
+			motivoRetorno = utils.MotivoError
 		}
 
 	case "GOTO":
@@ -101,14 +97,14 @@ func decodeAndExecute(pid, pc int, instruccion string) (int, string, map[string]
 			nuevoPC, err := strconv.Atoi(parametros[0])
 			if err != nil {
 				utils.ErrorLog.Error("Error en GOTO", "error", err)
-				motivoRetorno = "ERROR"
+				motivoRetorno = utils.MotivoError
 				break
 			}
 			siguientePC = nuevoPC
 			utils.InfoLog.Info("GOTO ejecutado", "pid", pid, "nuevo_pc", nuevoPC)
 		} else {
 			utils.ErrorLog.Error("GOTO: parámetros insuficientes", "parametros", parametros)
-			motivoRetorno = "ERROR"
+			motivoRetorno = utils.MotivoError
 		}
 
 	case "IO":
@@ -117,16 +113,16 @@ func decodeAndExecute(pid, pc int, instruccion string) (int, string, map[string]
 			tiempo, err := strconv.Atoi(parametros[1])
 			if err != nil {
 				utils.ErrorLog.Error("Error en tiempo IO", "error", err)
-				motivoRetorno = "ERROR"
+				motivoRetorno = utils.MotivoError
 				break
 			}
-			parametrosSyscall["dispositivo"] = dispositivo
-			parametrosSyscall["tiempo"] = tiempo
-			motivoRetorno = "SYSCALL_IO"
+			parametrosSyscall.Dispositivo = dispositivo
+			parametrosSyscall.Tiempo = tiempo
+			motivoRetorno = utils.MotivoSyscallIO
 			utils.InfoLog.Info("IO solicitado", "pid", pid, "dispositivo", dispositivo, "tiempo", tiempo)
 		} else {
 			utils.ErrorLog.Error("IO: parámetros insuficientes", "parametros", parametros)
-			motivoRetorno = "ERROR"
+			motivoRetorno = utils.MotivoError
 		}
 
 	case "INIT_PROC":
@@ -135,29 +131,39 @@ func decodeAndExecute(pid, pc int, instruccion string) (int, string, map[string]
 			tamano, err := strconv.Atoi(parametros[1])
 			if err != nil {
 				utils.ErrorLog.Error("Error en tamaño INIT_PROC", "error", err)
-				motivoRetorno = "ERROR"
+				motivoRetorno = utils.MotivoError
 				break
 			}
-			parametrosSyscall["archivo"] = archivo
-			parametrosSyscall["tamano"] = tamano
-			motivoRetorno = "SYSCALL_INIT_PROC"
-			utils.InfoLog.Info("INIT_PROC solicitado", "pid", pid, "archivo", archivo, "tamano", tamano)
+			// La prioridad es opcional: INIT_PROC ARCHIVO TAMAÑO [PRIORIDAD]
+			if len(parametros) >= 3 {
+				prioridad, err := strconv.Atoi(parametros[2])
+				if err != nil || prioridad < 0 {
+					utils.ErrorLog.Error("Error en prioridad INIT_PROC", "valor", parametros[2])
+					motivoRetorno = utils.MotivoError
+					break
+				}
+				parametrosSyscall.Prioridad = prioridad
+			}
+			parametrosSyscall.Archivo = archivo
+			parametrosSyscall.Tamano = tamano
+			motivoRetorno = utils.MotivoSyscallInitProc
+			utils.InfoLog.Info("INIT_PROC solicitado", "pid", pid, "archivo", archivo, "tamano", tamano, "prioridad", parametrosSyscall.Prioridad)
 		} else {
 			utils.ErrorLog.Error("INIT_PROC: parámetros insuficientes", "parametros", parametros)
-			motivoRetorno = "ERROR"
+			motivoRetorno = utils.MotivoError
 		}
 
 	case "DUMP_MEMORY":
-		motivoRetorno = "SYSCALL_DUMP_MEMORY"
+		motivoRetorno = utils.MotivoSyscallDump
 		utils.InfoLog.Info("DUMP_MEMORY solicitado", "pid", pid)
 
 	case "EXIT":
-		motivoRetorno = "EXIT"
+		motivoRetorno = utils.MotivoExit
 		utils.InfoLog.Info("EXIT ejecutado", "pid", pid)
 
 	default:
 		utils.ErrorLog.Error("Instrucción desconocida", "operacion", operacion)
-		motivoRetorno = "ERROR"
+		motivoRetorno = utils.MotivoError
 	}
 
 	return siguientePC, motivoRetorno, parametrosSyscall
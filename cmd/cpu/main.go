@@ -1,24 +1,31 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
 )
 
+// umbralMensajeLento es a partir de cuánto se avisa en el log que un mensaje tardó demasiado
+const umbralMensajeLento = time.Second
+
 var (
 	modulo        *utils.Modulo
 	identificador string
+	rutaConfig    string
 	kernelClient  *utils.HTTPClient
 	memoriaClient *utils.HTTPClient
 )
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Error: Uso: ./cpu [identificador] [archivo_config_opcional]")
+		fmt.Println("Error: Uso: ./cpu [identificador] [archivo_config_o_escenario_opcional]")
 		os.Exit(1)
 	}
 
@@ -32,22 +39,24 @@ func main() {
 	// Inicializar componentes de la CPU
 	inicializarCPU()
 
-	// Mantener vivo el proceso
-	select {}
+	// Atender hasta Ctrl+C y detener devolviendo el proceso en ejecución
+	if err := modulo.Ejecutar(context.Background(), utils.PlazoDetencionPorDefecto); err != nil {
+		os.Exit(1)
+	}
 }
 
 func inicializarModulo() {
 	// Determinar archivo de configuración
-	var rutaConfig string
+	rutaConfig = filepath.Join("configs", "cpu-config.json")
 	if len(os.Args) >= 3 {
 		rutaConfig = os.Args[2]
-	} else {
-		rutaConfig = filepath.Join("configs", "cpu-config.json")
 	}
 
-	// Verificar que el archivo existe
-	if _, err := os.Stat(rutaConfig); os.IsNotExist(err) {
-		fmt.Printf("Error: El archivo de configuración '%s' no existe\n", rutaConfig)
+	// Verificar que el archivo (o el escenario) existe
+	var err error
+	rutaConfig, err = utils.ResolverConfiguracion(rutaConfig)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -63,35 +72,89 @@ func inicializarModulo() {
 	utils.InicializarLogger("INFO", loggerName)
 
 	// Cargar configuración
-	config = utils.CargarConfiguracion[CPUConfig](rutaConfig)
+	config = utils.CargarConfiguracionModulo[CPUConfig](rutaConfig, utils.SeccionCPU, identificador)
 
 	// Actualizar nivel de log
-	utils.InicializarLogger(config.LogLevel, loggerName)
+	if err := utils.InicializarLoggerConOpciones(config.LogLevel, loggerName, config.OpcionesLog); err != nil {
+		utils.ErrorLog.Error("No se pudieron abrir los archivos de log", "error", err)
+	}
 	utils.InfoLog.Info("Configuración cargada", "nivel_log", config.LogLevel, "config_path", rutaConfig)
+	if err := utils.InicializarTrazas(loggerName, config.TrazasPath); err != nil {
+		utils.ErrorLog.Error("No se pudieron habilitar las trazas", "error", err)
+	}
+	utils.HabilitarFirma(config.SecretoCompartido)
+	utils.HabilitarTokenAdmin(config.TokenAdmin)
+	if err := utils.HabilitarTLS(config.OpcionesTLS); err != nil {
+		utils.ErrorLog.Error("No se pudo habilitar TLS", "error", err)
+		os.Exit(1)
+	}
 
 	// Datos para el handshake
-	datosHandshake := map[string]interface{}{
-		"nombre":        "CPU",
-		"tipo":          "CPU",
-		"ip":            config.IPCPU,
-		"puerto":        config.PortCPU,
-		"identificador": identificador,
+	datosHandshake := utils.SolicitudHandshake{
+		Version:       utils.VersionProtocolo,
+		Nombre:        "CPU",
+		Tipo:          "CPU",
+		IP:            config.IPCPU,
+		Puerto:        config.PortCPU,
+		Identificador: identificador,
 	}
 
 	// Registrar handlers
 	RegistrarHandlers()
 
 	// Iniciar servidor
-	modulo.IniciarServidor(config.IPCPU, config.PortCPU)
+	if err := modulo.Iniciar(config.IPCPU, config.PortCPU); err != nil {
+		utils.ErrorLog.Error("No se pudo iniciar el servidor", "error", err)
+		os.Exit(1)
+	}
 	utils.InfoLog.Info("Servidor iniciado", "ip", config.IPCPU, "puerto", config.PortCPU)
 
 	// Crear clientes HTTP directamente
 	kernelClient = utils.NewHTTPClient(config.IPKernel, config.PortKernel, "CPU->Kernel")
 	memoriaClient = utils.NewHTTPClient(config.IPMemory, config.PortMemory, "CPU->Memoria")
+	kernelClient.Timeout = config.Timeouts.Para("KERNEL")
+	memoriaClient.Timeout = config.Timeouts.Para("MEMORIA")
+	memoriaClient.ConReintentos(utils.PoliticaPorDefecto)
+	if config.Transporte != "" {
+		kernelClient.Transporte = config.Transporte
+		memoriaClient.Transporte = config.Transporte
+	}
 
 	utils.InfoLog.Info("Clientes HTTP creados")
 
-	// Conectar con reintentos
-	go conectarConReintentos(kernelClient, "Kernel", datosHandshake)
-	go conectarConReintentos(memoriaClient, "Memoria", datosHandshake)
+	// Al detenerse, la CPU se da de baja para no recibir más procesos, desaloja el que
+	// está ejecutando y, cuando este vuelve al Kernel, escribe la cache en Memoria
+	modulo.AlDetener("baja en Kernel", func(ctx context.Context) error {
+		if err := utils.AvisarBaja(ctx, kernelClient, datosHandshake); err != nil {
+			utils.ErrorLog.Warn("No se pudo avisar la baja al Kernel", "error", err)
+		}
+		interrumpirProcesoEnEjecucion()
+		return nil
+	})
+	modulo.AlFinalizar("vaciar cache", func(ctx context.Context) error {
+		vaciarCache()
+		return nil
+	})
+
+	// Conectar con reintentos. La CPU queda lista cuando ambos handshakes se completan
+	// y desde entonces mantiene su lease en el Kernel con latidos.
+	go func() {
+		var conexiones sync.WaitGroup
+		var errKernel, errMemoria error
+		conexiones.Add(2)
+		go func() {
+			defer conexiones.Done()
+			intervalo := time.Duration(config.LatidoMs) * time.Millisecond
+			_, errKernel = utils.MantenerConexion(modulo.Contexto(), kernelClient, "Kernel", datosHandshake, intervalo, modulo.Carga)
+		}()
+		go func() {
+			defer conexiones.Done()
+			_, errMemoria = utils.ConectarConReintentos(memoriaClient, "Memoria", datosHandshake)
+		}()
+		conexiones.Wait()
+
+		if errKernel == nil && errMemoria == nil {
+			modulo.MarcarListo()
+		}
+	}()
 }
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
@@ -34,6 +36,12 @@ var (
 	configCargada    bool = false
 )
 
+// Aciertos y fallos de TLB y caché expuestos en /metrics, por CPU y resultado
+var (
+	accesosTLB   = utils.NuevoContador("cpu_tlb_accesos_total", "Búsquedas en la TLB.", "cpu", "resultado")
+	accesosCache = utils.NuevoContador("cpu_cache_accesos_total", "Búsquedas en la caché de páginas.", "cpu", "resultado")
+)
+
 // Cargar configuración directamente desde memoria-config.json
 func cargarConfigMemoria() error {
 	if configCargada {
@@ -42,15 +50,21 @@ func cargarConfigMemoria() error {
 
 	utils.InfoLog.Info("Cargando configuración de memoria desde archivo")
 
-	rutaConfigMemoria := filepath.Join("configs", "memoria-config.json")
+	// En un escenario la geometría sale de su sección de Memoria
+	var configMemoria *MemoriaConfig
+	if utils.EsEscenario(rutaConfig) {
+		configMemoria = utils.CargarConfiguracionModulo[MemoriaConfig](rutaConfig, utils.SeccionMemoria, "")
+	} else {
+		rutaConfigMemoria := filepath.Join("configs", "memoria-config.json")
+
+		if _, err := os.Stat(rutaConfigMemoria); os.IsNotExist(err) {
+			utils.ErrorLog.Error("Archivo de configuración de memoria no encontrado", "ruta", rutaConfigMemoria)
+			return fmt.Errorf("archivo de configuración de memoria no encontrado: %s", rutaConfigMemoria)
+		}
 
-	if _, err := os.Stat(rutaConfigMemoria); os.IsNotExist(err) {
-		utils.ErrorLog.Error("Archivo de configuración de memoria no encontrado", "ruta", rutaConfigMemoria)
-		return fmt.Errorf("archivo de configuración de memoria no encontrado: %s", rutaConfigMemoria)
+		configMemoria = utils.CargarConfiguracion[MemoriaConfig](rutaConfigMemoria)
 	}
 
-	configMemoria := utils.CargarConfiguracion[MemoriaConfig](rutaConfigMemoria)
-
 	tamanoPagina = configMemoria.PageSize
 	entradasPorTabla = configMemoria.EntriesPerPage
 	numeroDeNiveles = configMemoria.NumberOfLevels
@@ -81,7 +95,7 @@ func calcularEntradasNiveles(direccionLogica int) ([]int, int) {
 }
 
 // Traducir dirección lógica a física
-func traducirDireccion(pid, direccionLogica int) int {
+func traducirDireccion(ctx context.Context, pid, direccionLogica int) int {
 	if !configCargada {
 		err := cargarConfigMemoria()
 		if err != nil {
@@ -93,20 +107,32 @@ func traducirDireccion(pid, direccionLogica int) int {
 	numeroPagina := int(math.Floor(float64(direccionLogica) / float64(tamanoPagina)))
 	desplazamiento := direccionLogica % tamanoPagina
 
+	ctx, span := utils.IniciarSpan(ctx, "translate", "pid", pid, "direccion_logica", direccionLogica, "pagina", numeroPagina)
+	defer span.Finalizar()
+
 	// Buscar en TLB si está habilitada
 	if config.TLBEntries > 0 {
 		marco := buscarEnTLB(pid, numeroPagina)
 		if marco != -1 {
-			utils.InfoLog.Info(fmt.Sprintf("PID: %d - TLB HIT - Página: %d", pid, numeroPagina))
+			utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - TLB HIT - Página: %d", pid, numeroPagina))
+			accesosTLB.Incrementar(identificador, "hit")
+			span.Atributo("tlb", "HIT")
 			return marco*tamanoPagina + desplazamiento
 		} else {
-			utils.InfoLog.Info(fmt.Sprintf("PID: %d - TLB MISS - Página: %d", pid, numeroPagina))
+			utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - TLB MISS - Página: %d", pid, numeroPagina))
+			accesosTLB.Incrementar(identificador, "miss")
+			span.Atributo("tlb", "MISS")
+
+			var spanMiss *utils.Span
+			ctx, spanMiss = utils.IniciarSpan(ctx, "tlb miss", "pid", pid, "pagina", numeroPagina)
+			defer spanMiss.Finalizar()
 		}
 	}
 
 	// Obtener marco desde memoria
-	marco := obtenerMarcoDeMemoria(pid, numeroPagina)
+	marco := obtenerMarcoDeMemoria(ctx, pid, numeroPagina)
 	if marco == -1 {
+		span.RegistrarError(fmt.Errorf("no se obtuvo marco para la página %d", numeroPagina))
 		utils.ErrorLog.Error("Error obteniendo marco de memoria", "pid", pid, "pagina", numeroPagina)
 		return -1
 	}
@@ -195,7 +221,7 @@ func actualizarTLB(pid, numeroPagina, marco int) {
 }
 
 // Obtener marco de memoria para una página
-func obtenerMarcoDeMemoria(pid, numeroPagina int) int {
+func obtenerMarcoDeMemoria(ctx context.Context, pid, numeroPagina int) int {
 	utils.InfoLog.Info("Buscando marco", "pid", pid, "pagina", numeroPagina)
 
 	// Verificar en caché si está habilitada
@@ -210,11 +236,11 @@ func obtenerMarcoDeMemoria(pid, numeroPagina int) int {
 	entradas, _ := calcularEntradasNiveles(direccionLogica)
 
 	// Preparar mensaje con info multinivel
-	params := map[string]interface{}{
-		"pid":              pid,
-		"pagina":           numeroPagina,
-		"entradas_niveles": entradas,
-		"niveles":          numeroDeNiveles,
+	solicitud := utils.SolicitudMarco{
+		PID:             pid,
+		Pagina:          numeroPagina,
+		EntradasNiveles: entradas,
+		Niveles:         numeroDeNiveles,
 	}
 
 	// Simular delay de cache si está configurado
@@ -223,30 +249,14 @@ func obtenerMarcoDeMemoria(pid, numeroPagina int) int {
 	}
 
 	// Enviar solicitud a memoria
-	respuesta, err := memoriaClient.EnviarHTTPMensaje(utils.MensajeObtenerMarco, "OBTENER_MARCO", params)
+	respuesta, err := utils.EnviarConContexto[utils.SolicitudMarco, utils.RespuestaMarco](ctx, memoriaClient, utils.MensajeObtenerMarco, "OBTENER_MARCO", solicitud)
 	if err != nil {
 		utils.ErrorLog.Error("Error al solicitar marco a memoria", "error", err)
 		return -1
 	}
+	marcoInt := respuesta.Marco
 
-	// Extraer marco de la respuesta
-	datos, ok := respuesta.(map[string]interface{})
-	if !ok {
-		utils.ErrorLog.Error("Formato de datos incorrecto")
-		return -1
-	}
-
-	var marcoInt int
-	if marco, ok := datos["marco"].(float64); ok {
-		marcoInt = int(marco)
-	} else if marco, ok := datos["marco"].(int); ok {
-		marcoInt = marco
-	} else {
-		utils.ErrorLog.Error("Formato de marco incorrecto", "marco", datos["marco"])
-		return -1
-	}
-
-	utils.InfoLog.Info(fmt.Sprintf("PID: %d - OBTENER MARCO - Página: %d - Marco: %d", pid, numeroPagina, marcoInt))
+	utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - OBTENER MARCO - Página: %d - Marco: %d", pid, numeroPagina, marcoInt))
 
 	// Actualizar caché si está habilitada
 	if config.CacheEntries > 0 {
@@ -263,7 +273,8 @@ func buscarEnCache(pid, numeroPagina int) int {
 
 	for i, entrada := range cacheEntries {
 		if entrada.PageNumber == numeroPagina && entrada.PID == pid {
-			utils.InfoLog.Info(fmt.Sprintf("PID: %d - Cache Hit - Página: %d", pid, numeroPagina))
+			utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Cache Hit - Página: %d", pid, numeroPagina))
+			accesosCache.Incrementar(identificador, "hit")
 
 			// Actualizar bit de referencia para CLOCK
 			cacheEntries[i].Referenced = true
@@ -272,7 +283,8 @@ func buscarEnCache(pid, numeroPagina int) int {
 		}
 	}
 
-	utils.InfoLog.Info(fmt.Sprintf("PID: %d - Cache Miss - Página: %d", pid, numeroPagina))
+	utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Cache Miss - Página: %d", pid, numeroPagina))
+	accesosCache.Incrementar(identificador, "miss")
 	return -1
 }
 
@@ -299,7 +311,7 @@ func actualizarCache(pid, numeroPagina int, marco int) {
 			Modified:    false,
 			Referenced:  true,
 		}
-		utils.InfoLog.Info(fmt.Sprintf("PID: %d - Cache Add - Página: %d", pid, numeroPagina))
+		utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Cache Add - Página: %d", pid, numeroPagina))
 		return
 	}
 
@@ -327,7 +339,7 @@ func aplicarCLOCK(pid, numeroPagina int, marco int) {
 				Modified:    false,
 				Referenced:  true,
 			}
-			utils.InfoLog.Info(fmt.Sprintf("PID: %d - Cache Add - Página: %d", pid, numeroPagina))
+			utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Cache Add - Página: %d", pid, numeroPagina))
 
 			clockPointer = (clockPointer + 1) % len(cacheEntries)
 			return
@@ -391,7 +403,7 @@ func aplicarCLOCKM(pid, numeroPagina int, marco int) {
 		Modified:    false,
 		Referenced:  true,
 	}
-	utils.InfoLog.Info(fmt.Sprintf("PID: %d - Cache Add - Página: %d", pid, numeroPagina))
+	utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Cache Add - Página: %d", pid, numeroPagina))
 
 	clockPointer = (clockPointer + 1) % len(cacheEntries)
 }
@@ -414,24 +426,134 @@ func actualizarMemoria(pid, numeroPagina int) {
 		return
 	}
 
-	params := map[string]interface{}{
-		"pid":              pid,
-		"direccion_fisica": marco * tamanoPagina,
-		"valor":            contenido,
+	direccionFisica := marco * tamanoPagina
+	solicitud := utils.SolicitudEscritura{
+		PID:             pid,
+		DireccionFisica: &direccionFisica,
+		Valor:           contenido,
 	}
 
-	_, err := memoriaClient.EnviarHTTPMensaje(utils.MensajeEscribir, "ESCRIBIR", params)
+	_, err := utils.Enviar[utils.SolicitudEscritura, utils.RespuestaEstado](memoriaClient, utils.MensajeEscribir, "ESCRIBIR", solicitud)
 	if err != nil {
 		utils.ErrorLog.Error("Error al actualizar memoria", "error", err)
 		return
 	}
 
-	utils.InfoLog.Info(fmt.Sprintf("PID: %d - Memory Update - Página: %d - Frame: %d", pid, numeroPagina, marco))
+	utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Memory Update - Página: %d - Frame: %d", pid, numeroPagina, marco))
+}
+
+// actualizarMemoriaEnLote escribe en memoria las páginas modificadas de la caché con una sola solicitud
+func actualizarMemoriaEnLote(pid int, paginas []CacheEntry) {
+	if len(paginas) == 0 {
+		return
+	}
+
+	lote := utils.NuevoLote()
+	for _, pagina := range paginas {
+		direccionFisica := pagina.FrameNumber * tamanoPagina
+		lote.Agregar(utils.MensajeEscribir, "ESCRIBIR", utils.SolicitudEscritura{
+			PID:             pid,
+			DireccionFisica: &direccionFisica,
+			Valor:           pagina.Content,
+		})
+	}
+
+	resultados, err := memoriaClient.EnviarLote(context.Background(), lote)
+	if err != nil {
+		utils.ErrorLog.Error("Error al actualizar memoria", "pid", pid, "paginas", len(paginas), "error", err)
+		return
+	}
+
+	for i, resultado := range resultados {
+		if err := resultado.Err(); err != nil {
+			utils.ErrorLog.Error("Error al actualizar memoria", "pid", pid, "pagina", paginas[i].PageNumber, "error", err)
+			continue
+		}
+		utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Memory Update - Página: %d - Frame: %d", pid, paginas[i].PageNumber, paginas[i].FrameNumber))
+	}
+}
+
+// fragmentoAcceso es la parte de una lectura o escritura que cae en una misma página
+type fragmentoAcceso struct {
+	direccionFisica int
+	desde, hasta    int // posiciones dentro del valor
+}
+
+// fragmentarAcceso divide un acceso de tamano bytes por las páginas que abarca y traduce
+// cada una. direccionFisica es la traducción de direccionLogica, que ya hizo el llamador.
+// Cada página traducida deja sus logs obligatorios de TLB y OBTENER MARCO, como cualquier
+// traducción; el log de la acción sigue siendo uno solo por acceso.
+func fragmentarAcceso(ctx context.Context, pid, direccionLogica, direccionFisica, tamano int) ([]fragmentoAcceso, error) {
+	hasta := tamano
+	if tamanoPagina > 0 {
+		hasta = min(tamano, tamanoPagina-direccionLogica%tamanoPagina)
+	}
+	fragmentos := []fragmentoAcceso{{direccionFisica: direccionFisica, desde: 0, hasta: hasta}}
+
+	for desde := hasta; desde < tamano; desde = hasta {
+		hasta = min(tamano, desde+tamanoPagina)
+		fisica := traducirDireccion(ctx, pid, direccionLogica+desde)
+		if fisica == -1 {
+			return nil, fmt.Errorf("no se pudo traducir la dirección lógica %d", direccionLogica+desde)
+		}
+		fragmentos = append(fragmentos, fragmentoAcceso{direccionFisica: fisica, desde: desde, hasta: hasta})
+	}
+	return fragmentos, nil
+}
+
+// escribirFragmentos escribe en un solo lote un valor que abarca varias páginas
+func escribirFragmentos(ctx context.Context, pid int, fragmentos []fragmentoAcceso, valor string) error {
+	lote := utils.NuevoLote().DetenerEnError()
+	for _, fragmento := range fragmentos {
+		lote.Agregar(utils.MensajeEscribir, "ESCRIBIR", utils.SolicitudEscritura{
+			PID:             pid,
+			DireccionFisica: &fragmento.direccionFisica,
+			Valor:           valor[fragmento.desde:fragmento.hasta],
+		})
+	}
+
+	resultados, err := memoriaClient.EnviarLote(ctx, lote)
+	if err != nil {
+		return err
+	}
+	for _, resultado := range resultados {
+		if err := resultado.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// leerFragmentos lee en un solo lote un valor que abarca varias páginas
+func leerFragmentos(ctx context.Context, pid int, fragmentos []fragmentoAcceso) (string, error) {
+	lote := utils.NuevoLote().DetenerEnError()
+	for _, fragmento := range fragmentos {
+		lote.Agregar(utils.MensajeLeer, "LEER", utils.SolicitudLectura{
+			PID:             pid,
+			DireccionFisica: &fragmento.direccionFisica,
+			Tamanio:         fragmento.hasta - fragmento.desde,
+		})
+	}
+
+	resultados, err := memoriaClient.EnviarLote(ctx, lote)
+	if err != nil {
+		return "", err
+	}
+
+	var valor strings.Builder
+	for _, resultado := range resultados {
+		lectura, err := utils.ResultadoComo[utils.RespuestaLectura](resultado)
+		if err != nil {
+			return "", err
+		}
+		valor.WriteString(lectura.Valor)
+	}
+	return valor.String(), nil
 }
 
 // Escribir en memoria
-func escribirEnMemoria(pid, direccionLogica int, valor string) {
-	direccionFisica := traducirDireccion(pid, direccionLogica)
+func escribirEnMemoria(ctx context.Context, pid, direccionLogica int, valor string) {
+	direccionFisica := traducirDireccion(ctx, pid, direccionLogica)
 
 	// Verificar si está en caché
 	if config.CacheEntries > 0 {
@@ -444,7 +566,7 @@ func escribirEnMemoria(pid, direccionLogica int, valor string) {
 				cacheEntries[i].Modified = true
 				cacheEntries[i].Referenced = true
 
-				utils.InfoLog.Info(fmt.Sprintf("PID: %d - Acción: ESCRIBIR - Dir Física: %d - Valor: %s", pid, direccionFisica, valor))
+				utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Acción: ESCRIBIR - Dir Física: %d - Valor: %s", pid, direccionFisica, valor))
 				mutex.Unlock()
 				return
 			}
@@ -452,25 +574,29 @@ func escribirEnMemoria(pid, direccionLogica int, valor string) {
 		mutex.Unlock()
 	}
 
-	// Escribir en memoria
-	params := map[string]interface{}{
-		"pid":       pid,
-		"direccion": direccionFisica,
-		"valor":     valor,
+	// Escribir en memoria; si el valor abarca varias páginas se escribe cada parte en un lote
+	fragmentos, err := fragmentarAcceso(ctx, pid, direccionLogica, direccionFisica, len(valor))
+	if err == nil && len(fragmentos) > 1 {
+		err = escribirFragmentos(ctx, pid, fragmentos, valor)
+	} else if err == nil {
+		solicitud := utils.SolicitudEscritura{
+			PID:             pid,
+			DireccionFisica: &direccionFisica,
+			Valor:           valor,
+		}
+		_, err = utils.EnviarConContexto[utils.SolicitudEscritura, utils.RespuestaEstado](ctx, memoriaClient, utils.MensajeEscribir, "ESCRIBIR", solicitud)
 	}
-
-	_, err := memoriaClient.EnviarHTTPMensaje(utils.MensajeEscribir, "ESCRIBIR", params)
 	if err != nil {
 		utils.ErrorLog.Error("Error al escribir en memoria", "error", err)
 		return
 	}
 
-	utils.InfoLog.Info(fmt.Sprintf("PID: %d - Acción: ESCRIBIR - Dir Física: %d - Valor: %s", pid, direccionFisica, valor))
+	utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Acción: ESCRIBIR - Dir Física: %d - Valor: %s", pid, direccionFisica, valor))
 }
 
 // Leer de memoria
-func leerDeMemoria(pid, direccionLogica, tamano int) string {
-	direccionFisica := traducirDireccion(pid, direccionLogica)
+func leerDeMemoria(ctx context.Context, pid, direccionLogica, tamano int) string {
+	direccionFisica := traducirDireccion(ctx, pid, direccionLogica)
 
 	// Verificar si está en caché
 	if config.CacheEntries > 0 {
@@ -482,7 +608,7 @@ func leerDeMemoria(pid, direccionLogica, tamano int) string {
 				valor := entrada.Content
 				cacheEntries[i].Referenced = true
 
-				utils.InfoLog.Info(fmt.Sprintf("PID: %d - Acción: LEER - Dir Física: %d - Valor: %s", pid, direccionFisica, valor))
+				utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Acción: LEER - Dir Física: %d - Valor: %s", pid, direccionFisica, valor))
 				mutex.Unlock()
 				return valor
 			}
@@ -490,31 +616,26 @@ func leerDeMemoria(pid, direccionLogica, tamano int) string {
 		mutex.Unlock()
 	}
 
-	// Leer de memoria
-	params := map[string]interface{}{
-		"pid":       pid,
-		"direccion": direccionFisica,
-		"tamano":    tamano,
+	// Leer de memoria; si el valor abarca varias páginas se lee cada parte en un lote
+	var valor string
+	fragmentos, err := fragmentarAcceso(ctx, pid, direccionLogica, direccionFisica, tamano)
+	if err == nil && len(fragmentos) > 1 {
+		valor, err = leerFragmentos(ctx, pid, fragmentos)
+	} else if err == nil {
+		solicitud := utils.SolicitudLectura{
+			PID:             pid,
+			DireccionFisica: &direccionFisica,
+			Tamanio:         tamano,
+		}
+		var respuesta utils.RespuestaLectura
+		respuesta, err = utils.EnviarConContexto[utils.SolicitudLectura, utils.RespuestaLectura](ctx, memoriaClient, utils.MensajeLeer, "LEER", solicitud)
+		valor = respuesta.Valor
 	}
-
-	respuesta, err := memoriaClient.EnviarHTTPMensaje(utils.MensajeLeer, "LEER", params)
 	if err != nil {
 		utils.ErrorLog.Error("Error al leer de memoria", "error", err)
 		return ""
 	}
 
-	datos, ok := respuesta.(map[string]interface{})
-	if !ok {
-		utils.ErrorLog.Error("Formato de datos incorrecto")
-		return ""
-	}
-
-	valor, ok := datos["valor"].(string)
-	if !ok {
-		utils.ErrorLog.Error("Formato de valor incorrecto")
-		return ""
-	}
-
-	utils.InfoLog.Info(fmt.Sprintf("PID: %d - Acción: LEER - Dir Física: %d - Valor: %s", pid, direccionFisica, valor))
+	utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Acción: LEER - Dir Física: %d - Valor: %s", pid, direccionFisica, valor))
 	return valor
 }
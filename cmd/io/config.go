@@ -1,16 +1,44 @@
 package main
 
+import "github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+
 // Estructura de configuración para IO
 type IOConfig struct {
-	IPIO        string `json:"IP_IO"`
+	IPIO        string `json:"IP_IO" default:"127.0.0.1"`
 	PortIO      int    `json:"PUERTO_IO"`
-	IPKernel    string `json:"IP_KERNEL"`
+	IPKernel    string `json:"IP_KERNEL" default:"127.0.0.1"`
 	PortKernel  int    `json:"PUERTO_KERNEL"`
-	LogLevel    string `json:"LOG_LEVEL"`
+	LogLevel    string `json:"LOG_LEVEL" default:"INFO"`
 	RetardoBase int    `json:"RETARDO_BASE"`
+	LatidoMs    int    `json:"LATIDO_MS,omitempty" default:"1000"` // 0: no envía latidos al Kernel
+
+	Timeouts   utils.TimeoutsPorDestino `json:"TIMEOUTS,omitempty"`
+	Transporte string                   `json:"TRANSPORTE,omitempty"`
+	TrazasPath string                   `json:"TRAZAS_PATH,omitempty"`
+	// SecretoCompartido firma y verifica los mensajes entre módulos; vacío no se firma
+	SecretoCompartido string `json:"SECRETO_COMPARTIDO,omitempty" sensible:"true"`
+	// TokenAdmin autentica la API REST; vacío la deja abierta solo si no hay secreto
+	TokenAdmin string `json:"TOKEN_ADMIN,omitempty" sensible:"true"`
+
+	utils.OpcionesLog
+	utils.OpcionesTLS
+}
+
+// ValidarConfig aplica las reglas de IO (ver utils.ConfigValidable)
+func (c *IOConfig) ValidarConfig(r *utils.ReporteConfig) {
+	r.Direccion("IP_IO", c.IPIO, "PUERTO_IO", c.PortIO)
+	r.Direccion("IP_KERNEL", c.IPKernel, "PUERTO_KERNEL", c.PortKernel)
+	r.DireccionesDistintas("PUERTO_IO", c.IPIO, c.PortIO, c.IPKernel, c.PortKernel)
+	r.NoNegativo("RETARDO_BASE", c.RetardoBase)
+	r.NoNegativo("LATIDO_MS", c.LatidoMs)
+	r.NivelLog(c.LogLevel)
+	r.OpcionesLog(c.OpcionesLog)
+	r.Comunicacion(c.Transporte, c.Timeouts)
+	r.Credenciales(c.SecretoCompartido, c.TokenAdmin)
+	r.OpcionesTLS(c.OpcionesTLS)
 }
 
 // Variables globales
 var (
 	config *IOConfig
-)
\ No newline at end of file
+)
@@ -1,36 +1,24 @@
 package main
 
 import (
-	"time"
-
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
 )
 
 // Handler para handshake
-func handlerHandshake(msg *utils.Mensaje) (interface{}, error) {
+func handlerHandshake(msg *utils.Mensaje, solicitud utils.SolicitudHandshake) (utils.RespuestaHandshake, error) {
 	utils.InfoLog.Info("Handshake recibido", "origen", msg.Origen)
-	return map[string]interface{}{"status": "OK"}, nil
-}
-
-// Handler para operaciones IO
-func handlerOperacion(msg *utils.Mensaje) (interface{}, error) {
-	return utils.HandlerGenerico(msg, config.RetardoBase, procesarOperacion)
+	return utils.RespuestaHandshake{Status: "OK", Version: utils.VersionProtocolo}, nil
 }
 
-func conectarConReintentos(cliente *utils.HTTPClient, nombreModulo string, datosHandshake map[string]interface{}) {
-	utils.InfoLog.Info("Iniciando conexión", "destino", nombreModulo)
+// Handler para operaciones IO. El RETARDO_BASE ya lo aplicó la cadena de middlewares.
+func handlerOperacion(msg *utils.Mensaje, solicitud utils.SolicitudIO) (utils.RespuestaEstado, error) {
+	// Si el dispositivo empezó a detenerse durante el retardo, el Kernel la reasigna
+	if modulo.Server.Deteniendo() {
+		return utils.RespuestaEstado{}, utils.NuevoError(utils.ErrorNoListo, "el dispositivo se está deteniendo, PID %d no atendido", solicitud.PID)
+	}
 
-	for i := 1; ; i++ {
-		_, err := cliente.EnviarHTTPMensaje(utils.MensajeHandshake, "handshake", datosHandshake)
-		if err == nil {
-			utils.InfoLog.Info("Conexión establecida", "destino", nombreModulo)
-			return
-		}
+	ctx := registrarOperacion(msg.Context(), solicitud)
+	defer terminarOperacion(solicitud.PID)
 
-		utils.InfoLog.Warn("Reintentando conexión",
-			"destino", nombreModulo,
-			"intento", i,
-			"próximo_en", "2s")
-		time.Sleep(2 * time.Second)
-	}
+	return procesarOperacion(ctx, solicitud)
 }
@@ -1,19 +1,110 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
 )
 
+// operacionIO es una solicitud de IO en curso
+type operacionIO struct {
+	tiempo   int
+	inicio   time.Time
+	cancelar context.CancelFunc
+}
+
+var (
+	operacionesEnCurso = make(map[int]*operacionIO)
+	operacionesMutex   sync.Mutex
+
+	// dispositivo es el nombre con el que el dispositivo se registra en el Kernel
+	dispositivo string
+)
+
+// registrarMetricas expone en /metrics las operaciones en curso del dispositivo
+func registrarMetricas(dispositivo string) {
+	utils.NuevoMedidor("io_operaciones_en_curso", "Solicitudes de IO que el dispositivo está atendiendo.", func() []utils.Muestra {
+		operacionesMutex.Lock()
+		enCurso := len(operacionesEnCurso)
+		operacionesMutex.Unlock()
+		return []utils.Muestra{{Etiquetas: map[string]string{"dispositivo": dispositivo}, Valor: float64(enCurso)}}
+	})
+}
+
+// registrarOperacion agrega la solicitud de pid a las operaciones en curso y devuelve
+// el contexto que se cancela si el dispositivo se detiene antes de terminarla
+func registrarOperacion(ctx context.Context, solicitud utils.SolicitudIO) context.Context {
+	ctx, cancelar := context.WithCancel(ctx)
+
+	operacionesMutex.Lock()
+	operacionesEnCurso[solicitud.PID] = &operacionIO{tiempo: solicitud.Tiempo, inicio: time.Now(), cancelar: cancelar}
+	operacionesMutex.Unlock()
+	return ctx
+}
+
+// terminarOperacion quita la operación de pid de las operaciones en curso y, si era la
+// última, publica que el dispositivo quedó libre. Devuelve false si ya la había tomado
+// interrumpirOperaciones, que es quien avisa al Kernel en ese caso.
+func terminarOperacion(pid int) bool {
+	operacionesMutex.Lock()
+	operacion, existe := operacionesEnCurso[pid]
+	if existe {
+		delete(operacionesEnCurso, pid)
+		operacion.cancelar()
+	}
+	libre := existe && len(operacionesEnCurso) == 0
+	operacionesMutex.Unlock()
+
+	if libre {
+		modulo.Publicar(utils.TemaDispositivoLibre, utils.DatosDispositivoLibre{Dispositivo: dispositivo, PID: pid})
+	}
+	return existe
+}
+
+// interrumpirOperaciones cancela las operaciones en curso y avisa al Kernel cuánto
+// tiempo le faltaba a cada una, para que las reasigne a otro dispositivo
+func interrumpirOperaciones(ctx context.Context, dispositivo string) error {
+	operacionesMutex.Lock()
+	pendientes := operacionesEnCurso
+	operacionesEnCurso = make(map[int]*operacionIO)
+	operacionesMutex.Unlock()
+
+	var primerError error
+	for pid, operacion := range pendientes {
+		operacion.cancelar()
+
+		restante := operacion.tiempo - int(time.Since(operacion.inicio).Milliseconds())
+		restante = max(0, min(restante, operacion.tiempo))
+
+		notificacion := utils.NotificacionKernel{
+			Evento:      utils.EventoIOInterrumpida,
+			PID:         pid,
+			Dispositivo: dispositivo,
+			Tiempo:      restante,
+			Timestamp:   time.Now().UnixMilli(),
+		}
+		_, err := utils.EnviarConContexto[utils.NotificacionKernel, utils.RespuestaEstado](ctx, kernelClient, utils.MensajeOperacion, "IO_INTERRUMPIDA", notificacion)
+		if err != nil {
+			utils.ErrorLog.Error("No se pudo avisar al Kernel la IO interrumpida", "pid", pid, "error", err)
+			if primerError == nil {
+				primerError = err
+			}
+			continue
+		}
+		utils.InfoLog.Info("IO interrumpida notificada a Kernel", "pid", pid, "tiempo_restante", restante)
+	}
+	return primerError
+}
+
 // Notificar al Kernel que la operación IO ha terminado
 func notificarIOTerminadaAKernel(pid int) {
-	datos := map[string]interface{}{
-		"evento":    "IO_TERMINADA",
-		"operacion": "IO_COMPLETADA",
-		"pid":       pid,
-		"timestamp": time.Now().UnixNano() / int64(time.Millisecond),
+	notificacion := utils.NotificacionKernel{
+		Evento:    utils.EventoIOTerminada,
+		PID:       pid,
+		Timestamp: time.Now().UnixMilli(),
 	}
 
 	if kernelClient == nil {
@@ -21,7 +112,7 @@ func notificarIOTerminadaAKernel(pid int) {
 		return
 	}
 
-	_, err := kernelClient.EnviarHTTPOperacion("IO_COMPLETADA", datos)
+	_, err := utils.Enviar[utils.NotificacionKernel, utils.RespuestaEstado](kernelClient, utils.MensajeOperacion, "IO_COMPLETADA", notificacion)
 	if err != nil {
 		utils.ErrorLog.Error("Error notificando IO terminada a Kernel", "error", err.Error(), "pid", pid)
 	} else {
@@ -29,53 +120,33 @@ func notificarIOTerminadaAKernel(pid int) {
 	}
 }
 
-// Procesar operación IO
-func procesarOperacion(msg *utils.Mensaje) (interface{}, error) {
-	datos, ok := msg.Datos.(map[string]interface{})
-	if !ok {
-		utils.ErrorLog.Warn("Formato de datos inválido en operación IO")
-		return map[string]interface{}{
-			"status":  "ERROR",
-			"mensaje": "Formato de datos inválido",
-		}, nil
-	}
-
-	// Extraer PID
-	pidFloat, pidOk := datos["pid"].(float64)
-	if !pidOk {
-		utils.ErrorLog.Warn("Operación IO sin PID válido", "datos", datos)
-		return map[string]interface{}{
-			"status":  "ERROR",
-			"mensaje": "PID inválido en solicitud IO",
-		}, nil
-	}
-	pid := int(pidFloat)
-
-	// Extraer tiempo
-	tiempoFloat, tiempoOk := datos["tiempo"].(float64)
-	if !tiempoOk {
-		utils.ErrorLog.Warn("Operación IO sin tiempo válido", "datos", datos)
-		return map[string]interface{}{
-			"status":  "ERROR",
-			"mensaje": "Tiempo inválido en solicitud IO",
-		}, nil
-	}
-	tiempo := int(tiempoFloat)
+// Procesar operación IO. Si el Kernel cancela la solicitud no se le notifica el fin.
+func procesarOperacion(ctx context.Context, solicitud utils.SolicitudIO) (utils.RespuestaEstado, error) {
+	pid := solicitud.PID
+	tiempo := solicitud.Tiempo
 
 	// Log de inicio de IO
-	utils.InfoLog.Info(fmt.Sprintf("PID: %d - Inicio de IO - Tiempo: %d", pid, tiempo))
+	utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Inicio de IO - Tiempo: %d", pid, tiempo))
+
+	ctx, span := utils.IniciarSpan(ctx, "io", "pid", pid, "tiempo", tiempo)
+	defer span.Finalizar()
 
 	// Simular la operación IO con el retardo configurado
-	utils.AplicarRetardo("io_operacion", tiempo)
+	if err := utils.AplicarRetardoConContexto(ctx, "io_operacion", tiempo); err != nil {
+		utils.InfoLog.Info(fmt.Sprintf("PID: %d - IO cancelada", pid), "motivo", err)
+		span.RegistrarError(err)
+		return utils.RespuestaEstado{}, err
+	}
+
+	// Si el dispositivo se detuvo justo al terminar, el Kernel ya fue avisado de la interrupción
+	if !terminarOperacion(pid) {
+		return utils.RespuestaEstado{}, utils.NuevoError(utils.ErrorCancelado, "IO de %d interrumpida por detención del dispositivo", pid)
+	}
 
 	// Log de fin de IO
-	utils.InfoLog.Info(fmt.Sprintf("PID: %d - Fin de IO", pid))
+	utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d - Fin de IO", pid))
 
-	// Notificar al Kernel que la operación IO ha terminado
 	go notificarIOTerminadaAKernel(pid)
 
-	return map[string]interface{}{
-		"status":  "OK",
-		"mensaje": "Operación I/O completada exitosamente",
-	}, nil
+	return utils.RespuestaOK("Operación I/O completada exitosamente"), nil
 }
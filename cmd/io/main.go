@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
 )
@@ -15,40 +17,54 @@ var (
 func main() {
 	// Verificar argumentos mínimos
 	if len(os.Args) < 3 {
-		fmt.Println("Uso: ./io <nombre_dispositivo> <ruta_configuracion>")
+		fmt.Println("Uso: ./io <nombre_dispositivo> <ruta_configuracion|escenario>")
 		fmt.Println("Ejemplo: ./io DISCO configs/io1-config.json")
 		os.Exit(1)
 	}
 
 	nombreDispositivo := os.Args[1]
-	rutaConfig := os.Args[2]
 
-	// Verificar que el archivo de configuración existe
-	if _, err := os.Stat(rutaConfig); os.IsNotExist(err) {
-		fmt.Printf("Error: El archivo de configuración '%s' no existe\n", rutaConfig)
+	// Verificar que el archivo de configuración (o el escenario) existe
+	rutaConfig, err := utils.ResolverConfiguracion(os.Args[2])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
 	// Inicializar módulo
 	inicializarModulo(rutaConfig, nombreDispositivo)
 
-	// Mantener vivo el proceso
-	select {}
+	// Atender hasta Ctrl+C y detener avisando al Kernel las IO pendientes
+	if err := modulo.Ejecutar(context.Background(), utils.PlazoDetencionPorDefecto); err != nil {
+		os.Exit(1)
+	}
 }
 
 func inicializarModulo(rutaConfig string, nombreDispositivo string) {
 	// Crear módulo
 	modulo = utils.NuevoModulo("IO", rutaConfig)
+	dispositivo = nombreDispositivo
 
 	// Inicializar logger
 	loggerName := fmt.Sprintf("IO-%s", nombreDispositivo)
 	utils.InicializarLogger("INFO", loggerName)
 
 	// Cargar configuración
-	config = utils.CargarConfiguracion[IOConfig](rutaConfig)
+	config = utils.CargarConfiguracionModulo[IOConfig](rutaConfig, utils.SeccionIO, nombreDispositivo)
 
 	// Actualizar nivel de log
-	utils.InicializarLogger(config.LogLevel, loggerName)
+	if err := utils.InicializarLoggerConOpciones(config.LogLevel, loggerName, config.OpcionesLog); err != nil {
+		utils.ErrorLog.Error("No se pudieron abrir los archivos de log", "error", err)
+	}
+	if err := utils.InicializarTrazas(loggerName, config.TrazasPath); err != nil {
+		utils.ErrorLog.Error("No se pudieron habilitar las trazas", "error", err)
+	}
+	utils.HabilitarFirma(config.SecretoCompartido)
+	utils.HabilitarTokenAdmin(config.TokenAdmin)
+	if err := utils.HabilitarTLS(config.OpcionesTLS); err != nil {
+		utils.ErrorLog.Error("No se pudo habilitar TLS", "error", err)
+		os.Exit(1)
+	}
 
 	utils.InfoLog.Info("Módulo IO inicializado",
 		"dispositivo", nombreDispositivo,
@@ -59,33 +75,74 @@ func inicializarModulo(rutaConfig string, nombreDispositivo string) {
 
 	// Registrar handlers
 	registrarHandlers()
+	registrarMetricas(nombreDispositivo)
 
 	// Iniciar servidor
-	modulo.IniciarServidor(config.IPIO, config.PortIO)
+	if err := modulo.Iniciar(config.IPIO, config.PortIO); err != nil {
+		utils.ErrorLog.Error("No se pudo iniciar el servidor", "error", err)
+		os.Exit(1)
+	}
 	utils.InfoLog.Info("Servidor iniciado", "ip", config.IPIO, "puerto", config.PortIO)
 
 	// Crear cliente HTTP directamente
 	kernelClient = utils.NewHTTPClient(config.IPKernel, config.PortKernel, "IO->Kernel")
+	kernelClient.Timeout = config.Timeouts.Para("KERNEL")
+	if config.Transporte != "" {
+		kernelClient.Transporte = config.Transporte
+	}
 	utils.InfoLog.Info("Cliente HTTP creado")
 
 	// Datos para handshake
-	datosHandshake := map[string]interface{}{
-		"nombre": nombreDispositivo,
-		"tipo":   "IO" + nombreDispositivo,
-		"ip":     config.IPIO,
-		"puerto": config.PortIO,
+	datosHandshake := utils.SolicitudHandshake{
+		Version: utils.VersionProtocolo,
+		Nombre:  nombreDispositivo,
+		Tipo:    "IO" + nombreDispositivo,
+		IP:      config.IPIO,
+		Puerto:  config.PortIO,
 	}
 
-	// Conectar con Kernel
-	go conectarConReintentos(kernelClient, "Kernel", datosHandshake)
+	// Al detenerse el dispositivo deja de recibir solicitudes y el Kernel reasigna las pendientes
+	modulo.AlDetener("avisar IO pendientes", func(ctx context.Context) error {
+		if err := utils.AvisarBaja(ctx, kernelClient, datosHandshake); err != nil {
+			utils.ErrorLog.Warn("No se pudo avisar la baja al Kernel", "error", err)
+		}
+		return interrumpirOperaciones(ctx, nombreDispositivo)
+	})
+
+	// Conectar con Kernel y mantener el lease con latidos
+	go func() {
+		intervalo := time.Duration(config.LatidoMs) * time.Millisecond
+		if _, err := utils.MantenerConexion(modulo.Contexto(), kernelClient, "Kernel", datosHandshake, intervalo, modulo.Carga); err == nil {
+			modulo.MarcarListo()
+		}
+	}()
 	utils.InfoLog.Info("Conectando a Kernel", "ip", config.IPKernel, "puerto", config.PortKernel)
 }
 
 func registrarHandlers() {
-	modulo.RegistrarHandler(fmt.Sprintf("%d", utils.MensajeHandshake), "handshake", handlerHandshake)
-	modulo.RegistrarHandler(fmt.Sprintf("%d", utils.MensajeOperacion), "EJECUTAR_PROCESO", handlerOperacion)
-	modulo.RegistrarHandler(fmt.Sprintf("%d", utils.MensajeOperacion), "IO_REQUEST", handlerOperacion)
-	modulo.RegistrarHandler(fmt.Sprintf("%d", utils.MensajeEjecutar), "default", handlerOperacion)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeHandshake, "handshake", handlerHandshake)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeOperacion, "EJECUTAR_PROCESO", handlerOperacion)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeOperacion, "IO_REQUEST", handlerOperacion)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeEjecutar, "default", handlerOperacion)
+
+	// Solo el Kernel pide operaciones, y cada una paga RETARDO_BASE antes de empezar.
+	// Las operaciones duran lo que pide el proceso, así que no se avisan las lentas.
+	// Una operación empezada no se corta cuando vence el plazo del Kernel: siempre
+	// termina con la notificación de fin de IO, que es lo que desbloquea al proceso.
+	modulo.Usar(
+		utils.Recuperacion(),
+		utils.Temporizacion(0),
+		utils.Auditoria(),
+		utils.Autorizacion(utils.Permisos{
+			utils.MensajeOperacion: {"Kernel"},
+			utils.MensajeEjecutar:  {"Kernel"},
+		}),
+		utils.SinPlazo(),
+		utils.Retardo(0, map[int]int{
+			utils.MensajeOperacion: config.RetardoBase,
+			utils.MensajeEjecutar:  config.RetardoBase,
+		}),
+	)
 
 	utils.InfoLog.Info("Handlers registrados correctamente")
 }
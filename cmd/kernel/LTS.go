@@ -1,16 +1,16 @@
 package main
 
 import (
+	"context"
 	"sort"
 	"time"
 
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
 )
 
-const (
-	maxIntentosMemoria     = 5
-	tiempoEsperaReintentos = 2 * time.Second
-)
+// plazoAdmision es cuánto espera el LTS un lugar en la multiprogramación antes de
+// volver a revisar las colas de NEW y SUSP.READY
+const plazoAdmision = time.Second
 
 // PlanificarLargoPlazo optimizado
 func PlanificarLargoPlazo() {
@@ -28,6 +28,15 @@ func PlanificarLargoPlazo() {
 
 		// Esperar hasta que haya procesos disponibles (SUSP.READY tiene prioridad)
 		for {
+			if kernelModulo.Contexto().Err() != nil {
+				utils.InfoLog.Info("Planificador de Largo Plazo detenido")
+				return
+			}
+			if err := pausaLargoPlazo.esperar(kernelModulo.Contexto()); err != nil {
+				utils.InfoLog.Info("Planificador de Largo Plazo detenido")
+				return
+			}
+
 			// Revisar SUSP.READY primero (prioridad alta)
 			suspReadyMutex.Lock()
 			if len(colaSuspReady) > 0 {
@@ -36,12 +45,22 @@ func PlanificarLargoPlazo() {
 				colaSuspReady = colaSuspReady[1:]
 				suspReadyMutex.Unlock()
 
-				semaforoMultiprogram.Wait()
+				if err := semaforoMultiprogram.Acquire(kernelModulo.Contexto(), 1); err != nil {
+					devolverASuspReady(pcb)
+					utils.InfoLog.Info("Planificador de Largo Plazo detenido")
+					return
+				}
+				if !pausaLargoPlazo.Activo() {
+					// Se detuvo la planificación mientras esperaba lugar
+					semaforoMultiprogram.Release(1)
+					devolverASuspReady(pcb)
+					continue
+				}
 
 				// Verificar si el proceso necesita desswap
 				if pcb.EnSwap {
 					// Proceso suspendido por timeout, necesita desswap
-					go notificarDesswapAMemoria(pcb.PID)
+					notificarDesswapAMemoria(pcb.PID)
 					utils.InfoLog.Info("Proceso de SUSP.READY enviado a desswap", "pid", pcb.PID)
 				} else {
 					// Proceso completó IO, ya está en memoria
@@ -68,16 +87,26 @@ func PlanificarLargoPlazo() {
 
 			// No hay procesos en ninguna cola, esperar señales
 			utils.InfoLog.Info("LTS esperando procesos disponibles")
-			condNew.Wait() // Espera señales de NEW o SUSP.READY
+			if kernelModulo.Contexto().Err() == nil {
+				condNew.Wait() // Espera señales de NEW, SUSP.READY, la pausa o la detención
+			}
 			newMutex.Unlock()
 		}
 
 		// Caso especial para proceso inicial (PID 0)
 		if pcb.PID == 0 {
 			utils.InfoLog.Info("Admitiendo proceso inicial", "pid", 0)
+			if err := semaforoMultiprogram.Acquire(kernelModulo.Contexto(), 1); err != nil {
+				utils.InfoLog.Info("Planificador de Largo Plazo detenido")
+				return
+			}
+			if !pausaLargoPlazo.Activo() {
+				semaforoMultiprogram.Release(1)
+				continue
+			}
 			removerDeCola(&colaNew, pcb)
 
-			if inicializarEnMemoriaConReintentos(pcb) {
+			if err := inicializarProcesoEnMemoria(pcb.PID, pcb.Tamanio, pcb.NombreArchivo); err == nil {
 				pcb.CambiarEstado(EstadoReady)
 				readyMutex.Lock()
 				colaReady = append(colaReady, pcb)
@@ -85,17 +114,35 @@ func PlanificarLargoPlazo() {
 				condReady.Signal()
 				utils.InfoLog.Info("Proceso inicial admitido a READY", "pid", pcb.PID)
 			} else {
-				utils.ErrorLog.Error("Error al inicializar proceso inicial", "pid", pcb.PID)
+				utils.ErrorLog.Error("Error al inicializar proceso inicial", "pid", pcb.PID, "codigo", utils.CodigoDe(err))
 				FinalizarProceso(pcb, "ERROR_INICIALIZACION_MEMORIA_PROCESO_INICIAL")
+				semaforoMultiprogram.Release(1)
 			}
 			continue
 		}
 
-		// Esperar semáforo antes de inicializar en memoria
-		semaforoMultiprogram.Wait()
+		// Esperar lugar en la multiprogramación antes de inicializar en memoria. Al vencer
+		// el plazo se vuelven a revisar las colas: el proceso sigue en NEW y uno que haya
+		// llegado a SUSP.READY mientras tanto tiene prioridad.
+		if err := esperarMultiprogramacion(); err != nil {
+			if kernelModulo.Contexto().Err() != nil {
+				utils.InfoLog.Info("Planificador de Largo Plazo detenido")
+				return
+			}
+			utils.InfoLog.Debug("Sin lugar en la multiprogramación, se revisan las colas", "pid", pcb.PID, "en_uso", semaforoMultiprogram.EnUso())
+			continue
+		}
+		if !pausaLargoPlazo.Activo() {
+			// Se detuvo la planificación mientras esperaba lugar: el proceso sigue en NEW
+			semaforoMultiprogram.Release(1)
+			continue
+		}
 
-		if inicializarEnMemoriaConReintentos(pcb) {
-			removerDeCola(&colaNew, pcb)
+		liberaciones, procesosEnNew := estadoAdmision()
+		err := inicializarProcesoEnMemoria(pcb.PID, pcb.Tamanio, pcb.NombreArchivo)
+		switch {
+		case err == nil:
+			removerDeNew(pcb)
 			pcb.CambiarEstado(EstadoReady)
 
 			readyMutex.Lock()
@@ -103,32 +150,102 @@ func PlanificarLargoPlazo() {
 			readyMutex.Unlock()
 			condReady.Signal()
 			utils.InfoLog.Info("Proceso admitido a READY", "pid", pcb.PID)
-		} else {
-			removerDeCola(&colaNew, pcb)
-			FinalizarProceso(pcb, "ERROR_INICIALIZACION_MEMORIA")
-			semaforoMultiprogram.Signal()
+
+		case utils.CodigoDe(err) == utils.ErrorMemoriaSinEspacio:
+			// El proceso sigue en NEW hasta que Memoria libere espacio
+			semaforoMultiprogram.Release(1)
+			utils.InfoLog.Info("Memoria sin espacio, el proceso queda en NEW", "pid", pcb.PID)
+			esperarLiberacionMemoria(liberaciones, procesosEnNew)
+
+		default:
+			removerDeNew(pcb)
+			FinalizarProceso(pcb, motivoErrorInicializacion(err))
+			semaforoMultiprogram.Release(1)
 		}
 	}
 }
 
-// inicializarEnMemoriaConReintentos maneja reintentos automáticamente
-func inicializarEnMemoriaConReintentos(pcb *PCB) bool {
-	utils.InfoLog.Info("Inicializando proceso en memoria", "pid", pcb.PID, "max_intentos", maxIntentosMemoria)
+// esperarMultiprogramacion toma un lugar del grado de multiprogramación, esperando
+// a lo sumo plazoAdmision o hasta que se detenga el Kernel
+func esperarMultiprogramacion() error {
+	ctx, cancelar := context.WithTimeout(kernelModulo.Contexto(), plazoAdmision)
+	defer cancelar()
+	return semaforoMultiprogram.Acquire(ctx, 1)
+}
+
+// devolverASuspReady vuelve a poner al proceso al frente de SUSP.READY
+func devolverASuspReady(pcb *PCB) {
+	suspReadyMutex.Lock()
+	colaSuspReady = append([]*PCB{pcb}, colaSuspReady...)
+	suspReadyMutex.Unlock()
+}
 
-	for intento := 1; intento <= maxIntentosMemoria; intento++ {
-		if inicializarProcesoEnMemoria(pcb.PID, pcb.Tamanio, pcb.NombreArchivo) {
-			utils.InfoLog.Info("Proceso inicializado en memoria", "pid", pcb.PID, "intento", intento)
-			return true
-		}
+// estadoAdmision devuelve cuántas liberaciones de memoria hubo y cuántos procesos hay en NEW
+func estadoAdmision() (int, int) {
+	newMutex.Lock()
+	defer newMutex.Unlock()
+	return liberacionesMemoria, len(colaNew)
+}
 
-		if intento < maxIntentosMemoria {
-			utils.InfoLog.Warn("Intento fallido, reintentando", "pid", pcb.PID, "intento", intento, "espera", tiempoEsperaReintentos)
-			time.Sleep(tiempoEsperaReintentos)
-		}
+// esperarLiberacionMemoria bloquea al LTS hasta que Memoria libere espacio, llegue un
+// proceso a NEW o a SUSP.READY (que tiene prioridad), se detenga el planificador o el Kernel
+func esperarLiberacionMemoria(liberaciones int, procesosEnNew int) {
+	newMutex.Lock()
+	defer newMutex.Unlock()
+	for liberacionesMemoria == liberaciones && len(colaNew) <= procesosEnNew && !haySuspReady() &&
+		pausaLargoPlazo.Activo() && kernelModulo.Contexto().Err() == nil {
+		condNew.Wait()
 	}
+}
 
-	utils.ErrorLog.Error("Todos los intentos de inicialización fallaron", "pid", pcb.PID)
-	return false
+// haySuspReady indica si hay procesos esperando en SUSP.READY
+func haySuspReady() bool {
+	suspReadyMutex.Lock()
+	defer suspReadyMutex.Unlock()
+	return len(colaSuspReady) > 0
+}
+
+// despertarLTS despierta al LTS para que vuelva a revisar las colas, la pausa y la
+// detención. Se toma newMutex para que el aviso no se pierda entre la revisión y el Wait.
+func despertarLTS() {
+	newMutex.Lock()
+	condNew.Broadcast()
+	newMutex.Unlock()
+}
+
+// notificarMemoriaLiberada avisa al LTS que Memoria liberó espacio (finalización o swap).
+// Se llama al confirmar la operación y también con cada evento de Memoria: el evento es
+// un aviso extra que puede perderse si se corta la suscripción.
+func notificarMemoriaLiberada() {
+	newMutex.Lock()
+	liberacionesMemoria++
+	newMutex.Unlock()
+	condNew.Signal()
+}
+
+// suscribirLiberacionesMemoria despierta al LTS cada vez que Memoria publica que liberó
+// marcos, sea por un proceso que finalizó o por uno que pasó a SWAP
+func suscribirLiberacionesMemoria() {
+	kernelModulo.Suscribir(memoriaClient, []string{utils.TemaMarcosLiberados}, func(evento utils.Evento) {
+		if datos, err := utils.DatosEvento[utils.DatosMarcosLiberados](evento); err == nil {
+			utils.InfoLog.Info("Memoria liberó marcos", "pid", datos.PID, "marcos", datos.Marcos, "libres", datos.Libres, "motivo", datos.Motivo)
+		}
+		notificarMemoriaLiberada()
+	})
+}
+
+// motivoErrorInicializacion traduce el código de error de Memoria al motivo de finalización
+func motivoErrorInicializacion(err error) string {
+	switch utils.CodigoDe(err) {
+	case utils.ErrorArchivoInexistente:
+		return "ERROR_ARCHIVO_INEXISTENTE"
+	case utils.ErrorProcesoDuplicado:
+		return "ERROR_PROCESO_DUPLICADO"
+	case utils.ErrorRedCaida, utils.ErrorTimeout, utils.ErrorCircuitoAbierto:
+		return "ERROR_CONEXION_MEMORIA"
+	default:
+		return "ERROR_INICIALIZACION_MEMORIA"
+	}
 }
 
 // seleccionarProcesoLTS selecciona el próximo proceso según algoritmo
@@ -181,65 +298,38 @@ func seleccionarPMCP() *PCB {
 }
 
 // inicializarProcesoEnMemoria simplificado
-func inicializarProcesoEnMemoria(pid int, tamanio int, nombreArchivo string) bool {
+func inicializarProcesoEnMemoria(pid int, tamanio int, nombreArchivo string) error {
 	cliente := GetMemoriaClient()
 	if cliente == nil {
 		utils.ErrorLog.Error("No se pudo obtener cliente de memoria", "pid", pid)
-		return false
+		return utils.NuevoError(utils.ErrorRedCaida, "cliente de memoria no inicializado")
 	}
 
-	datos := map[string]interface{}{
-		"pid":     pid,
-		"tamanio": tamanio,
-		"archivo": nombreArchivo,
+	solicitud := utils.SolicitudInicializarProceso{
+		PID:     pid,
+		Tamanio: tamanio,
+		Archivo: nombreArchivo,
 	}
 
-	respuesta, err := cliente.EnviarHTTPMensaje(utils.MensajeInicializarProceso, "default", datos)
+	respuesta, err := utils.Enviar[utils.SolicitudInicializarProceso, utils.RespuestaEstado](cliente, utils.MensajeInicializarProceso, "default", solicitud)
 	if err != nil {
-		utils.ErrorLog.Error("Error de comunicación con Memoria", "pid", pid, "error", err.Error())
-		return false
+		utils.ErrorLog.Error("Memoria rechazó la inicialización", "pid", pid, "error", err.Error())
+		return err
 	}
 
-	if respuestaMap, ok := respuesta.(map[string]interface{}); ok {
-		status, _ := respuestaMap["status"].(string)
-		if status == "OK" {
-			utils.InfoLog.Info("Proceso inicializado en Memoria", "pid", pid)
-			return true
-		} else {
-			message, _ := respuestaMap["message"].(string)
-			utils.ErrorLog.Error("Memoria rechazó la inicialización", "pid", pid, "status", status, "message", message)
-			return false
-		}
+	if respuesta.Status != "OK" {
+		utils.ErrorLog.Error("Memoria rechazó la inicialización", "pid", pid, "status", respuesta.Status, "message", respuesta.Mensaje)
+		return utils.NuevoError(utils.ErrorInterno, "estado inesperado de Memoria: %s", respuesta.Status)
 	}
 
-	utils.ErrorLog.Error("Respuesta de Memoria en formato inválido", "pid", pid)
-	return false
+	utils.InfoLog.Info("Proceso inicializado en Memoria", "pid", pid)
+	return nil
 }
 
-// notificarDesswapAMemoria con log de notificación
-func notificarDesswapAMemoria(pid int) bool {
-	cliente := GetMemoriaClient()
-	if cliente == nil {
-		utils.ErrorLog.Error("No se pudo obtener cliente de memoria para desswap", "pid", pid)
-		return false
-	}
-
+// notificarDesswapAMemoria pide a Memoria que cargue el proceso desde SWAP, en el próximo
+// lote de swaps: va detrás de su suspensión si todavía no salió
+func notificarDesswapAMemoria(pid int) {
 	// Log para visualizar la petición a Memoria para cargar desde SWAP
 	utils.InfoLog.Info("Notificando a Memoria: Cargar desde SWAP", "pid", pid)
-
-	datos := map[string]interface{}{
-		"pid": pid,
-	}
-
-	respuesta, err := cliente.EnviarHTTPMensaje(utils.MensajeDessuspenderProceso, "default", datos)
-	if err != nil {
-		return false
-	}
-
-	if respuestaMap, ok := respuesta.(map[string]interface{}); ok {
-		status, _ := respuestaMap["status"].(string)
-		return status == "OK"
-	}
-
-	return false
+	pedirSwap(utils.MensajeDessuspenderProceso, pid)
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"sync"
@@ -43,11 +44,26 @@ func registrarCPU(nombre string, ip string, puerto int) {
 		utils.InfoLog.Info("Usando nombre generado para CPU", "nombre_generado", nombreCPU)
 	}
 
-	cpuClients[nombreCPU] = utils.NewHTTPClient(ip, puerto, "Kernel->"+nombreCPU)
+	cpuClients[nombreCPU] = nuevoCliente(ip, puerto, "Kernel->"+nombreCPU, "CPU")
 
 	utils.InfoLog.Info("CPU registrada correctamente", "nombre", nombreCPU, "ip", ip, "puerto", puerto, "total_cpus", len(cpuClients))
 }
 
+// desregistrarCPU quita una CPU que dejó de enviar latidos. El proceso que estuviera
+// ejecutando vuelve a READY cuando falle su despacho. Devuelve false si no era una CPU.
+func desregistrarCPU(nombre string) bool {
+	cpuClientsMutex.Lock()
+	defer cpuClientsMutex.Unlock()
+
+	if _, existe := cpuClients[nombre]; !existe {
+		return false
+	}
+
+	delete(cpuClients, nombre)
+	utils.InfoLog.Warn("CPU dada de baja", "nombre", nombre, "total_cpus", len(cpuClients))
+	return true
+}
+
 // PlanificarCortoPlazo gestiona transición de procesos entre READY y EXEC
 func PlanificarCortoPlazo() {
 	defer func() {
@@ -60,18 +76,26 @@ func PlanificarCortoPlazo() {
 	utils.InfoLog.Info("Iniciando Planificador de Corto Plazo")
 
 	for {
+		if err := pausaCortoPlazo.esperar(kernelModulo.Contexto()); err != nil {
+			utils.InfoLog.Info("Planificador de Corto Plazo detenido")
+			return
+		}
+
 		utils.InfoLog.Info("Esperando procesos en READY")
 		readyMutex.Lock()
-		for len(colaReady) == 0 {
+		if procesosEnReady() == 0 {
+			// Al despertar se vuelve a pasar por la pausa antes de elegir
 			condReady.Wait()
+			readyMutex.Unlock()
+			continue
 		}
-		utils.InfoLog.Info("Proceso detectado en READY", "procesos_en_ready", len(colaReady))
+		utils.InfoLog.Info("Proceso detectado en READY", "procesos_en_ready", procesosEnReady())
 
 		pcb := seleccionarProcesoSTS()
 
 		if pcb != nil {
 			utils.InfoLog.Info("Proceso seleccionado", "pid", pcb.PID)
-			removerDeCola(&colaReady, pcb)
+			quitarDeReady(pcb)
 		}
 
 		if pcb == nil {
@@ -86,35 +110,58 @@ func PlanificarCortoPlazo() {
 		var nombreCPU string
 		var cpuClient *utils.HTTPClient
 
+		var despacho uint64
+
 		utils.InfoLog.Info("Buscando CPU disponible")
 		for {
 			nombreCPU, cpuClient = obtenerCPUDisponibleParaEjecucion()
 			if cpuClient != nil {
-				execMutex.Lock()
-				colaExec[nombreCPU] = pcb
-				execMutex.Unlock()
-				utils.InfoLog.Info("CPU encontrada y reservada", "nombre", nombreCPU)
+				if despacho = reservarCPU(nombreCPU, pcb); despacho != 0 {
+					utils.InfoLog.Info("CPU encontrada y reservada", "nombre", nombreCPU)
+					break
+				}
+			}
+			if !pausaCortoPlazo.Activo() {
+				cpuClient = nil
 				break
 			}
 			utils.InfoLog.Warn("No hay CPU disponible, reintentando")
 			time.Sleep(200 * time.Millisecond)
 		}
+		if cpuClient == nil {
+			// Se detuvo la planificación mientras esperaba una CPU
+			devolverAReady(pcb)
+			continue
+		}
 
 		pcb.CambiarEstado(EstadoExec)
 		utils.InfoLog.Info("Proceso despachado a CPU", "pid", pcb.PID, "cpu", nombreCPU)
+		despachos.Incrementar(nombreCPU)
 
-		go despacharYProcesarCPU(nombreCPU, cpuClient, pcb)
+		go despacharYProcesarCPU(nombreCPU, cpuClient, pcb, despacho)
 	}
 }
 
 // despacharYProcesarCPU maneja el ciclo de vida de un proceso en la CPU
-func despacharYProcesarCPU(nombreCPU string, cpuClient *utils.HTTPClient, pcb *PCB) {
+func despacharYProcesarCPU(nombreCPU string, cpuClient *utils.HTTPClient, pcb *PCB, despacho uint64) {
 	utils.InfoLog.Info("Iniciando ejecución en CPU", "pid", pcb.PID, "cpu", nombreCPU)
 
+	detenerQuantum := func() {}
+	if usaQuantum() {
+		detenerQuantum = iniciarQuantum(pcb, nombreCPU, despacho)
+	}
+
 	defer func() {
+		detenerQuantum()
 		utils.InfoLog.Info("Liberando CPU", "pid", pcb.PID, "cpu", nombreCPU)
 		execMutex.Lock()
-		delete(colaExec, nombreCPU)
+		// Al bloquearse, el proceso ya liberó la CPU y otro puede estar despachado en ella:
+		// solo se libera si sigue siendo este despacho
+		if despachoExec[nombreCPU] == despacho {
+			delete(colaExec, nombreCPU)
+			delete(despachoExec, nombreCPU)
+		}
+		delete(desalojosPedidos, pcb.PID)
 		execMutex.Unlock()
 	}()
 
@@ -201,12 +248,12 @@ func obtenerCPUDisponibleParaEjecucion() (string, *utils.HTTPClient) {
 
 // seleccionarProcesoSTS selecciona proceso según algoritmo configurado
 func seleccionarProcesoSTS() *PCB {
-	if len(colaReady) == 0 {
+	if procesosEnReady() == 0 {
 		return nil
 	}
 
 	algoritmo := kernelConfig.SchedulerAlgorithm
-	utils.InfoLog.Info("Seleccionando proceso STS", "algoritmo", algoritmo, "procesos_disponibles", len(colaReady))
+	utils.InfoLog.Info("Seleccionando proceso STS", "algoritmo", algoritmo, "procesos_disponibles", procesosEnReady())
 
 	switch algoritmo {
 	case "FIFO":
@@ -215,6 +262,10 @@ func seleccionarProcesoSTS() *PCB {
 		return seleccionarSJF()
 	case "SRT":
 		return seleccionarSRT()
+	case "RR", "VRR":
+		return seleccionarRR()
+	case "PRIORIDADES":
+		return seleccionarPrioridades()
 	default:
 		utils.InfoLog.Warn("Algoritmo STS no reconocido, usando FIFO", "algoritmo", algoritmo)
 		return seleccionarFIFO()
@@ -264,7 +315,7 @@ func seleccionarSRT() *PCB {
 	execMutex.Unlock()
 
 	if procesoADesalojar != nil {
-		utils.InfoLog.Info(fmt.Sprintf("(%d) - Desalojado por algoritmo SJF/SRT", procesoADesalojar.PID))
+		utils.ObligatorioLog.Info(fmt.Sprintf("(%d) - Desalojado por algoritmo SJF/SRT", procesoADesalojar.PID))
 		utils.InfoLog.Info("Desalojando proceso por SRT", "desalojado", procesoADesalojar.PID, "nuevo", mejorCandidatoReady.PID)
 		go desalojarProcesoActual(procesoADesalojar)
 		return nil
@@ -307,6 +358,62 @@ func encontrarProcesoADesalojar(candidato *PCB) *PCB {
 	return procesoMasLargo
 }
 
+// despachoExec guarda el número de despacho de cada CPU ocupada (protegido por execMutex).
+// El quantum y la liberación de una ráfaga solo actúan si la CPU sigue con su despacho.
+var (
+	despachoExec      = make(map[string]uint64)
+	secuenciaDespacho uint64
+)
+
+// reservarCPU asigna la CPU al proceso si el planificador sigue activo y devuelve el número
+// de despacho, o 0 si no la reservó. Se decide con execMutex tomado para que una detención
+// simultánea encuentre al proceso y lo desaloje.
+func reservarCPU(nombreCPU string, pcb *PCB) uint64 {
+	execMutex.Lock()
+	defer execMutex.Unlock()
+	if !pausaCortoPlazo.Activo() {
+		return 0
+	}
+	secuenciaDespacho++
+	colaExec[nombreCPU] = pcb
+	despachoExec[nombreCPU] = secuenciaDespacho
+	return secuenciaDespacho
+}
+
+// despachoVigente indica si el proceso sigue en la CPU por el mismo despacho
+func despachoVigente(nombreCPU string, pcb *PCB, despacho uint64) bool {
+	execMutex.Lock()
+	defer execMutex.Unlock()
+	return colaExec[nombreCPU] == pcb && despachoExec[nombreCPU] == despacho
+}
+
+// devolverAReady vuelve a poner al proceso al frente de READY, o de la cola auxiliar de VRR
+// si de ahí salió
+func devolverAReady(pcb *PCB) {
+	readyMutex.Lock()
+	if pcb.QuantumRestante > 0 {
+		colaReadyAux = append([]*PCB{pcb}, colaReadyAux...)
+	} else {
+		colaReady = append([]*PCB{pcb}, colaReady...)
+	}
+	readyMutex.Unlock()
+}
+
+// desalojarProcesosEnExec interrumpe a las CPUs ocupadas para que sus procesos vuelvan a READY
+func desalojarProcesosEnExec() {
+	execMutex.Lock()
+	enExec := make([]*PCB, 0, len(colaExec))
+	for _, pcb := range colaExec {
+		enExec = append(enExec, pcb)
+	}
+	execMutex.Unlock()
+
+	for _, pcb := range enExec {
+		utils.InfoLog.Info("Desalojando proceso por detención del planificador de corto plazo", "pid", pcb.PID)
+		go desalojarProcesoActual(pcb)
+	}
+}
+
 // desalojarProcesoActual maneja el desalojo de un proceso por SRT
 func desalojarProcesoActual(pcb *PCB) {
 	var cpuADesalojar string
@@ -334,7 +441,8 @@ func desalojarProcesoActual(pcb *PCB) {
 	}
 
 	utils.InfoLog.Info("Enviando interrupción a CPU", "cpu", cpuADesalojar, "pid", pcb.PID)
-	_, err := cpuClient.EnviarHTTPOperacion("INTERRUPT", nil)
+	solicitud := utils.SolicitudInterrupcion{PID: pcb.PID}
+	_, err := utils.Enviar[utils.SolicitudInterrupcion, utils.RespuestaEstado](cpuClient, utils.MensajeInterrupcion, "INTERRUPCION", solicitud)
 	if err != nil {
 		utils.ErrorLog.Error("Fallo al enviar interrupción a CPU", "cpu", cpuADesalojar, "error", err)
 	}
@@ -347,106 +455,108 @@ func EnviarProcesoCPU(pcb *PCB, nombreCPU string) bool {
 	cpuClientsMutex.Unlock()
 
 	if !existe {
+		// La CPU se dio de baja mientras el proceso estaba en EXEC
 		utils.ErrorLog.Error("CPU no encontrada", "cpu_id", nombreCPU)
+		MoverProcesoAReady(pcb)
 		return false
 	}
 
-	datos := map[string]interface{}{
-		"pid": pcb.PID,
-		"pc":  pcb.PC,
+	solicitud := utils.SolicitudEjecucion{
+		PID: pcb.PID,
+		PC:  pcb.PC,
 	}
 
 	utils.InfoLog.Info("Enviando proceso a CPU", "pid", pcb.PID, "pc", pcb.PC, "cpu", nombreCPU)
 
-	respuesta, err := cpuClient.EnviarHTTPOperacion("EJECUTAR_PROCESO", datos)
+	// Cada despacho es la raíz de una traza que recorre CPU y Memoria
+	ctx, span := utils.IniciarSpan(context.Background(), "dispatch", "pid", pcb.PID, "pc", pcb.PC, "cpu", nombreCPU)
+	defer span.Finalizar()
+
+	respuesta, err := utils.EnviarConContexto[utils.SolicitudEjecucion, utils.RespuestaEjecucion](ctx, cpuClient, utils.MensajeOperacion, "EJECUTAR_PROCESO", solicitud)
 
 	if err != nil {
+		span.RegistrarError(err)
 		utils.ErrorLog.Error("Error enviando proceso a CPU", "pid", pcb.PID, "error", err.Error())
 		MoverProcesoAReady(pcb)
 		return false
 	}
 
-	// Procesar respuesta
-	if respuestaMap, ok := respuesta.(map[string]interface{}); ok {
-		if errorMsg, tieneError := respuestaMap["error"].(string); tieneError {
-			utils.ErrorLog.Error("Error reportado por CPU", "pid", pcb.PID, "mensaje", errorMsg)
-			return false
-		}
+	// Actualizar PC
+	pcb.PC = respuesta.PC
 
-		// Actualizar PC
-		pcActualizadoPorCPU := false
-		if nuevoPC, hayPC := respuestaMap["pc"].(float64); hayPC {
-			pcb.PC = int(nuevoPC)
-			pcActualizadoPorCPU = true
-		}
-
-		// Verificar motivo de retorno
-		if motivoRetorno, hayMotivo := respuestaMap["motivo_retorno"].(string); hayMotivo {
-			utils.InfoLog.Info("Motivo de retorno recibido", "pid", pcb.PID, "motivo", motivoRetorno)
-
-			switch motivoRetorno {
-			case "SYSCALL_INIT_PROC":
-				utils.InfoLog.Info(fmt.Sprintf("(%d) - Solicitó syscall: INIT_PROC", pcb.PID))
-				if parametros, ok := respuestaMap["parametros"].(map[string]interface{}); ok {
-					archivo, _ := parametros["archivo"].(string)
-					tamano, _ := parametros["tamano"].(float64)
-
-					utils.InfoLog.Info("Procesando INIT_PROC", "pid", pcb.PID, "archivo", archivo, "tamaño", int(tamano))
-
-					nuevoPCB := NuevoPCB(-1, int(tamano))
-					nuevoPCB.NombreArchivo = archivo
-					utils.InfoLog.Info("Nuevo proceso creado", "nuevo_pid", nuevoPCB.PID, "estado", "NEW")
-					AgregarProcesoANew(nuevoPCB)
-				}
-
-				pcb.PC++
-				utils.InfoLog.Info("PC incrementado después de INIT_PROC", "pid", pcb.PID, "nuevo_pc", pcb.PC)
-				return true
+	if motivo, pendiente := tomarFinalizacionPendiente(pcb.PID); pendiente {
+		utils.InfoLog.Info("Proceso devuelto por la CPU con finalización pendiente", "pid", pcb.PID, "cpu", nombreCPU)
+		FinalizarProceso(pcb, motivo)
+		return true
+	}
+	parametros := respuesta.Parametros
+	if parametros == nil {
+		parametros = &utils.ParametrosSyscall{}
+	}
 
-			case "SYSCALL_IO":
-				utils.InfoLog.Info(fmt.Sprintf("(%d) - Solicitó syscall: IO", pcb.PID))
-				utils.InfoLog.Info("Procesando IO", "pid", pcb.PID)
-				pcb.CambiarEstado(EstadoBlocked)
+	// Verificar motivo de retorno
+	if motivoRetorno := respuesta.MotivoRetorno; motivoRetorno != "" {
+		utils.InfoLog.Info("Motivo de retorno recibido", "pid", pcb.PID, "motivo", motivoRetorno)
+		span.Atributo("motivo", motivoRetorno)
 
-				if parametros, ok := respuestaMap["parametros"].(map[string]interface{}); ok {
-					dispositivo, _ := parametros["dispositivo"].(string)
-					tiempo, _ := parametros["tiempo"].(float64)
+		switch motivoRetorno {
+		case utils.MotivoSyscallInitProc:
+			utils.ObligatorioLog.Info(fmt.Sprintf("(%d) - Solicitó syscall: INIT_PROC", pcb.PID))
+			utils.InfoLog.Info("Procesando INIT_PROC", "pid", pcb.PID, "archivo", parametros.Archivo, "tamaño", parametros.Tamano, "prioridad", parametros.Prioridad)
 
-					dispositivoReal := SeleccionarDispositivoIO(dispositivo, pcb.PID)
-					MoverProcesoABlocked(pcb, fmt.Sprintf("IO_%s", dispositivoReal))
-					go EnviarSolicitudIO(pcb, dispositivoReal, int(tiempo))
-				}
-				return true
-
-			case "SYSCALL_DUMP_MEMORY":
-				utils.InfoLog.Info(fmt.Sprintf("(%d) - Solicitó syscall: DUMP_MEMORY", pcb.PID))
-				utils.InfoLog.Info("Procesando DUMP_MEMORY", "pid", pcb.PID)
-				pcb.CambiarEstado(EstadoBlocked)
-				MoverProcesoABlocked(pcb, "DUMP_MEMORY")
-				return true
-
-			case "EXIT":
-				utils.InfoLog.Info(fmt.Sprintf("(%d) - Solicitó syscall: EXIT", pcb.PID))
-				utils.InfoLog.Info("Proceso solicita EXIT", "pid", pcb.PID)
-				FinalizarProceso(pcb, "EXIT")
-				return true
-
-			case "ERROR":
-				utils.ErrorLog.Error("Error en ejecución de proceso", "pid", pcb.PID)
-				FinalizarProceso(pcb, "ERROR")
-				return true
-			}
-		}
+			nuevoPCB := NuevoPCB(-1, parametros.Tamano)
+			nuevoPCB.NombreArchivo = parametros.Archivo
+			nuevoPCB.AsignarPrioridad(parametros.Prioridad)
+			utils.InfoLog.Info("Nuevo proceso creado", "nuevo_pid", nuevoPCB.PID, "estado", "NEW")
+			AgregarProcesoANew(nuevoPCB)
 
-		// Continuar ejecución
-		if !pcActualizadoPorCPU {
 			pcb.PC++
+			utils.InfoLog.Info("PC incrementado después de INIT_PROC", "pid", pcb.PID, "nuevo_pc", pcb.PC)
+			return true
+
+		case utils.MotivoSyscallIO:
+			utils.ObligatorioLog.Info(fmt.Sprintf("(%d) - Solicitó syscall: IO", pcb.PID))
+			utils.InfoLog.Info("Procesando IO", "pid", pcb.PID)
+			pcb.CambiarEstado(EstadoBlocked)
+
+			dispositivoReal := SeleccionarDispositivoIO(parametros.Dispositivo, pcb.PID)
+			MoverProcesoABlocked(pcb, fmt.Sprintf("IO_%s", dispositivoReal))
+			go EnviarSolicitudIO(pcb, dispositivoReal, parametros.Tiempo)
+			return true
+
+		case utils.MotivoSyscallDump:
+			utils.ObligatorioLog.Info(fmt.Sprintf("(%d) - Solicitó syscall: DUMP_MEMORY", pcb.PID))
+			utils.InfoLog.Info("Procesando DUMP_MEMORY", "pid", pcb.PID)
+			pcb.CambiarEstado(EstadoBlocked)
+			MoverProcesoABlocked(pcb, "DUMP_MEMORY")
+			return true
+
+		case utils.MotivoInterrumpido:
+			utils.InfoLog.Info("Proceso desalojado de la CPU", "pid", pcb.PID, "cpu", nombreCPU)
+			desalojos.Incrementar(nombreCPU)
+			MoverProcesoAReady(pcb)
+			return true
+
+		case utils.MotivoExit:
+			utils.ObligatorioLog.Info(fmt.Sprintf("(%d) - Solicitó syscall: EXIT", pcb.PID))
+			utils.InfoLog.Info("Proceso solicita EXIT", "pid", pcb.PID)
+			FinalizarProceso(pcb, "EXIT")
+			return true
+
+		case utils.MotivoError:
+			utils.ErrorLog.Error("Error en ejecución de proceso", "pid", pcb.PID)
+			FinalizarProceso(pcb, "ERROR")
+			return true
+
+		default:
+			utils.ErrorLog.Error("Motivo de retorno desconocido", "pid", pcb.PID, "motivo", motivoRetorno)
+			FinalizarProceso(pcb, "ERROR_MOTIVO_DESCONOCIDO")
+			return true
 		}
-		utils.InfoLog.Info("Continuando ejecución", "pid", pcb.PID, "nuevo_pc", pcb.PC)
-
-		return true
 	}
 
-	utils.ErrorLog.Warn("Formato de respuesta inválido de CPU", "respuesta", fmt.Sprintf("%v", respuesta))
-	return false
+	// Continuar ejecución
+	utils.InfoLog.Info("Continuando ejecución", "pid", pcb.PID, "nuevo_pc", pcb.PC)
+
+	return true
 }
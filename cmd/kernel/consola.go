@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+)
+
+// maxAnidamientoScripts acota EJECUTAR_SCRIPT dentro de otro script
+const maxAnidamientoScripts = 8
+
+// origenConsola identifica en los logs lo que se pidió desde la consola
+const origenConsola = "consola"
+
+// comandoConsola es un comando de la consola del Kernel
+type comandoConsola struct {
+	nombre    string
+	uso       string // argumentos, para la ayuda
+	ayuda     string
+	cantArgs  int
+	opcional  bool                          // acepta un argumento más que cantArgs
+	completar func(prefijo string) []string // candidatos para el primer argumento
+	ejecutar  func(c *consola, args []string) error
+}
+
+// consola atiende los comandos que se escriben en la entrada estándar del Kernel
+type consola struct {
+	salida   io.Writer
+	comandos map[string]*comandoConsola
+	// iniciada indica si ya se inició la planificación alguna vez: mientras no, ENTER la inicia
+	iniciada    bool
+	profundidad int
+}
+
+func nuevaConsola(salida io.Writer) *consola {
+	c := &consola{salida: salida, comandos: make(map[string]*comandoConsola)}
+	for _, comando := range []*comandoConsola{
+		{nombre: "INICIAR_PLANIFICACION", uso: "[LARGO|CORTO]", ayuda: "inicia o reanuda los planificadores (los dos si no se indica cuál)", opcional: true, completar: completarPlanificadores, ejecutar: (*consola).iniciarPlanificacion},
+		{nombre: "DETENER_PLANIFICACION", uso: "[LARGO|CORTO]", ayuda: "detiene los planificadores; el de corto plazo devuelve a READY los procesos en EXEC", opcional: true, completar: completarPlanificadores, ejecutar: (*consola).detenerPlanificacion},
+		{nombre: "INICIAR_PROCESO", uso: "<script> <tamaño> [prioridad]", ayuda: "crea un proceso en NEW (prioridad 0 si no se indica)", cantArgs: 2, opcional: true, completar: completarScripts, ejecutar: (*consola).iniciarProceso},
+		{nombre: "FINALIZAR_PROCESO", uso: "<pid>", ayuda: "finaliza un proceso en cualquier estado", cantArgs: 1, ejecutar: (*consola).finalizarProceso},
+		{nombre: "PROCESO_ESTADO", ayuda: "lista los procesos de cada estado", ejecutar: (*consola).procesoEstado},
+		{nombre: "MULTIPROGRAMACION", uso: "<grado>", ayuda: "cambia el grado de multiprogramación", cantArgs: 1, ejecutar: (*consola).multiprogramacion},
+		{nombre: "EJECUTAR_SCRIPT", uso: "<archivo>", ayuda: "ejecuta los comandos de consola de un archivo, uno por línea", cantArgs: 1, completar: completarArchivos, ejecutar: (*consola).ejecutarScript},
+		{nombre: "AYUDA", ayuda: "muestra esta ayuda", ejecutar: (*consola).mostrarAyuda},
+	} {
+		c.comandos[comando.nombre] = comando
+	}
+	return c
+}
+
+// ejecutarConsola lee comandos hasta que se cierre la entrada estándar. Si se cierra
+// sin haber iniciado la planificación, la inicia, como hacía el ENTER inicial.
+func ejecutarConsola() {
+	c := nuevaConsola(os.Stdout)
+	lector := nuevoLectorLineas(c.completar)
+	defer lector.Cerrar()
+	kernelModulo.AlDetener("consola", func(ctx context.Context) error {
+		lector.Cerrar()
+		return nil
+	})
+
+	fmt.Fprintln(c.salida, "Consola del Kernel: ENTER inicia la planificación, AYUDA lista los comandos")
+	for {
+		linea, err := lector.LeerLinea("kernel> ")
+		if err != nil {
+			if !c.iniciada {
+				utils.InfoLog.Info("Entrada estándar cerrada, se inicia la planificación")
+				c.iniciarPlanificacion(nil)
+			}
+			return
+		}
+
+		if strings.TrimSpace(linea) == "" {
+			if !c.iniciada {
+				c.iniciarPlanificacion(nil)
+			}
+			continue
+		}
+		if err := c.ejecutarLinea(linea); err != nil {
+			fmt.Fprintf(c.salida, "Error: %v\n", err)
+		}
+	}
+}
+
+// ejecutarLinea interpreta una línea de comando; las vacías y los comentarios (#) no hacen nada
+func (c *consola) ejecutarLinea(linea string) error {
+	campos := strings.Fields(linea)
+	if len(campos) == 0 || strings.HasPrefix(campos[0], "#") {
+		return nil
+	}
+
+	comando, existe := c.comandos[strings.ToUpper(campos[0])]
+	if !existe {
+		return fmt.Errorf("comando desconocido %q, AYUDA lista los comandos", campos[0])
+	}
+	args := campos[1:]
+	if len(args) != comando.cantArgs && !(comando.opcional && len(args) == comando.cantArgs+1) {
+		return fmt.Errorf("uso: %s %s", comando.nombre, comando.uso)
+	}
+
+	utils.InfoLog.Info("Comando de consola", "comando", comando.nombre, "args", args)
+	return comando.ejecutar(c, args)
+}
+
+func (c *consola) iniciarPlanificacion(args []string) error {
+	planificador, err := argumentoPlanificador(args)
+	if err != nil {
+		return err
+	}
+	c.iniciada = true
+	if !iniciarPlanificacion(planificador) {
+		fmt.Fprintf(c.salida, "La planificación ya estaba %s\n", estadoPlanificacion())
+		return nil
+	}
+	fmt.Fprintf(c.salida, "Planificación %s\n", estadoPlanificacion())
+	return nil
+}
+
+func (c *consola) detenerPlanificacion(args []string) error {
+	planificador, err := argumentoPlanificador(args)
+	if err != nil {
+		return err
+	}
+	if !detenerPlanificacion(planificador) {
+		fmt.Fprintf(c.salida, "La planificación ya estaba %s\n", estadoPlanificacion())
+		return nil
+	}
+	fmt.Fprintf(c.salida, "Planificación %s\n", estadoPlanificacion())
+	return nil
+}
+
+// argumentoPlanificador traduce el argumento opcional LARGO o CORTO; sin argumento son los dos
+func argumentoPlanificador(args []string) (string, error) {
+	if len(args) == 0 {
+		return utils.PlanificadorTodos, nil
+	}
+	switch planificador := strings.ToLower(args[0]); planificador {
+	case utils.PlanificadorLargoPlazo, utils.PlanificadorCortoPlazo:
+		return planificador, nil
+	}
+	return "", fmt.Errorf("planificador inválido: %q (LARGO o CORTO)", args[0])
+}
+
+func (c *consola) iniciarProceso(args []string) error {
+	tamanio, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("tamaño inválido: %q", args[1])
+	}
+	solicitud := utils.SolicitudCrearProceso{Archivo: args[0], Tamanio: tamanio}
+	if len(args) == 3 {
+		if solicitud.Prioridad, err = strconv.Atoi(args[2]); err != nil {
+			return fmt.Errorf("prioridad inválida: %q", args[2])
+		}
+	}
+	if err := solicitud.Validar(); err != nil {
+		return err
+	}
+
+	pcb := crearProceso(solicitud.Archivo, solicitud.Tamanio, solicitud.Prioridad, origenConsola)
+	fmt.Fprintf(c.salida, "Proceso %d creado en NEW\n", pcb.PID)
+	return nil
+}
+
+func (c *consola) finalizarProceso(args []string) error {
+	pid, err := strconv.Atoi(args[0])
+	if err != nil || pid < 0 {
+		return fmt.Errorf("PID inválido: %q", args[0])
+	}
+	mensaje, err := finalizarPorUsuario(pid, origenConsola)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.salida, mensaje)
+	return nil
+}
+
+func (c *consola) procesoEstado(_ []string) error {
+	colas := []struct {
+		estado string
+		pids   []int
+	}{
+		{EstadoNew, pidsDeCola(&newMutex, &colaNew)},
+		{EstadoReady, pidsReady()},
+		{EstadoExec, nil},
+		{EstadoBlocked, pidsDeCola(&blockedMutex, &colaBlocked)},
+		{EstadoSuspReady, pidsDeCola(&suspReadyMutex, &colaSuspReady)},
+		{EstadoSuspBlocked, pidsDeCola(&suspBlockedMutex, &colaSuspBlocked)},
+		{EstadoExit, pidsDeCola(&exitMutex, &colaExit)},
+	}
+	cpus := cpusPorPID()
+
+	for _, cola := range colas {
+		var pids []string
+		if cola.estado == EstadoExec {
+			for pid, cpu := range cpus {
+				pids = append(pids, fmt.Sprintf("%d (%s)", pid, cpu))
+			}
+			sort.Strings(pids)
+		} else {
+			for _, pid := range cola.pids {
+				pids = append(pids, strconv.Itoa(pid))
+			}
+		}
+		fmt.Fprintf(c.salida, "%-14s %3d: %s\n", cola.estado, len(pids), strings.Join(pids, " "))
+	}
+	fmt.Fprintf(c.salida, "Multiprogramación: %d/%d, planificación %s\n",
+		semaforoMultiprogram.EnUso(), semaforoMultiprogram.Capacidad(), estadoPlanificacion())
+	return nil
+}
+
+func (c *consola) multiprogramacion(args []string) error {
+	grado, err := strconv.Atoi(args[0])
+	if err != nil || grado <= 0 {
+		return fmt.Errorf("el grado debe ser un entero positivo: %q", args[0])
+	}
+	cambiarMultiprogramacion(grado)
+	fmt.Fprintf(c.salida, "Grado de multiprogramación: %d (en uso: %d)\n", grado, semaforoMultiprogram.EnUso())
+	return nil
+}
+
+// ejecutarScript ejecuta en orden los comandos del archivo y se detiene en el primero que falla
+func (c *consola) ejecutarScript(args []string) error {
+	if c.profundidad >= maxAnidamientoScripts {
+		return fmt.Errorf("demasiados EJECUTAR_SCRIPT anidados (máximo %d)", maxAnidamientoScripts)
+	}
+	archivo, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer archivo.Close()
+
+	c.profundidad++
+	defer func() { c.profundidad-- }()
+
+	scanner := bufio.NewScanner(archivo)
+	for numero := 1; scanner.Scan(); numero++ {
+		linea := strings.TrimSpace(scanner.Text())
+		if linea == "" || strings.HasPrefix(linea, "#") {
+			continue
+		}
+		fmt.Fprintf(c.salida, "%s:%d> %s\n", args[0], numero, linea)
+		if err := c.ejecutarLinea(linea); err != nil {
+			return fmt.Errorf("%s:%d: %w", args[0], numero, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (c *consola) mostrarAyuda(_ []string) error {
+	nombres := make([]string, 0, len(c.comandos))
+	for nombre := range c.comandos {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+	for _, nombre := range nombres {
+		comando := c.comandos[nombre]
+		fmt.Fprintf(c.salida, "  %-40s %s\n", strings.TrimSpace(comando.nombre+" "+comando.uso), comando.ayuda)
+	}
+	fmt.Fprintln(c.salida, "ENTER sin comando inicia la planificación si todavía no se inició. TAB completa comandos y scripts.")
+	return nil
+}
+
+// completar devuelve los candidatos para la palabra que termina en el cursor: nombres de
+// comando en la primera palabra y el primer argumento según el comando
+func (c *consola) completar(linea string) []string {
+	campos := strings.Fields(linea)
+	palabraNueva := linea == "" || strings.HasSuffix(linea, " ")
+
+	if len(campos) == 0 || (len(campos) == 1 && !palabraNueva) {
+		prefijo := ""
+		if len(campos) == 1 {
+			prefijo = strings.ToUpper(campos[0])
+		}
+		var candidatos []string
+		for nombre := range c.comandos {
+			if strings.HasPrefix(nombre, prefijo) {
+				candidatos = append(candidatos, nombre)
+			}
+		}
+		sort.Strings(candidatos)
+		return candidatos
+	}
+
+	comando, existe := c.comandos[strings.ToUpper(campos[0])]
+	argumento := len(campos) - 1
+	if palabraNueva {
+		argumento++
+	}
+	if !existe || comando.completar == nil || argumento != 1 {
+		return nil
+	}
+	prefijo := ""
+	if !palabraNueva {
+		prefijo = campos[len(campos)-1]
+	}
+	return comando.completar(prefijo)
+}
+
+// completarPlanificadores ofrece LARGO y CORTO
+func completarPlanificadores(prefijo string) []string {
+	var candidatos []string
+	for _, planificador := range []string{"CORTO", "LARGO"} {
+		if strings.HasPrefix(planificador, strings.ToUpper(prefijo)) {
+			candidatos = append(candidatos, planificador)
+		}
+	}
+	return candidatos
+}
+
+// completarScripts ofrece los pseudocódigos de SCRIPTS_PATH
+func completarScripts(prefijo string) []string {
+	entradas, err := os.ReadDir(kernelConfig.ScriptsPath)
+	if err != nil {
+		return nil
+	}
+	var candidatos []string
+	for _, entrada := range entradas {
+		if entrada.Type().IsRegular() && strings.HasPrefix(entrada.Name(), prefijo) {
+			candidatos = append(candidatos, entrada.Name())
+		}
+	}
+	return candidatos
+}
+
+// completarArchivos ofrece las rutas que empiezan con prefijo; los directorios terminan en /
+func completarArchivos(prefijo string) []string {
+	directorio, base := filepath.Split(prefijo)
+	leer := directorio
+	if leer == "" {
+		leer = "."
+	}
+	entradas, err := os.ReadDir(leer)
+	if err != nil {
+		return nil
+	}
+	var candidatos []string
+	for _, entrada := range entradas {
+		if !strings.HasPrefix(entrada.Name(), base) || (base == "" && strings.HasPrefix(entrada.Name(), ".")) {
+			continue
+		}
+		candidato := directorio + entrada.Name()
+		if entrada.IsDir() {
+			candidato += "/"
+		}
+		candidatos = append(candidatos, candidato)
+	}
+	return candidatos
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// maxHistorial es cuántas líneas recuerda la consola
+const maxHistorial = 500
+
+// lectorLineas lee los comandos de la consola. Si la entrada es una terminal permite
+// editar la línea, recorrer el historial con las flechas y completar con TAB; si no
+// (una tubería o un archivo) lee líneas comunes.
+type lectorLineas struct {
+	entrada  *bufio.Reader
+	salida   io.Writer
+	terminal *estadoTerminal
+	cerrar   sync.Once
+
+	historial []string
+	// completar devuelve los candidatos para la última palabra de la línea hasta el cursor
+	completar func(linea string) []string
+}
+
+// nuevoLectorLineas lee de stdin y escribe el eco en stdout
+func nuevoLectorLineas(completar func(linea string) []string) *lectorLineas {
+	lector := &lectorLineas{
+		entrada:   bufio.NewReader(os.Stdin),
+		salida:    os.Stdout,
+		completar: completar,
+	}
+	if terminal, err := modoCrudo(int(os.Stdin.Fd())); err == nil {
+		lector.terminal = terminal
+	}
+	return lector
+}
+
+// Cerrar restaura la terminal; se puede llamar más de una vez
+func (l *lectorLineas) Cerrar() {
+	l.cerrar.Do(func() {
+		if l.terminal != nil {
+			l.terminal.restaurar()
+		}
+	})
+}
+
+// LeerLinea muestra el prompt y devuelve la línea ingresada, o io.EOF si se cerró la entrada
+func (l *lectorLineas) LeerLinea(prompt string) (string, error) {
+	fmt.Fprint(l.salida, prompt)
+	if l.terminal == nil {
+		linea, err := l.entrada.ReadString('\n')
+		if err != nil && linea == "" {
+			return "", err
+		}
+		return strings.TrimRight(linea, "\r\n"), nil
+	}
+
+	linea, err := l.editar(prompt)
+	if err == nil && strings.TrimSpace(linea) != "" {
+		l.agregarAlHistorial(linea)
+	}
+	return linea, err
+}
+
+// edicion es el estado de la línea que se está escribiendo
+type edicion struct {
+	prompt string
+	linea  []rune
+	cursor int
+}
+
+// editar atiende tecla por tecla hasta ENTER
+func (l *lectorLineas) editar(prompt string) (string, error) {
+	e := &edicion{prompt: prompt}
+	posicion := len(l.historial) // len(historial) es la línea nueva
+	borrador := ""
+	tabAnterior := false
+
+	for {
+		tecla, _, err := l.entrada.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		esTab := tecla == '\t'
+
+		switch tecla {
+		case '\r', '\n':
+			fmt.Fprint(l.salida, "\r\n")
+			return string(e.linea), nil
+		case 4: // Ctrl+D
+			if len(e.linea) == 0 {
+				fmt.Fprint(l.salida, "\r\n")
+				return "", io.EOF
+			}
+			e.borrarEnCursor()
+		case 127, 8: // Backspace
+			if e.cursor > 0 {
+				e.cursor--
+				e.borrarEnCursor()
+			}
+		case 1: // Ctrl+A
+			e.cursor = 0
+		case 5: // Ctrl+E
+			e.cursor = len(e.linea)
+		case 21: // Ctrl+U
+			e.linea = e.linea[e.cursor:]
+			e.cursor = 0
+		case '\t':
+			l.completarPalabra(e, tabAnterior)
+		case 27: // Secuencias de escape: flechas, Inicio, Fin y Suprimir
+			switch l.leerEscape() {
+			case "A":
+				if posicion > 0 {
+					if posicion == len(l.historial) {
+						borrador = string(e.linea)
+					}
+					posicion--
+					e.reemplazar(l.historial[posicion])
+				}
+			case "B":
+				if posicion < len(l.historial) {
+					posicion++
+					if posicion == len(l.historial) {
+						e.reemplazar(borrador)
+					} else {
+						e.reemplazar(l.historial[posicion])
+					}
+				}
+			case "C":
+				if e.cursor < len(e.linea) {
+					e.cursor++
+				}
+			case "D":
+				if e.cursor > 0 {
+					e.cursor--
+				}
+			case "H", "1~":
+				e.cursor = 0
+			case "F", "4~":
+				e.cursor = len(e.linea)
+			case "3~":
+				e.borrarEnCursor()
+			}
+		default:
+			if tecla >= ' ' {
+				e.insertar(string(tecla))
+			}
+		}
+
+		tabAnterior = esTab
+		l.redibujar(e)
+	}
+}
+
+// leerEscape lee el resto de una secuencia ESC [ ... o ESC O ... y devuelve lo que sigue al prefijo
+func (l *lectorLineas) leerEscape() string {
+	prefijo, _, err := l.entrada.ReadRune()
+	if err != nil || (prefijo != '[' && prefijo != 'O') {
+		return ""
+	}
+	var secuencia strings.Builder
+	for {
+		tecla, _, err := l.entrada.ReadRune()
+		if err != nil {
+			return ""
+		}
+		secuencia.WriteRune(tecla)
+		if tecla >= '@' && tecla <= '~' && !(tecla >= '0' && tecla <= '9') {
+			return secuencia.String()
+		}
+	}
+}
+
+// completarPalabra completa la palabra bajo el cursor con el único candidato o con el
+// prefijo común de todos. Si no se avanzó y es el segundo TAB seguido, lista los candidatos.
+func (l *lectorLineas) completarPalabra(e *edicion, tabAnterior bool) {
+	if l.completar == nil {
+		return
+	}
+	antes := string(e.linea[:e.cursor])
+	inicio := strings.LastIndex(antes, " ") + 1
+	palabra := antes[inicio:]
+
+	candidatos := l.completar(antes)
+	switch len(candidatos) {
+	case 0:
+		return
+	case 1:
+		completo := candidatos[0]
+		if !strings.HasSuffix(completo, "/") {
+			completo += " "
+		}
+		e.reemplazarPalabra(palabra, completo)
+		return
+	}
+
+	comun := prefijoComun(candidatos)
+	if len(comun) > len(palabra) {
+		e.reemplazarPalabra(palabra, comun)
+		return
+	}
+	if tabAnterior {
+		fmt.Fprintf(l.salida, "\r\n%s\r\n", strings.Join(candidatos, "  "))
+	}
+}
+
+// redibujar reescribe la línea y ubica el cursor
+func (l *lectorLineas) redibujar(e *edicion) {
+	fmt.Fprintf(l.salida, "\r%s%s\x1b[K", e.prompt, string(e.linea))
+	if atras := len(e.linea) - e.cursor; atras > 0 {
+		fmt.Fprintf(l.salida, "\x1b[%dD", atras)
+	}
+}
+
+func (l *lectorLineas) agregarAlHistorial(linea string) {
+	if n := len(l.historial); n > 0 && l.historial[n-1] == linea {
+		return
+	}
+	l.historial = append(l.historial, linea)
+	if len(l.historial) > maxHistorial {
+		l.historial = l.historial[len(l.historial)-maxHistorial:]
+	}
+}
+
+func (e *edicion) insertar(texto string) {
+	nuevo := []rune(texto)
+	e.linea = append(e.linea[:e.cursor], append(nuevo, e.linea[e.cursor:]...)...)
+	e.cursor += len(nuevo)
+}
+
+func (e *edicion) borrarEnCursor() {
+	if e.cursor < len(e.linea) {
+		e.linea = append(e.linea[:e.cursor], e.linea[e.cursor+1:]...)
+	}
+}
+
+// reemplazarPalabra cambia la palabra que termina en el cursor (los comandos se completan
+// en mayúsculas aunque se escriban en minúsculas)
+func (e *edicion) reemplazarPalabra(palabra string, completa string) {
+	inicio := e.cursor - len([]rune(palabra))
+	e.linea = append(e.linea[:inicio], e.linea[e.cursor:]...)
+	e.cursor = inicio
+	e.insertar(completa)
+}
+
+func (e *edicion) reemplazar(linea string) {
+	e.linea = []rune(linea)
+	e.cursor = len(e.linea)
+}
+
+// prefijoComun devuelve el prefijo que comparten todos los candidatos
+func prefijoComun(candidatos []string) string {
+	comun := candidatos[0]
+	for _, candidato := range candidatos[1:] {
+		for !strings.HasPrefix(candidato, comun) {
+			comun = comun[:len(comun)-1]
+		}
+	}
+	return comun
+}
@@ -2,153 +2,135 @@ package main
 
 import (
 	"fmt"
+	"net/http"
+	"sort"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
 )
 
 // HandlerHandshake optimizado
-func HandlerHandshake(msg *utils.Mensaje) (interface{}, error) {
-	utils.InfoLog.Info("Handshake recibido", "origen", msg.Origen)
-
-	datosMap, ok := msg.Datos.(map[string]interface{})
-	if !ok {
-		utils.ErrorLog.Error("Datos inválidos en handshake", "datos", fmt.Sprintf("%v", msg.Datos))
-		return map[string]interface{}{"status": "ERROR", "message": "Datos inválidos"}, nil
-	}
+func HandlerHandshake(msg *utils.Mensaje, solicitud utils.SolicitudHandshake) (utils.RespuestaHandshake, error) {
+	utils.InfoLog.Info("Handshake recibido", "origen", msg.Origen, "version", solicitud.Version)
 
 	// Procesar IO
-	if respuesta, manejado := ManejadorRegistroIO(msg.Origen, datosMap); manejado {
+	if respuesta, manejado, err := ManejadorRegistroIO(solicitud); manejado {
 		utils.InfoLog.Info("Procesado como dispositivo IO", "origen", msg.Origen)
-		return respuesta, nil
+		return respuesta, err
 	}
 
 	// Procesar CPU
-	if esCPU(msg.Origen, datosMap) {
+	if esCPU(msg.Origen, solicitud) {
 		utils.InfoLog.Info("Procesando como CPU", "origen", msg.Origen)
-		respuesta, _ := manejarRegistroCPU(msg.Origen, datosMap)
-		return respuesta, nil
+		return manejarRegistroCPU(msg.Origen, solicitud)
 	}
 
 	utils.InfoLog.Info("Handshake genérico completado", "origen", msg.Origen)
-	return map[string]interface{}{"status": "OK", "message": "Handshake recibido"}, nil
+	return respuestaHandshake("Handshake recibido"), nil
+}
+
+// respuestaHandshake construye la respuesta exitosa de handshake del Kernel
+func respuestaHandshake(mensaje string) utils.RespuestaHandshake {
+	return utils.RespuestaHandshake{Status: "OK", Version: utils.VersionProtocolo, Mensaje: mensaje}
 }
 
 // esCPU simplificado
-func esCPU(origen string, datos map[string]interface{}) bool {
+func esCPU(origen string, solicitud utils.SolicitudHandshake) bool {
 	return origen == "CPU" ||
-		datos["tipo"] == "CPU" ||
-		datos["nombre"] == "CPU"
+		solicitud.Tipo == "CPU" ||
+		solicitud.Nombre == "CPU"
 }
 
 // manejarRegistroCPU optimizado
-func manejarRegistroCPU(origen string, datos map[string]interface{}) (interface{}, error) {
-	ip, ipOk := datos["ip"].(string)
-	if !ipOk {
-		return map[string]interface{}{"status": "ERROR", "message": "IP requerida"}, nil
+func manejarRegistroCPU(origen string, solicitud utils.SolicitudHandshake) (utils.RespuestaHandshake, error) {
+	if solicitud.IP == "" {
+		return utils.RespuestaHandshake{}, utils.NuevoError(utils.ErrorEsquemaInvalido, "handshake de CPU sin IP")
 	}
 
-	puerto, puertoOk := extraerPuerto(datos["puerto"])
-	if !puertoOk {
-		return map[string]interface{}{"status": "ERROR", "message": "Puerto inválido"}, nil
+	if solicitud.Puerto <= 0 {
+		return utils.RespuestaHandshake{}, utils.NuevoError(utils.ErrorEsquemaInvalido, "handshake de CPU con puerto inválido: %d", solicitud.Puerto)
 	}
 
 	// Usar identificador específico de la CPU
 	identificadorCPU := origen
-	if id, existe := datos["identificador"].(string); existe && id != "" {
-		identificadorCPU = id
+	if solicitud.Identificador != "" {
+		identificadorCPU = solicitud.Identificador
 	}
 
 	// Registro síncrono
-	registrarCPU(identificadorCPU, ip, puerto)
+	registrarCPU(identificadorCPU, solicitud.IP, solicitud.Puerto)
+	leases.Otorgar(identificadorCPU)
 
-	utils.InfoLog.Info("CPU registrada", "identificador", identificadorCPU, "ip", ip, "puerto", puerto)
+	utils.InfoLog.Info("CPU registrada", "identificador", identificadorCPU, "ip", solicitud.IP, "puerto", solicitud.Puerto)
 
-	return map[string]interface{}{"status": "OK", "message": fmt.Sprintf("CPU %s registrada", identificadorCPU)}, nil
+	return respuestaHandshake(fmt.Sprintf("CPU %s registrada", identificadorCPU)), nil
 }
 
-// extraerPuerto helper
-func extraerPuerto(puerto interface{}) (int, bool) {
-	switch p := puerto.(type) {
-	case float64:
-		return int(p), true
-	case int:
-		return p, true
-	case string:
-		if val, err := strconv.Atoi(p); err == nil {
-			return val, true
-		}
+// HandlerLatido renueva el lease de una CPU o dispositivo IO registrado
+func HandlerLatido(msg *utils.Mensaje, latido utils.SolicitudLatido) (utils.RespuestaLatido, error) {
+	lease, err := leases.Renovar(latido.Identificador, latido.Carga)
+	if err != nil {
+		utils.InfoLog.Warn("Latido sin lease vigente", "identificador", latido.Identificador)
+		return utils.RespuestaLatido{}, err
 	}
-	return 0, false
-}
 
-func HandlerOperacion(msg *utils.Mensaje) (interface{}, error) {
-	return procesarOperacionEspecifica(msg)
+	utils.InfoLog.Debug("Latido recibido", "identificador", latido.Identificador, "carga", latido.Carga)
+	return utils.RespuestaLatido{VenceMs: lease.Vence.UnixMilli()}, nil
 }
 
-// procesarOperacionEspecifica con pipeline optimizado
-func procesarOperacionEspecifica(msg *utils.Mensaje) (interface{}, error) {
-	datos, ok := msg.Datos.(map[string]interface{})
-	if !ok {
-		return map[string]interface{}{"status": "ERROR", "mensaje": "Datos inválidos"}, nil
-	}
+// HandlerBaja quita una CPU o dispositivo IO que avisó que se detiene
+func HandlerBaja(msg *utils.Mensaje, baja utils.SolicitudLatido) (utils.RespuestaEstado, error) {
+	utils.InfoLog.Info("Baja recibida", "identificador", baja.Identificador)
+	leases.Revocar(baja.Identificador)
+	darDeBajaModulo(baja.Identificador)
+	return utils.RespuestaOK(fmt.Sprintf("%s dado de baja", baja.Identificador)), nil
+}
 
-	pid, pidOk := extraerPID(datos["pid"])
-	if !pidOk {
-		if _, esFinIO := datos["evento"].(string); !esFinIO {
-			return map[string]interface{}{"status": "ERROR", "mensaje": "PID inválido o faltante"}, nil
-		}
-	}
+func HandlerOperacion(msg *utils.Mensaje, notificacion utils.NotificacionKernel) (utils.RespuestaEstado, error) {
+	return procesarOperacionEspecifica(notificacion)
+}
 
+// procesarOperacionEspecifica con pipeline optimizado
+func procesarOperacionEspecifica(notificacion utils.NotificacionKernel) (utils.RespuestaEstado, error) {
 	// Pipeline de procesamiento
-	handlers := []func(int, map[string]interface{}) (interface{}, bool){
+	handlers := []func(utils.NotificacionKernel) (utils.RespuestaEstado, bool, error){
 		ProcesarRetornoCPU,
-		func(pid int, datos map[string]interface{}) (interface{}, bool) {
-			return ProcesarSolicitudIO(datos)
-		},
-		func(pid int, datos map[string]interface{}) (interface{}, bool) {
-			return ProcesarIOTerminada(datos)
-		},
+		ProcesarSolicitudIO,
+		ProcesarIOTerminada,
+		ProcesarIOInterrumpida,
 		procesarFinalizacionSiCorresponde,
 	}
 
 	for _, handler := range handlers {
-		if respuesta, manejado := handler(pid, datos); manejado {
-			return respuesta, nil
+		if respuesta, manejado, err := handler(notificacion); manejado {
+			return respuesta, err
 		}
 	}
 
-	return map[string]interface{}{"status": "ERROR", "mensaje": "Operación desconocida o no manejada"}, nil
+	return utils.RespuestaEstado{}, utils.NuevoError(utils.ErrorOperacionDesconocida, "operación desconocida o no manejada: evento %s", notificacion.Evento)
 }
 
 // ProcesarRetornoCPU maneja retorno de procesos desde CPU
-func ProcesarRetornoCPU(pid int, datos map[string]interface{}) (interface{}, bool) {
-	motivo, ok := datos["motivo_retorno"].(string)
-	if !ok {
-		return nil, false
+func ProcesarRetornoCPU(notificacion utils.NotificacionKernel) (utils.RespuestaEstado, bool, error) {
+	if notificacion.Evento != utils.EventoRetornoCPU || notificacion.MotivoRetorno != utils.MotivoInterrumpido {
+		return utils.RespuestaEstado{}, false, nil
 	}
 
+	pid := notificacion.PID
 	pcb := BuscarPCBPorPID(pid)
 	if pcb == nil {
 		utils.ErrorLog.Warn("Retorno de CPU para PID inexistente", "pid", pid)
-		return map[string]interface{}{"status": "ERROR", "mensaje": "PID no encontrado"}, true
+		return utils.RespuestaEstado{}, true, utils.NuevoError(utils.ErrorPIDInexistente, "PID %d no encontrado", pid)
 	}
 
 	liberarCPU(pid)
 
-	switch motivo {
-	case "INTERRUPTED":
-		utils.InfoLog.Info("Proceso interrumpido por Kernel", "pid", pid)
-		MoverProcesoAReady(pcb)
-		go despacharProcesoSiCorresponde()
-		return map[string]interface{}{"status": "OK", "message": "Proceso movido a READY por interrupción"}, true
-
-	case "SYSCALL_IO":
-		return nil, false
-
-	default:
-		return nil, false
-	}
+	utils.InfoLog.Info("Proceso interrumpido por Kernel", "pid", pid)
+	MoverProcesoAReady(pcb)
+	go despacharProcesoSiCorresponde()
+	return utils.RespuestaOK("Proceso movido a READY por interrupción"), true, nil
 }
 
 // liberarCPU encuentra y libera la CPU que ejecutaba un proceso
@@ -172,35 +154,26 @@ func liberarCPU(pid int) string {
 	return cpuLiberada
 }
 
-// extraerPID helper
-func extraerPID(pid interface{}) (int, bool) {
-	if pidFloat, ok := pid.(float64); ok {
-		return int(pidFloat), true
-	}
-	return 0, false
-}
-
 // procesarFinalizacionSiCorresponde con detección optimizada
-func procesarFinalizacionSiCorresponde(pid int, datos map[string]interface{}) (interface{}, bool) {
-	evento, _ := datos["evento"].(string)
-	motivoRetorno, _ := datos["motivo_retorno"].(string)
+func procesarFinalizacionSiCorresponde(notificacion utils.NotificacionKernel) (utils.RespuestaEstado, bool, error) {
+	motivoRetorno := notificacion.MotivoRetorno
 
-	if evento == "PROCESO_TERMINADO" || motivoRetorno == "EXIT" || motivoRetorno == "ERROR" {
-		liberarCPU(pid)
-		respuesta, _ := procesarFinalizacion(pid, datos)
-		return respuesta, true
+	if notificacion.Evento == utils.EventoProcesoTerminado || motivoRetorno == utils.MotivoExit || motivoRetorno == utils.MotivoError {
+		liberarCPU(notificacion.PID)
+		respuesta, err := procesarFinalizacion(notificacion)
+		return respuesta, true, err
 	}
-	return nil, false
+	return utils.RespuestaEstado{}, false, nil
 }
 
 // procesarFinalizacion optimizado
-func procesarFinalizacion(pid int, datos map[string]interface{}) (interface{}, error) {
-	pcb := BuscarPCBPorPID(pid)
+func procesarFinalizacion(notificacion utils.NotificacionKernel) (utils.RespuestaEstado, error) {
+	pcb := BuscarPCBPorPID(notificacion.PID)
 	if pcb == nil {
-		return map[string]interface{}{"status": "ERROR", "mensaje": "Proceso no encontrado"}, nil
+		return utils.RespuestaEstado{}, utils.NuevoError(utils.ErrorPIDInexistente, "proceso %d no encontrado", notificacion.PID)
 	}
 
-	motivo := determinarMotivo(datos)
+	motivo := determinarMotivo(notificacion)
 	FinalizarProceso(pcb, motivo)
 
 	// Operaciones post-finalización en paralelo
@@ -209,16 +182,217 @@ func procesarFinalizacion(pid int, datos map[string]interface{}) (interface{}, e
 		despacharProcesoSiCorresponde()
 	}()
 
-	return map[string]interface{}{"status": "OK", "mensaje": "Proceso finalizado"}, nil
+	return utils.RespuestaOK("Proceso finalizado"), nil
 }
 
 // determinarMotivo helper
-func determinarMotivo(datos map[string]interface{}) string {
-	if m, ok := datos["motivo"].(string); ok {
-		return m
+func determinarMotivo(notificacion utils.NotificacionKernel) string {
+	if notificacion.Motivo != "" {
+		return notificacion.Motivo
 	}
-	if motivoRetorno, ok := datos["motivo_retorno"].(string); ok && motivoRetorno == "ERROR" {
+	if notificacion.MotivoRetorno == utils.MotivoError {
 		return "ERROR_CPU"
 	}
 	return "EXIT_NORMAL"
 }
+
+// === CONSULTAS REST ===
+
+// HandlerListarProcesos devuelve los procesos que todavía no terminaron y, si se piden,
+// también los finalizados
+func HandlerListarProcesos(msg *utils.Mensaje, solicitud utils.SolicitudListarProcesos) (utils.RespuestaProcesos, error) {
+	mapaMutex.RLock()
+	pcbs := make([]*PCB, 0, len(mapaPCBs))
+	for _, pcb := range mapaPCBs {
+		pcbs = append(pcbs, pcb)
+	}
+	mapaMutex.RUnlock()
+	if solicitud.Finalizados {
+		exitMutex.Lock()
+		pcbs = append(pcbs, colaExit...)
+		exitMutex.Unlock()
+	}
+	sort.Slice(pcbs, func(i, j int) bool { return pcbs[i].PID < pcbs[j].PID })
+
+	cpus := cpusPorPID()
+	respuesta := utils.RespuestaProcesos{Procesos: make([]utils.ResumenProceso, 0, len(pcbs))}
+	for _, pcb := range pcbs {
+		respuesta.Procesos = append(respuesta.Procesos, resumenProceso(pcb, cpus[pcb.PID]))
+	}
+	return respuesta, nil
+}
+
+// HandlerConsultarProceso devuelve el detalle de un proceso
+func HandlerConsultarProceso(msg *utils.Mensaje, solicitud utils.SolicitudPID) (utils.DetalleProceso, error) {
+	pcb := BuscarPCBPorPID(solicitud.PID)
+	if pcb == nil {
+		return utils.DetalleProceso{}, utils.NuevoError(utils.ErrorPIDInexistente, "proceso %d no encontrado", solicitud.PID)
+	}
+
+	return utils.DetalleProceso{
+		ResumenProceso:     resumenProceso(pcb, cpusPorPID()[pcb.PID]),
+		EstimacionRafagaMs: pcb.EstimacionSiguienteRafaga,
+		UltimaRafagaMs:     pcb.UltimaRafagaReal,
+		Ejecuciones:        pcb.TotalEjecuciones,
+		TiempoEjecucionMs:  pcb.TotalTiempoEjecucion,
+		VecesReady:         pcb.TotalReady,
+		TiempoReadyMs:      pcb.TotalTiempoReady * 1000,
+		EnSwap:             pcb.EnSwap,
+		Creado:             pcb.HoraCreacion,
+	}, nil
+}
+
+// HandlerFinalizarProceso finaliza un proceso a pedido del usuario
+func HandlerFinalizarProceso(msg *utils.Mensaje, solicitud utils.SolicitudPID) (utils.RespuestaEstado, error) {
+	mensaje, err := finalizarPorUsuario(solicitud.PID, msg.Origen)
+	if err != nil {
+		return utils.RespuestaEstado{}, err
+	}
+	return utils.RespuestaOK(mensaje), nil
+}
+
+// HandlerCrearProceso crea un proceso en NEW a pedido del usuario, como INIT_PROC
+func HandlerCrearProceso(msg *utils.Mensaje, solicitud utils.SolicitudCrearProceso) (utils.RespuestaCrearProceso, error) {
+	pcb := crearProceso(solicitud.Archivo, solicitud.Tamanio, solicitud.Prioridad, msg.Origen)
+	return utils.RespuestaCrearProceso{PID: pcb.PID}, nil
+}
+
+// HandlerConsultarPlanificacion informa qué planificadores están activos
+func HandlerConsultarPlanificacion(msg *utils.Mensaje, _ utils.SinDatos) (utils.RespuestaPlanificacion, error) {
+	return respuestaPlanificacion(), nil
+}
+
+// HandlerIniciarPlanificacion reanuda el planificador pedido (largo, corto o todos)
+func HandlerIniciarPlanificacion(msg *utils.Mensaje, solicitud utils.SolicitudPlanificacion) (utils.RespuestaPlanificacion, error) {
+	utils.InfoLog.Info("Inicio de planificación solicitado", "planificador", solicitud.Planificador, "origen", msg.Origen)
+	iniciarPlanificacion(solicitud.Planificador)
+	return respuestaPlanificacion(), nil
+}
+
+// HandlerDetenerPlanificacion pausa el planificador pedido (largo, corto o todos)
+func HandlerDetenerPlanificacion(msg *utils.Mensaje, solicitud utils.SolicitudPlanificacion) (utils.RespuestaPlanificacion, error) {
+	utils.InfoLog.Info("Detención de planificación solicitada", "planificador", solicitud.Planificador, "origen", msg.Origen)
+	detenerPlanificacion(solicitud.Planificador)
+	return respuestaPlanificacion(), nil
+}
+
+// finalizarPorUsuario finaliza un proceso en cualquier estado (REST y consola) y
+// describe lo que se hizo
+func finalizarPorUsuario(pid int, origen string) (string, error) {
+	pcb := BuscarPCBPorPID(pid)
+	if pcb == nil {
+		return "", utils.NuevoError(utils.ErrorPIDInexistente, "proceso %d no encontrado", pid)
+	}
+	if pcb.Estado == EstadoExit {
+		return fmt.Sprintf("el proceso %d ya había finalizado", pcb.PID), nil
+	}
+
+	utils.InfoLog.Info("Finalización de proceso solicitada", "pid", pcb.PID, "estado", pcb.Estado, "origen", origen)
+	SolicitarFinalizacion(pcb, "FINALIZADO_POR_USUARIO")
+	return fmt.Sprintf("finalización del proceso %d solicitada", pcb.PID), nil
+}
+
+// HandlerListarColas devuelve los PIDs de cada cola de planificación
+func HandlerListarColas(msg *utils.Mensaje, _ utils.SinDatos) (utils.RespuestaColas, error) {
+	respuesta := utils.RespuestaColas{
+		Colas: map[string][]int{
+			EstadoNew:         pidsDeCola(&newMutex, &colaNew),
+			EstadoReady:       pidsReady(),
+			EstadoBlocked:     pidsDeCola(&blockedMutex, &colaBlocked),
+			EstadoSuspReady:   pidsDeCola(&suspReadyMutex, &colaSuspReady),
+			EstadoSuspBlocked: pidsDeCola(&suspBlockedMutex, &colaSuspBlocked),
+			EstadoExit:        pidsDeCola(&exitMutex, &colaExit),
+		},
+		Exec:                   make(map[string]int),
+		Multiprogramacion:      semaforoMultiprogram.EnUso(),
+		GradoMultiprogramacion: semaforoMultiprogram.Capacidad(),
+	}
+	for pid, cpu := range cpusPorPID() {
+		respuesta.Exec[cpu] = pid
+	}
+	return respuesta, nil
+}
+
+// pidsDeCola copia los PIDs de una cola tomando su mutex
+func pidsDeCola(mutex *sync.Mutex, cola *[]*PCB) []int {
+	mutex.Lock()
+	defer mutex.Unlock()
+	pids := make([]int, 0, len(*cola))
+	for _, pcb := range *cola {
+		pids = append(pids, pcb.PID)
+	}
+	return pids
+}
+
+// HandlerMetricasProceso devuelve las métricas de estado de un proceso, aunque ya haya finalizado
+func HandlerMetricasProceso(msg *utils.Mensaje, solicitud utils.SolicitudPID) (utils.RespuestaMetricasProceso, error) {
+	pcb := BuscarPCBPorPID(solicitud.PID)
+	if pcb == nil {
+		pcb = buscarFinalizado(solicitud.PID)
+	}
+	if pcb == nil {
+		return utils.RespuestaMetricasProceso{}, utils.NuevoError(utils.ErrorPIDInexistente, "proceso %d no encontrado", solicitud.PID)
+	}
+
+	return utils.RespuestaMetricasProceso{
+		PID:             pcb.PID,
+		Estado:          pcb.Estado,
+		MetricasProceso: pcb.metricasEstado(time.Now()),
+	}, nil
+}
+
+// buscarFinalizado busca un proceso en EXIT, que ya no está en mapaPCBs
+func buscarFinalizado(pid int) *PCB {
+	exitMutex.Lock()
+	defer exitMutex.Unlock()
+	for _, pcb := range colaExit {
+		if pcb.PID == pid {
+			return pcb
+		}
+	}
+	return nil
+}
+
+// datosListarProcesos lee ?finalizados=true de GET /procesos
+func datosListarProcesos(r *http.Request) (interface{}, error) {
+	finalizados, err := strconv.ParseBool(r.URL.Query().Get("finalizados"))
+	if err != nil && r.URL.Query().Has("finalizados") {
+		return nil, utils.NuevoError(utils.ErrorEsquemaInvalido, "finalizados debe ser true o false: %q", r.URL.Query().Get("finalizados"))
+	}
+	return utils.SolicitudListarProcesos{Finalizados: finalizados}, nil
+}
+
+// datosPlanificacion arma la SolicitudPlanificacion con el parámetro {planificador} de la ruta
+func datosPlanificacion(r *http.Request) (interface{}, error) {
+	return utils.SolicitudPlanificacion{Planificador: r.PathValue("planificador")}, nil
+}
+
+// resumenProceso arma la descripción de un proceso para las consultas
+func resumenProceso(pcb *PCB, cpu string) utils.ResumenProceso {
+	resumen := utils.ResumenProceso{
+		PID:       pcb.PID,
+		Estado:    pcb.Estado,
+		Archivo:   pcb.NombreArchivo,
+		Tamanio:   pcb.Tamanio,
+		PC:        pcb.PC,
+		Prioridad: pcb.Prioridad,
+		CPU:       cpu,
+	}
+	if pcb.Estado == EstadoBlocked || pcb.Estado == EstadoSuspBlocked {
+		resumen.MotivoBloqueo = pcb.MotivoBloqueo
+	}
+	return resumen
+}
+
+// cpusPorPID devuelve en qué CPU se ejecuta cada proceso en EXEC
+func cpusPorPID() map[int]string {
+	execMutex.Lock()
+	defer execMutex.Unlock()
+	cpus := make(map[int]string, len(colaExec))
+	for cpu, pcb := range colaExec {
+		if pcb != nil {
+			cpus[pcb.PID] = cpu
+		}
+	}
+	return cpus
+}
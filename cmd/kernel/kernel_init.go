@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -9,57 +10,181 @@ import (
 
 // KernelConfig define la configuración del módulo Kernel
 type KernelConfig struct {
-	IPKernel               string  `json:"IP_KERNEL"`
+	IPKernel               string  `json:"IP_KERNEL" default:"127.0.0.1"`
 	PortKernel             int     `json:"PUERTO_KERNEL"`
-	IPMemory               string  `json:"IP_MEMORIA"`
+	IPMemory               string  `json:"IP_MEMORIA" default:"127.0.0.1"`
 	PortMemory             int     `json:"PUERTO_MEMORIA"`
-	LogLevel               string  `json:"LOG_LEVEL"`
-	SchedulerAlgorithm     string  `json:"ALGORITMO_CORTO_PLAZO"`
-	ReadyIngressAlgorithm  string  `json:"ALGORITMO_INGRESO_A_READY"`
-	Alpha                  float64 `json:"ALFA"`
-	InitialEstimate        int     `json:"ESTIMACION_INICIAL"`
+	LogLevel               string  `json:"LOG_LEVEL" default:"INFO"`
+	SchedulerAlgorithm     string  `json:"ALGORITMO_CORTO_PLAZO" default:"FIFO"`
+	ReadyIngressAlgorithm  string  `json:"ALGORITMO_INGRESO_A_READY" default:"FIFO"`
+	Alpha                  float64 `json:"ALFA" default:"0.5"`
+	InitialEstimate        int     `json:"ESTIMACION_INICIAL" default:"10000"`
+	Quantum                int     `json:"QUANTUM,omitempty"`               // milisegundos, para RR y VRR
+	TiempoEnvejecimiento   int     `json:"TIEMPO_ENVEJECIMIENTO,omitempty"` // milisegundos en READY para subir un nivel de prioridad; 0 no envejece
 	SuspensionTime         int     `json:"TIEMPO_SUSPENSION"`
 	GradoMultiprogramacion int     `json:"GRADO_MULTIPROGRAMACION"`
-	ScriptsPath            string  `json:"SCRIPTS_PATH,omitempty"`
+	ScriptsPath            string  `json:"SCRIPTS_PATH,omitempty" default:"scripts/"`
+	LeaseMs                int     `json:"LEASE_MS,omitempty" default:"3000"` // 0: las CPUs e IOs no se dan de baja por falta de latidos
+
+	Timeouts   utils.TimeoutsPorDestino `json:"TIMEOUTS,omitempty"`
+	Transporte string                   `json:"TRANSPORTE,omitempty"`
+	TrazasPath string                   `json:"TRAZAS_PATH,omitempty"`
+	// SecretoCompartido firma y verifica los mensajes entre módulos; vacío no se firma
+	SecretoCompartido string `json:"SECRETO_COMPARTIDO,omitempty" sensible:"true"`
+	// TokenAdmin autentica la API REST; vacío la deja abierta solo si no hay secreto
+	TokenAdmin string `json:"TOKEN_ADMIN,omitempty" sensible:"true"`
+	// Webhooks reciben por POST los eventos del ciclo de vida de los procesos
+	Webhooks         []string `json:"WEBHOOKS,omitempty"`
+	WebhookTimeoutMs int      `json:"WEBHOOK_TIMEOUT_MS,omitempty" default:"2000"`
+	// WebhookSecreto firma el cuerpo de las notificaciones; vacío no se firman
+	WebhookSecreto string `json:"WEBHOOK_SECRETO,omitempty" sensible:"true"`
+
+	utils.OpcionesLog
+	utils.OpcionesTLS
+}
+
+// ValidarConfig aplica las reglas del Kernel (ver utils.ConfigValidable)
+func (c *KernelConfig) ValidarConfig(r *utils.ReporteConfig) {
+	r.Direccion("IP_KERNEL", c.IPKernel, "PUERTO_KERNEL", c.PortKernel)
+	r.Direccion("IP_MEMORIA", c.IPMemory, "PUERTO_MEMORIA", c.PortMemory)
+	r.DireccionesDistintas("PUERTO_KERNEL", c.IPKernel, c.PortKernel, c.IPMemory, c.PortMemory)
+	r.UnoDe("ALGORITMO_CORTO_PLAZO", c.SchedulerAlgorithm, "FIFO", "SJF", "SRT", "RR", "VRR", "PRIORIDADES")
+	r.UnoDe("ALGORITMO_INGRESO_A_READY", c.ReadyIngressAlgorithm, "FIFO", "PMCP")
+	r.Rango("ALFA", c.Alpha, 0, 1)
+	r.Positivo("ESTIMACION_INICIAL", c.InitialEstimate)
+	if c.SchedulerAlgorithm == "RR" || c.SchedulerAlgorithm == "VRR" {
+		r.Positivo("QUANTUM", c.Quantum)
+	} else {
+		r.NoNegativo("QUANTUM", c.Quantum)
+	}
+	r.NoNegativo("TIEMPO_ENVEJECIMIENTO", c.TiempoEnvejecimiento)
+	r.NoNegativo("TIEMPO_SUSPENSION", c.SuspensionTime)
+	r.Positivo("GRADO_MULTIPROGRAMACION", c.GradoMultiprogramacion)
+	r.NoNegativo("LEASE_MS", c.LeaseMs)
+	r.NivelLog(c.LogLevel)
+	r.OpcionesLog(c.OpcionesLog)
+	r.Comunicacion(c.Transporte, c.Timeouts)
+	r.Credenciales(c.SecretoCompartido, c.TokenAdmin)
+	for i, url := range c.Webhooks {
+		r.URL(fmt.Sprintf("WEBHOOKS[%d]", i), url)
+	}
+	r.Positivo("WEBHOOK_TIMEOUT_MS", c.WebhookTimeoutMs)
+	r.SecretoPropio("WEBHOOK_SECRETO", c.WebhookSecreto, c.SecretoCompartido)
+	r.OpcionesTLS(c.OpcionesTLS)
 }
 
+// umbralMensajeLento es a partir de cuánto se avisa en el log que un mensaje tardó demasiado
+const umbralMensajeLento = time.Second
+
 var (
 	kernelModulo  *utils.Modulo
 	kernelConfig  *KernelConfig
 	memoriaClient *utils.HTTPClient
+	// leases de las CPUs y dispositivos IO registrados, renovados con sus latidos
+	leases *utils.RegistroLeases
 )
 
 // inicializarKernel optimizado
 func inicializarKernel(configPath string) error {
 	kernelModulo = utils.NuevoModulo("Kernel", configPath)
-	kernelConfig = utils.CargarConfiguracion[KernelConfig](configPath)
+	kernelConfig = utils.CargarConfiguracionModulo[KernelConfig](configPath, utils.SeccionKernel, "")
 
-	utils.InicializarLogger(kernelConfig.LogLevel, "Kernel")
+	if err := utils.InicializarLoggerConOpciones(kernelConfig.LogLevel, "Kernel", kernelConfig.OpcionesLog); err != nil {
+		utils.ErrorLog.Error("No se pudieron abrir los archivos de log", "error", err)
+	}
 	utils.InfoLog.Info("Inicializando Kernel", "config_path", configPath)
+	if err := utils.InicializarTrazas("Kernel", kernelConfig.TrazasPath); err != nil {
+		utils.ErrorLog.Error("No se pudieron habilitar las trazas", "error", err)
+	}
+	utils.HabilitarFirma(kernelConfig.SecretoCompartido)
+	utils.HabilitarTokenAdmin(kernelConfig.TokenAdmin)
+	if err := utils.HabilitarTLS(kernelConfig.OpcionesTLS); err != nil {
+		return err
+	}
 
 	// Inicializar el mapa de CPUs ANTES de cualquier otra operación
 	inicializarMapaCPUs()
 
 	InicializarPlanificador(kernelConfig)
+	context.AfterFunc(kernelModulo.Contexto(), despertarLTS)
+
+	leases = utils.NuevoRegistroLeases(time.Duration(kernelConfig.LeaseMs)*time.Millisecond, darDeBajaModulo)
+	go leases.Vigilar(kernelModulo.Contexto())
 
 	// Inicializar y conectar con Memoria
-	memoriaClient = utils.NewHTTPClient(kernelConfig.IPMemory, kernelConfig.PortMemory, "Kernel->Memoria")
+	memoriaClient = nuevoCliente(kernelConfig.IPMemory, kernelConfig.PortMemory, "Kernel->Memoria", "MEMORIA")
+	memoriaClient.ConReintentos(utils.PoliticaPorDefecto)
 	if err := conectarAMemoria(10); err != nil {
 		utils.ErrorLog.Error("No se pudo conectar con Memoria", "error", err)
 		return err
 	}
+	suscribirLiberacionesMemoria()
 
 	registrarHandlers()
-	kernelModulo.IniciarServidor(kernelConfig.IPKernel, kernelConfig.PortKernel)
+	kernelModulo.ReportarCarga(cargaKernel)
+	registrarMetricas()
+	kernelModulo.AlFinalizar("métricas finales", imprimirMetricasFinales)
+	iniciarWebhooks(kernelConfig.Webhooks, kernelConfig.WebhookTimeoutMs, kernelConfig.WebhookSecreto)
+	if err := kernelModulo.Iniciar(kernelConfig.IPKernel, kernelConfig.PortKernel); err != nil {
+		return err
+	}
+	kernelModulo.MarcarListo()
+	iniciarPlanificadores()
 
 	utils.InfoLog.Info("Kernel inicializado correctamente")
 	return nil
 }
 
+// nuevoCliente crea un cliente hacia otro módulo con el timeout y el transporte configurados
+func nuevoCliente(ip string, puerto int, nombre string, destino string) *utils.HTTPClient {
+	cliente := utils.NewHTTPClient(ip, puerto, nombre)
+	cliente.Timeout = kernelConfig.Timeouts.Para(destino)
+	if kernelConfig.Transporte != "" {
+		cliente.Transporte = kernelConfig.Transporte
+	}
+	return cliente
+}
+
 // registrarHandlers registra todos los manejadores HTTP
 func registrarHandlers() {
-	kernelModulo.RegistrarHandler(fmt.Sprintf("%d", utils.MensajeHandshake), "handshake", HandlerHandshake)
-	kernelModulo.RegistrarHandler(fmt.Sprintf("%d", utils.MensajeOperacion), "default", HandlerOperacion)
+	utils.RegistrarHandlerTipado(kernelModulo, utils.MensajeHandshake, "handshake", HandlerHandshake)
+	utils.RegistrarHandlerTipado(kernelModulo, utils.MensajeOperacion, "default", HandlerOperacion)
+	utils.RegistrarHandlerTipado(kernelModulo, utils.MensajeLatido, "latido", HandlerLatido)
+	utils.RegistrarHandlerTipado(kernelModulo, utils.MensajeLatido, "baja", HandlerBaja)
+	utils.RegistrarHandlerTipado(kernelModulo, utils.MensajeListarProcesos, "default", HandlerListarProcesos)
+	utils.RegistrarHandlerTipado(kernelModulo, utils.MensajeConsultarProceso, "default", HandlerConsultarProceso)
+	utils.RegistrarHandlerTipado(kernelModulo, utils.MensajeFinalizarProceso, "default", HandlerFinalizarProceso)
+	utils.RegistrarHandlerTipado(kernelModulo, utils.MensajeCrearProceso, "default", HandlerCrearProceso)
+	utils.RegistrarHandlerTipado(kernelModulo, utils.MensajeListarColas, "default", HandlerListarColas)
+	utils.RegistrarHandlerTipado(kernelModulo, utils.MensajeMetricasProceso, "default", HandlerMetricasProceso)
+	utils.RegistrarHandlerTipado(kernelModulo, utils.MensajePlanificacion, "consultar", HandlerConsultarPlanificacion)
+	utils.RegistrarHandlerTipado(kernelModulo, utils.MensajePlanificacion, "iniciar", HandlerIniciarPlanificacion)
+	utils.RegistrarHandlerTipado(kernelModulo, utils.MensajePlanificacion, "detener", HandlerDetenerPlanificacion)
+
+	kernelModulo.RegistrarRuta("GET /procesos", utils.MensajeListarProcesos, "default", datosListarProcesos)
+	kernelModulo.RegistrarRuta("POST /procesos", utils.MensajeCrearProceso, "default", utils.DatosCuerpo)
+	kernelModulo.RegistrarRuta("GET /procesos/{pid}", utils.MensajeConsultarProceso, "default", utils.DatosPID)
+	kernelModulo.RegistrarRuta("GET /procesos/{pid}/metricas", utils.MensajeMetricasProceso, "default", utils.DatosPID)
+	kernelModulo.RegistrarRuta("DELETE /procesos/{pid}", utils.MensajeFinalizarProceso, "default", utils.DatosPID)
+	kernelModulo.RegistrarRuta("GET /colas", utils.MensajeListarColas, "default", nil)
+	kernelModulo.RegistrarRuta("GET /planificacion", utils.MensajePlanificacion, "consultar", nil)
+	kernelModulo.RegistrarRuta("POST /planificacion/{planificador}/iniciar", utils.MensajePlanificacion, "iniciar", datosPlanificacion)
+	kernelModulo.RegistrarRuta("POST /planificacion/{planificador}/detener", utils.MensajePlanificacion, "detener", datosPlanificacion)
+
+	// Las notificaciones de IO solo llegan de los dispositivos, los latidos de CPUs e IOs
+	// y los pedidos del usuario (crear y finalizar procesos, pausar la planificación) por REST
+	kernelModulo.Usar(
+		utils.Recuperacion(),
+		utils.Temporizacion(umbralMensajeLento),
+		utils.Auditoria(),
+		utils.Autorizacion(utils.Permisos{
+			utils.MensajeOperacion:        {"IO"},
+			utils.MensajeLatido:           {"CPU", "IO"},
+			utils.MensajeFinalizarProceso: {utils.RolREST},
+			utils.MensajeCrearProceso:     {utils.RolREST},
+			utils.MensajePlanificacion:    {utils.RolREST},
+		}),
+	)
 
 	utils.InfoLog.Info("Handlers registrados correctamente")
 }
@@ -68,36 +193,47 @@ func registrarHandlers() {
 func conectarAMemoria(intentosMax int) error {
 	utils.InfoLog.Info("Conectando con Memoria", "intentos_max", intentosMax)
 
-	for i := 0; i < intentosMax; i++ {
-		err := memoriaClient.VerificarConexion()
-		if err == nil {
-			utils.InfoLog.Info("Conexión establecida con Memoria")
-			return nil
-		}
+	politica := utils.PoliticaConexion
+	politica.MaxIntentos = intentosMax
 
-		utils.InfoLog.Warn("Fallo al conectar con Memoria, reintentando", "intento", i+1, "error", err)
-		time.Sleep(3 * time.Second)
+	err := politica.Ejecutar(context.Background(), "conexión con Memoria", func(ctx context.Context) error {
+		return memoriaClient.VerificarConexion()
+	})
+	if err != nil {
+		return fmt.Errorf("no se pudo establecer conexión con Memoria: %w", err)
 	}
 
-	return fmt.Errorf("no se pudo establecer conexión después de %d intentos", intentosMax)
+	utils.InfoLog.Info("Conexión establecida con Memoria")
+	return nil
 }
 
 // crearYAdmitirProcesoInicial crea el PCB inicial y lo coloca en NEW
-func crearYAdmitirProcesoInicial(nombreArchivo string, tamanio int) {
-	utils.InfoLog.Info("Creando proceso inicial", "archivo", nombreArchivo, "tamaño", tamanio)
+func crearYAdmitirProcesoInicial(nombreArchivo string, tamanio int, prioridad int) {
+	utils.InfoLog.Info("Creando proceso inicial", "archivo", nombreArchivo, "tamaño", tamanio, "prioridad", prioridad)
+	crearProceso(nombreArchivo, tamanio, prioridad, "inicial")
+}
 
-	pcb := NuevoPCB(-1, tamanio) // Usar -1 para generar PID 0
+// crearProceso crea un PCB para el pseudocódigo y lo coloca en NEW. Lo usan el proceso
+// inicial, la API REST y la consola; INIT_PROC crea los suyos desde la syscall.
+func crearProceso(nombreArchivo string, tamanio int, prioridad int, origen string) *PCB {
+	pcb := NuevoPCB(-1, tamanio)
 	pcb.NombreArchivo = nombreArchivo
+	pcb.AsignarPrioridad(prioridad)
 
-	utils.InfoLog.Info("Proceso inicial creado", "pid", pcb.PID, "estado", "NEW")
+	utils.InfoLog.Info("Proceso creado", "pid", pcb.PID, "archivo", nombreArchivo, "tamaño", tamanio, "prioridad", prioridad, "origen", origen)
 	AgregarProcesoANew(pcb)
+	return pcb
 }
 
-// iniciarPlanificadores se llama después de presionar Enter
+// iniciarPlanificadores lanza los planificadores, que esperan detenidos hasta
+// INICIAR_PLANIFICACION (ver consola.go)
 func iniciarPlanificadores() {
 	utils.InfoLog.Info("Iniciando planificadores")
 	go PlanificarLargoPlazo()
 	go PlanificarCortoPlazo()
+	if kernelConfig.SchedulerAlgorithm == "PRIORIDADES" && kernelConfig.TiempoEnvejecimiento > 0 {
+		go envejecerProcesos(time.Duration(kernelConfig.TiempoEnvejecimiento) * time.Millisecond)
+	}
 	utils.InfoLog.Info("Planificadores iniciados")
 }
 
@@ -115,3 +251,89 @@ func GetMemoriaClient() *utils.HTTPClient {
 	}
 	return memoriaClient
 }
+
+// darDeBajaModulo quita la CPU o el dispositivo IO cuyo lease venció.
+// Vuelve a quedar disponible cuando repite el handshake.
+func darDeBajaModulo(clave string) {
+	if desregistrarCPU(clave) {
+		return
+	}
+	DesregistrarDispositivoIO(clave)
+}
+
+// cargaKernel informa en /health la cantidad de procesos en cada estado
+func cargaKernel() map[string]int {
+	carga := longitudColas()
+
+	cpuClientsMutex.Lock()
+	carga["CPUS"] = len(cpuClients)
+	cpuClientsMutex.Unlock()
+	dispositivosIOMutex.RLock()
+	carga["IOS"] = len(dispositivosIO)
+	dispositivosIOMutex.RUnlock()
+
+	return carga
+}
+
+// longitudColas devuelve cuántos procesos hay en cada estado
+func longitudColas() map[string]int {
+	carga := make(map[string]int)
+
+	newMutex.Lock()
+	carga[EstadoNew] = len(colaNew)
+	newMutex.Unlock()
+	readyMutex.Lock()
+	carga[EstadoReady] = procesosEnReady()
+	readyMutex.Unlock()
+	execMutex.Lock()
+	carga[EstadoExec] = len(colaExec)
+	execMutex.Unlock()
+	blockedMutex.Lock()
+	carga[EstadoBlocked] = len(colaBlocked)
+	blockedMutex.Unlock()
+	suspReadyMutex.Lock()
+	carga[EstadoSuspReady] = len(colaSuspReady)
+	suspReadyMutex.Unlock()
+	suspBlockedMutex.Lock()
+	carga[EstadoSuspBlocked] = len(colaSuspBlocked)
+	suspBlockedMutex.Unlock()
+
+	return carga
+}
+
+// imprimirMetricasFinales resume al detener el Kernel cuántos procesos se crearon,
+// cuántos terminaron y en qué estado quedaron los demás
+func imprimirMetricasFinales(ctx context.Context) error {
+	pidMutex.Lock()
+	creados := proximoPID
+	pidMutex.Unlock()
+
+	exitMutex.Lock()
+	finalizados := len(colaExit)
+	tiempoReady := 0.0
+	for _, pcb := range colaExit {
+		tiempoReady += pcb.TotalTiempoReady
+	}
+	exitMutex.Unlock()
+
+	esperaPromedio := 0.0
+	if finalizados > 0 {
+		esperaPromedio = tiempoReady / float64(finalizados)
+	}
+
+	carga := cargaKernel()
+	utils.InfoLog.Info("Métricas finales del Kernel",
+		"procesos_creados", creados,
+		"procesos_finalizados", finalizados,
+		"espera_promedio_ready_s", esperaPromedio,
+		"procesos_por_estado", carga)
+
+	fmt.Println("\n=== Métricas finales del Kernel ===")
+	fmt.Printf("Procesos creados: %d\n", creados)
+	fmt.Printf("Procesos finalizados: %d\n", finalizados)
+	fmt.Printf("Tiempo promedio en READY de los finalizados: %.2f s\n", esperaPromedio)
+	for _, estado := range []string{EstadoNew, EstadoReady, EstadoExec, EstadoBlocked, EstadoSuspReady, EstadoSuspBlocked} {
+		fmt.Printf("Procesos en %s: %d\n", estado, carga[estado])
+	}
+	return nil
+}
@@ -1,13 +1,10 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"os"
-	"os/signal"
 	"strconv"
-	"strings"
-	"syscall"
 
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
 )
@@ -18,32 +15,49 @@ func main() {
 
 	utils.InfoLog.Info("Kernel iniciando", "args", os.Args)
 
-	// Verificar argumentos mínimos
-	if len(os.Args) < 4 {
-		fmt.Fprintf(os.Stderr, "Uso: %s <archivo_configuracion> <archivo_pseudocódigo> <tamaño>\n", os.Args[0])
+	// Verificar argumentos mínimos. El proceso inicial es opcional: también se pueden
+	// crear procesos desde la consola o la API REST.
+	if len(os.Args) != 2 && len(os.Args) != 4 && len(os.Args) != 5 {
+		fmt.Fprintf(os.Stderr, "Uso: %s <archivo_configuracion|escenario> [<archivo_pseudocódigo> <tamaño> [<prioridad>]]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Ejemplo: %s configs/kernel-config-PlaniCortoFIFO scripts/PLANI_CORTO_PLAZO 0\n", os.Args[0])
 		os.Exit(1)
 	}
 
 	// Obtener parámetros
-	configPath := os.Args[1]                        // configs/kernel-config-PlaniCortoFIFO
-	nombreArchivoInicial := os.Args[2]              // scripts/PLANI_CORTO_PLAZO
-	tamanioInicial, err := strconv.Atoi(os.Args[3]) // 0
-	if err != nil {
-		utils.ErrorLog.Error("El tamaño del proceso inicial debe ser un número entero", "error", err, "valor", os.Args[3])
-		os.Exit(1)
+	configPath := os.Args[1] // configs/kernel-config-PlaniCortoFIFO
+	nombreArchivoInicial := ""
+	tamanioInicial := 0
+	prioridadInicial := 0
+	if len(os.Args) >= 4 {
+		nombreArchivoInicial = os.Args[2] // scripts/PLANI_CORTO_PLAZO
+		var err error
+		tamanioInicial, err = strconv.Atoi(os.Args[3]) // 0
+		if err != nil {
+			utils.ErrorLog.Error("El tamaño del proceso inicial debe ser un número entero", "error", err, "valor", os.Args[3])
+			os.Exit(1)
+		}
+	}
+	if len(os.Args) == 5 {
+		var err error
+		prioridadInicial, err = strconv.Atoi(os.Args[4])
+		if err != nil || prioridadInicial < 0 {
+			utils.ErrorLog.Error("La prioridad del proceso inicial debe ser un entero no negativo", "valor", os.Args[4])
+			os.Exit(1)
+		}
 	}
 
-	// Verificar que el archivo de configuración existe
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		utils.ErrorLog.Error("El archivo de configuración no existe", "archivo", configPath)
+	// Verificar que el archivo de configuración (o el escenario) existe
+	configPath, err := utils.ResolverConfiguracion(configPath)
+	if err != nil {
+		utils.ErrorLog.Error("El archivo de configuración no existe", "error", err)
 		os.Exit(1)
 	}
 
 	utils.InfoLog.Info("Parámetros procesados",
 		"config", configPath,
 		"script", nombreArchivoInicial,
-		"tamaño", tamanioInicial)
+		"tamaño", tamanioInicial,
+		"prioridad", prioridadInicial)
 
 	// Inicializar kernel
 	err = inicializarKernel(configPath)
@@ -53,28 +67,20 @@ func main() {
 	}
 
 	// Crear proceso inicial
-	crearYAdmitirProcesoInicial(nombreArchivoInicial, tamanioInicial)
+	if nombreArchivoInicial != "" {
+		crearYAdmitirProcesoInicial(nombreArchivoInicial, tamanioInicial, prioridadInicial)
+	}
 
 	utils.InfoLog.Info("Kernel listo y esperando conexiones")
 
-	// Esperar Enter para iniciar planificadores
-	fmt.Println("Presione ENTER para iniciar los planificadores...")
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(input)
-
-	utils.InfoLog.Info("Enter presionado, iniciando planificadores")
-	fmt.Println("Planificadores iniciados. Sistema funcionando...")
+	// Los planificadores arrancan detenidos hasta que se inician desde la consola.
+	// Mientras tanto Ctrl+C ya detiene el Kernel.
+	go ejecutarConsola()
 
-	iniciarPlanificadores()
-
-	// Configurar manejo de señales
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	// Esperar señal de terminación
-	<-sigChan
-	utils.InfoLog.Info("Ctrl+C recibido. Finalizando Kernel")
-	fmt.Println("\nKernel finalizando...")
-	os.Exit(0)
+	// Esperar señal de terminación y detener ordenadamente
+	err = kernelModulo.Ejecutar(context.Background(), utils.PlazoDetencionPorDefecto)
+	fmt.Println("\nKernel finalizado")
+	if err != nil {
+		os.Exit(1)
+	}
 }
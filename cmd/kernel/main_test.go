@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+)
+
+// TestMain descarta los logs del Kernel: los tests solo miran el estado de las colas y los PCB
+func TestMain(m *testing.M) {
+	descartar := slog.New(slog.NewTextHandler(io.Discard, nil))
+	slog.SetDefault(descartar)
+	utils.InfoLog = descartar
+	utils.ErrorLog = descartar
+	utils.ObligatorioLog = descartar
+	os.Exit(m.Run())
+}
+
+// configurarKernel usa config como configuración del Kernel durante el test, con las
+// colas de READY vacías
+func configurarKernel(t *testing.T, config KernelConfig) {
+	t.Helper()
+	anterior := kernelConfig
+	kernelConfig = &config
+	colaReady, colaReadyAux = nil, nil
+	t.Cleanup(func() {
+		kernelConfig = anterior
+		colaReady, colaReadyAux = nil, nil
+	})
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
 )
@@ -14,15 +16,25 @@ var (
 	dispositivosIOMutex sync.RWMutex
 	contadorBalanceador int
 	balanceadorMutex    sync.Mutex
+
+	// cancelacionesIO permite abortar la IO en curso de un proceso que se finaliza
+	cancelacionesIO      = make(map[int]*cancelacionIO)
+	cancelacionesIOMutex sync.Mutex
 )
 
+// cancelacionIO es la IO en curso de un proceso. Se guarda por puntero para que una
+// solicitud vieja no cancele la que la reemplazó en otro dispositivo.
+type cancelacionIO struct {
+	cancelar context.CancelFunc
+}
+
 // RegistrarDispositivoIO optimizado
 func RegistrarDispositivoIO(nombre string, ip string, puerto int) {
 	dispositivosIOMutex.Lock()
 	defer dispositivosIOMutex.Unlock()
 
 	if _, existe := dispositivosIO[nombre]; !existe {
-		dispositivosIO[nombre] = utils.NewHTTPClient(ip, puerto, "Kernel->"+nombre)
+		dispositivosIO[nombre] = nuevoCliente(ip, puerto, "Kernel->"+nombre, "IO")
 		utils.InfoLog.Info("Dispositivo IO registrado", "nombre", nombre, "ip", ip, "puerto", puerto)
 	}
 }
@@ -44,21 +56,94 @@ func EnviarSolicitudIO(pcb *PCB, dispositivo string, tiempo int) {
 	}
 
 	utils.InfoLog.Info("Enviando petición a IO", "pid", pcb.PID, "dispositivo", dispositivo)
+	sumarPendienteIO(dispositivo, 1)
+	defer sumarPendienteIO(dispositivo, -1)
 
-	datos := map[string]interface{}{
-		"pid":       pcb.PID,
-		"tiempo":    tiempo,
-		"operacion": "IO_REQUEST",
+	solicitud := utils.SolicitudIO{
+		PID:    pcb.PID,
+		Tiempo: tiempo,
 	}
 
-	_, err := cliente.EnviarHTTPOperacion("IO_REQUEST", datos)
+	// El dispositivo responde al terminar la IO: el plazo es el tiempo de IO más el timeout del destino
+	ctx, cancelar := context.WithTimeout(context.Background(), time.Duration(tiempo)*time.Millisecond+cliente.Timeout)
+	cancelacion := registrarCancelacionIO(pcb.PID, cancelar)
+	defer liberarCancelacionIO(pcb.PID, cancelacion)
 
-	// --- CAMBIO CLAVE Y DEFINITIVO ---
-	// Si hay un error de comunicación (ej: el IO está caído), finalizamos el proceso.
-	if err != nil {
+	_, err := utils.EnviarConContexto[utils.SolicitudIO, utils.RespuestaEstado](ctx, cliente, utils.MensajeOperacion, "IO_REQUEST", solicitud)
+
+	switch utils.CodigoDe(err) {
+	case "":
+		return
+	case utils.ErrorCancelado:
+		utils.InfoLog.Info("Solicitud de IO cancelada", "dispositivo", dispositivo, "pid", pcb.PID)
+	case utils.ErrorTimeout:
+		// El dispositivo no corta la IO por el plazo: el proceso queda bloqueado hasta recibir IO_TERMINADA
+		utils.InfoLog.Warn("Timeout esperando respuesta de IO, se espera su notificación", "dispositivo", dispositivo, "pid", pcb.PID)
+	case utils.ErrorNoListo:
+		// El dispositivo se está deteniendo: se lo da de baja y se intenta con otro
+		utils.InfoLog.Warn("Dispositivo IO deteniéndose, se reasigna la solicitud", "dispositivo", dispositivo, "pid", pcb.PID)
+		DesregistrarDispositivoIO(dispositivo)
+		go EnviarSolicitudIO(pcb, SeleccionarDispositivoIO(dispositivo, pcb.PID), tiempo)
+	case utils.ErrorRedCaida, utils.ErrorCircuitoAbierto:
+		// El dispositivo está caído: se lo da de baja y se finaliza el proceso
 		utils.ErrorLog.Error("Error de comunicación con dispositivo IO. El proceso será finalizado.", "dispositivo", dispositivo, "pid", pcb.PID, "error", err.Error())
+		DesregistrarDispositivoIO(dispositivo)
 		FinalizarProceso(pcb, "ERROR_IO_CONNECTION")
-		return // Importante: Salimos de la función aquí.
+	default:
+		utils.ErrorLog.Error("Dispositivo IO rechazó la solicitud. El proceso será finalizado.", "dispositivo", dispositivo, "pid", pcb.PID, "error", err.Error())
+		FinalizarProceso(pcb, "ERROR_IO_SOLICITUD_RECHAZADA")
+	}
+}
+
+// registrarCancelacionIO guarda la función que aborta la IO en curso de un proceso
+func registrarCancelacionIO(pid int, cancelar context.CancelFunc) *cancelacionIO {
+	cancelacionesIOMutex.Lock()
+	defer cancelacionesIOMutex.Unlock()
+	cancelacion := &cancelacionIO{cancelar: cancelar}
+	cancelacionesIO[pid] = cancelacion
+	return cancelacion
+}
+
+// liberarCancelacionIO libera el contexto de una solicitud de IO terminada, y la quita
+// del registro solo si sigue siendo la IO en curso del proceso
+func liberarCancelacionIO(pid int, cancelacion *cancelacionIO) {
+	cancelacionesIOMutex.Lock()
+	if cancelacionesIO[pid] == cancelacion {
+		delete(cancelacionesIO, pid)
+	}
+	cancelacionesIOMutex.Unlock()
+
+	cancelacion.cancelar()
+}
+
+// cancelarIO aborta la IO en curso de un proceso, si la hay
+func cancelarIO(pid int) {
+	cancelacionesIOMutex.Lock()
+	cancelacion, existe := cancelacionesIO[pid]
+	delete(cancelacionesIO, pid)
+	cancelacionesIOMutex.Unlock()
+
+	if existe {
+		cancelacion.cancelar()
+	}
+}
+
+// DesregistrarDispositivoIO elimina un dispositivo que dejó de responder
+func DesregistrarDispositivoIO(nombre string) {
+	dispositivosIOMutex.Lock()
+	defer dispositivosIOMutex.Unlock()
+
+	cliente, existe := dispositivosIO[nombre]
+	if !existe {
+		return
+	}
+
+	// Un mismo dispositivo puede estar registrado con nombre completo y simplificado
+	for otroNombre, otroCliente := range dispositivosIO {
+		if otroCliente.BaseURL == cliente.BaseURL {
+			delete(dispositivosIO, otroNombre)
+			utils.InfoLog.Warn("Dispositivo IO dado de baja", "nombre", otroNombre)
+		}
 	}
 }
 
@@ -79,96 +164,62 @@ func manejarCompletionIO(pcb *PCB) {
 }
 
 // ManejadorRegistroIO simplificado
-func ManejadorRegistroIO(origen string, datos map[string]interface{}) (interface{}, bool) {
-	tipoModulo, ok := datos["tipo"].(string)
-	if !ok || !strings.HasPrefix(tipoModulo, "IO") {
-		return nil, false
+func ManejadorRegistroIO(solicitud utils.SolicitudHandshake) (utils.RespuestaHandshake, bool, error) {
+	tipoModulo := solicitud.Tipo
+	if !strings.HasPrefix(tipoModulo, "IO") {
+		return utils.RespuestaHandshake{}, false, nil
 	}
 
-	ip, okIP := datos["ip"].(string)
-	puertoFloat, okPuerto := datos["puerto"].(float64)
-
-	if !okIP || !okPuerto {
-		return map[string]interface{}{
-			"status":  "ERROR",
-			"message": "Handshake incompleto",
-		}, true
+	if solicitud.IP == "" || solicitud.Puerto <= 0 {
+		return utils.RespuestaHandshake{}, true, utils.NuevoError(utils.ErrorEsquemaInvalido, "handshake incompleto de %s", tipoModulo)
 	}
 
 	// Registrar con nombre completo y simplificado
-	RegistrarDispositivoIO(tipoModulo, ip, int(puertoFloat))
+	RegistrarDispositivoIO(tipoModulo, solicitud.IP, solicitud.Puerto)
+	leases.Otorgar(solicitud.ClaveLease())
 
 	nombreSimplificado := strings.TrimPrefix(tipoModulo, "IO")
 	if nombreSimplificado != tipoModulo {
-		RegistrarDispositivoIO(nombreSimplificado, ip, int(puertoFloat))
+		RegistrarDispositivoIO(nombreSimplificado, solicitud.IP, solicitud.Puerto)
 		utils.InfoLog.Info("Dispositivo IO registrado", "completo", tipoModulo, "simple", nombreSimplificado)
 	} else {
 		utils.InfoLog.Info("Módulo IO registrado", "nombre", tipoModulo)
 	}
 
-	return map[string]interface{}{
-		"status":  "OK",
-		"message": fmt.Sprintf("IO '%s' registrado", tipoModulo),
-	}, true
+	return respuestaHandshake(fmt.Sprintf("IO '%s' registrado", tipoModulo)), true, nil
 }
 
 // ProcesarSolicitudIO optimizado
-func ProcesarSolicitudIO(datos map[string]interface{}) (interface{}, bool) {
-	evento, _ := datos["evento"].(string)
-	motivo, _ := datos["motivo_retorno"].(string)
-
-	if evento != "SOLICITUD_IO" && motivo != "IO_REQUEST" {
-		return nil, false
-	}
-
-	pidFloat, pidOk := datos["pid"].(float64)
-	if !pidOk {
-		return map[string]interface{}{"status": "ERROR", "mensaje": "PID inválido"}, true
-	}
-
-	dispositivo, _ := datos["dispositivo"].(string)
-	if dispositivo == "" {
-		dispositivo, _ = datos["nombre_dispositivo"].(string)
+func ProcesarSolicitudIO(notificacion utils.NotificacionKernel) (utils.RespuestaEstado, bool, error) {
+	if notificacion.Evento != utils.EventoSolicitudIO {
+		return utils.RespuestaEstado{}, false, nil
 	}
 
-	tiempoFloat, _ := datos["tiempo"].(float64)
-	if tiempoFloat == 0 {
-		tiempoFloat, _ = datos["tiempo_bloqueo"].(float64)
-	}
-
-	pcb := BuscarPCBPorPID(int(pidFloat))
+	pcb := BuscarPCBPorPID(notificacion.PID)
 	if pcb == nil {
-		return map[string]interface{}{"status": "ERROR", "mensaje": "Proceso no encontrado"}, true
+		return utils.RespuestaEstado{}, true, utils.NuevoError(utils.ErrorPIDInexistente, "proceso %d no encontrado", notificacion.PID)
 	}
 
-	dispositivoSeleccionado := SeleccionarDispositivoIO(dispositivo, pcb.PID)
+	dispositivoSeleccionado := SeleccionarDispositivoIO(notificacion.Dispositivo, pcb.PID)
 	MoverProcesoABlocked(pcb, fmt.Sprintf("IO_%s", dispositivoSeleccionado))
-	go EnviarSolicitudIO(pcb, dispositivoSeleccionado, int(tiempoFloat))
+	go EnviarSolicitudIO(pcb, dispositivoSeleccionado, notificacion.Tiempo)
 	go despacharProcesoSiCorresponde()
 
-	return map[string]interface{}{"status": "OK", "mensaje": "IO procesando"}, true
+	return utils.RespuestaOK("IO procesando"), true, nil
 }
 
 // ProcesarIOTerminada optimizado
-func ProcesarIOTerminada(datos map[string]interface{}) (interface{}, bool) {
-	evento, _ := datos["evento"].(string)
-	operacion, _ := datos["operacion"].(string)
-
-	if evento != "IO_TERMINADA" && operacion != "IO_COMPLETADA" {
-		return nil, false
+func ProcesarIOTerminada(notificacion utils.NotificacionKernel) (utils.RespuestaEstado, bool, error) {
+	if notificacion.Evento != utils.EventoIOTerminada {
+		return utils.RespuestaEstado{}, false, nil
 	}
 
-	pidFloat, pidOk := datos["pid"].(float64)
-	if !pidOk {
-		return map[string]interface{}{"status": "ERROR", "mensaje": "PID inválido"}, true
-	}
-
-	pcb := BuscarPCBPorPID(int(pidFloat))
+	pcb := BuscarPCBPorPID(notificacion.PID)
 	if pcb == nil {
-		return map[string]interface{}{"status": "ERROR", "mensaje": "Proceso no encontrado"}, true
+		return utils.RespuestaEstado{}, true, utils.NuevoError(utils.ErrorPIDInexistente, "proceso %d no encontrado", notificacion.PID)
 	}
 
-	utils.InfoLog.Info(fmt.Sprintf("(%d) - Finalizó IO y pasa a READY", pcb.PID))
+	utils.ObligatorioLog.Info(fmt.Sprintf("(%d) - Finalizó IO y pasa a READY", pcb.PID))
 	utils.InfoLog.Info("IO finalizada, proceso pasa a READY", "pid", pcb.PID)
 	pcb.PC++
 
@@ -186,7 +237,27 @@ func ProcesarIOTerminada(datos map[string]interface{}) (interface{}, bool) {
 		MoverProcesoASuspReady(pcb)
 	}
 
-	return map[string]interface{}{"status": "OK", "mensaje": "IO completada"}, true
+	return utils.RespuestaOK("IO completada"), true, nil
+}
+
+// ProcesarIOInterrumpida reasigna la IO de un proceso cuyo dispositivo se detuvo antes
+// de terminarla. Si no queda otro dispositivo el proceso se finaliza.
+func ProcesarIOInterrumpida(notificacion utils.NotificacionKernel) (utils.RespuestaEstado, bool, error) {
+	if notificacion.Evento != utils.EventoIOInterrumpida {
+		return utils.RespuestaEstado{}, false, nil
+	}
+
+	pcb := BuscarPCBPorPID(notificacion.PID)
+	if pcb == nil {
+		return utils.RespuestaEstado{}, true, utils.NuevoError(utils.ErrorPIDInexistente, "proceso %d no encontrado", notificacion.PID)
+	}
+
+	utils.InfoLog.Warn("IO interrumpida por detención del dispositivo", "pid", pcb.PID, "dispositivo", notificacion.Dispositivo, "tiempo_restante", notificacion.Tiempo)
+
+	dispositivoSeleccionado := SeleccionarDispositivoIO(notificacion.Dispositivo, pcb.PID)
+	go EnviarSolicitudIO(pcb, dispositivoSeleccionado, notificacion.Tiempo)
+
+	return utils.RespuestaOK("IO reasignada"), true, nil
 }
 
 // SeleccionarDispositivoIO implementa balanceador de carga
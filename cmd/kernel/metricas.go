@@ -0,0 +1,58 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+)
+
+// Métricas del Kernel expuestas en /metrics
+var (
+	despachos = utils.NuevoContador("kernel_despachos_total", "Procesos despachados a una CPU.", "cpu")
+	desalojos = utils.NuevoContador("kernel_desalojos_total", "Procesos desalojados de una CPU por el planificador.", "cpu")
+	// finesDeQuantum cuenta los desalojos pedidos por fin de quantum (RR y VRR)
+	finesDeQuantum = utils.NuevoContador("kernel_fin_quantum_total", "Desalojos por fin de quantum.", "cpu")
+
+	// pendientesIO cuenta las solicitudes enviadas a cada dispositivo que todavía no respondió
+	pendientesIO      = make(map[string]int)
+	pendientesIOMutex sync.Mutex
+)
+
+// registrarMetricas define los medidores que se calculan al consultar /metrics
+func registrarMetricas() {
+	utils.NuevoMedidor("kernel_procesos", "Procesos en cada estado.", func() []utils.Muestra {
+		colas := longitudColas()
+		estados := []string{EstadoNew, EstadoReady, EstadoExec, EstadoBlocked, EstadoSuspReady, EstadoSuspBlocked}
+		muestras := make([]utils.Muestra, 0, len(estados))
+		for _, estado := range estados {
+			muestras = append(muestras, utils.Muestra{Etiquetas: map[string]string{"estado": estado}, Valor: float64(colas[estado])})
+		}
+		return muestras
+	})
+
+	utils.NuevoMedidor("kernel_multiprogramacion", "Lugares de la multiprogramación ocupados y grado configurado.", func() []utils.Muestra {
+		return []utils.Muestra{
+			{Etiquetas: map[string]string{"tipo": "en_uso"}, Valor: float64(semaforoMultiprogram.EnUso())},
+			{Etiquetas: map[string]string{"tipo": "grado"}, Valor: float64(semaforoMultiprogram.Capacidad())},
+		}
+	})
+
+	utils.NuevoMedidor("kernel_io_pendientes", "Solicitudes de IO esperando respuesta de cada dispositivo.", func() []utils.Muestra {
+		pendientesIOMutex.Lock()
+		defer pendientesIOMutex.Unlock()
+		muestras := make([]utils.Muestra, 0, len(pendientesIO))
+		for _, dispositivo := range slices.Sorted(maps.Keys(pendientesIO)) {
+			muestras = append(muestras, utils.Muestra{Etiquetas: map[string]string{"dispositivo": dispositivo}, Valor: float64(pendientesIO[dispositivo])})
+		}
+		return muestras
+	})
+}
+
+// sumarPendienteIO registra una solicitud más (o menos) en curso en el dispositivo
+func sumarPendienteIO(dispositivo string, delta int) {
+	pendientesIOMutex.Lock()
+	defer pendientesIOMutex.Unlock()
+	pendientesIO[dispositivo] += delta
+}
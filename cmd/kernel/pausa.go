@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+)
+
+// pausaPlanificador detiene a un planificador antes de su próxima decisión. Las decisiones
+// ya tomadas no se deshacen: un proceso admitido sigue en memoria.
+type pausaPlanificador struct {
+	nombre string
+
+	mutex  sync.Mutex
+	activo bool
+	// reanudado se cierra al activar el planificador y se renueva al detenerlo
+	reanudado chan struct{}
+}
+
+// Los planificadores arrancan detenidos hasta INICIAR_PLANIFICACION
+var (
+	pausaLargoPlazo = nuevaPausa("largo plazo")
+	pausaCortoPlazo = nuevaPausa("corto plazo")
+)
+
+func nuevaPausa(nombre string) *pausaPlanificador {
+	return &pausaPlanificador{nombre: nombre, reanudado: make(chan struct{})}
+}
+
+// Activar deja decidir al planificador. Devuelve false si ya estaba activo.
+func (p *pausaPlanificador) Activar() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.activo {
+		return false
+	}
+	p.activo = true
+	close(p.reanudado)
+	utils.InfoLog.Info("Planificador activado", "planificador", p.nombre)
+	return true
+}
+
+// Detener frena al planificador antes de su próxima decisión. Devuelve false si ya estaba detenido.
+func (p *pausaPlanificador) Detener() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.activo {
+		return false
+	}
+	p.activo = false
+	p.reanudado = make(chan struct{})
+	utils.InfoLog.Info("Planificador detenido", "planificador", p.nombre)
+	return true
+}
+
+// Activo indica si el planificador está tomando decisiones
+func (p *pausaPlanificador) Activo() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.activo
+}
+
+// esperar bloquea mientras el planificador esté detenido. Devuelve el error de ctx si se
+// cancela antes de que se active.
+func (p *pausaPlanificador) esperar(ctx context.Context) error {
+	p.mutex.Lock()
+	reanudado := p.reanudado
+	activo := p.activo
+	p.mutex.Unlock()
+	if activo {
+		return nil
+	}
+
+	select {
+	case <-reanudado:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pausasDe devuelve las pausas del planificador pedido: largo, corto o todos
+func pausasDe(planificador string) []*pausaPlanificador {
+	switch planificador {
+	case utils.PlanificadorLargoPlazo:
+		return []*pausaPlanificador{pausaLargoPlazo}
+	case utils.PlanificadorCortoPlazo:
+		return []*pausaPlanificador{pausaCortoPlazo}
+	default:
+		return []*pausaPlanificador{pausaLargoPlazo, pausaCortoPlazo}
+	}
+}
+
+// iniciarPlanificacion activa los planificadores pedidos. Devuelve false si ya estaban activos.
+func iniciarPlanificacion(planificador string) bool {
+	cambio := false
+	for _, pausa := range pausasDe(planificador) {
+		cambio = pausa.Activar() || cambio
+	}
+	despertarLTS()
+	return cambio
+}
+
+// detenerPlanificacion detiene los planificadores pedidos. Devuelve false si ya estaban
+// detenidos. Al detener el de corto plazo se desalojan los procesos en EXEC: terminan la
+// instrucción en curso y vuelven a READY.
+func detenerPlanificacion(planificador string) bool {
+	cambio := false
+	for _, pausa := range pausasDe(planificador) {
+		if pausa.Detener() {
+			cambio = true
+			if pausa == pausaCortoPlazo {
+				desalojarProcesosEnExec()
+			}
+		}
+	}
+	// El LTS puede estar esperando que Memoria libere espacio: que vea la pausa
+	despertarLTS()
+	return cambio
+}
+
+// estadoPlanificacion describe qué planificadores están activos
+func estadoPlanificacion() string {
+	largo, corto := pausaLargoPlazo.Activo(), pausaCortoPlazo.Activo()
+	switch {
+	case largo && corto:
+		return "activa"
+	case largo:
+		return "activa solo en largo plazo"
+	case corto:
+		return "activa solo en corto plazo"
+	default:
+		return "detenida"
+	}
+}
+
+// respuestaPlanificacion arma el estado de los planificadores para la API
+func respuestaPlanificacion() utils.RespuestaPlanificacion {
+	return utils.RespuestaPlanificacion{
+		LargoPlazo: pausaLargoPlazo.Activo(),
+		CortoPlazo: pausaCortoPlazo.Activo(),
+		Estado:     estadoPlanificacion(),
+	}
+}
@@ -24,9 +24,12 @@ type PCB struct {
 	Tamanio                   int
 	PC                        int
 	EstimacionSiguienteRafaga float64
+	Prioridad                 int // PRIORIDADES: menor número, mayor prioridad
+	PrioridadBase             int // la asignada al crearlo; el envejecimiento solo dura una ráfaga
 
 	// Timestamps
 	HoraCreacion     time.Time
+	HoraAdmision     time.Time // primer paso de NEW a READY; no cambia en los siguientes
 	HoraListo        time.Time
 	HoraEjecucion    time.Time
 	HoraBloqueo      time.Time
@@ -40,12 +43,22 @@ type PCB struct {
 	MotivoBloqueo        string
 
 	// Tracking de estados para métricas
-	TotalReady        int
-	TotalTiempoReady  float64
-	InicioUltimoReady time.Time
+	TotalNew           int
+	TotalReady         int
+	TotalTiempoReady   float64
+	InicioUltimoReady  time.Time
+	TotalBlocked       int
+	TotalTiempoBlocked float64
 
 	// Flag para distinguir si el proceso está realmente en SWAP o ya fue cargado por IO
 	EnSwap bool
+
+	// Último aumento de prioridad por envejecimiento en READY
+	UltimoEnvejecimiento time.Time
+
+	// Quantum de la ráfaga en curso y el que le quedó al bloquearse (RR y VRR)
+	QuantumRafaga   time.Duration
+	QuantumRestante time.Duration
 }
 
 // NuevoPCB simplificado
@@ -68,6 +81,7 @@ func NuevoPCB(pid int, tamanio int) *PCB {
 		PC:                        0,
 		EstimacionSiguienteRafaga: estimacionInicial,
 		HoraCreacion:              horaActual,
+		TotalNew:                  1,
 		EnSwap:                    false, // Los procesos nuevos no están en SWAP
 	}
 
@@ -75,11 +89,17 @@ func NuevoPCB(pid int, tamanio int) *PCB {
 	mapaPCBs[pcb.PID] = pcb
 	mapaMutex.Unlock()
 
-	utils.InfoLog.Info(fmt.Sprintf("(%d) - Se crea el proceso - Estado: %s", pcb.PID, pcb.Estado))
+	utils.ObligatorioLog.Info(fmt.Sprintf("(%d) - Se crea el proceso - Estado: %s", pcb.PID, pcb.Estado))
 
 	return pcb
 }
 
+// AsignarPrioridad fija la prioridad con la que se crea el proceso
+func (pcb *PCB) AsignarPrioridad(prioridad int) {
+	pcb.Prioridad = prioridad
+	pcb.PrioridadBase = prioridad
+}
+
 // CambiarEstado optimizado
 func (pcb *PCB) CambiarEstado(nuevoEstado string) {
 	if pcb.Estado == nuevoEstado {
@@ -93,9 +113,15 @@ func (pcb *PCB) CambiarEstado(nuevoEstado string) {
 		tiempoEnReady := horaActual.Sub(pcb.InicioUltimoReady).Seconds()
 		pcb.TotalTiempoReady += tiempoEnReady
 	}
+	if estadoAnterior == EstadoBlocked && !pcb.HoraBloqueo.IsZero() {
+		pcb.TotalTiempoBlocked += horaActual.Sub(pcb.HoraBloqueo).Seconds()
+	}
 
 	// Manejar transiciones de ejecución
 	switch {
+	case estadoAnterior == EstadoNew && nuevoEstado == EstadoReady && pcb.HoraAdmision.IsZero():
+		pcb.HoraAdmision = horaActual
+
 	case estadoAnterior == EstadoReady && nuevoEstado == EstadoExec:
 		pcb.InicioUltimaRafaga = horaActual
 		pcb.HoraEjecucion = horaActual
@@ -111,18 +137,25 @@ func (pcb *PCB) CambiarEstado(nuevoEstado string) {
 
 	// Actualizar timestamps y contadores
 	switch nuevoEstado {
+	case EstadoNew:
+		pcb.TotalNew++
 	case EstadoReady:
 		pcb.HoraListo = horaActual
 		pcb.InicioUltimoReady = horaActual
 		pcb.TotalReady++
 	case EstadoBlocked:
 		pcb.HoraBloqueo = horaActual
+		pcb.TotalBlocked++
 	case EstadoExit:
 		pcb.HoraFinalizacion = horaActual
 	}
 
 	pcb.Estado = nuevoEstado
-	utils.InfoLog.Info(fmt.Sprintf("(%d) - Pasa del estado %s al estado %s", pcb.PID, estadoAnterior, nuevoEstado))
+	utils.ObligatorioLog.Info(fmt.Sprintf("(%d) - Pasa del estado %s al estado %s", pcb.PID, estadoAnterior, nuevoEstado))
+	kernelModulo.Publicar(utils.TemaEstadoProceso, utils.DatosEstadoProceso{PID: pcb.PID, Anterior: estadoAnterior, Nuevo: nuevoEstado})
+	if evento := eventoWebhook(estadoAnterior, nuevoEstado); evento != "" {
+		notificarWebhooks(pcb, evento, estadoAnterior, nuevoEstado, "", nil)
+	}
 }
 
 // actualizarEstimacion simplificada
@@ -144,28 +177,50 @@ func (pcb *PCB) String() string {
 		pcb.PID, pcb.Estado, pcb.Tamanio, pcb.PC)
 }
 
-// CalcularMetricas optimizado
-func (pcb *PCB) CalcularMetricas() {
-	// Si el proceso está saliendo de READY al finalizar, acumular el último tiempo
-	if pcb.Estado == EstadoReady && !pcb.InicioUltimoReady.IsZero() {
-		tiempoEnReady := time.Now().Sub(pcb.InicioUltimoReady).Seconds()
-		pcb.TotalTiempoReady += tiempoEnReady
-	}
+// CalcularMetricas calcula las métricas de estado y las deja en el log obligatorio
+func (pcb *PCB) CalcularMetricas() utils.MetricasProceso {
+	metricas := pcb.metricasEstado(time.Now())
+
+	utils.ObligatorioLog.Info(fmt.Sprintf("(%d) - Métricas de estado: NEW (%d)(%.2f), READY (%d)(%.2f), EXEC (%d)(%.2f), BLOCKED (%d)(%.2f)",
+		pcb.PID, metricas.VecesNew, metricas.SegundosNew, metricas.VecesReady, metricas.SegundosReady,
+		metricas.VecesExec, metricas.SegundosExec, metricas.VecesBlocked, metricas.SegundosBlocked))
+
+	return metricas
+}
 
+// metricasEstado calcula las métricas de estado hasta ahora sin modificar el PCB; en un
+// proceso que no finalizó cuenta también el tiempo que lleva en su estado actual
+func (pcb *PCB) metricasEstado(ahora time.Time) utils.MetricasProceso {
 	tiempoNew := 0.0
-	if pcb.HoraListo.After(pcb.HoraCreacion) {
-		tiempoNew = pcb.HoraListo.Sub(pcb.HoraCreacion).Seconds()
+	if !pcb.HoraAdmision.IsZero() {
+		tiempoNew = pcb.HoraAdmision.Sub(pcb.HoraCreacion).Seconds()
+	} else if pcb.Estado == EstadoNew {
+		tiempoNew = ahora.Sub(pcb.HoraCreacion).Seconds()
 	}
 
 	tiempoReady := pcb.TotalTiempoReady
+	if pcb.Estado == EstadoReady && !pcb.InicioUltimoReady.IsZero() {
+		tiempoReady += ahora.Sub(pcb.InicioUltimoReady).Seconds()
+	}
 
 	tiempoExec := pcb.TotalTiempoEjecucion / 1000.0
+	if pcb.Estado == EstadoExec && !pcb.InicioUltimaRafaga.IsZero() {
+		tiempoExec += ahora.Sub(pcb.InicioUltimaRafaga).Seconds()
+	}
 
-	tiempoBlocked := 0.0
-	if !pcb.HoraBloqueo.IsZero() && !pcb.HoraFinalizacion.IsZero() {
-		tiempoBlocked = pcb.HoraFinalizacion.Sub(pcb.HoraBloqueo).Seconds()
+	tiempoBlocked := pcb.TotalTiempoBlocked
+	if pcb.Estado == EstadoBlocked && !pcb.HoraBloqueo.IsZero() {
+		tiempoBlocked += ahora.Sub(pcb.HoraBloqueo).Seconds()
 	}
 
-	utils.InfoLog.Info(fmt.Sprintf("(%d) - Métricas de estado: NEW (%d)(%.2f), READY (%d)(%.2f), EXEC (%d)(%.2f), BLOCKED (%d)(%.2f)",
-		pcb.PID, 1, tiempoNew, pcb.TotalReady, tiempoReady, pcb.TotalEjecuciones, tiempoExec, 1, tiempoBlocked))
+	return utils.MetricasProceso{
+		VecesNew:        pcb.TotalNew,
+		SegundosNew:     tiempoNew,
+		VecesReady:      pcb.TotalReady,
+		SegundosReady:   tiempoReady,
+		VecesExec:       pcb.TotalEjecuciones,
+		SegundosExec:    tiempoExec,
+		VecesBlocked:    pcb.TotalBlocked,
+		SegundosBlocked: tiempoBlocked,
+	}
 }
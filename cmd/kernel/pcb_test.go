@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricasEstado(t *testing.T) {
+	creacion := time.Now()
+	segundos := func(s float64) time.Time {
+		return creacion.Add(time.Duration(s * float64(time.Second)))
+	}
+
+	casos := []struct {
+		nombre  string
+		pcb     PCB
+		ahora   float64
+		new     float64
+		ready   float64
+		blocked float64
+	}{
+		{
+			nombre: "en NEW cuenta hasta ahora",
+			pcb:    PCB{Estado: EstadoNew},
+			ahora:  2,
+			new:    2,
+		},
+		{
+			nombre: "NEW termina en la primera admisión aunque vuelva a READY",
+			pcb: PCB{Estado: EstadoReady, HoraAdmision: segundos(1), HoraListo: segundos(5),
+				InicioUltimoReady: segundos(5), TotalTiempoReady: 1},
+			ahora: 6,
+			new:   1,
+			ready: 2,
+		},
+		{
+			nombre: "BLOCKED suma el bloqueo en curso",
+			pcb: PCB{Estado: EstadoBlocked, HoraAdmision: segundos(0.5), HoraListo: segundos(2),
+				HoraBloqueo: segundos(4), TotalTiempoBlocked: 1.5},
+			ahora:   6,
+			new:     0.5,
+			blocked: 3.5,
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			caso.pcb.HoraCreacion = creacion
+			metricas := caso.pcb.metricasEstado(segundos(caso.ahora))
+
+			obtenido := []float64{metricas.SegundosNew, metricas.SegundosReady, metricas.SegundosBlocked}
+			esperado := []float64{caso.new, caso.ready, caso.blocked}
+			for i, estado := range []string{EstadoNew, EstadoReady, EstadoBlocked} {
+				if diferencia := obtenido[i] - esperado[i]; diferencia < -0.001 || diferencia > 0.001 {
+					t.Errorf("%s: %.3f segundos, se esperaban %.3f", estado, obtenido[i], esperado[i])
+				}
+			}
+		})
+	}
+}
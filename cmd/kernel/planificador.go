@@ -35,11 +35,19 @@ var (
 	condNew   *sync.Cond
 	condReady *sync.Cond
 
+	// liberacionesMemoria cuenta las veces que Memoria liberó espacio, confirmadas o por
+	// evento (protegida por newMutex)
+	liberacionesMemoria int
+
 	mapaPCBs               map[int]*PCB = make(map[int]*PCB)
 	gradoMultiprogramacion int
 	semaforoMultiprogram   *utils.Semaforo
 	timersSuspension       map[int]*time.Timer
 	timersMutex            sync.Mutex
+
+	// finalizacionesPendientes son los procesos en EXEC que se pidió finalizar
+	finalizacionesPendientes = make(map[int]string)
+	finalizacionesMutex      sync.Mutex
 )
 
 // InicializarPlanificador optimizado
@@ -57,6 +65,7 @@ func InicializarPlanificador(config *KernelConfig) {
 	utils.InfoLog.Info("Planificador inicializado",
 		"algoritmo_sts", config.SchedulerAlgorithm,
 		"algoritmo_lts", config.ReadyIngressAlgorithm,
+		"quantum_ms", config.Quantum,
 		"multiprogramacion", gradoMultiprogramacion)
 }
 
@@ -76,8 +85,17 @@ func BuscarPCBPorPID(pid int) *PCB {
 	return mapaPCBs[pid]
 }
 
+// cambiarMultiprogramacion ajusta el grado de multiprogramación en ejecución. Si baja
+// de los procesos que ya están en memoria, no se admiten nuevos hasta que terminen los suficientes.
+func cambiarMultiprogramacion(grado int) {
+	anterior := semaforoMultiprogram.Capacidad()
+	semaforoMultiprogram.Resize(grado)
+	utils.InfoLog.Info("Grado de multiprogramación cambiado", "anterior", anterior, "nuevo", grado, "en_uso", semaforoMultiprogram.EnUso())
+}
+
 // AgregarProcesoANew optimizado
 func AgregarProcesoANew(pcb *PCB) {
+	notificarWebhooks(pcb, WebhookCreado, "", EstadoNew, "", nil)
 	newMutex.Lock()
 	colaNew = append(colaNew, pcb)
 	newMutex.Unlock()
@@ -110,10 +128,13 @@ func MoverProcesoAReady(pcb *PCB) {
 	}
 	timersMutex.Unlock()
 
+	if pcb.Estado == EstadoExec {
+		restaurarPrioridad(pcb)
+	}
 	pcb.CambiarEstado(EstadoReady)
 
 	readyMutex.Lock()
-	colaReady = append(colaReady, pcb)
+	encolarEnReady(pcb)
 	readyMutex.Unlock()
 	condReady.Signal()
 }
@@ -143,7 +164,7 @@ func MoverProcesoASuspReady(pcb *PCB) {
 	suspReadyMutex.Unlock()
 
 	// Señalizar al LTS que hay procesos en SUSP.READY disponibles para admisión
-	condNew.Signal()
+	despertarLTS()
 
 	utils.InfoLog.Info("Timer de suspensión cancelado - proceso terminó IO", "pid", pcb.PID)
 	utils.InfoLog.Info("Proceso movido de SUSP.BLOCKED -> SUSP.READY", "pid", pcb.PID)
@@ -162,6 +183,8 @@ func MoverProcesoABlocked(pcb *PCB, motivo string) {
 
 	pcb.MotivoBloqueo = motivo
 	pcb.CambiarEstado(EstadoBlocked)
+	guardarQuantumRestante(pcb)
+	restaurarPrioridad(pcb)
 
 	// Log específico para bloqueo por IO
 	if motivo != "" && (motivo[:3] == "IO_" || motivo == "DUMP_MEMORY") {
@@ -169,7 +192,7 @@ func MoverProcesoABlocked(pcb *PCB, motivo string) {
 		if motivo[:3] == "IO_" {
 			dispositivoNombre = motivo[3:] // Remover "IO_" del prefijo
 		}
-		utils.InfoLog.Info(fmt.Sprintf("(%d) - Bloqueado por IO: %s", pcb.PID, dispositivoNombre))
+		utils.ObligatorioLog.Info(fmt.Sprintf("(%d) - Bloqueado por IO: %s", pcb.PID, dispositivoNombre))
 	}
 
 	utils.InfoLog.Info("Proceso bloqueado", "pid", pcb.PID, "motivo", motivo)
@@ -226,13 +249,40 @@ func suspenderProceso(pid int) {
 
 	pcb.CambiarEstado(EstadoSuspBlocked)
 	pcb.EnSwap = true // Marcar que el proceso estará en SWAP
+	// Al volver de la suspensión entra por READY con el quantum completo
+	pcb.QuantumRestante = 0
 
 	suspBlockedMutex.Lock()
 	colaSuspBlocked = append(colaSuspBlocked, pcb)
 	suspBlockedMutex.Unlock()
 
-	go notificarSwapAMemoria(pcb.PID) // La función notificarSwapAMemoria ya la tienes bien.
-	semaforoMultiprogram.Signal()
+	notificarSwapAMemoria(pcb.PID) // La función notificarSwapAMemoria ya la tienes bien.
+	semaforoMultiprogram.Release(1)
+}
+
+// SolicitarFinalizacion finaliza un proceso desde fuera del ciclo de planificación. Si
+// está en EXEC se interrumpe su CPU y se finaliza cuando ésta lo devuelve, para que la
+// CPU no reciba otro proceso mientras sigue ejecutando el anterior.
+func SolicitarFinalizacion(pcb *PCB, motivo string) {
+	if pcb.Estado != EstadoExec {
+		FinalizarProceso(pcb, motivo)
+		return
+	}
+
+	finalizacionesMutex.Lock()
+	finalizacionesPendientes[pcb.PID] = motivo
+	finalizacionesMutex.Unlock()
+	go desalojarProcesoActual(pcb)
+}
+
+// tomarFinalizacionPendiente devuelve el motivo si se pidió finalizar el proceso
+// mientras estaba en EXEC, y olvida el pedido
+func tomarFinalizacionPendiente(pid int) (string, bool) {
+	finalizacionesMutex.Lock()
+	defer finalizacionesMutex.Unlock()
+	motivo, pendiente := finalizacionesPendientes[pid]
+	delete(finalizacionesPendientes, pid)
+	return motivo, pendiente
 }
 
 // FinalizarProceso optimizado
@@ -254,6 +304,10 @@ func FinalizarProceso(pcb *PCB, motivo string) {
 	}
 	timersMutex.Unlock()
 
+	// Abortar la IO en curso, el dispositivo no debe notificar su fin
+	cancelarIO(pcb.PID)
+	tomarFinalizacionPendiente(pcb.PID)
+
 	// Remover de cola actual
 	fueRemovido := false
 	switch estadoPrevio {
@@ -289,15 +343,16 @@ func FinalizarProceso(pcb *PCB, motivo string) {
 
 	// Liberar multiprogramación
 	if estadoPrevio == EstadoReady || estadoPrevio == EstadoExec || estadoPrevio == EstadoBlocked {
-		semaforoMultiprogram.Signal()
+		semaforoMultiprogram.Release(1)
 	}
 
 	go notificarFinalizacionAMemoria(pcb.PID)
 
 	if estadoPrevio != EstadoExit {
-		utils.InfoLog.Info(fmt.Sprintf("(%d) - Finaliza el proceso", pcb.PID))
+		utils.ObligatorioLog.Info(fmt.Sprintf("(%d) - Finaliza el proceso", pcb.PID))
 		utils.InfoLog.Info("Proceso finalizado", "pid", pcb.PID, "motivo", motivo)
-		pcb.CalcularMetricas()
+		metricas := pcb.CalcularMetricas()
+		notificarWebhooks(pcb, WebhookFinalizado, estadoPrevio, EstadoExit, motivo, &metricas)
 	}
 
 	mapaMutex.Lock()
@@ -316,21 +371,21 @@ func notificarFinalizacionAMemoria(pid int) {
 		return
 	}
 
-	datos := map[string]interface{}{
-		"pid": pid,
-	}
+	solicitud := utils.SolicitudPID{PID: pid}
 
-	_, err := cliente.EnviarHTTPMensaje(utils.MensajeFinalizarProceso, "default", datos)
+	_, err := utils.Enviar[utils.SolicitudPID, utils.RespuestaEstado](cliente, utils.MensajeFinalizarProceso, "default", solicitud)
 	if err != nil {
 		utils.ErrorLog.Error("Error notificando finalización a Memoria", "pid", pid, "error", err.Error())
+		return
 	}
+	notificarMemoriaLiberada()
 }
 
 // Funciones auxiliares optimizadas
 func removerDeReady(pcb *PCB) bool {
 	readyMutex.Lock()
 	defer readyMutex.Unlock()
-	return removerDeCola(&colaReady, pcb)
+	return quitarDeReady(pcb)
 }
 
 func removerDeBlocked(pcb *PCB) bool {
@@ -383,15 +438,7 @@ func despacharProcesoSiCorresponde() {
 	condReady.Signal()
 }
 
+// notificarSwapAMemoria pide a Memoria que pase el proceso a SWAP, en el próximo lote de swaps
 func notificarSwapAMemoria(pid int) {
-	cliente := GetMemoriaClient()
-	if cliente == nil {
-		utils.ErrorLog.Error("No se pudo obtener cliente de memoria para swap", "pid", pid)
-		return
-	}
-
-	datos := map[string]interface{}{
-		"pid": pid,
-	}
-	cliente.EnviarHTTPMensaje(utils.MensajeSuspenderProceso, "default", datos)
+	pedirSwap(utils.MensajeSuspenderProceso, pid)
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+)
+
+// Con PRIORIDADES el menor número es la mayor prioridad; 0 es la máxima y la de los
+// procesos que no indican otra.
+
+// desalojosPedidos son los procesos en EXEC a los que ya se les pidió desalojar por
+// prioridad, para no interrumpirlos dos veces (protegido por execMutex)
+var desalojosPedidos = make(map[int]bool)
+
+// seleccionarPrioridades elige el proceso de READY de mayor prioridad; a igual prioridad,
+// el que está primero en la cola. Si no hay CPU libre desaloja al proceso en EXEC de menor
+// prioridad que el elegido, si lo hay, y devuelve nil: el STS vuelve a elegir cuando se
+// libere la CPU.
+func seleccionarPrioridades() *PCB {
+	if len(colaReady) == 0 {
+		return nil
+	}
+
+	elegido := colaReady[0]
+	for _, pcb := range colaReady[1:] {
+		if pcb.Prioridad < elegido.Prioridad {
+			elegido = pcb
+		}
+	}
+	if hayCPULibre() {
+		return elegido
+	}
+
+	execMutex.Lock()
+	var desalojar *PCB
+	for _, pcbEnExec := range colaExec {
+		if pcbEnExec.Prioridad <= elegido.Prioridad || desalojosPedidos[pcbEnExec.PID] {
+			continue
+		}
+		if desalojar == nil || pcbEnExec.Prioridad > desalojar.Prioridad {
+			desalojar = pcbEnExec
+		}
+	}
+	if desalojar != nil {
+		desalojosPedidos[desalojar.PID] = true
+	}
+	execMutex.Unlock()
+
+	if desalojar != nil {
+		utils.ObligatorioLog.Info(fmt.Sprintf("(%d) - Desalojado por algoritmo PRIORIDADES", desalojar.PID))
+		utils.InfoLog.Info("Desalojando proceso por prioridad", "desalojado", desalojar.PID, "prioridad_desalojado", desalojar.Prioridad,
+			"nuevo", elegido.PID, "prioridad_nuevo", elegido.Prioridad)
+		go desalojarProcesoActual(desalojar)
+	}
+	return nil
+}
+
+// restaurarPrioridad devuelve al proceso que sale de EXEC a la prioridad con la que se
+// creó: lo ganado por envejecimiento le da una ráfaga, no la CPU indefinidamente
+func restaurarPrioridad(pcb *PCB) {
+	pcb.Prioridad = pcb.PrioridadBase
+}
+
+// hayCPULibre indica si alguna CPU registrada no tiene proceso asignado
+func hayCPULibre() bool {
+	cpuClientsMutex.Lock()
+	defer cpuClientsMutex.Unlock()
+	execMutex.Lock()
+	defer execMutex.Unlock()
+
+	for nombre := range cpuClients {
+		if _, ocupada := colaExec[nombre]; !ocupada {
+			return true
+		}
+	}
+	return false
+}
+
+// envejecerProcesos sube un nivel la prioridad de los procesos que llevan intervalo
+// esperando en READY desde que llegaron o desde su último envejecimiento, para que los de
+// baja prioridad no esperen indefinidamente. Con la planificación de corto plazo detenida
+// no se envejece.
+func envejecerProcesos(intervalo time.Duration) {
+	periodo := intervalo / 4
+	if periodo < 10*time.Millisecond {
+		periodo = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(periodo)
+	defer ticker.Stop()
+
+	utils.InfoLog.Info("Envejecimiento de prioridades habilitado", "intervalo_ms", intervalo.Milliseconds())
+	for {
+		select {
+		case <-kernelModulo.Contexto().Done():
+			return
+		case ahora := <-ticker.C:
+			if !pausaCortoPlazo.Activo() {
+				continue
+			}
+			envejecerReady(ahora, intervalo)
+		}
+	}
+}
+
+// envejecerReady hace una pasada de envejecimiento sobre READY a la hora ahora
+func envejecerReady(ahora time.Time, intervalo time.Duration) {
+	readyMutex.Lock()
+	defer readyMutex.Unlock()
+
+	for _, pcb := range colaReady {
+		desde := pcb.HoraListo
+		if pcb.UltimoEnvejecimiento.After(desde) {
+			desde = pcb.UltimoEnvejecimiento
+		}
+		if pcb.Prioridad > 0 && ahora.Sub(desde) >= intervalo {
+			pcb.Prioridad--
+			pcb.UltimoEnvejecimiento = ahora
+			utils.InfoLog.Info("Prioridad aumentada por envejecimiento", "pid", pcb.PID, "prioridad", pcb.Prioridad)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+)
+
+// procesoPrueba es un proceso en READY: su prioridad y hace cuántos intervalos llegó
+type procesoPrueba struct {
+	pid       int
+	prioridad int
+	espera    int
+}
+
+func TestSeleccionarPrioridades(t *testing.T) {
+	const intervalo = 100 * time.Millisecond
+
+	casos := []struct {
+		nombre      string
+		procesos    []procesoPrueba // en el orden de la cola
+		pasadas     int             // pasadas de envejecimiento, una por intervalo
+		elegido     int
+		prioridades []int
+	}{
+		{
+			nombre:      "gana la menor prioridad numérica",
+			procesos:    []procesoPrueba{{1, 3, 0}, {2, 1, 0}},
+			elegido:     2,
+			prioridades: []int{3, 1},
+		},
+		{
+			nombre:      "a igual prioridad, el primero de la cola",
+			procesos:    []procesoPrueba{{1, 2, 0}, {2, 2, 0}},
+			elegido:     1,
+			prioridades: []int{2, 2},
+		},
+		{
+			nombre:      "sin esperar un intervalo no envejece",
+			procesos:    []procesoPrueba{{1, 2, 0}, {2, 1, 0}},
+			pasadas:     1,
+			elegido:     2,
+			prioridades: []int{2, 1},
+		},
+		{
+			nombre:      "el envejecimiento alcanza al de mayor prioridad",
+			procesos:    []procesoPrueba{{1, 2, 1}, {2, 1, 0}},
+			pasadas:     1,
+			elegido:     1,
+			prioridades: []int{1, 1},
+		},
+		{
+			nombre:      "sube un nivel por intervalo",
+			procesos:    []procesoPrueba{{1, 5, 1}, {2, 2, 0}},
+			pasadas:     3,
+			elegido:     2,
+			prioridades: []int{2, 0},
+		},
+		{
+			nombre:      "no sube más allá de 0",
+			procesos:    []procesoPrueba{{1, 1, 5}, {2, 0, 0}},
+			pasadas:     4,
+			elegido:     1,
+			prioridades: []int{0, 0},
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			configurarKernel(t, KernelConfig{SchedulerAlgorithm: "PRIORIDADES", TiempoEnvejecimiento: int(intervalo.Milliseconds())})
+			conCPULibre(t)
+
+			inicio := time.Now()
+			for _, proceso := range caso.procesos {
+				pcb := &PCB{PID: proceso.pid, HoraListo: inicio.Add(-time.Duration(proceso.espera) * intervalo)}
+				pcb.AsignarPrioridad(proceso.prioridad)
+				colaReady = append(colaReady, pcb)
+			}
+
+			for pasada := 0; pasada < caso.pasadas; pasada++ {
+				envejecerReady(inicio.Add(time.Duration(pasada)*intervalo), intervalo)
+			}
+
+			elegido := seleccionarPrioridades()
+			if elegido == nil || elegido.PID != caso.elegido {
+				t.Fatalf("se eligió %v, se esperaba el PID %d", elegido, caso.elegido)
+			}
+			for i, pcb := range colaReady {
+				if pcb.Prioridad != caso.prioridades[i] {
+					t.Errorf("PID %d con prioridad %d, se esperaba %d", pcb.PID, pcb.Prioridad, caso.prioridades[i])
+				}
+			}
+
+			// Lo ganado por envejecimiento dura una ráfaga
+			restaurarPrioridad(elegido)
+			if elegido.Prioridad != caso.procesos[caso.elegido-1].prioridad {
+				t.Fatalf("al salir de EXEC quedó con prioridad %d", elegido.Prioridad)
+			}
+		})
+	}
+}
+
+// conCPULibre registra una CPU sin proceso asignado durante el test
+func conCPULibre(t *testing.T) {
+	t.Helper()
+	anteriores := cpuClients
+	cpuClients = map[string]*utils.HTTPClient{"CPU1": nil}
+	t.Cleanup(func() { cpuClients = anteriores })
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+)
+
+// colaReadyAux es la cola auxiliar de VRR: los procesos que se bloquearon antes de agotar
+// su quantum vuelven a ella y se despachan antes que los de READY (protegida por readyMutex)
+var colaReadyAux []*PCB = []*PCB{}
+
+// usaQuantum indica si el algoritmo de corto plazo desaloja por fin de quantum
+func usaQuantum() bool {
+	return kernelConfig.SchedulerAlgorithm == "RR" || kernelConfig.SchedulerAlgorithm == "VRR"
+}
+
+// quantumDeRafaga devuelve el quantum de la ráfaga que empieza: lo que le quedaba si viene
+// de la cola auxiliar de VRR, o QUANTUM completo
+func quantumDeRafaga(pcb *PCB) time.Duration {
+	quantum := time.Duration(kernelConfig.Quantum) * time.Millisecond
+	if pcb.QuantumRestante > 0 && pcb.QuantumRestante < quantum {
+		quantum = pcb.QuantumRestante
+	}
+	pcb.QuantumRestante = 0
+	return quantum
+}
+
+// iniciarQuantum arma el timer de la ráfaga del proceso en la CPU. Al vencer se interrumpe
+// la CPU y el proceso vuelve a READY cuando termina la instrucción en curso. Un vencimiento
+// de un despacho anterior se ignora: el proceso pudo bloquearse y volver a despacharse antes
+// de que se detenga el timer. Devuelve la función que lo cancela al terminar la ráfaga.
+func iniciarQuantum(pcb *PCB, nombreCPU string, despacho uint64) func() {
+	quantum := quantumDeRafaga(pcb)
+	pcb.QuantumRafaga = quantum
+	utils.InfoLog.Info("Quantum iniciado", "pid", pcb.PID, "cpu", nombreCPU, "quantum_ms", quantum.Milliseconds())
+
+	timer := time.AfterFunc(quantum, func() {
+		if !despachoVigente(nombreCPU, pcb, despacho) {
+			return
+		}
+		utils.ObligatorioLog.Info(fmt.Sprintf("(%d) - Desalojado por fin de quantum", pcb.PID))
+		finesDeQuantum.Incrementar(nombreCPU)
+		desalojarProcesoActual(pcb)
+	})
+
+	return func() {
+		timer.Stop()
+	}
+}
+
+// guardarQuantumRestante recuerda, en VRR, cuánto quantum le quedaba al proceso que se
+// bloqueó, para que vuelva por la cola auxiliar. Se llama al salir de EXEC.
+func guardarQuantumRestante(pcb *PCB) {
+	if kernelConfig.SchedulerAlgorithm != "VRR" {
+		return
+	}
+	usado := time.Duration(pcb.UltimaRafagaReal * float64(time.Millisecond))
+	if restante := pcb.QuantumRafaga - usado; restante > 0 {
+		pcb.QuantumRestante = restante
+		utils.InfoLog.Info("Quantum restante guardado", "pid", pcb.PID, "restante_ms", restante.Milliseconds())
+	}
+}
+
+// encolarEnReady agrega el proceso a la cola auxiliar si le quedó quantum, o a READY.
+// Debe llamarse con readyMutex tomado.
+func encolarEnReady(pcb *PCB) {
+	if pcb.QuantumRestante > 0 {
+		colaReadyAux = append(colaReadyAux, pcb)
+		return
+	}
+	colaReady = append(colaReady, pcb)
+}
+
+// quitarDeReady saca al proceso de la cola auxiliar o de READY. Debe llamarse con readyMutex tomado.
+func quitarDeReady(pcb *PCB) bool {
+	return removerDeCola(&colaReadyAux, pcb) || removerDeCola(&colaReady, pcb)
+}
+
+// procesosEnReady cuenta los procesos de READY y de la cola auxiliar. Debe llamarse con readyMutex tomado.
+func procesosEnReady() int {
+	return len(colaReadyAux) + len(colaReady)
+}
+
+// pidsReady lista los PIDs en READY en el orden en que se despachan: primero la cola auxiliar
+func pidsReady() []int {
+	readyMutex.Lock()
+	defer readyMutex.Unlock()
+	pids := make([]int, 0, procesosEnReady())
+	for _, pcb := range colaReadyAux {
+		pids = append(pids, pcb.PID)
+	}
+	for _, pcb := range colaReady {
+		pids = append(pids, pcb.PID)
+	}
+	return pids
+}
+
+// seleccionarRR elige por orden de llegada; en VRR la cola auxiliar tiene prioridad
+func seleccionarRR() *PCB {
+	if len(colaReadyAux) > 0 {
+		return colaReadyAux[0]
+	}
+	return seleccionarFIFO()
+}
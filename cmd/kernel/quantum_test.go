@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuantumDeRafaga(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		restante time.Duration
+		esperado time.Duration
+	}{
+		{"ráfaga nueva usa QUANTUM completo", 0, 100 * time.Millisecond},
+		{"vuelve de la cola auxiliar con lo que le quedaba", 30 * time.Millisecond, 30 * time.Millisecond},
+		{"un restante mayor que QUANTUM se acota", 150 * time.Millisecond, 100 * time.Millisecond},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			configurarKernel(t, KernelConfig{SchedulerAlgorithm: "VRR", Quantum: 100})
+			pcb := &PCB{PID: 1, QuantumRestante: caso.restante}
+
+			if quantum := quantumDeRafaga(pcb); quantum != caso.esperado {
+				t.Fatalf("quantum = %s, se esperaba %s", quantum, caso.esperado)
+			}
+			if pcb.QuantumRestante != 0 {
+				t.Fatalf("el restante quedó en %s: se usa una sola vez", pcb.QuantumRestante)
+			}
+		})
+	}
+}
+
+func TestGuardarQuantumRestante(t *testing.T) {
+	casos := []struct {
+		nombre     string
+		algoritmo  string
+		rafaga     time.Duration
+		usadoMs    float64
+		restante   time.Duration
+		enAuxiliar bool
+	}{
+		{"VRR bloqueado a mitad de quantum", "VRR", 100 * time.Millisecond, 30, 70 * time.Millisecond, true},
+		{"VRR con quantum reducido", "VRR", 40 * time.Millisecond, 25, 15 * time.Millisecond, true},
+		{"VRR que agotó el quantum", "VRR", 100 * time.Millisecond, 100, 0, false},
+		{"VRR que se pasó del quantum", "VRR", 100 * time.Millisecond, 120, 0, false},
+		{"RR no guarda restante", "RR", 100 * time.Millisecond, 30, 0, false},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			configurarKernel(t, KernelConfig{SchedulerAlgorithm: caso.algoritmo, Quantum: 100})
+			otro := &PCB{PID: 1}
+			colaReady = []*PCB{otro}
+
+			pcb := &PCB{PID: 2, QuantumRafaga: caso.rafaga, UltimaRafagaReal: caso.usadoMs}
+			guardarQuantumRestante(pcb)
+			if pcb.QuantumRestante != caso.restante {
+				t.Fatalf("restante = %s, se esperaba %s", pcb.QuantumRestante, caso.restante)
+			}
+
+			// El que tiene restante vuelve por la cola auxiliar y se despacha primero
+			encolarEnReady(pcb)
+			elegido := seleccionarRR()
+			if enAuxiliar := elegido == pcb; enAuxiliar != caso.enAuxiliar {
+				t.Fatalf("se eligió el PID %d (cola auxiliar %v)", elegido.PID, colaReadyAux)
+			}
+			if caso.enAuxiliar {
+				if quantum := quantumDeRafaga(pcb); quantum != caso.restante {
+					t.Fatalf("la ráfaga siguiente tiene quantum %s, se esperaba %s", quantum, caso.restante)
+				}
+			}
+			if !quitarDeReady(pcb) || procesosEnReady() != 1 {
+				t.Fatalf("el proceso no se pudo quitar de READY (quedan %d)", procesosEnReady())
+			}
+		})
+	}
+}
+
+func TestDespachoVigente(t *testing.T) {
+	if pausaCortoPlazo.Activar() {
+		t.Cleanup(func() { pausaCortoPlazo.Detener() })
+	}
+	t.Cleanup(func() {
+		execMutex.Lock()
+		delete(colaExec, "CPU1")
+		delete(despachoExec, "CPU1")
+		execMutex.Unlock()
+	})
+	pcb := &PCB{PID: 1}
+
+	primero := reservarCPU("CPU1", pcb)
+	if primero == 0 || !despachoVigente("CPU1", pcb, primero) {
+		t.Fatal("el despacho recién reservado no está vigente")
+	}
+
+	// Al bloquearse, el proceso libera la CPU por PID antes de que termine su ráfaga
+	execMutex.Lock()
+	delete(colaExec, "CPU1")
+	execMutex.Unlock()
+	if despachoVigente("CPU1", pcb, primero) {
+		t.Fatal("el despacho sigue vigente con la CPU liberada")
+	}
+
+	// Vuelve a despacharse en la misma CPU: el quantum de la ráfaga anterior no lo desaloja
+	segundo := reservarCPU("CPU1", pcb)
+	if despachoVigente("CPU1", pcb, primero) {
+		t.Fatal("el despacho anterior sigue vigente tras volver a despacharse")
+	}
+	if !despachoVigente("CPU1", pcb, segundo) {
+		t.Fatal("el nuevo despacho no está vigente")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+)
+
+// pedidoSwap es una suspensión o dessuspensión que falta informar a Memoria
+type pedidoSwap struct {
+	tipo int // MensajeSuspenderProceso o MensajeDessuspenderProceso
+	pid  int
+}
+
+// Los pedidos de swap van a Memoria en lotes: mientras un lote está en viaje, los que
+// llegan se acumulan y salen juntos en el siguiente, en el orden en que se pidieron
+var (
+	swapMutex       sync.Mutex
+	swapsPendientes []pedidoSwap
+	enviandoSwaps   bool
+)
+
+// pedirSwap encola el pedido y, si no hay un lote en viaje, arranca el envío
+func pedirSwap(tipo int, pid int) {
+	swapMutex.Lock()
+	swapsPendientes = append(swapsPendientes, pedidoSwap{tipo: tipo, pid: pid})
+	if enviandoSwaps {
+		swapMutex.Unlock()
+		return
+	}
+	enviandoSwaps = true
+	swapMutex.Unlock()
+	go enviarSwaps()
+}
+
+// enviarSwaps envía lotes hasta que no queden pedidos pendientes
+func enviarSwaps() {
+	for {
+		swapMutex.Lock()
+		pedidos := swapsPendientes
+		if len(pedidos) > utils.MaxElementosLote {
+			pedidos = pedidos[:utils.MaxElementosLote]
+		}
+		swapsPendientes = swapsPendientes[len(pedidos):]
+		if len(pedidos) == 0 {
+			enviandoSwaps = false
+			swapMutex.Unlock()
+			return
+		}
+		swapMutex.Unlock()
+
+		enviarLoteSwap(pedidos)
+	}
+}
+
+// enviarLoteSwap informa los pedidos a Memoria con una sola solicitud. Memoria atiende
+// cada uno por separado: si uno falla, los demás se aplican igual.
+func enviarLoteSwap(pedidos []pedidoSwap) {
+	cliente := GetMemoriaClient()
+	if cliente == nil {
+		utils.ErrorLog.Error("No se pudo obtener cliente de memoria para swap", "procesos", len(pedidos))
+		return
+	}
+
+	lote := utils.NuevoLote()
+	for _, pedido := range pedidos {
+		lote.Agregar(pedido.tipo, "default", utils.SolicitudPID{PID: pedido.pid})
+	}
+
+	resultados, err := cliente.EnviarLote(context.Background(), lote)
+	if err != nil {
+		utils.ErrorLog.Error("Error notificando swap a Memoria", "procesos", len(pedidos), "error", err.Error())
+		return
+	}
+
+	liberoEspacio := false
+	for i, resultado := range resultados {
+		if err := resultado.Err(); err != nil {
+			utils.ErrorLog.Error("Memoria rechazó el swap", "pid", pedidos[i].pid, "tipo", pedidos[i].tipo, "error", err.Error())
+			continue
+		}
+		if pedidos[i].tipo == utils.MensajeSuspenderProceso {
+			liberoEspacio = true
+		}
+	}
+	if liberoEspacio {
+		notificarMemoriaLiberada()
+	}
+}
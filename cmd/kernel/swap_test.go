@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+)
+
+// memoriaDeLotes simula a Memoria: registra cada lote recibido y espera a liberar antes
+// de responder, para que los pedidos que lleguen mientras tanto se acumulen. Devuelve los
+// lotes recibidos hasta el momento.
+func memoriaDeLotes(t *testing.T, liberar <-chan struct{}) func() [][]pedidoSwap {
+	t.Helper()
+	var lotes [][]pedidoSwap
+	var mutex sync.Mutex
+
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var mensaje struct {
+			Tipo  int                 `json:"tipo"`
+			Datos utils.SolicitudLote `json:"datos"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&mensaje); err != nil || mensaje.Tipo != utils.MensajeLote {
+			http.Error(w, "se esperaba un lote", http.StatusBadRequest)
+			return
+		}
+
+		var pedidos []pedidoSwap
+		var respuesta utils.RespuestaLote
+		for _, elemento := range mensaje.Datos.Elementos {
+			var solicitud utils.SolicitudPID
+			json.Unmarshal(elemento.Datos, &solicitud)
+			pedidos = append(pedidos, pedidoSwap{tipo: elemento.Tipo, pid: solicitud.PID})
+			respuesta.Resultados = append(respuesta.Resultados, utils.ResultadoLote{Respuesta: json.RawMessage(`{"status":"OK"}`)})
+		}
+		mutex.Lock()
+		lotes = append(lotes, pedidos)
+		mutex.Unlock()
+
+		<-liberar
+		json.NewEncoder(w).Encode(respuesta)
+	}))
+	t.Cleanup(servidor.Close)
+
+	host, puerto, _ := net.SplitHostPort(servidor.Listener.Addr().String())
+	numero, _ := strconv.Atoi(puerto)
+	anterior, anteriorCond := memoriaClient, condNew
+	memoriaClient = utils.NewHTTPClient(host, numero, "Memoria")
+	if condNew == nil {
+		condNew = sync.NewCond(&newMutex)
+	}
+	t.Cleanup(func() { memoriaClient, condNew = anterior, anteriorCond })
+
+	return func() [][]pedidoSwap {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([][]pedidoSwap(nil), lotes...)
+	}
+}
+
+// esperarHasta falla el test si condicion no se cumple en dos segundos
+func esperarHasta(t *testing.T, descripcion string, condicion func() bool) {
+	t.Helper()
+	for limite := time.Now().Add(2 * time.Second); !condicion(); time.Sleep(time.Millisecond) {
+		if time.Now().After(limite) {
+			t.Fatalf("no se cumplió a tiempo: %s", descripcion)
+		}
+	}
+}
+
+func TestPedirSwapAgrupaLosPedidos(t *testing.T) {
+	liberar := make(chan struct{})
+	lotes := memoriaDeLotes(t, liberar)
+	terminado := func() bool {
+		swapMutex.Lock()
+		defer swapMutex.Unlock()
+		return !enviandoSwaps
+	}
+
+	// El primer pedido sale solo; los que llegan con él en viaje salen juntos y en orden
+	notificarSwapAMemoria(1)
+	esperarHasta(t, "llega el primer lote", func() bool { return len(lotes()) == 1 })
+	notificarSwapAMemoria(2)
+	notificarSwapAMemoria(3)
+	notificarDesswapAMemoria(1)
+	close(liberar)
+	esperarHasta(t, "se envían todos los pedidos", terminado)
+
+	suspender, dessuspender := utils.MensajeSuspenderProceso, utils.MensajeDessuspenderProceso
+	esperados := [][]pedidoSwap{
+		{{suspender, 1}},
+		{{suspender, 2}, {suspender, 3}, {dessuspender, 1}},
+	}
+	if recibidos := lotes(); !reflect.DeepEqual(recibidos, esperados) {
+		t.Fatalf("se enviaron los lotes %v, se esperaban %v", recibidos, esperados)
+	}
+}
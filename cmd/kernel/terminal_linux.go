@@ -0,0 +1,45 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// estadoTerminal guarda el modo de la terminal para restaurarlo al salir
+type estadoTerminal struct {
+	fd       int
+	original syscall.Termios
+}
+
+// modoCrudo desactiva el eco y el modo canónico de la terminal fd para editar la línea
+// tecla por tecla. Ctrl+C sigue generando SIGINT. Falla si fd no es una terminal.
+func modoCrudo(fd int) (*estadoTerminal, error) {
+	estado := &estadoTerminal{fd: fd}
+	if err := ioctlTermios(fd, syscall.TCGETS, &estado.original); err != nil {
+		return nil, err
+	}
+
+	crudo := estado.original
+	crudo.Lflag &^= syscall.ICANON | syscall.ECHO
+	crudo.Cc[syscall.VMIN] = 1
+	crudo.Cc[syscall.VTIME] = 0
+	if err := ioctlTermios(fd, syscall.TCSETS, &crudo); err != nil {
+		return nil, err
+	}
+	return estado, nil
+}
+
+// restaurar devuelve la terminal al modo en que estaba
+func (e *estadoTerminal) restaurar() error {
+	return ioctlTermios(e.fd, syscall.TCSETS, &e.original)
+}
+
+func ioctlTermios(fd int, pedido uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), pedido, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
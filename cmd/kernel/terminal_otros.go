@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// estadoTerminal no se usa fuera de Linux: la consola lee líneas sin edición
+type estadoTerminal struct{}
+
+// modoCrudo no está disponible en este sistema
+func modoCrudo(fd int) (*estadoTerminal, error) {
+	return nil, errors.New("edición de línea no disponible en este sistema")
+}
+
+func (e *estadoTerminal) restaurar() error {
+	return nil
+}
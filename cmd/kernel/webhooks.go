@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+)
+
+// Eventos del ciclo de vida de un proceso que se notifican a los WEBHOOKS
+const (
+	WebhookCreado     = "CREADO"
+	WebhookAdmitido   = "ADMITIDO"
+	WebhookDespachado = "DESPACHADO"
+	WebhookBloqueado  = "BLOQUEADO"
+	WebhookSuspendido = "SUSPENDIDO"
+	WebhookFinalizado = "FINALIZADO"
+)
+
+// capacidadColaWebhook es cuántas notificaciones se encolan por URL antes de descartar
+const capacidadColaWebhook = 1024
+
+// politicaWebhooks reintenta pocas veces: el planificador no espera a los receptores
+var politicaWebhooks = utils.PoliticaReintentos{
+	MaxIntentos:   3,
+	EsperaInicial: 200 * time.Millisecond,
+	EsperaMaxima:  2 * time.Second,
+	Factor:        2,
+	Jitter:        0.2,
+}
+
+var notificacionesWebhook = utils.NuevoContador("kernel_webhooks_total", "Notificaciones a webhooks por resultado.", "resultado")
+
+// NotificacionProceso es el JSON que recibe cada webhook
+type NotificacionProceso struct {
+	// Secuencia crece de a uno por notificación; un salto indica notificaciones descartadas
+	Secuencia uint64                 `json:"secuencia"`
+	Evento    string                 `json:"evento"`
+	PID       int                    `json:"pid"`
+	Archivo   string                 `json:"archivo,omitempty"`
+	Tamanio   int                    `json:"tamanio"`
+	Anterior  string                 `json:"estado_anterior,omitempty"`
+	Estado    string                 `json:"estado"`
+	Motivo    string                 `json:"motivo,omitempty"`
+	Metricas  *utils.MetricasProceso `json:"metricas,omitempty"`
+	Sello     int64                  `json:"sello"` // milisegundos Unix
+}
+
+// destinoWebhook entrega en orden las notificaciones de una URL, sin frenar a las demás
+type destinoWebhook struct {
+	url  string
+	cola chan NotificacionProceso
+}
+
+var (
+	webhooks          []*destinoWebhook
+	webhooksMutex     sync.Mutex
+	webhooksCerrados  bool
+	webhooksPendiente sync.WaitGroup
+	secuenciaWebhook  uint64 // protegida por webhooksMutex
+	clienteWebhooks   *http.Client
+	secretoWebhooks   string
+	// cancelarWebhooks corta los reintentos en curso si la detención se queda sin tiempo
+	ctxWebhooks      context.Context
+	cancelarWebhooks context.CancelFunc
+)
+
+// iniciarWebhooks arranca un repartidor por URL configurada y registra el vaciado de
+// las colas al detener el Kernel
+func iniciarWebhooks(urls []string, timeoutMs int, secreto string) {
+	if len(urls) == 0 {
+		return
+	}
+
+	clienteWebhooks = &http.Client{Timeout: time.Duration(timeoutMs) * time.Millisecond}
+	secretoWebhooks = secreto
+	ctxWebhooks, cancelarWebhooks = context.WithCancel(context.Background())
+	for _, url := range urls {
+		destino := &destinoWebhook{url: url, cola: make(chan NotificacionProceso, capacidadColaWebhook)}
+		webhooks = append(webhooks, destino)
+		webhooksPendiente.Add(1)
+		go destino.repartir()
+	}
+	kernelModulo.AlFinalizar("webhooks", vaciarWebhooks)
+	utils.InfoLog.Info("Webhooks habilitados", "urls", urls)
+}
+
+// notificarWebhooks encola la notificación en cada URL sin bloquear al planificador.
+// Si la cola de una URL está llena, esa notificación se descarta para esa URL. La
+// secuencia se asigna y se encola bajo el mismo lock, así cada cola la recibe en orden.
+func notificarWebhooks(pcb *PCB, evento string, anterior string, estado string, motivo string, metricas *utils.MetricasProceso) {
+	webhooksMutex.Lock()
+	defer webhooksMutex.Unlock()
+	if len(webhooks) == 0 || webhooksCerrados {
+		return
+	}
+
+	secuenciaWebhook++
+	notificacion := NotificacionProceso{
+		Secuencia: secuenciaWebhook,
+		Evento:    evento,
+		PID:       pcb.PID,
+		Archivo:   pcb.NombreArchivo,
+		Tamanio:   pcb.Tamanio,
+		Anterior:  anterior,
+		Estado:    estado,
+		Motivo:    motivo,
+		Metricas:  metricas,
+		Sello:     time.Now().UnixMilli(),
+	}
+	for _, destino := range webhooks {
+		select {
+		case destino.cola <- notificacion:
+		default:
+			notificacionesWebhook.Incrementar("descartada")
+			utils.ErrorLog.Error("Cola de webhook llena, se descarta la notificación",
+				"url", destino.url, "evento", evento, "pid", pcb.PID)
+		}
+	}
+}
+
+// eventoWebhook traduce un cambio de estado al evento que se notifica, o "" si no se notifica.
+// FINALIZADO se notifica aparte, con el motivo y las métricas del proceso.
+func eventoWebhook(anterior string, nuevo string) string {
+	switch {
+	case nuevo == EstadoReady && (anterior == EstadoNew || anterior == EstadoSuspReady):
+		return WebhookAdmitido
+	case nuevo == EstadoExec:
+		return WebhookDespachado
+	case nuevo == EstadoBlocked:
+		return WebhookBloqueado
+	case nuevo == EstadoSuspBlocked:
+		return WebhookSuspendido
+	}
+	return ""
+}
+
+// repartir envía las notificaciones de la cola hasta que se cierre
+func (d *destinoWebhook) repartir() {
+	defer webhooksPendiente.Done()
+	for notificacion := range d.cola {
+		cuerpo, err := json.Marshal(notificacion)
+		if err != nil {
+			utils.ErrorLog.Error("Error serializando notificación de webhook", "error", err)
+			continue
+		}
+
+		err = politicaWebhooks.Ejecutar(ctxWebhooks, "webhook "+d.url, func(ctx context.Context) error {
+			return d.enviar(ctx, notificacion.Evento, cuerpo)
+		})
+		if err != nil {
+			notificacionesWebhook.Incrementar("fallida")
+			utils.ErrorLog.Error("No se pudo entregar la notificación al webhook",
+				"url", d.url, "evento", notificacion.Evento, "pid", notificacion.PID, "error", err)
+			continue
+		}
+		notificacionesWebhook.Incrementar("entregada")
+	}
+}
+
+// enviar hace el POST de una notificación. Con WEBHOOK_SECRETO el cuerpo va firmado en
+// X-Firma, así el receptor puede verificarlo sin que el secreto viaje en la solicitud.
+func (d *destinoWebhook) enviar(ctx context.Context, evento string, cuerpo []byte) error {
+	solicitud, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(cuerpo))
+	if err != nil {
+		return utils.NuevoError(utils.ErrorEsquemaInvalido, "URL de webhook inválida: %v", err)
+	}
+	solicitud.Header.Set("Content-Type", "application/json")
+	solicitud.Header.Set("X-Evento", evento)
+	if firma := utils.FirmarCuerpo(secretoWebhooks, cuerpo); firma != "" {
+		solicitud.Header.Set("X-Firma", firma)
+	}
+
+	respuesta, err := clienteWebhooks.Do(solicitud)
+	if err != nil {
+		return utils.NuevoError(utils.ErrorRedCaida, "%v", err)
+	}
+	io.Copy(io.Discard, respuesta.Body)
+	respuesta.Body.Close()
+
+	if respuesta.StatusCode >= 300 {
+		errorModulo := utils.NuevoError(utils.ErrorInterno, "el webhook respondió %s", respuesta.Status)
+		// Los 4xx no mejoran reintentando, salvo 429
+		errorModulo.Reintentable = respuesta.StatusCode >= 500 || respuesta.StatusCode == http.StatusTooManyRequests
+		return errorModulo
+	}
+	return nil
+}
+
+// vaciarWebhooks deja de aceptar notificaciones y espera a que se entreguen las encoladas
+// o a que venza ctx
+func vaciarWebhooks(ctx context.Context) error {
+	webhooksMutex.Lock()
+	webhooksCerrados = true
+	for _, destino := range webhooks {
+		close(destino.cola)
+	}
+	webhooksMutex.Unlock()
+
+	terminado := make(chan struct{})
+	go func() {
+		webhooksPendiente.Wait()
+		close(terminado)
+	}()
+
+	select {
+	case <-terminado:
+		return nil
+	case <-ctx.Done():
+		cancelarWebhooks()
+		return fmt.Errorf("quedaron notificaciones de webhook sin entregar: %w", ctx.Err())
+	}
+}
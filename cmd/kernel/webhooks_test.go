@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestNotificarWebhooksEnOrden(t *testing.T) {
+	const emisores, porEmisor = 8, 50
+
+	destino := &destinoWebhook{url: "prueba", cola: make(chan NotificacionProceso, emisores*porEmisor)}
+	webhooksMutex.Lock()
+	webhooks, webhooksCerrados, secuenciaWebhook = []*destinoWebhook{destino}, false, 0
+	webhooksMutex.Unlock()
+	t.Cleanup(func() {
+		webhooksMutex.Lock()
+		webhooks, secuenciaWebhook = nil, 0
+		webhooksMutex.Unlock()
+	})
+
+	var grupo sync.WaitGroup
+	for i := 0; i < emisores; i++ {
+		grupo.Add(1)
+		go func(pid int) {
+			defer grupo.Done()
+			pcb := &PCB{PID: pid, Estado: EstadoReady}
+			for j := 0; j < porEmisor; j++ {
+				notificarWebhooks(pcb, WebhookFinalizado, EstadoReady, EstadoExit, "", nil)
+			}
+		}(i)
+	}
+	grupo.Wait()
+	close(destino.cola)
+
+	var esperada uint64 = 1
+	for notificacion := range destino.cola {
+		if notificacion.Secuencia != esperada {
+			t.Fatalf("llegó la secuencia %d, se esperaba %d", notificacion.Secuencia, esperada)
+		}
+		if notificacion.Estado != EstadoExit {
+			t.Fatalf("se notificó el estado %s, se esperaba %s", notificacion.Estado, EstadoExit)
+		}
+		esperada++
+	}
+	if esperada != emisores*porEmisor+1 {
+		t.Fatalf("llegaron %d notificaciones, se esperaban %d", esperada-1, emisores*porEmisor)
+	}
+}
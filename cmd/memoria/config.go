@@ -1,19 +1,48 @@
 package main
 
+import "github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+
 // MemoryConfig representa la configuración específica del módulo Memoria
 type MemoryConfig struct {
-	IPMemory       string `json:"IP_MEMORIA"`
+	IPMemory       string `json:"IP_MEMORIA" default:"127.0.0.1"`
 	PortMemory     int    `json:"PUERTO_MEMORIA"`
-	LogLevel       string `json:"LOG_LEVEL"`
-	MemorySize     int    `json:"TAM_MEMORIA"`        // Tamaño de la memoria en bytes
-	PageSize       int    `json:"TAM_PAGINA"`         // Tamaño de página en bytes
-	NumberOfLevels int    `json:"CANTIDAD_NIVELES"`   // Número de niveles de tabla de páginas
-	EntriesPerPage int    `json:"ENTRADAS_POR_TABLA"` // Entradas por página
-	MemoryDelay    int    `json:"RETARDO_MEMORIA"`    // Retardo de acceso a memoria
-	SwapDelay      int    `json:"RETARDO_SWAP"`       // Retardo de acceso a swap
-	SwapfilePath   string `json:"SWAPFILE_PATH"`      // Ruta al archivo de swap
-	DumpPath       string `json:"DUMP_PATH"`          // Ruta para los archivos de dump
-	ScriptsPath    string `json:"SCRIPTS_PATH"`
+	LogLevel       string `json:"LOG_LEVEL" default:"INFO"`
+	MemorySize     int    `json:"TAM_MEMORIA"`                               // Tamaño de la memoria en bytes
+	PageSize       int    `json:"TAM_PAGINA"`                                // Tamaño de página en bytes
+	NumberOfLevels int    `json:"CANTIDAD_NIVELES"`                          // Número de niveles de tabla de páginas
+	EntriesPerPage int    `json:"ENTRADAS_POR_TABLA"`                        // Entradas por página
+	MemoryDelay    int    `json:"RETARDO_MEMORIA"`                           // Retardo de acceso a memoria
+	SwapDelay      int    `json:"RETARDO_SWAP"`                              // Retardo de acceso a swap
+	SwapfilePath   string `json:"SWAPFILE_PATH" default:"swap/swapfile.bin"` // Ruta al archivo de swap
+	DumpPath       string `json:"DUMP_PATH" default:"dump/"`                 // Ruta para los archivos de dump
+	ScriptsPath    string `json:"SCRIPTS_PATH" default:"scripts/"`
+	TrazasPath     string `json:"TRAZAS_PATH,omitempty"` // Archivo JSONL de spans (opcional)
+
+	SecretoCompartido string `json:"SECRETO_COMPARTIDO,omitempty" sensible:"true"` // Firma de mensajes; vacío no se firma
+	TokenAdmin        string `json:"TOKEN_ADMIN,omitempty" sensible:"true"`        // API REST; vacío la deja abierta solo si no hay secreto
+
+	utils.OpcionesLog // Archivos de log, rotación y formato
+	utils.OpcionesTLS // Certificados para TLS mutuo (opcional)
+}
+
+// ValidarConfig aplica las reglas de Memoria (ver utils.ConfigValidable)
+func (c *MemoryConfig) ValidarConfig(r *utils.ReporteConfig) {
+	r.Direccion("IP_MEMORIA", c.IPMemory, "PUERTO_MEMORIA", c.PortMemory)
+	r.Positivo("TAM_MEMORIA", c.MemorySize)
+	r.PotenciaDeDos("TAM_PAGINA", c.PageSize)
+	if c.MemorySize > 0 && c.PageSize > 0 {
+		r.Verificar(c.MemorySize%c.PageSize == 0, "TAM_MEMORIA", "%d no es divisible por TAM_PAGINA (%d)", c.MemorySize, c.PageSize)
+	}
+	r.Positivo("CANTIDAD_NIVELES", c.NumberOfLevels)
+	r.Positivo("ENTRADAS_POR_TABLA", c.EntriesPerPage)
+	r.NoNegativo("RETARDO_MEMORIA", c.MemoryDelay)
+	r.NoNegativo("RETARDO_SWAP", c.SwapDelay)
+	r.Requerido("SWAPFILE_PATH", c.SwapfilePath)
+	r.Requerido("DUMP_PATH", c.DumpPath)
+	r.NivelLog(c.LogLevel)
+	r.OpcionesLog(c.OpcionesLog)
+	r.Credenciales(c.SecretoCompartido, c.TokenAdmin)
+	r.OpcionesTLS(c.OpcionesTLS)
 }
 
 var config *MemoryConfig
@@ -1,8 +1,6 @@
 package main
 
 import (
-	"fmt"
-
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
 )
 
@@ -12,7 +10,7 @@ func traducirDireccion(pid int, dirLogica int) (int, error) {
 	tabla, existe := tablasPaginas[pid]
 	if !existe {
 		utils.ErrorLog.Error("No existe tabla de páginas", "pid", pid)
-		return 0, fmt.Errorf("no existe tabla de páginas para PID %d", pid)
+		return 0, utils.NuevoError(utils.ErrorPIDInexistente, "no existe tabla de páginas para PID %d", pid)
 	}
 
 	// Calcular componentes de la dirección lógica
@@ -26,7 +26,7 @@ func crearMemoryDump(pid int) error {
 	marcos, existe := marcosAsignadosPorProceso[pid]
 	if !existe {
 		utils.ErrorLog.Error("Proceso sin marcos asignados", "pid", pid)
-		return fmt.Errorf("el proceso %d no tiene marcos asignados", pid)
+		return utils.NuevoError(utils.ErrorPIDInexistente, "el proceso %d no tiene marcos asignados", pid)
 	}
 
 	utils.InfoLog.Info("Marcos del proceso", "pid", pid, "cantidad_marcos", len(marcos))
@@ -67,24 +67,15 @@ func crearMemoryDump(pid int) error {
 	}
 
 	// Log obligatorio del enunciado
-	utils.InfoLog.Info(fmt.Sprintf("## PID: %d Memory Dump solicitado", pid))
+	utils.ObligatorioLog.Info(fmt.Sprintf("## PID: %d Memory Dump solicitado", pid))
 	utils.InfoLog.Info("Memory dump completado", "pid", pid, "archivo", nombreArchivo)
 
 	return nil
 }
 
 // handlerMemoryDump crea un volcado de memoria para un proceso
-func handlerMemoryDump(msg *utils.Mensaje) (interface{}, error) {
-	// Extraer el PID del mensaje
-	datos := msg.Datos.(map[string]interface{})
-	pid, ok := datos["pid"].(float64)
-	if !ok {
-		utils.ErrorLog.Error("PID no proporcionado o formato incorrecto", "datos", datos)
-		return map[string]interface{}{
-			"error": "PID no proporcionado o formato incorrecto",
-		}, nil
-	}
-	pidInt := int(pid)
+func handlerMemoryDump(msg *utils.Mensaje, solicitud utils.SolicitudPID) (utils.RespuestaEstado, error) {
+	pidInt := solicitud.PID
 
 	utils.InfoLog.Info("Solicitud de memory dump recibida", "pid", pidInt)
 
@@ -92,17 +83,10 @@ func handlerMemoryDump(msg *utils.Mensaje) (interface{}, error) {
 	err := crearMemoryDump(pidInt)
 	if err != nil {
 		utils.ErrorLog.Error("Error al crear memory dump", "pid", pidInt, "error", err)
-		return map[string]interface{}{
-			"error": err.Error(),
-		}, nil
+		return utils.RespuestaEstado{}, err
 	}
 
-	// Aplicar el retardo de memoria
-	utils.AplicarRetardo("memory", config.MemoryDelay)
-
 	utils.InfoLog.Info("Memory dump completado exitosamente", "pid", pidInt)
 
-	return map[string]interface{}{
-		"status": "OK",
-	}, nil
+	return utils.RespuestaOK(""), nil
 }
@@ -11,36 +11,17 @@ func handlerOperacion(msg *utils.Mensaje) (interface{}, error) {
 	// Determinar el tipo de operación
 	tipoOperacion := utils.ObtenerTipoOperacion(msg, "memoria")
 
-	// Seleccionar el retardo adecuado
-	retardo := config.MemoryDelay
+	// RETARDO_MEMORIA lo aplica la cadena de middlewares; el acceso a swap paga además RETARDO_SWAP
 	if tipoOperacion == "swap" {
-		retardo = config.SwapDelay
+		utils.AplicarRetardo("swap", config.SwapDelay)
 	}
 
-	return utils.HandlerGenerico(msg, retardo, procesarOperacion)
+	return procesarOperacion(msg)
 }
 
-func handlerObtenerInstruccion(msg *utils.Mensaje) (interface{}, error) {
-	// Extraer el PID y PC del mensaje
-	datos := msg.Datos.(map[string]interface{})
-	pid, ok := datos["pid"].(float64)
-	if !ok {
-		utils.ErrorLog.Error("PID no proporcionado", "datos", datos)
-		return map[string]interface{}{
-			"error": "PID no proporcionado o formato incorrecto",
-		}, nil
-	}
-
-	pc, ok := datos["pc"].(float64)
-	if !ok {
-		utils.ErrorLog.Error("PC no proporcionado", "datos", datos)
-		return map[string]interface{}{
-			"error": "PC no proporcionado o formato incorrecto",
-		}, nil
-	}
-
-	pidInt := int(pid)
-	pcInt := int(pc)
+func handlerObtenerInstruccion(msg *utils.Mensaje, solicitud utils.SolicitudInstruccion) (utils.RespuestaInstruccion, error) {
+	pidInt := solicitud.PID
+	pcInt := solicitud.PC
 
 	utils.InfoLog.Info("Solicitud de instrucción", "pid", pidInt, "pc", pcInt)
 
@@ -56,9 +37,7 @@ func handlerObtenerInstruccion(msg *utils.Mensaje) (interface{}, error) {
 			if err := cargarInstrucciones(pidInt); err != nil {
 				instruccionesMutex.Unlock()
 				utils.ErrorLog.Error("Error cargando instrucciones", "pid", pidInt, "error", err)
-				return map[string]interface{}{
-					"error": fmt.Sprintf("No se pudieron cargar instrucciones para el PID %d: %v", pidInt, err),
-				}, nil
+				return utils.RespuestaInstruccion{}, utils.NuevoError(utils.ErrorPIDInexistente, "no se pudieron cargar instrucciones para el PID %d: %v", pidInt, err)
 			}
 			instrucciones = instruccionesPorProceso[pidInt]
 		}
@@ -68,16 +47,14 @@ func handlerObtenerInstruccion(msg *utils.Mensaje) (interface{}, error) {
 	// Verificar que el PC esté dentro del rango válido
 	if pcInt < 0 || pcInt >= len(instrucciones) {
 		utils.ErrorLog.Error("PC fuera de rango", "pid", pidInt, "pc", pcInt, "max", len(instrucciones)-1)
-		return map[string]interface{}{
-			"error": fmt.Sprintf("PC fuera de rango para PID %d: PC=%d, máximo=%d", pidInt, pcInt, len(instrucciones)-1),
-		}, nil
+		return utils.RespuestaInstruccion{}, utils.NuevoError(utils.ErrorDireccionInvalida, "PC fuera de rango para PID %d: PC=%d, máximo=%d", pidInt, pcInt, len(instrucciones)-1)
 	}
 
 	// Obtener la instrucción
 	instruccion := instrucciones[pcInt]
 
 	// Log obligatorio del enunciado
-	utils.InfoLog.Info(fmt.Sprintf("## PID: %d - Obtener instrucción: %d - Instrucción: %s", pidInt, pcInt, instruccion))
+	utils.ObligatorioLog.Info(fmt.Sprintf("## PID: %d - Obtener instrucción: %d - Instrucción: %s", pidInt, pcInt, instruccion))
 
 	// Dumps intermedios automáticos
 	if pcInt == 5 || pcInt == 10 || pcInt == 15 {
@@ -91,23 +68,49 @@ func handlerObtenerInstruccion(msg *utils.Mensaje) (interface{}, error) {
 
 	utils.InfoLog.Info("Instrucción entregada", "pid", pidInt, "pc", pcInt, "instruccion", instruccion)
 
-	return map[string]interface{}{
-		"status":      "OK",
-		"instruccion": instruccion,
+	return utils.RespuestaInstruccion{
+		Status:      "OK",
+		Instruccion: instruccion,
 	}, nil
 }
 
-func handlerEspacioLibre(msg *utils.Mensaje) (interface{}, error) {
+func handlerEspacioLibre(msg *utils.Mensaje, _ utils.SinDatos) (utils.RespuestaEspacioLibre, error) {
 	espacioLibre := calcularEspacioLibre()
 
 	utils.InfoLog.Info("Espacio libre consultado", "espacio_libre_bytes", espacioLibre)
 
-	return map[string]interface{}{
-		"status":        "OK",
-		"espacio_libre": espacioLibre,
+	return utils.RespuestaEspacioLibre{
+		Status:       "OK",
+		EspacioLibre: espacioLibre,
 	}, nil
 }
 
+// handlerListarMarcos informa qué marcos están libres y a qué proceso pertenecen los ocupados
+func handlerListarMarcos(msg *utils.Mensaje, _ utils.SinDatos) (utils.RespuestaMarcos, error) {
+	duenios := make(map[int]int)
+	for pid, marcos := range marcosAsignadosPorProceso {
+		for _, marco := range marcos {
+			duenios[marco] = pid
+		}
+	}
+
+	respuesta := utils.RespuestaMarcos{
+		Total:     len(marcosLibres),
+		TamPagina: config.PageSize,
+		Marcos:    make([]utils.EstadoMarco, 0, len(marcosLibres)),
+	}
+	for marco, libre := range marcosLibres {
+		estado := utils.EstadoMarco{Marco: marco, Libre: libre, PID: -1}
+		if libre {
+			respuesta.Libres++
+		} else if pid, existe := duenios[marco]; existe {
+			estado.PID = pid
+		}
+		respuesta.Marcos = append(respuesta.Marcos, estado)
+	}
+	return respuesta, nil
+}
+
 // calcularEspacioLibre calcula el espacio libre total en bytes
 func calcularEspacioLibre() int {
 	espacioLibre := 0
@@ -119,66 +122,47 @@ func calcularEspacioLibre() int {
 	return espacioLibre
 }
 
-func handlerInicializarProceso(msg *utils.Mensaje) (interface{}, error) {
-	datos, ok := msg.Datos.(map[string]interface{})
-	if !ok {
-		utils.ErrorLog.Error("Formato de datos incorrecto", "datos", msg.Datos)
-		return map[string]interface{}{"error": "Formato de datos incorrecto"}, nil
-	}
-
-	pid := int(datos["pid"].(float64))
-	tamanio := int(datos["tamanio"].(float64))
-	archivoOrigen := datos["archivo"].(string)
+func handlerInicializarProceso(msg *utils.Mensaje, solicitud utils.SolicitudInicializarProceso) (utils.RespuestaEstado, error) {
+	pid := solicitud.PID
+	tamanio := solicitud.Tamanio
+	archivoOrigen := solicitud.Archivo
 
 	utils.InfoLog.Info("Solicitud de inicialización de proceso", "pid", pid, "tamanio", tamanio, "archivo", archivoOrigen)
 
 	// Verificar espacio libre
 	if calcularEspacioLibre() < tamanio {
 		utils.ErrorLog.Error("Espacio insuficiente", "pid", pid, "tamanio_requerido", tamanio, "espacio_libre", calcularEspacioLibre())
-		return map[string]interface{}{
-			"error": fmt.Sprintf("No hay suficiente espacio libre para inicializar el proceso %d", pid),
-		}, nil
+		return utils.RespuestaEstado{}, utils.NuevoError(utils.ErrorMemoriaSinEspacio, "no hay suficiente espacio libre para inicializar el proceso %d", pid)
 	}
 
 	// Copiar el archivo de pseudocódigo
 	destino := filepath.Join(config.ScriptsPath, fmt.Sprintf("%d.txt", pid))
 	if err := copiarPseudocodigo(archivoOrigen, destino); err != nil {
 		utils.ErrorLog.Error("Error copiando pseudocódigo", "archivo_origen", archivoOrigen, "destino", destino, "error", err)
-		return map[string]interface{}{"error": err.Error()}, nil
+		return utils.RespuestaEstado{}, err
 	}
 
 	// Crear tablas de páginas
 	_, err := crearTablasPaginas(pid, tamanio)
 	if err != nil {
 		utils.ErrorLog.Error("Error creando tablas de páginas", "pid", pid, "error", err)
-		return map[string]interface{}{"error": err.Error()}, nil
+		return utils.RespuestaEstado{}, err
 	}
 
 	// Cargar instrucciones en memoria
 	if err := cargarInstrucciones(pid); err != nil {
 		utils.ErrorLog.Error("Error cargando instrucciones", "pid", pid, "error", err)
 		liberarMemoriaProceso(pid)
-		return map[string]interface{}{"error": err.Error()}, nil
+		return utils.RespuestaEstado{}, err
 	}
 
 	utils.InfoLog.Info("Proceso inicializado correctamente", "pid", pid, "tamanio", tamanio, "archivo", archivoOrigen)
 
-	return map[string]interface{}{
-		"status": "OK",
-	}, nil
+	return utils.RespuestaOK(""), nil
 }
 
-func handlerFinalizarProceso(msg *utils.Mensaje) (interface{}, error) {
-	datos := msg.Datos.(map[string]interface{})
-	pid, ok := datos["pid"].(float64)
-	if !ok {
-		utils.ErrorLog.Error("PID no proporcionado", "datos", datos)
-		return map[string]interface{}{
-			"error": "PID no proporcionado o formato incorrecto",
-		}, nil
-	}
-
-	pidInt := int(pid)
+func handlerFinalizarProceso(msg *utils.Mensaje, solicitud utils.SolicitudPID) (utils.RespuestaEstado, error) {
+	pidInt := solicitud.PID
 
 	utils.InfoLog.Info("Solicitud de finalización de proceso", "pid", pidInt)
 
@@ -190,14 +174,12 @@ func handlerFinalizarProceso(msg *utils.Mensaje) (interface{}, error) {
 	// Liberar memoria del proceso
 	if err := liberarMemoriaProceso(pidInt); err != nil {
 		utils.ErrorLog.Error("Error liberando memoria", "pid", pidInt, "error", err)
-		return map[string]interface{}{
-			"error": fmt.Sprintf("Error al liberar memoria del proceso %d: %v", pidInt, err),
-		}, nil
+		return utils.RespuestaEstado{}, fmt.Errorf("error al liberar memoria del proceso %d: %w", pidInt, err)
 	}
 
 	// Log de métricas finales
 	if metricas, existe := metricasPorProceso[pidInt]; existe {
-		utils.InfoLog.Info(fmt.Sprintf("## PID: %d - Proceso Destruido - Métricas: ATP;%d;SWAP;%d;MemPrin;%d;LecMem;%d;EscMem;%d",
+		utils.ObligatorioLog.Info(fmt.Sprintf("## PID: %d - Proceso Destruido - Métricas: ATP;%d;SWAP;%d;MemPrin;%d;LecMem;%d;EscMem;%d",
 			pidInt,
 			metricas.AccesosTablasPaginas,
 			metricas.BajadasSwap,
@@ -215,216 +197,157 @@ func handlerFinalizarProceso(msg *utils.Mensaje) (interface{}, error) {
 
 	utils.InfoLog.Info("Proceso finalizado correctamente", "pid", pidInt)
 
-	return map[string]interface{}{
-		"status": "OK",
-	}, nil
+	return utils.RespuestaOK(""), nil
 }
 
-func handlerLeerMemoria(msg *utils.Mensaje) (interface{}, error) {
-	datos := msg.Datos.(map[string]interface{})
-	pid, ok := datos["pid"].(float64)
-	if !ok {
-		utils.ErrorLog.Error("PID no proporcionado", "datos", datos)
-		return map[string]interface{}{"error": "PID no proporcionado o formato incorrecto"}, nil
+// resolverDireccionFisica obtiene la dirección física a partir de la física o la lógica recibida
+func resolverDireccionFisica(pid int, dirFisica *int, dirLogica *int) (int, error) {
+	if dirFisica != nil {
+		return *dirFisica, nil
 	}
-	pidInt := int(pid)
 
-	// Dirección puede ser física o lógica
-	dirFisica, ok := datos["direccion_fisica"].(float64)
-	if !ok {
-		dirLogica, ok := datos["direccion_logica"].(float64)
-		if !ok {
-			utils.ErrorLog.Error("Dirección no proporcionada", "datos", datos)
-			return map[string]interface{}{"error": "Dirección no proporcionada o formato incorrecto"}, nil
-		}
+	dirFisicaInt, err := traducirDireccion(pid, *dirLogica)
+	if err != nil {
+		utils.ErrorLog.Error("Error traduciendo dirección", "pid", pid, "dir_logica", *dirLogica, "error", err)
+		return 0, fmt.Errorf("error traduciendo dirección: %w", err)
+	}
+	return dirFisicaInt, nil
+}
 
-		dirFisicaInt, err := traducirDireccion(pidInt, int(dirLogica))
-		if err != nil {
-			utils.ErrorLog.Error("Error traduciendo dirección", "pid", pidInt, "dir_logica", int(dirLogica), "error", err)
-			return map[string]interface{}{"error": fmt.Sprintf("Error traduciendo dirección: %v", err)}, nil
-		}
-		dirFisica = float64(dirFisicaInt)
+func handlerLeerMemoria(msg *utils.Mensaje, solicitud utils.SolicitudLectura) (utils.RespuestaLectura, error) {
+	pidInt := solicitud.PID
+
+	// Dirección puede ser física o lógica
+	dirFisica, err := resolverDireccionFisica(pidInt, solicitud.DireccionFisica, solicitud.DireccionLogica)
+	if err != nil {
+		return utils.RespuestaLectura{}, err
 	}
 
-	tamanio, ok := datos["tamanio"].(float64)
-	if !ok {
+	tamanio := solicitud.Tamanio
+	if tamanio <= 0 {
 		tamanio = 1
 	}
 
 	// Verificar límites
-	if int(dirFisica) < 0 || int(dirFisica)+int(tamanio) > len(memoriaPrincipal) {
-		utils.ErrorLog.Error("Dirección fuera de rango", "pid", pidInt, "dir_fisica", int(dirFisica), "tamanio", int(tamanio))
-		return map[string]interface{}{"error": "Dirección fuera de rango"}, nil
+	if dirFisica < 0 || dirFisica+tamanio > len(memoriaPrincipal) {
+		utils.ErrorLog.Error("Dirección fuera de rango", "pid", pidInt, "dir_fisica", dirFisica, "tamanio", tamanio)
+		return utils.RespuestaLectura{}, utils.NuevoError(utils.ErrorDireccionInvalida, "dirección física %d fuera de rango", dirFisica)
 	}
 
 	// Leer de memoria
-	valor := memoriaPrincipal[int(dirFisica):int(dirFisica+tamanio)]
+	valor := memoriaPrincipal[dirFisica : dirFisica+tamanio]
 
 	// Actualizar métricas
 	actualizarMetricasLectura(pidInt)
 
 	// Log obligatorio
-	utils.InfoLog.Info(fmt.Sprintf("## PID: %d - Lectura - Dir Física: %d - Tamaño: %d",
-		pidInt, int(dirFisica), int(tamanio)))
+	utils.ObligatorioLog.Info(fmt.Sprintf("## PID: %d - Lectura - Dir Física: %d - Tamaño: %d",
+		pidInt, dirFisica, tamanio))
 
-	utils.InfoLog.Info("Lectura de memoria realizada", "pid", pidInt, "dir_fisica", int(dirFisica), "tamanio", int(tamanio))
+	utils.InfoLog.Info("Lectura de memoria realizada", "pid", pidInt, "dir_fisica", dirFisica, "tamanio", tamanio)
 
-	return map[string]interface{}{
-		"status": "OK",
-		"valor":  string(valor),
+	return utils.RespuestaLectura{
+		Status: "OK",
+		Valor:  string(valor),
 	}, nil
 }
 
-func handlerEscribirMemoria(msg *utils.Mensaje) (interface{}, error) {
-	datos := msg.Datos.(map[string]interface{})
-	pid, ok := datos["pid"].(float64)
-	if !ok {
-		utils.ErrorLog.Error("PID no proporcionado", "datos", datos)
-		return map[string]interface{}{"error": "PID no proporcionado o formato incorrecto"}, nil
-	}
-	pidInt := int(pid)
+func handlerEscribirMemoria(msg *utils.Mensaje, solicitud utils.SolicitudEscritura) (utils.RespuestaEstado, error) {
+	pidInt := solicitud.PID
 
 	// Dirección puede ser física o lógica
-	dirFisica, ok := datos["direccion_fisica"].(float64)
-	if !ok {
-		dirLogica, ok := datos["direccion_logica"].(float64)
-		if !ok {
-			utils.ErrorLog.Error("Dirección no proporcionada", "datos", datos)
-			return map[string]interface{}{"error": "Dirección no proporcionada o formato incorrecto"}, nil
-		}
-
-		dirFisicaInt, err := traducirDireccion(pidInt, int(dirLogica))
-		if err != nil {
-			utils.ErrorLog.Error("Error traduciendo dirección", "pid", pidInt, "dir_logica", int(dirLogica), "error", err)
-			return map[string]interface{}{"error": fmt.Sprintf("Error traduciendo dirección: %v", err)}, nil
-		}
-		dirFisica = float64(dirFisicaInt)
+	dirFisica, err := resolverDireccionFisica(pidInt, solicitud.DireccionFisica, solicitud.DireccionLogica)
+	if err != nil {
+		return utils.RespuestaEstado{}, err
 	}
 
-	valor, ok := datos["valor"].(string)
-	if !ok {
-		utils.ErrorLog.Error("Valor no proporcionado", "datos", datos)
-		return map[string]interface{}{"error": "Valor no proporcionado o formato incorrecto"}, nil
-	}
+	valor := solicitud.Valor
 
 	// Verificar límites
-	if int(dirFisica) < 0 || int(dirFisica)+len(valor) > len(memoriaPrincipal) {
-		utils.ErrorLog.Error("Dirección fuera de rango para escritura", "pid", pidInt, "dir_fisica", int(dirFisica), "tamanio_valor", len(valor))
-		return map[string]interface{}{"error": "Dirección fuera de rango"}, nil
+	if dirFisica < 0 || dirFisica+len(valor) > len(memoriaPrincipal) {
+		utils.ErrorLog.Error("Dirección fuera de rango para escritura", "pid", pidInt, "dir_fisica", dirFisica, "tamanio_valor", len(valor))
+		return utils.RespuestaEstado{}, utils.NuevoError(utils.ErrorDireccionInvalida, "dirección física %d fuera de rango", dirFisica)
 	}
 
 	// Escribir en memoria
-	copy(memoriaPrincipal[int(dirFisica):int(dirFisica)+len(valor)], []byte(valor))
+	copy(memoriaPrincipal[dirFisica:dirFisica+len(valor)], []byte(valor))
 
 	// Actualizar métricas
 	actualizarMetricasEscritura(pidInt)
 
 	// Log obligatorio
-	utils.InfoLog.Info(fmt.Sprintf("## PID: %d - Escritura - Dir Física: %d - Tamaño: %d",
-		pidInt, int(dirFisica), len(valor)))
+	utils.ObligatorioLog.Info(fmt.Sprintf("## PID: %d - Escritura - Dir Física: %d - Tamaño: %d",
+		pidInt, dirFisica, len(valor)))
 
-	utils.InfoLog.Info("Escritura en memoria realizada", "pid", pidInt, "dir_fisica", int(dirFisica), "tamanio", len(valor))
+	utils.InfoLog.Info("Escritura en memoria realizada", "pid", pidInt, "dir_fisica", dirFisica, "tamanio", len(valor))
 
-	return map[string]interface{}{
-		"status": "OK",
-	}, nil
+	return utils.RespuestaOK(""), nil
 }
 
-func handlerObtenerMarco(msg *utils.Mensaje) (interface{}, error) {
-	datos := msg.Datos.(map[string]interface{})
-	pid, ok := datos["pid"].(float64)
-	if !ok {
-		utils.ErrorLog.Error("PID no proporcionado", "datos", datos)
-		return map[string]interface{}{"error": "PID no proporcionado o formato incorrecto"}, nil
-	}
-	pidInt := int(pid)
-
-	numPagina, ok := datos["pagina"].(float64)
-	if !ok {
-		utils.ErrorLog.Error("Número de página no proporcionado", "datos", datos)
-		return map[string]interface{}{"error": "Número de página no proporcionado o formato incorrecto"}, nil
-	}
+func handlerObtenerMarco(msg *utils.Mensaje, solicitud utils.SolicitudMarco) (utils.RespuestaMarco, error) {
+	pidInt := solicitud.PID
+	numPagina := solicitud.Pagina
 
-	utils.InfoLog.Info("Solicitud de marco", "pid", pidInt, "pagina", int(numPagina))
+	utils.InfoLog.Info("Solicitud de marco", "pid", pidInt, "pagina", numPagina)
 
 	// Obtener la tabla de páginas del proceso
 	tabla, existe := tablasPaginas[pidInt]
 	if !existe {
 		utils.ErrorLog.Error("No existe tabla de páginas", "pid", pidInt)
-		return map[string]interface{}{"error": "No existe tabla de páginas para el PID proporcionado"}, nil
+		return utils.RespuestaMarco{}, utils.NuevoError(utils.ErrorPIDInexistente, "no existe tabla de páginas para el PID %d", pidInt)
 	}
 
 	// Obtener el marco para la página solicitada
-	marco, err := obtenerMarcoDesdeTabla(pidInt, tabla, int(numPagina), 1)
+	marco, err := obtenerMarcoDesdeTabla(pidInt, tabla, numPagina, 1)
 	if err != nil {
-		utils.ErrorLog.Error("Error obteniendo marco", "pid", pidInt, "pagina", int(numPagina), "error", err)
-		return map[string]interface{}{"error": fmt.Sprintf("Error obteniendo marco: %v", err)}, nil
+		utils.ErrorLog.Error("Error obteniendo marco", "pid", pidInt, "pagina", numPagina, "error", err)
+		return utils.RespuestaMarco{}, fmt.Errorf("error obteniendo marco: %w", err)
 	}
 
 	// Log obligatorio
-	utils.InfoLog.Info(fmt.Sprintf("PID: %d OBTENER MARCO Página: %d Marco: %d",
-		pidInt, int(numPagina), marco))
+	utils.ObligatorioLog.Info(fmt.Sprintf("PID: %d OBTENER MARCO Página: %d Marco: %d",
+		pidInt, numPagina, marco))
 
-	utils.InfoLog.Info("Marco obtenido", "pid", pidInt, "pagina", int(numPagina), "marco", marco)
+	utils.InfoLog.Info("Marco obtenido", "pid", pidInt, "pagina", numPagina, "marco", marco)
 
-	return map[string]interface{}{
-		"status": "OK",
-		"marco":  marco,
+	return utils.RespuestaMarco{
+		Status: "OK",
+		Marco:  marco,
 	}, nil
 }
 
-func handlerSuspenderProceso(msg *utils.Mensaje) (interface{}, error) {
-	datos := msg.Datos.(map[string]interface{})
-	pid, ok := datos["pid"].(float64)
-	if !ok {
-		utils.ErrorLog.Error("PID no proporcionado para suspensión", "datos", datos)
-		return map[string]interface{}{
-			"error": "PID no proporcionado o formato incorrecto",
-		}, nil
-	}
-	pidInt := int(pid)
+func handlerSuspenderProceso(msg *utils.Mensaje, solicitud utils.SolicitudPID) (utils.RespuestaEstado, error) {
+	pidInt := solicitud.PID
 
 	utils.InfoLog.Info("Solicitud de suspensión", "pid", pidInt)
 
 	err := suspenderProceso(pidInt)
 	if err != nil {
 		utils.ErrorLog.Error("Error suspendiendo proceso", "pid", pidInt, "error", err)
-		return map[string]interface{}{
-			"error": err.Error(),
-		}, nil
+		return utils.RespuestaEstado{}, err
 	}
 
 	utils.InfoLog.Info("Proceso suspendido correctamente", "pid", pidInt)
 
-	return map[string]interface{}{
-		"status": "OK",
-	}, nil
+	return utils.RespuestaOK(""), nil
 }
 
-func handlerDessuspenderProceso(msg *utils.Mensaje) (interface{}, error) {
-	datos := msg.Datos.(map[string]interface{})
-	pid, ok := datos["pid"].(float64)
-	if !ok {
-		utils.ErrorLog.Error("PID no proporcionado para dessuspensión", "datos", datos)
-		return map[string]interface{}{
-			"error": "PID no proporcionado o formato incorrecto",
-		}, nil
-	}
-	pidInt := int(pid)
+func handlerDessuspenderProceso(msg *utils.Mensaje, solicitud utils.SolicitudPID) (utils.RespuestaEstado, error) {
+	pidInt := solicitud.PID
 
 	utils.InfoLog.Info("Solicitud de dessuspensión", "pid", pidInt)
 
+	_, span := utils.IniciarSpan(msg.Context(), "swap-in", "pid", pidInt)
+	defer span.Finalizar()
+
 	err := dessuspenderProceso(pidInt)
 	if err != nil {
+		span.RegistrarError(err)
 		utils.ErrorLog.Error("Error dessuspendiendo proceso", "pid", pidInt, "error", err)
-		return map[string]interface{}{
-			"error": err.Error(),
-		}, nil
+		return utils.RespuestaEstado{}, err
 	}
 
 	utils.InfoLog.Info("Proceso dessuspendido correctamente", "pid", pidInt)
 
-	return map[string]interface{}{
-		"status": "OK",
-	}, nil
+	return utils.RespuestaOK(""), nil
 }
@@ -1,22 +1,28 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
 )
 
-var (
-	modulo     *utils.Modulo
-	httpServer interface{}
-)
+// ttlIdempotencia es cuánto se recuerda el resultado de un mensaje para descartar reintentos
+const ttlIdempotencia = 2 * time.Minute
+
+// umbralMensajeLento es a partir de cuánto se avisa en el log que un mensaje tardó
+// demasiado, además de RETARDO_MEMORIA
+const umbralMensajeLento = time.Second
+
+var modulo *utils.Modulo
 
 func main() {
 	// Verificar argumentos
 	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Uso: %s <archivo_configuracion>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Uso: %s <archivo_configuracion|escenario>\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Ejemplo: %s configs/memoria-config-PlaniCorto.json\n", os.Args[0])
 		os.Exit(1)
 	}
@@ -31,17 +37,18 @@ func main() {
 
 	utils.InfoLog.Info("Memoria inicializada correctamente")
 
-	// Mantener el programa corriendo
-	select {}
+	// Atender hasta Ctrl+C y sincronizar el SWAP antes de salir
+	if err := modulo.Ejecutar(context.Background(), utils.PlazoDetencionPorDefecto); err != nil {
+		os.Exit(1)
+	}
 }
 
 func inicializarModulo() {
 	// Usar el archivo de configuración pasado como argumento
-	rutaConfig := os.Args[1]
-
-	// Verificar que el archivo existe
-	if _, err := os.Stat(rutaConfig); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "Error: El archivo de configuración no existe: %s\n", rutaConfig)
+	// Verificar que el archivo (o el escenario) existe
+	rutaConfig, err := utils.ResolverConfiguracion(os.Args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -49,11 +56,22 @@ func inicializarModulo() {
 	modulo = utils.NuevoModulo("Memoria", rutaConfig)
 
 	// Cargar configuración
-	config = utils.CargarConfiguracion[MemoryConfig](rutaConfig)
+	config = utils.CargarConfiguracionModulo[MemoryConfig](rutaConfig, utils.SeccionMemoria, "")
 
 	// Actualizar logger con configuración del archivo
-	utils.InicializarLogger(config.LogLevel, "Memoria")
+	if err := utils.InicializarLoggerConOpciones(config.LogLevel, "Memoria", config.OpcionesLog); err != nil {
+		utils.ErrorLog.Error("No se pudieron abrir los archivos de log", "error", err)
+	}
 	utils.InfoLog.Info("Configuración cargada", "nivel_log", config.LogLevel, "config_path", rutaConfig)
+	if err := utils.InicializarTrazas("Memoria", config.TrazasPath); err != nil {
+		utils.ErrorLog.Error("No se pudieron habilitar las trazas", "error", err)
+	}
+	utils.HabilitarFirma(config.SecretoCompartido)
+	utils.HabilitarTokenAdmin(config.TokenAdmin)
+	if err := utils.HabilitarTLS(config.OpcionesTLS); err != nil {
+		utils.ErrorLog.Error("No se pudo habilitar TLS", "error", err)
+		os.Exit(1)
+	}
 
 	// Verificar directorio de dumps
 	if err := os.MkdirAll(config.DumpPath, 0755); err != nil {
@@ -73,44 +91,95 @@ func inicializarModulo() {
 	// Registrar handlers
 	registrarHandlers()
 
+	// Los reintentos de Kernel y CPU no deben aplicarse dos veces
+	modulo.HabilitarIdempotencia(ttlIdempotencia)
+
 	// Iniciar servidor
-	modulo.IniciarServidor(config.IPMemory, config.PortMemory)
+	modulo.ReportarCarga(cargaMemoria)
+	registrarMetricas()
+	modulo.AlFinalizar("sincronizar swap", sincronizarSwap)
+	if err := modulo.Iniciar(config.IPMemory, config.PortMemory); err != nil {
+		utils.ErrorLog.Error("No se pudo iniciar el servidor", "error", err)
+		os.Exit(1)
+	}
+	modulo.MarcarListo()
 	utils.InfoLog.Info("Servidor iniciado", "ip", config.IPMemory, "puerto", config.PortMemory)
-
-	httpServer = modulo.Server
 }
 
 func registrarHandlers() {
-	modulo.RegistrarHandler(strconv.Itoa(utils.MensajeHandshake), "handshake", handlerHandshake)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeHandshake, "handshake", handlerHandshake)
 	modulo.RegistrarHandler(strconv.Itoa(utils.MensajeOperacion), "default", handlerOperacion)
-	modulo.RegistrarHandler(strconv.Itoa(utils.MensajeObtenerInstruccion), "default", handlerObtenerInstruccion)
-	modulo.RegistrarHandler(strconv.Itoa(utils.MensajeFetch), "default", handlerObtenerInstruccion)
-	modulo.RegistrarHandler(strconv.Itoa(utils.MensajeEspacioLibre), "default", handlerEspacioLibre)
-	modulo.RegistrarHandler(strconv.Itoa(utils.MensajeInicializarProceso), "default", handlerInicializarProceso)
-	modulo.RegistrarHandler(strconv.Itoa(utils.MensajeFinalizarProceso), "default", handlerFinalizarProceso)
-	modulo.RegistrarHandler(strconv.Itoa(utils.MensajeLeer), "default", handlerLeerMemoria)
-	modulo.RegistrarHandler(strconv.Itoa(utils.MensajeEscribir), "default", handlerEscribirMemoria)
-	modulo.RegistrarHandler(strconv.Itoa(utils.MensajeObtenerMarco), "default", handlerObtenerMarco)
-	modulo.RegistrarHandler(strconv.Itoa(utils.MensajeSuspenderProceso), "default", handlerSuspenderProceso)
-	modulo.RegistrarHandler(strconv.Itoa(utils.MensajeDessuspenderProceso), "default", handlerDessuspenderProceso)
-	modulo.RegistrarHandler(strconv.Itoa(utils.MensajeMemoryDump), "default", handlerMemoryDump)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeObtenerInstruccion, "default", handlerObtenerInstruccion)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeFetch, "default", handlerObtenerInstruccion)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeEspacioLibre, "default", handlerEspacioLibre)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeInicializarProceso, "default", handlerInicializarProceso)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeFinalizarProceso, "default", handlerFinalizarProceso)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeLeer, "default", handlerLeerMemoria)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeEscribir, "default", handlerEscribirMemoria)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeObtenerMarco, "default", handlerObtenerMarco)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeSuspenderProceso, "default", handlerSuspenderProceso)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeDessuspenderProceso, "default", handlerDessuspenderProceso)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeMemoryDump, "default", handlerMemoryDump)
+	utils.RegistrarHandlerTipado(modulo, utils.MensajeListarMarcos, "default", handlerListarMarcos)
+
+	modulo.RegistrarRuta("GET /memoria/marcos", utils.MensajeListarMarcos, "default", nil)
+
+	// Las CPUs acceden a instrucciones, datos y marcos; el Kernel administra los procesos.
+	// Todo acceso a memoria paga RETARDO_MEMORIA, salvo las consultas REST.
+	modulo.Usar(
+		utils.Recuperacion(),
+		utils.Temporizacion(umbralMensajeLento+time.Duration(config.MemoryDelay)*time.Millisecond),
+		utils.Auditoria(),
+		utils.Autorizacion(utils.Permisos{
+			utils.MensajeFetch:               {"CPU"},
+			utils.MensajeObtenerInstruccion:  {"CPU"},
+			utils.MensajeLeer:                {"CPU"},
+			utils.MensajeEscribir:            {"CPU"},
+			utils.MensajeObtenerMarco:        {"CPU"},
+			utils.MensajeInicializarProceso:  {"Kernel"},
+			utils.MensajeFinalizarProceso:    {"Kernel"},
+			utils.MensajeSuspenderProceso:    {"Kernel"},
+			utils.MensajeDessuspenderProceso: {"Kernel"},
+			utils.MensajeEspacioLibre:        {"Kernel"},
+			utils.MensajeMemoryDump:          {"Kernel"},
+		}),
+		utils.Retardo(config.MemoryDelay, map[int]int{utils.MensajeListarMarcos: 0}),
+	)
 
 	utils.InfoLog.Info("Handlers registrados correctamente")
 }
 
 // Handler para handshake
-func handlerHandshake(msg *utils.Mensaje) (interface{}, error) {
-	utils.InfoLog.Info("Handshake recibido", "origen", msg.Origen)
+func handlerHandshake(msg *utils.Mensaje, solicitud utils.SolicitudHandshake) (utils.RespuestaHandshake, error) {
+	utils.InfoLog.Info("Handshake recibido", "origen", msg.Origen, "version", solicitud.Version)
+
+	return utils.RespuestaHandshake{
+		Status:           "OK",
+		Version:          utils.VersionProtocolo,
+		TamPagina:        config.PageSize,
+		EntradasPorTabla: config.EntriesPerPage,
+		Niveles:          config.NumberOfLevels,
+	}, nil
+}
 
-	// Aplicar retardo de memoria
-	utils.AplicarRetardo("handshake", config.MemoryDelay)
+// cargaMemoria informa en /health los marcos libres y los procesos cargados
+func cargaMemoria() map[string]int {
+	libres := 0
+	for _, libre := range marcosLibres {
+		if libre {
+			libres++
+		}
+	}
 
-	return map[string]interface{}{
-		"status":           "OK",
-		"tam_pagina":       config.PageSize,
-		"entradas_por_pag": config.EntriesPerPage,
-		"niveles":          config.NumberOfLevels,
-	}, nil
+	instruccionesMutex.RLock()
+	procesos := len(instruccionesPorProceso)
+	instruccionesMutex.RUnlock()
+
+	return map[string]int{
+		"marcos_libres": libres,
+		"marcos_total":  len(marcosLibres),
+		"procesos":      procesos,
+	}
 }
 
 func procesarOperacion(msg *utils.Mensaje) (interface{}, error) {
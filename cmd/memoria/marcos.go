@@ -1,8 +1,6 @@
 package main
 
 import (
-	"fmt"
-
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
 )
 
@@ -33,7 +31,7 @@ func asignarMarco(pid int) (int, error) {
 	}
 
 	utils.ErrorLog.Error("No hay marcos libres disponibles", "pid", pid)
-	return 0, fmt.Errorf("no hay marcos libres disponibles")
+	return 0, utils.NuevoError(utils.ErrorMemoriaSinEspacio, "no hay marcos libres disponibles")
 }
 
 // Cuenta el número de marcos libres disponibles
@@ -57,7 +55,7 @@ func liberarMemoriaProceso(pid int) error {
 	marcos, existe := marcosAsignadosPorProceso[pid]
 	if !existe {
 		utils.ErrorLog.Error("No existe asignación de memoria", "pid", pid)
-		return fmt.Errorf("no existe asignación de memoria para el proceso %d", pid)
+		return utils.NuevoError(utils.ErrorPIDInexistente, "no existe asignación de memoria para el proceso %d", pid)
 	}
 
 	utils.InfoLog.Info("Marcos a liberar", "pid", pid, "cantidad_marcos", len(marcos), "marcos", marcos)
@@ -83,6 +81,12 @@ func liberarMemoriaProceso(pid int) error {
 	delete(tablasPaginas, pid)
 
 	utils.InfoLog.Info("Memoria liberada completamente", "pid", pid, "marcos_liberados", len(marcos))
+	modulo.Publicar(utils.TemaMarcosLiberados, utils.DatosMarcosLiberados{
+		PID:    pid,
+		Marcos: len(marcos),
+		Libres: contarMarcosLibres(),
+		Motivo: "FINALIZACION",
+	})
 
 	return nil
 }
@@ -4,6 +4,38 @@ import (
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
 )
 
+// Totales de todos los procesos expuestos en /metrics
+var (
+	contadorAccesosTabla  = utils.NuevoContador("memoria_accesos_tablas_paginas_total", "Accesos a tablas de páginas.")
+	contadorInstrucciones = utils.NuevoContador("memoria_instrucciones_solicitadas_total", "Instrucciones entregadas a las CPUs.")
+	contadorBajadasSwap   = utils.NuevoContador("memoria_swap_out_total", "Páginas escritas en SWAP.")
+	contadorSubidasSwap   = utils.NuevoContador("memoria_swap_in_total", "Páginas recuperadas de SWAP.")
+	contadorLecturas      = utils.NuevoContador("memoria_lecturas_total", "Lecturas de memoria de usuario.")
+	contadorEscrituras    = utils.NuevoContador("memoria_escrituras_total", "Escrituras en memoria de usuario.")
+	contadorFallosPagina  = utils.NuevoContador("memoria_fallos_pagina_total", "Accesos a páginas sin marco o fuera de memoria principal.", "motivo")
+)
+
+// registrarMetricas define los medidores de marcos que se calculan al consultar /metrics
+func registrarMetricas() {
+	contadorFallosPagina.Sumar(0, "sin_marco")
+	contadorFallosPagina.Sumar(0, "swap")
+
+	utils.NuevoMedidor("memoria_marcos_libres", "Marcos de memoria principal libres.", func() []utils.Muestra {
+		return []utils.Muestra{{Valor: float64(cargaMemoria()["marcos_libres"])}}
+	})
+	utils.NuevoMedidor("memoria_marcos_total", "Marcos de memoria principal.", func() []utils.Muestra {
+		return []utils.Muestra{{Valor: float64(len(marcosLibres))}}
+	})
+}
+
+// actualizarMetricasFalloPagina cuenta un fallo de página: "sin_marco" si la página
+// todavía no tenía marco asignado, "swap" si hubo que traerla de SWAP
+func actualizarMetricasFalloPagina(pid int, motivo string) {
+	contadorFallosPagina.Incrementar(motivo)
+
+	utils.InfoLog.Info("Fallo de página", "pid", pid, "motivo", motivo)
+}
+
 // Funciones para actualizar métricas
 
 // Actualizar métricas de acceso a tablas de páginas
@@ -12,6 +44,7 @@ func actualizarMetricasAccesoTabla(pid int) {
 		metricasPorProceso[pid] = &MetricasProceso{}
 	}
 	metricasPorProceso[pid].AccesosTablasPaginas++
+	contadorAccesosTabla.Incrementar()
 
 	utils.InfoLog.Info("Acceso a tabla de páginas", "pid", pid, "total_accesos", metricasPorProceso[pid].AccesosTablasPaginas)
 }
@@ -22,6 +55,7 @@ func actualizarMetricasInstruccion(pid int) {
 		metricasPorProceso[pid] = &MetricasProceso{}
 	}
 	metricasPorProceso[pid].InstruccionesSolicitadas++
+	contadorInstrucciones.Incrementar()
 
 	utils.InfoLog.Info("Instrucción solicitada", "pid", pid, "total_instrucciones", metricasPorProceso[pid].InstruccionesSolicitadas)
 }
@@ -32,6 +66,7 @@ func actualizarMetricasBajadaSwap(pid int) {
 		metricasPorProceso[pid] = &MetricasProceso{}
 	}
 	metricasPorProceso[pid].BajadasSwap++
+	contadorBajadasSwap.Incrementar()
 
 	utils.InfoLog.Info("Bajada a SWAP", "pid", pid, "total_bajadas", metricasPorProceso[pid].BajadasSwap)
 }
@@ -42,6 +77,7 @@ func actualizarMetricasSubidaMemoria(pid int) {
 		metricasPorProceso[pid] = &MetricasProceso{}
 	}
 	metricasPorProceso[pid].SubidasMemoria++
+	contadorSubidasSwap.Incrementar()
 
 	utils.InfoLog.Info("Subida a memoria", "pid", pid, "total_subidas", metricasPorProceso[pid].SubidasMemoria)
 }
@@ -52,6 +88,7 @@ func actualizarMetricasLectura(pid int) {
 		metricasPorProceso[pid] = &MetricasProceso{}
 	}
 	metricasPorProceso[pid].LecturasMemoria++
+	contadorLecturas.Incrementar()
 
 	utils.InfoLog.Info("Lectura de memoria", "pid", pid, "total_lecturas", metricasPorProceso[pid].LecturasMemoria)
 }
@@ -62,6 +99,7 @@ func actualizarMetricasEscritura(pid int) {
 		metricasPorProceso[pid] = &MetricasProceso{}
 	}
 	metricasPorProceso[pid].EscriturasMemoria++
+	contadorEscrituras.Incrementar()
 
 	utils.InfoLog.Info("Escritura en memoria", "pid", pid, "total_escrituras", metricasPorProceso[pid].EscriturasMemoria)
 }
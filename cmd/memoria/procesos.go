@@ -44,7 +44,7 @@ func cargarInstrucciones(pid int) error {
 	instruccionesMutex.Unlock()
 
 	// Log obligatorio del enunciado
-	utils.InfoLog.Info(fmt.Sprintf("## PID: %d - Proceso Creado - Tamaño: %d",
+	utils.ObligatorioLog.Info(fmt.Sprintf("## PID: %d - Proceso Creado - Tamaño: %d",
 		pid, len(instruccionesFiltradas)))
 
 	utils.InfoLog.Info("Instrucciones cargadas exitosamente", "pid", pid, "instrucciones", len(instruccionesFiltradas))
@@ -64,7 +64,7 @@ func copiarPseudocodigo(origen string, destino string) error {
 	input, err := os.ReadFile(rutaCompleta)
 	if err != nil {
 		utils.ErrorLog.Error("Error leyendo archivo origen", "archivo", rutaCompleta, "error", err)
-		return err
+		return utils.NuevoError(utils.ErrorArchivoInexistente, "no se pudo leer el pseudocódigo %s: %v", rutaCompleta, err)
 	}
 
 	err = os.WriteFile(destino, input, 0644)
@@ -89,7 +89,7 @@ func suspenderProceso(pid int) error {
 	marcos, existe := marcosAsignadosPorProceso[pid]
 	if !existe {
 		utils.ErrorLog.Error("Proceso sin marcos asignados", "pid", pid)
-		return fmt.Errorf("el proceso %d no tiene marcos asignados", pid)
+		return utils.NuevoError(utils.ErrorPIDInexistente, "el proceso %d no tiene marcos asignados", pid)
 	}
 
 	// Crear una copia de los marcos para evitar modificaciones durante iteración
@@ -100,7 +100,7 @@ func suspenderProceso(pid int) error {
 	tabla, existeTabla := tablasPaginas[pid]
 	if !existeTabla {
 		utils.ErrorLog.Error("Proceso sin tabla de páginas", "pid", pid)
-		return fmt.Errorf("el proceso %d no tiene tabla de páginas", pid)
+		return utils.NuevoError(utils.ErrorPIDInexistente, "el proceso %d no tiene tabla de páginas", pid)
 	}
 
 	utils.InfoLog.Info("Proceso a suspender", "pid", pid, "marcos_asignados", len(marcosCopia))
@@ -144,9 +144,15 @@ func suspenderProceso(pid int) error {
 	}
 
 	// Log obligatorio del enunciado
-	utils.InfoLog.Info(fmt.Sprintf("## PID: %d - Proceso suspendido a SWAP", pid))
+	utils.ObligatorioLog.Info(fmt.Sprintf("## PID: %d - Proceso suspendido a SWAP", pid))
 
 	utils.InfoLog.Info("Proceso suspendido correctamente", "pid", pid)
+	modulo.Publicar(utils.TemaMarcosLiberados, utils.DatosMarcosLiberados{
+		PID:    pid,
+		Marcos: len(marcosCopia),
+		Libres: contarMarcosLibres(),
+		Motivo: "SUSPENSION",
+	})
 	return nil
 }
 
@@ -158,7 +164,7 @@ func dessuspenderProceso(pid int) error {
 	tabla, existeTabla := tablasPaginas[pid]
 	if !existeTabla {
 		utils.ErrorLog.Error("Proceso sin tabla de páginas", "pid", pid)
-		return fmt.Errorf("el proceso %d no tiene tabla de páginas", pid)
+		return utils.NuevoError(utils.ErrorPIDInexistente, "el proceso %d no tiene tabla de páginas", pid)
 	}
 
 	// Buscar todas las entradas de SWAP para este proceso
@@ -184,7 +190,7 @@ func dessuspenderProceso(pid int) error {
 	marcosDisponibles := contarMarcosLibres()
 	if marcosDisponibles < marcosNecesarios {
 		utils.ErrorLog.Error("Marcos insuficientes para dessuspensión", "pid", pid, "necesarios", marcosNecesarios, "disponibles", marcosDisponibles)
-		return fmt.Errorf("no hay suficientes marcos libres para dessuspender el proceso %d: "+
+		return utils.NuevoError(utils.ErrorMemoriaSinEspacio, "no hay suficientes marcos libres para dessuspender el proceso %d: "+
 			"necesita %d, disponibles %d", pid, marcosNecesarios, marcosDisponibles)
 	}
 
@@ -201,7 +207,7 @@ func dessuspenderProceso(pid int) error {
 				marcosLibres[m] = true
 			}
 			utils.ErrorLog.Error("Error asignando marco", "pid", pid, "error", err)
-			return fmt.Errorf("error asignando marco: %v", err)
+			return fmt.Errorf("error asignando marco: %w", err)
 		}
 		marcosAsignados = append(marcosAsignados, marco)
 
@@ -224,7 +230,7 @@ func dessuspenderProceso(pid int) error {
 	marcosAsignadosPorProceso[pid] = marcosAsignados
 
 	// Log obligatorio del enunciado
-	utils.InfoLog.Info(fmt.Sprintf("## PID: %d - Proceso dessuspendido desde SWAP", pid))
+	utils.ObligatorioLog.Info(fmt.Sprintf("## PID: %d - Proceso dessuspendido desde SWAP", pid))
 
 	utils.InfoLog.Info("Proceso dessuspendido correctamente", "pid", pid, "marcos_asignados", len(marcosAsignados))
 	return nil
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -107,7 +108,7 @@ func moverASwap(pid int, numPagina int, marco int) (int64, error) {
 	actualizarMetricasBajadaSwap(pid)
 
 	// Log obligatorio del enunciado
-	utils.InfoLog.Info(fmt.Sprintf("## PID: %d - Datos movidos a SWAP - Página: %d", pid, numPagina))
+	utils.ObligatorioLog.Info(fmt.Sprintf("## PID: %d - Datos movidos a SWAP - Página: %d", pid, numPagina))
 
 	utils.InfoLog.Info("Página movida a SWAP exitosamente", "pid", pid, "pagina", numPagina, "offset", offset)
 
@@ -173,9 +174,37 @@ func recuperarDeSwap(pid int, numPagina int, marco int) error {
 	actualizarMetricasSubidaMemoria(pid)
 
 	// Log obligatorio del enunciado
-	utils.InfoLog.Info(fmt.Sprintf("## PID: %d - Página %d recuperada de SWAP al marco %d", pid, numPagina, marco))
+	utils.ObligatorioLog.Info(fmt.Sprintf("## PID: %d - Página %d recuperada de SWAP al marco %d", pid, numPagina, marco))
 
 	utils.InfoLog.Info("Página recuperada exitosamente", "pid", pid, "pagina", numPagina, "marco", marco)
 
 	return nil
 }
+
+// sincronizarSwap fuerza a disco lo escrito en el archivo de SWAP antes de salir
+func sincronizarSwap(ctx context.Context) error {
+	swapMutex.Lock()
+	defer swapMutex.Unlock()
+
+	swapFile, err := os.OpenFile(config.SwapfilePath, os.O_WRONLY, 0644)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("no se pudo abrir el archivo SWAP: %w", err)
+	}
+	defer swapFile.Close()
+
+	if err := swapFile.Sync(); err != nil {
+		return fmt.Errorf("no se pudo sincronizar el archivo SWAP: %w", err)
+	}
+
+	enUso := 0
+	for _, entrada := range mapaSwap {
+		if entrada.EnUso {
+			enUso++
+		}
+	}
+	utils.InfoLog.Info("SWAP sincronizado", "archivo", config.SwapfilePath, "paginas_en_swap", enUso)
+	return nil
+}
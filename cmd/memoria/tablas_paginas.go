@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"sync"
 
 	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
@@ -28,14 +27,14 @@ func crearTablasPaginas(pid int, tamanio int) (*TablaPaginas, error) {
 	marcosFree := contarMarcosLibres()
 	if marcosFree < numPaginas {
 		utils.ErrorLog.Error("Marcos insuficientes", "pid", pid, "marcos_libres", marcosFree, "paginas_requeridas", numPaginas)
-		return nil, fmt.Errorf("no hay suficientes marcos libres (%d) para el proceso %d que requiere %d páginas",
+		return nil, utils.NuevoError(utils.ErrorMemoriaSinEspacio, "no hay suficientes marcos libres (%d) para el proceso %d que requiere %d páginas",
 			marcosFree, pid, numPaginas)
 	}
 
 	// Verificar que no exista ya una tabla para este PID (prevención adicional)
 	if _, existe := tablasPaginas[pid]; existe {
 		utils.InfoLog.Warn("Tabla de páginas ya existe para el proceso", "pid", pid)
-		return nil, fmt.Errorf("tabla de páginas ya existe para el proceso %d", pid)
+		return nil, utils.NuevoError(utils.ErrorProcesoDuplicado, "tabla de páginas ya existe para el proceso %d", pid)
 	}
 
 	// Crear tabla de nivel 1
@@ -84,6 +83,7 @@ func obtenerMarcoDesdeTabla(pid int, tabla *TablaPaginas, numPagina int, nivelAc
 		} else {
 			// En el último nivel, asignar un marco
 			utils.InfoLog.Info("Último nivel, asignando marco", "pid", pid, "pagina", numPagina)
+			actualizarMetricasFalloPagina(pid, "sin_marco")
 			marco, err := asignarMarco(pid)
 			if err != nil {
 				utils.ErrorLog.Error("Error asignando marco", "pid", pid, "error", err)
@@ -104,6 +104,7 @@ func obtenerMarcoDesdeTabla(pid int, tabla *TablaPaginas, numPagina int, nivelAc
 	if nivelActual == config.NumberOfLevels {
 		if !tabla.Entradas[indice].Presente {
 			utils.InfoLog.Info("Página no presente, trayendo de SWAP", "pid", pid, "pagina", numPagina)
+			actualizarMetricasFalloPagina(pid, "swap")
 			// Traer página de SWAP si es necesario
 			err := traerPaginaDeSwap(pid, numPagina, tabla.Entradas[indice].Marco)
 			if err != nil {
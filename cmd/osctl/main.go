@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+)
+
+// estados en el orden en que se muestran las colas
+var estados = []string{"NEW", "READY", "BLOCKED", "SUSP. READY", "SUSP. BLOCKED", "EXIT"}
+
+// clienteKernel llama a la API REST del Kernel
+type clienteKernel struct {
+	base  string
+	token string
+	http  *http.Client
+	json  bool
+}
+
+func main() {
+	kernel := flag.String("kernel", direccionPorDefecto(), "IP:PUERTO del Kernel")
+	ca := flag.String("ca", os.Getenv("SO_TLS_CA"), "CA para TLS mutuo")
+	certificado := flag.String("cert", os.Getenv("SO_TLS_CERTIFICADO"), "certificado de cliente para TLS mutuo")
+	clave := flag.String("key", os.Getenv("SO_TLS_CLAVE"), "clave del certificado de cliente")
+	salidaJSON := flag.Bool("json", false, "mostrar la respuesta JSON del Kernel sin formatear")
+	timeout := flag.Duration("timeout", 5*time.Second, "timeout de cada solicitud")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Uso: %s [opciones] <comando> [argumentos]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Comandos:")
+		fmt.Fprintln(os.Stderr, "  ps [-a]                  procesos sin finalizar (-a: también los finalizados)")
+		fmt.Fprintln(os.Stderr, "  run ARCHIVO TAMAÑO [PRIO] crea un proceso en NEW con el pseudocódigo ARCHIVO")
+		fmt.Fprintln(os.Stderr, "  kill PID                 finaliza un proceso en cualquier estado")
+		fmt.Fprintln(os.Stderr, "  queues                   PIDs de cada cola de planificación")
+		fmt.Fprintln(os.Stderr, "  metrics PID              métricas de estado de un proceso")
+		fmt.Fprintln(os.Stderr, "  sched                    estado de los planificadores")
+		fmt.Fprintln(os.Stderr, "  pause [largo|corto]      detiene la planificación (los dos planificadores si no se indica)")
+		fmt.Fprintln(os.Stderr, "  resume [largo|corto]     reanuda la planificación (los dos planificadores si no se indica)")
+		fmt.Fprintln(os.Stderr, "\nEl token de la API se toma de SO_TOKEN_ADMIN. Opciones:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Los logs de utils (TLS) no son parte de la salida del comando
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	opcionesTLS := utils.OpcionesTLS{TLSCA: *ca, TLSCertificado: *certificado, TLSClave: *clave}
+	if err := utils.HabilitarTLS(opcionesTLS); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cliente := &clienteKernel{
+		base:  "http://" + *kernel,
+		token: os.Getenv("SO_TOKEN_ADMIN"),
+		http:  &http.Client{Timeout: *timeout},
+		json:  *salidaJSON,
+	}
+	if configTLS := utils.ConfigTLSCliente(); configTLS != nil {
+		cliente.base = "https://" + *kernel
+		cliente.http.Transport = &http.Transport{TLSClientConfig: configTLS}
+	}
+
+	if err := ejecutar(cliente, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// direccionPorDefecto arma IP:PUERTO del Kernel con las mismas variables que su configuración
+func direccionPorDefecto() string {
+	ip := os.Getenv("SO_IP_KERNEL")
+	if ip == "" {
+		ip = "127.0.0.1"
+	}
+	puerto := os.Getenv("SO_PUERTO_KERNEL")
+	if puerto == "" {
+		puerto = "8001"
+	}
+	return ip + ":" + puerto
+}
+
+// ejecutar atiende un comando con sus argumentos
+func ejecutar(c *clienteKernel, comando string, args []string) error {
+	switch comando {
+	case "ps":
+		todos := len(args) == 1 && args[0] == "-a"
+		if len(args) > 1 || (len(args) == 1 && !todos) {
+			return fmt.Errorf("uso: ps [-a]")
+		}
+		return c.ps(todos)
+	case "run":
+		if len(args) != 2 && len(args) != 3 {
+			return fmt.Errorf("uso: run ARCHIVO TAMAÑO [PRIORIDAD]")
+		}
+		tamanio, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("tamaño inválido: %q", args[1])
+		}
+		prioridad := 0
+		if len(args) == 3 {
+			if prioridad, err = strconv.Atoi(args[2]); err != nil {
+				return fmt.Errorf("prioridad inválida: %q", args[2])
+			}
+		}
+		return c.run(args[0], tamanio, prioridad)
+	case "kill":
+		pid, err := argumentoPID("kill", args)
+		if err != nil {
+			return err
+		}
+		return c.kill(pid)
+	case "queues":
+		if len(args) != 0 {
+			return fmt.Errorf("uso: queues")
+		}
+		return c.queues()
+	case "metrics":
+		pid, err := argumentoPID("metrics", args)
+		if err != nil {
+			return err
+		}
+		return c.metrics(pid)
+	case "sched":
+		if len(args) != 0 {
+			return fmt.Errorf("uso: sched")
+		}
+		return c.planificacion(http.MethodGet, "/planificacion")
+	case "pause", "resume":
+		planificador, err := argumentoPlanificador(comando, args)
+		if err != nil {
+			return err
+		}
+		accion := "detener"
+		if comando == "resume" {
+			accion = "iniciar"
+		}
+		return c.planificacion(http.MethodPost, "/planificacion/"+planificador+"/"+accion)
+	default:
+		return fmt.Errorf("comando desconocido %q (ver %s -h)", comando, os.Args[0])
+	}
+}
+
+func argumentoPID(comando string, args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("uso: %s PID", comando)
+	}
+	pid, err := strconv.Atoi(args[0])
+	if err != nil || pid < 0 {
+		return 0, fmt.Errorf("PID inválido: %q", args[0])
+	}
+	return pid, nil
+}
+
+// argumentoPlanificador valida el planificador opcional de pause y resume
+func argumentoPlanificador(comando string, args []string) (string, error) {
+	if len(args) == 0 {
+		return utils.PlanificadorTodos, nil
+	}
+	if len(args) > 1 || (args[0] != utils.PlanificadorLargoPlazo && args[0] != utils.PlanificadorCortoPlazo) {
+		return "", fmt.Errorf("uso: %s [largo|corto]", comando)
+	}
+	return args[0], nil
+}
+
+func (c *clienteKernel) ps(todos bool) error {
+	ruta := "/procesos"
+	if todos {
+		ruta += "?finalizados=true"
+	}
+	var respuesta utils.RespuestaProcesos
+	if err := c.llamar(http.MethodGet, ruta, nil, &respuesta); err != nil || c.json {
+		return err
+	}
+
+	tabla := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabla, "PID\tESTADO\tARCHIVO\tTAMAÑO\tPC\tPRIO\tCPU\tMOTIVO")
+	for _, p := range respuesta.Procesos {
+		fmt.Fprintf(tabla, "%d\t%s\t%s\t%d\t%d\t%d\t%s\t%s\n", p.PID, p.Estado, p.Archivo, p.Tamanio, p.PC, p.Prioridad, guionSiVacio(p.CPU), guionSiVacio(p.MotivoBloqueo))
+	}
+	return tabla.Flush()
+}
+
+func (c *clienteKernel) run(archivo string, tamanio int, prioridad int) error {
+	var respuesta utils.RespuestaCrearProceso
+	solicitud := utils.SolicitudCrearProceso{Archivo: archivo, Tamanio: tamanio, Prioridad: prioridad}
+	if err := c.llamar(http.MethodPost, "/procesos", solicitud, &respuesta); err != nil || c.json {
+		return err
+	}
+	fmt.Printf("Proceso %d creado (%s, %d bytes)\n", respuesta.PID, archivo, tamanio)
+	return nil
+}
+
+func (c *clienteKernel) kill(pid int) error {
+	var respuesta utils.RespuestaEstado
+	if err := c.llamar(http.MethodDelete, fmt.Sprintf("/procesos/%d", pid), nil, &respuesta); err != nil || c.json {
+		return err
+	}
+	fmt.Println(respuesta.Mensaje)
+	return nil
+}
+
+func (c *clienteKernel) queues() error {
+	var respuesta utils.RespuestaColas
+	if err := c.llamar(http.MethodGet, "/colas", nil, &respuesta); err != nil || c.json {
+		return err
+	}
+
+	tabla := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, estado := range estados {
+		pids := make([]string, 0, len(respuesta.Colas[estado]))
+		for _, pid := range respuesta.Colas[estado] {
+			pids = append(pids, strconv.Itoa(pid))
+		}
+		fmt.Fprintf(tabla, "%s\t%s\n", estado, guionSiVacio(strings.Join(pids, " ")))
+	}
+	exec := make([]string, 0, len(respuesta.Exec))
+	for cpu, pid := range respuesta.Exec {
+		exec = append(exec, fmt.Sprintf("%s=%d", cpu, pid))
+	}
+	sort.Strings(exec)
+	fmt.Fprintf(tabla, "EXEC\t%s\n", guionSiVacio(strings.Join(exec, " ")))
+	fmt.Fprintf(tabla, "Multiprogramación\t%d/%d\n", respuesta.Multiprogramacion, respuesta.GradoMultiprogramacion)
+	return tabla.Flush()
+}
+
+func (c *clienteKernel) metrics(pid int) error {
+	var m utils.RespuestaMetricasProceso
+	if err := c.llamar(http.MethodGet, fmt.Sprintf("/procesos/%d/metricas", pid), nil, &m); err != nil || c.json {
+		return err
+	}
+
+	fmt.Printf("Proceso %d (%s)\n", m.PID, m.Estado)
+	tabla := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabla, "ESTADO\tVECES\tSEGUNDOS")
+	fmt.Fprintf(tabla, "NEW\t%d\t%.2f\n", m.VecesNew, m.SegundosNew)
+	fmt.Fprintf(tabla, "READY\t%d\t%.2f\n", m.VecesReady, m.SegundosReady)
+	fmt.Fprintf(tabla, "EXEC\t%d\t%.2f\n", m.VecesExec, m.SegundosExec)
+	fmt.Fprintf(tabla, "BLOCKED\t%d\t%.2f\n", m.VecesBlocked, m.SegundosBlocked)
+	return tabla.Flush()
+}
+
+// planificacion consulta o cambia la planificación y muestra cómo quedó cada planificador
+func (c *clienteKernel) planificacion(metodo string, ruta string) error {
+	var respuesta utils.RespuestaPlanificacion
+	if err := c.llamar(metodo, ruta, nil, &respuesta); err != nil || c.json {
+		return err
+	}
+
+	tabla := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tabla, "Largo plazo\t%s\n", activoODetenido(respuesta.LargoPlazo))
+	fmt.Fprintf(tabla, "Corto plazo\t%s\n", activoODetenido(respuesta.CortoPlazo))
+	return tabla.Flush()
+}
+
+// llamar hace la solicitud y decodifica la respuesta en destino. Con -json la imprime
+// tal cual; los errores del Kernel se muestran con su código.
+func (c *clienteKernel) llamar(metodo string, ruta string, datos interface{}, destino interface{}) error {
+	var cuerpo io.Reader
+	if datos != nil {
+		crudo, err := json.Marshal(datos)
+		if err != nil {
+			return err
+		}
+		cuerpo = bytes.NewReader(crudo)
+	}
+
+	solicitud, err := http.NewRequest(metodo, c.base+ruta, cuerpo)
+	if err != nil {
+		return err
+	}
+	if datos != nil {
+		solicitud.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		solicitud.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	respuesta, err := c.http.Do(solicitud)
+	if err != nil {
+		return fmt.Errorf("no se pudo contactar al Kernel: %v", err)
+	}
+	defer respuesta.Body.Close()
+	crudo, err := io.ReadAll(respuesta.Body)
+	if err != nil {
+		return fmt.Errorf("error leyendo la respuesta del Kernel: %v", err)
+	}
+
+	if respuesta.StatusCode >= 300 {
+		var sobre struct {
+			Error *utils.ErrorModulo `json:"error"`
+		}
+		if json.Unmarshal(crudo, &sobre) == nil && sobre.Error != nil {
+			return sobre.Error
+		}
+		return fmt.Errorf("el Kernel respondió %s: %s", respuesta.Status, strings.TrimSpace(string(crudo)))
+	}
+
+	if c.json {
+		fmt.Println(strings.TrimSpace(string(crudo)))
+		return nil
+	}
+	if err := json.Unmarshal(crudo, destino); err != nil {
+		return fmt.Errorf("respuesta del Kernel con formato inválido: %v", err)
+	}
+	return nil
+}
+
+func activoODetenido(activo bool) string {
+	if activo {
+		return "activo"
+	}
+	return "detenido"
+}
+
+func guionSiVacio(valor string) string {
+	if valor == "" {
+		return "-"
+	}
+	return valor
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/GonzaloPontnau/SISTEMA-OPERATIVO.go.git/utils"
+)
+
+// eventoChrome es un evento del formato Trace Event que leen chrome://tracing y Perfetto
+type eventoChrome struct {
+	Nombre    string                 `json:"name"`
+	Categoria string                 `json:"cat,omitempty"`
+	Fase      string                 `json:"ph"`
+	Inicio    int64                  `json:"ts"`
+	Duracion  int64                  `json:"dur,omitempty"`
+	PID       int                    `json:"pid"`
+	TID       int                    `json:"tid"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+}
+
+type archivoChrome struct {
+	Eventos       []eventoChrome `json:"traceEvents"`
+	UnidadDisplay string         `json:"displayTimeUnit"`
+}
+
+func main() {
+	salida := flag.String("salida", "trazas.json", "archivo de salida en formato Chrome/Perfetto")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Uso: %s [-salida archivo.json] <trazas.jsonl>...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Ejemplo: %s -salida corrida.json logs/trazas/*.jsonl\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var spans []utils.Span
+	for _, ruta := range flag.Args() {
+		leidos, err := leerSpans(ruta)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		spans = append(spans, leidos...)
+	}
+
+	archivo, err := os.Create(*salida)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error al crear %s: %v\n", *salida, err)
+		os.Exit(1)
+	}
+	defer archivo.Close()
+
+	if err := json.NewEncoder(archivo).Encode(convertirAChrome(spans)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error al escribir %s: %v\n", *salida, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d spans exportados a %s\n", len(spans), *salida)
+}
+
+// leerSpans lee un archivo JSONL de spans, ignorando las líneas que no se pueden decodificar
+func leerSpans(ruta string) ([]utils.Span, error) {
+	archivo, err := os.Open(ruta)
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir %s: %v", ruta, err)
+	}
+	defer archivo.Close()
+
+	var spans []utils.Span
+	scanner := bufio.NewScanner(archivo)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var span utils.Span
+		if err := json.Unmarshal(scanner.Bytes(), &span); err != nil {
+			fmt.Fprintf(os.Stderr, "Línea inválida en %s: %v\n", ruta, err)
+			continue
+		}
+		spans = append(spans, span)
+	}
+	return spans, scanner.Err()
+}
+
+// convertirAChrome agrupa los spans en un proceso por módulo y un hilo por traza,
+// así los spans concurrentes de distintas trazas no se solapan en la misma fila
+func convertirAChrome(spans []utils.Span) archivoChrome {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].InicioUs < spans[j].InicioUs })
+
+	pids := make(map[string]int)
+	tids := make(map[string]map[string]int)
+	var eventos []eventoChrome
+
+	for _, span := range spans {
+		pid, existe := pids[span.Modulo]
+		if !existe {
+			pid = len(pids) + 1
+			pids[span.Modulo] = pid
+			tids[span.Modulo] = make(map[string]int)
+			eventos = append(eventos, eventoChrome{
+				Nombre: "process_name",
+				Fase:   "M",
+				PID:    pid,
+				Args:   map[string]interface{}{"name": span.Modulo},
+			})
+		}
+
+		tid, existe := tids[span.Modulo][span.TraceID]
+		if !existe {
+			tid = len(tids[span.Modulo]) + 1
+			tids[span.Modulo][span.TraceID] = tid
+		}
+
+		args := map[string]interface{}{
+			"trace_id": span.TraceID,
+			"span_id":  span.SpanID,
+		}
+		if span.SpanPadre != "" {
+			args["span_padre"] = span.SpanPadre
+		}
+		if span.Error != "" {
+			args["error"] = span.Error
+		}
+		for clave, valor := range span.Atributos {
+			args[clave] = valor
+		}
+
+		eventos = append(eventos, eventoChrome{
+			Nombre:    span.Nombre,
+			Categoria: span.Modulo,
+			Fase:      "X",
+			Inicio:    span.InicioUs,
+			Duracion:  span.DuracionUs,
+			PID:       pid,
+			TID:       tid,
+			Args:      args,
+		})
+	}
+
+	return archivoChrome{Eventos: eventos, UnidadDisplay: "ms"}
+}
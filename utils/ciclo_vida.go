@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// PlazoDetencionPorDefecto es cuánto espera un módulo a que terminen sus ganchos
+// y los mensajes en curso antes de salir igual
+const PlazoDetencionPorDefecto = 10 * time.Second
+
+// GanchoDetencion es una tarea que el módulo ejecuta al detenerse
+type GanchoDetencion func(ctx context.Context) error
+
+type ganchoRegistrado struct {
+	nombre string
+	gancho GanchoDetencion
+}
+
+// AlDetener registra un gancho que se ejecuta al empezar a detener el módulo, cuando ya
+// no se aceptan mensajes nuevos pero todavía se atienden los que están en curso.
+// Sirve para avisar la baja a otros módulos o interrumpir trabajo largo.
+func (m *Modulo) AlDetener(nombre string, gancho GanchoDetencion) {
+	m.ganchosDetener = append(m.ganchosDetener, ganchoRegistrado{nombre, gancho})
+}
+
+// AlFinalizar registra un gancho que se ejecuta después de atender los mensajes en curso.
+// Sirve para persistir estado o mostrar métricas finales.
+func (m *Modulo) AlFinalizar(nombre string, gancho GanchoDetencion) {
+	m.ganchosFinalizar = append(m.ganchosFinalizar, ganchoRegistrado{nombre, gancho})
+}
+
+// Contexto se cancela cuando el módulo empieza a detenerse. Las tareas de fondo
+// (latidos, vigilancia de leases) lo usan para terminar.
+func (m *Modulo) Contexto() context.Context {
+	return m.ctx
+}
+
+// Iniciar abre el puerto del módulo y empieza a atender mensajes en segundo plano.
+// Devuelve error si el puerto no se puede abrir; los errores posteriores del servidor
+// detienen el módulo desde Ejecutar.
+func (m *Modulo) Iniciar(ip string, puerto int) error {
+	m.Server = NewHTTPServer(ip, puerto, m.Nombre)
+	m.Server.Idempotencia = m.idempotencia
+	m.Server.ReportarCarga(m.carga)
+	m.registrarHandlersEnServidor()
+	m.Server.rutas = m.rutas
+	m.Server.eventos = m.eventos
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", ip, puerto))
+	if err != nil {
+		return fmt.Errorf("no se pudo abrir %s:%d: %w", ip, puerto, err)
+	}
+	m.Server.Listener = listener
+
+	go func() {
+		if err := m.Server.Start(); err != nil && !m.Server.Deteniendo() {
+			m.errServidor <- err
+		}
+	}()
+
+	slog.Info("Servidor HTTP iniciado", "módulo", m.Nombre, "dirección", listener.Addr().String())
+	return nil
+}
+
+// Ejecutar bloquea hasta recibir SIGINT o SIGTERM, hasta que ctx se cancele o hasta que
+// el servidor falle, y después detiene el módulo con el plazo indicado.
+func (m *Modulo) Ejecutar(ctx context.Context, plazo time.Duration) error {
+	ctxSenal, detenerSenal := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer detenerSenal()
+
+	var errServidor error
+	select {
+	case <-ctxSenal.Done():
+		InfoLog.Info("Señal de terminación recibida, deteniendo módulo", "módulo", m.Nombre)
+	case errServidor = <-m.errServidor:
+		ErrorLog.Error("El servidor dejó de atender, deteniendo módulo", "módulo", m.Nombre, "error", errServidor)
+	}
+	// Un segundo Ctrl+C mientras se detiene termina el proceso de inmediato
+	detenerSenal()
+
+	ctxDetener, cancelar := context.WithTimeout(context.Background(), plazo)
+	defer cancelar()
+	return errors.Join(errServidor, m.Detener(ctxDetener))
+}
+
+// Detener corre los ganchos AlDetener, espera los mensajes en curso, cierra el servidor
+// y corre los ganchos AlFinalizar. Si ctx vence se abandona lo que falte esperar.
+// Solo tiene efecto la primera vez que se llama.
+func (m *Modulo) Detener(ctx context.Context) error {
+	var err error
+	m.detenerUnaVez.Do(func() {
+		InfoLog.Info("Deteniendo módulo", "módulo", m.Nombre)
+		m.cancelar()
+
+		var errores []error
+		if m.Server != nil {
+			m.Server.dejarDeAceptar()
+		}
+		errores = append(errores, m.correrGanchos(ctx, m.ganchosDetener)...)
+		if m.Server != nil {
+			if errServidor := m.Server.Detener(ctx); errServidor != nil {
+				errores = append(errores, errServidor)
+			}
+		}
+		errores = append(errores, m.correrGanchos(ctx, m.ganchosFinalizar)...)
+
+		err = errors.Join(errores...)
+		if err != nil {
+			ErrorLog.Error("Módulo detenido con errores", "módulo", m.Nombre, "error", err)
+		} else {
+			InfoLog.Info("Módulo detenido", "módulo", m.Nombre)
+		}
+	})
+	return err
+}
+
+// correrGanchos ejecuta los ganchos en orden de registro. Un gancho que falla no impide
+// que corran los siguientes.
+func (m *Modulo) correrGanchos(ctx context.Context, ganchos []ganchoRegistrado) []error {
+	var errores []error
+	for _, g := range ganchos {
+		inicio := time.Now()
+		if err := g.gancho(ctx); err != nil {
+			ErrorLog.Error("Falló gancho de detención", "módulo", m.Nombre, "gancho", g.nombre, "error", err)
+			errores = append(errores, fmt.Errorf("%s: %w", g.nombre, err))
+			continue
+		}
+		InfoLog.Info("Gancho de detención completado", "módulo", m.Nombre, "gancho", g.nombre, "duracion", time.Since(inicio))
+	}
+	return errores
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// EstadoCircuito es el estado de un CircuitBreaker
+type EstadoCircuito int
+
+const (
+	CircuitoCerrado     EstadoCircuito = iota // las solicitudes pasan normalmente
+	CircuitoAbierto                           // el destino se considera caído, se rechaza sin enviar
+	CircuitoSemiAbierto                       // se deja pasar una solicitud de prueba
+)
+
+func (e EstadoCircuito) String() string {
+	switch e {
+	case CircuitoAbierto:
+		return "ABIERTO"
+	case CircuitoSemiAbierto:
+		return "SEMI_ABIERTO"
+	default:
+		return "CERRADO"
+	}
+}
+
+// CircuitBreaker corta los envíos a un destino tras varios fallos de red consecutivos
+// y vuelve a probarlo cuando pasa el tiempo de enfriamiento
+type CircuitBreaker struct {
+	Destino      string
+	Umbral       int
+	Enfriamiento time.Duration
+
+	mutex     sync.Mutex
+	estado    EstadoCircuito
+	fallos    int
+	abiertoEn time.Time
+	enPrueba  bool
+}
+
+// NuevoCircuitBreaker crea un circuito cerrado para el destino indicado
+func NuevoCircuitBreaker(destino string, umbral int, enfriamiento time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Destino:      destino,
+		Umbral:       umbral,
+		Enfriamiento: enfriamiento,
+	}
+}
+
+// Estado devuelve el estado actual del circuito
+func (cb *CircuitBreaker) Estado() EstadoCircuito {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	return cb.estado
+}
+
+// Permitir indica si se puede enviar una solicitud al destino
+func (cb *CircuitBreaker) Permitir() error {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	switch cb.estado {
+	case CircuitoAbierto:
+		if time.Since(cb.abiertoEn) < cb.Enfriamiento {
+			return NuevoError(ErrorCircuitoAbierto, "circuito abierto hacia %s", cb.Destino)
+		}
+		cb.cambiarEstado(CircuitoSemiAbierto)
+		cb.enPrueba = true
+		return nil
+	case CircuitoSemiAbierto:
+		if cb.enPrueba {
+			return NuevoError(ErrorCircuitoAbierto, "circuito hacia %s esperando solicitud de prueba", cb.Destino)
+		}
+		cb.enPrueba = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// RegistrarResultado actualiza el circuito con el resultado de un envío.
+// Solo los errores de red cuentan como fallo: un error de aplicación prueba que el destino responde.
+func (cb *CircuitBreaker) RegistrarResultado(err error) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	codigo := CodigoDe(err)
+	if codigo == ErrorCancelado {
+		cb.enPrueba = false
+		return
+	}
+
+	if codigo != ErrorRedCaida && codigo != ErrorTimeout {
+		cb.fallos = 0
+		cb.enPrueba = false
+		if cb.estado != CircuitoCerrado {
+			cb.cambiarEstado(CircuitoCerrado)
+		}
+		return
+	}
+
+	cb.fallos++
+	cb.enPrueba = false
+	if cb.estado == CircuitoSemiAbierto || cb.fallos >= cb.Umbral {
+		cb.abiertoEn = time.Now()
+		if cb.estado != CircuitoAbierto {
+			cb.cambiarEstado(CircuitoAbierto)
+		}
+	}
+}
+
+func (cb *CircuitBreaker) cambiarEstado(nuevo EstadoCircuito) {
+	InfoLog.Warn("Cambio de estado del circuito", "destino", cb.Destino, "anterior", cb.estado.String(), "nuevo", nuevo.String(), "fallos", cb.fallos)
+	cb.estado = nuevo
+}
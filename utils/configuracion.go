@@ -0,0 +1,349 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/bits"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PrefijoEntorno antecede a la clave JSON en las variables de entorno que
+// reemplazan valores de la configuración, por ejemplo SO_IP_MEMORIA=192.168.0.190
+const PrefijoEntorno = "SO_"
+
+// ConfigValidable lo implementan las configuraciones de los módulos con reglas propias
+type ConfigValidable interface {
+	ValidarConfig(r *ReporteConfig)
+}
+
+// ReporteConfig acumula todos los problemas de una configuración para informarlos juntos
+type ReporteConfig struct {
+	Archivo      string
+	Errores      []string
+	Advertencias []string
+}
+
+// ErrorConfiguracion lista todos los problemas encontrados en un archivo de configuración
+type ErrorConfiguracion struct {
+	Archivo string
+	Errores []string
+}
+
+func (e *ErrorConfiguracion) Error() string {
+	return fmt.Sprintf("configuración inválida en %s:\n  - %s", e.Archivo, strings.Join(e.Errores, "\n  - "))
+}
+
+// CargarConfiguracion lee, completa y valida la configuración. Si hay errores los
+// muestra todos juntos y termina el programa.
+func CargarConfiguracion[T any](ruta string) *T {
+	slog.Info("Cargando configuración", "ruta", ruta)
+
+	config, err := LeerConfiguracion[T](ruta)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		slog.Error("Error cargando configuración", "archivo", ruta)
+		os.Exit(1)
+	}
+
+	slog.Info("Configuración cargada correctamente")
+	return config
+}
+
+// LeerConfiguracion aplica, en orden: los valores por defecto de las etiquetas `default`,
+// el archivo JSON, las variables de entorno SO_<CLAVE> y las reglas de ConfigValidable.
+// Las claves desconocidas se informan como advertencia.
+func LeerConfiguracion[T any](ruta string) (*T, error) {
+	contenido, err := os.ReadFile(ruta)
+	if err != nil {
+		return nil, &ErrorConfiguracion{Archivo: ruta, Errores: []string{err.Error()}}
+	}
+	return decodificarConfiguracion[T](ruta, contenido, nil)
+}
+
+// decodificarConfiguracion hace el trabajo de LeerConfiguracion sobre un JSON ya leído.
+// Las claves de clavesCompartidas no se advierten como desconocidas.
+func decodificarConfiguracion[T any](origen string, contenido []byte, clavesCompartidas map[string]bool) (*T, error) {
+	var config T
+	reporte := &ReporteConfig{Archivo: origen}
+	valor := reflect.ValueOf(&config).Elem()
+
+	recorrerCampos(valor, func(clave string, campo reflect.Value, etiqueta reflect.StructTag) {
+		if porDefecto, existe := etiqueta.Lookup("default"); existe {
+			if err := asignarTexto(campo, porDefecto); err != nil {
+				reporte.Errorf(clave, "valor por defecto inválido %q: %v", porDefecto, err)
+			}
+		}
+	})
+
+	if err := json.Unmarshal(contenido, &config); err != nil {
+		return nil, &ErrorConfiguracion{Archivo: origen, Errores: []string{describirErrorJSON(err)}}
+	}
+
+	reporte.advertirClavesDesconocidas(contenido, valor, clavesCompartidas)
+
+	recorrerCampos(valor, func(clave string, campo reflect.Value, etiqueta reflect.StructTag) {
+		texto, existe := os.LookupEnv(PrefijoEntorno + clave)
+		if !existe {
+			return
+		}
+		if err := asignarTexto(campo, texto); err != nil {
+			reporte.Errorf(clave, "variable %s%s inválida %q: %v", PrefijoEntorno, clave, texto, err)
+			return
+		}
+		// Los campos con la etiqueta sensible:"true" (secretos) no se muestran en el log
+		if etiqueta.Get("sensible") == "true" {
+			texto = "***"
+		}
+		slog.Info("Configuración reemplazada por variable de entorno", "clave", clave, "valor", texto)
+	})
+
+	if validable, ok := any(&config).(ConfigValidable); ok {
+		validable.ValidarConfig(reporte)
+	}
+
+	for _, advertencia := range reporte.Advertencias {
+		slog.Warn("Configuración", "archivo", origen, "advertencia", advertencia)
+	}
+
+	if err := reporte.Err(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Err devuelve un ErrorConfiguracion con todos los errores, o nil si no hubo
+func (r *ReporteConfig) Err() error {
+	if len(r.Errores) == 0 {
+		return nil
+	}
+	return &ErrorConfiguracion{Archivo: r.Archivo, Errores: r.Errores}
+}
+
+// Errorf registra un error de la clave indicada
+func (r *ReporteConfig) Errorf(clave string, formato string, args ...interface{}) {
+	r.Errores = append(r.Errores, fmt.Sprintf("%s: %s", clave, fmt.Sprintf(formato, args...)))
+}
+
+// Verificar registra el error si la condición no se cumple
+func (r *ReporteConfig) Verificar(condicion bool, clave string, formato string, args ...interface{}) {
+	if !condicion {
+		r.Errorf(clave, formato, args...)
+	}
+}
+
+// Requerido verifica que el valor no esté vacío
+func (r *ReporteConfig) Requerido(clave string, valor string) {
+	r.Verificar(strings.TrimSpace(valor) != "", clave, "es obligatorio")
+}
+
+// Positivo verifica que el valor sea mayor a cero
+func (r *ReporteConfig) Positivo(clave string, valor int) {
+	r.Verificar(valor > 0, clave, "debe ser mayor a 0 (es %d)", valor)
+}
+
+// NoNegativo verifica que el valor sea cero o mayor
+func (r *ReporteConfig) NoNegativo(clave string, valor int) {
+	r.Verificar(valor >= 0, clave, "no puede ser negativo (es %d)", valor)
+}
+
+// Rango verifica que min <= valor <= max
+func (r *ReporteConfig) Rango(clave string, valor float64, min float64, max float64) {
+	r.Verificar(valor >= min && valor <= max, clave, "debe estar entre %v y %v (es %v)", min, max, valor)
+}
+
+// PotenciaDeDos verifica que el valor sea una potencia de dos positiva
+func (r *ReporteConfig) PotenciaDeDos(clave string, valor int) {
+	r.Verificar(valor > 0 && bits.OnesCount(uint(valor)) == 1, clave, "debe ser una potencia de 2 (es %d)", valor)
+}
+
+// UnoDe verifica que el valor sea exactamente una de las opciones
+func (r *ReporteConfig) UnoDe(clave string, valor string, opciones ...string) {
+	for _, opcion := range opciones {
+		if valor == opcion {
+			return
+		}
+	}
+	r.Errorf(clave, "valor %q desconocido, se esperaba uno de %s", valor, strings.Join(opciones, ", "))
+}
+
+// Host verifica que el valor sea una IP o un nombre de host
+func (r *ReporteConfig) Host(clave string, valor string) {
+	if valor == "" {
+		r.Errorf(clave, "es obligatorio")
+		return
+	}
+	if net.ParseIP(valor) != nil {
+		return
+	}
+	r.Verificar(!strings.ContainsAny(valor, " :/"), clave, "%q no es una IP ni un nombre de host", valor)
+}
+
+// Puerto verifica que el valor sea un puerto TCP utilizable
+func (r *ReporteConfig) Puerto(clave string, valor int) {
+	r.Verificar(valor > 0 && valor <= 65535, clave, "puerto %d fuera de rango (1-65535)", valor)
+}
+
+// Direccion verifica el par IP/puerto de un módulo
+func (r *ReporteConfig) Direccion(claveIP string, ip string, clavePuerto string, puerto int) {
+	r.Host(claveIP, ip)
+	r.Puerto(clavePuerto, puerto)
+}
+
+// URL verifica que el valor sea una URL http o https absoluta
+func (r *ReporteConfig) URL(clave string, valor string) {
+	direccion, err := url.Parse(valor)
+	if err != nil {
+		r.Errorf(clave, "%q no es una URL: %v", valor, err)
+		return
+	}
+	r.Verificar((direccion.Scheme == "http" || direccion.Scheme == "https") && direccion.Host != "", clave, "%q debe ser una URL http:// o https:// con host", valor)
+}
+
+// DireccionesDistintas verifica que dos módulos no compartan IP y puerto
+func (r *ReporteConfig) DireccionesDistintas(clave string, ip1 string, puerto1 int, ip2 string, puerto2 int) {
+	r.Verificar(ip1 != ip2 || puerto1 != puerto2, clave, "coincide con otro módulo en %s:%d", ip1, puerto1)
+}
+
+// NivelLog verifica LOG_LEVEL
+func (r *ReporteConfig) NivelLog(valor string) {
+	r.UnoDe("LOG_LEVEL", strings.ToUpper(valor), "DEBUG", "INFO", "WARN", "ERROR")
+}
+
+// OpcionesLog verifica las claves de OpcionesLog
+func (r *ReporteConfig) OpcionesLog(opciones OpcionesLog) {
+	if opciones.LogFormato != "" {
+		r.UnoDe("LOG_FORMATO", strings.ToUpper(opciones.LogFormato), "TEXT", "JSON")
+	}
+	r.NoNegativo("LOG_MAX_MB", opciones.LogMaxMB)
+	r.NoNegativo("LOG_MAX_HORAS", opciones.LogMaxHoras)
+	r.NoNegativo("LOG_MAX_ARCHIVOS", opciones.LogMaxArchivos)
+}
+
+// Secreto verifica que un secreto configurado tenga al menos LargoMinimoSecreto caracteres
+func (r *ReporteConfig) Secreto(clave string, valor string) {
+	r.Verificar(valor == "" || len(valor) >= LargoMinimoSecreto, clave, "debe tener al menos %d caracteres", LargoMinimoSecreto)
+}
+
+// Credenciales verifica SECRETO_COMPARTIDO y TOKEN_ADMIN: el token de la API no puede
+// ser el secreto con el que se firman los mensajes entre módulos
+func (r *ReporteConfig) Credenciales(secreto string, token string) {
+	r.Secreto("SECRETO_COMPARTIDO", secreto)
+	r.Secreto("TOKEN_ADMIN", token)
+	r.Verificar(token == "" || token != secreto, "TOKEN_ADMIN", "debe ser distinto de SECRETO_COMPARTIDO")
+}
+
+// SecretoPropio verifica un secreto que no debe coincidir con SECRETO_COMPARTIDO, porque
+// se comparte con sistemas de afuera
+func (r *ReporteConfig) SecretoPropio(clave string, valor string, secretoCompartido string) {
+	r.Secreto(clave, valor)
+	r.Verificar(valor == "" || valor != secretoCompartido, clave, "debe ser distinto de SECRETO_COMPARTIDO")
+}
+
+// Comunicacion verifica TRANSPORTE y TIMEOUTS
+func (r *ReporteConfig) Comunicacion(transporte string, timeouts TimeoutsPorDestino) {
+	if transporte != "" {
+		r.UnoDe("TRANSPORTE", strings.ToUpper(transporte), TransporteHTTP, TransporteTCP)
+	}
+	destinos := make([]string, 0, len(timeouts))
+	for destino := range timeouts {
+		destinos = append(destinos, destino)
+	}
+	sort.Strings(destinos)
+
+	for _, destino := range destinos {
+		r.UnoDe("TIMEOUTS", strings.ToUpper(destino), "MEMORIA", "CPU", "IO", "KERNEL")
+		r.Positivo("TIMEOUTS."+destino, timeouts[destino])
+	}
+}
+
+// advertirClavesDesconocidas avisa de las claves del archivo que ningún campo usa,
+// que suelen ser errores de tipeo
+func (r *ReporteConfig) advertirClavesDesconocidas(contenido []byte, valor reflect.Value, clavesCompartidas map[string]bool) {
+	var claves map[string]json.RawMessage
+	if err := json.Unmarshal(contenido, &claves); err != nil {
+		return
+	}
+
+	conocidas := make(map[string]bool)
+	recorrerCampos(valor, func(clave string, _ reflect.Value, _ reflect.StructTag) {
+		conocidas[clave] = true
+	})
+
+	var desconocidas []string
+	for clave := range claves {
+		if !conocidas[clave] && !clavesCompartidas[clave] {
+			desconocidas = append(desconocidas, clave)
+		}
+	}
+	sort.Strings(desconocidas)
+
+	for _, clave := range desconocidas {
+		r.Advertencias = append(r.Advertencias, fmt.Sprintf("clave desconocida %s, se ignora", clave))
+	}
+}
+
+// recorrerCampos visita cada campo con etiqueta json, incluidos los de structs embebidos
+func recorrerCampos(valor reflect.Value, visitar func(clave string, campo reflect.Value, etiqueta reflect.StructTag)) {
+	tipo := valor.Type()
+	for i := 0; i < tipo.NumField(); i++ {
+		campoTipo := tipo.Field(i)
+		if !campoTipo.IsExported() {
+			continue
+		}
+
+		if campoTipo.Anonymous && campoTipo.Type.Kind() == reflect.Struct {
+			recorrerCampos(valor.Field(i), visitar)
+			continue
+		}
+
+		clave := strings.Split(campoTipo.Tag.Get("json"), ",")[0]
+		if clave == "" || clave == "-" {
+			continue
+		}
+		visitar(clave, valor.Field(i), campoTipo.Tag)
+	}
+}
+
+// asignarTexto interpreta texto según el tipo del campo. Solo admite tipos escalares.
+func asignarTexto(campo reflect.Value, texto string) error {
+	switch campo.Kind() {
+	case reflect.String:
+		campo.SetString(texto)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(texto), 10, 64)
+		if err != nil {
+			return fmt.Errorf("se esperaba un entero")
+		}
+		campo.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(texto), 64)
+		if err != nil {
+			return fmt.Errorf("se esperaba un número")
+		}
+		campo.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(texto))
+		if err != nil {
+			return fmt.Errorf("se esperaba true o false")
+		}
+		campo.SetBool(b)
+	default:
+		return json.Unmarshal([]byte(texto), campo.Addr().Interface())
+	}
+	return nil
+}
+
+// describirErrorJSON agrega la clave afectada a los errores de tipo del decodificador
+func describirErrorJSON(err error) string {
+	if errTipo, ok := err.(*json.UnmarshalTypeError); ok {
+		return fmt.Sprintf("%s: se esperaba %s y se encontró %s", errTipo.Field, errTipo.Type, errTipo.Value)
+	}
+	return err.Error()
+}
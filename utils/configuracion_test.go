@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// configPrueba ejercita los valores por defecto, las variables SO_ y las reglas de validación
+type configPrueba struct {
+	IP       string `json:"IP" default:"127.0.0.1"`
+	Puerto   int    `json:"PUERTO" default:"8000"`
+	Nivel    string `json:"LOG_LEVEL" default:"INFO"`
+	Retardo  int    `json:"RETARDO,omitempty"`
+	Secreto  string `json:"SECRETO_COMPARTIDO,omitempty" sensible:"true"`
+	Token    string `json:"TOKEN_ADMIN,omitempty" sensible:"true"`
+	Webhook  string `json:"WEBHOOK_SECRETO,omitempty" sensible:"true"`
+	Activado bool   `json:"ACTIVADO,omitempty"`
+}
+
+func (c *configPrueba) ValidarConfig(r *ReporteConfig) {
+	r.Direccion("IP", c.IP, "PUERTO", c.Puerto)
+	r.NivelLog(c.Nivel)
+	r.NoNegativo("RETARDO", c.Retardo)
+	r.Credenciales(c.Secreto, c.Token)
+	r.SecretoPropio("WEBHOOK_SECRETO", c.Webhook, c.Secreto)
+}
+
+// leerConfigPrueba escribe contenido en un archivo temporal y lo lee como configPrueba
+func leerConfigPrueba(t *testing.T, contenido string) (*configPrueba, error) {
+	t.Helper()
+	ruta := filepath.Join(t.TempDir(), "prueba.json")
+	if err := os.WriteFile(ruta, []byte(contenido), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return LeerConfiguracion[configPrueba](ruta)
+}
+
+func TestLeerConfiguracion(t *testing.T) {
+	const secreto = "secreto-de-prueba-1234"
+
+	casos := []struct {
+		nombre   string
+		json     string
+		entorno  map[string]string
+		esperado configPrueba
+		// errores son fragmentos que deben aparecer, uno por error informado
+		errores []string
+	}{
+		{
+			nombre:   "valores por defecto",
+			json:     `{}`,
+			esperado: configPrueba{IP: "127.0.0.1", Puerto: 8000, Nivel: "INFO"},
+		},
+		{
+			nombre:   "el archivo reemplaza los valores por defecto",
+			json:     `{"PUERTO": 9000, "LOG_LEVEL": "DEBUG"}`,
+			esperado: configPrueba{IP: "127.0.0.1", Puerto: 9000, Nivel: "DEBUG"},
+		},
+		{
+			nombre:   "el entorno reemplaza al archivo",
+			json:     `{"PUERTO": 9000, "RETARDO": 10}`,
+			entorno:  map[string]string{"SO_PUERTO": "9100", "SO_ACTIVADO": "true", "SO_SECRETO_COMPARTIDO": secreto},
+			esperado: configPrueba{IP: "127.0.0.1", Puerto: 9100, Nivel: "INFO", Retardo: 10, Secreto: secreto, Activado: true},
+		},
+		{
+			nombre:  "variable de entorno inválida",
+			json:    `{}`,
+			entorno: map[string]string{"SO_PUERTO": "ocho mil"},
+			errores: []string{"SO_PUERTO"},
+		},
+		{
+			nombre:  "se informan todos los errores juntos",
+			json:    `{"PUERTO": 70000, "LOG_LEVEL": "VERBOSO", "RETARDO": -1}`,
+			errores: []string{"PUERTO", "LOG_LEVEL", "RETARDO"},
+		},
+		{
+			nombre:  "secreto corto",
+			json:    `{"SECRETO_COMPARTIDO": "corto"}`,
+			errores: []string{"SECRETO_COMPARTIDO"},
+		},
+		{
+			nombre:  "el token no puede ser el secreto",
+			json:    `{"SECRETO_COMPARTIDO": "` + secreto + `", "TOKEN_ADMIN": "` + secreto + `"}`,
+			errores: []string{"TOKEN_ADMIN"},
+		},
+		{
+			nombre:  "el secreto de webhooks no puede ser el secreto",
+			json:    `{}`,
+			entorno: map[string]string{"SO_SECRETO_COMPARTIDO": secreto, "SO_WEBHOOK_SECRETO": secreto},
+			errores: []string{"WEBHOOK_SECRETO"},
+		},
+		{
+			nombre:  "JSON inválido",
+			json:    `{"PUERTO": "8000"}`,
+			errores: []string{"PUERTO"},
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			for clave, valor := range caso.entorno {
+				t.Setenv(clave, valor)
+			}
+
+			config, err := leerConfigPrueba(t, caso.json)
+			if len(caso.errores) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if *config != caso.esperado {
+					t.Fatalf("configuración %+v, se esperaba %+v", *config, caso.esperado)
+				}
+				return
+			}
+
+			errorConfig, ok := err.(*ErrorConfiguracion)
+			if !ok {
+				t.Fatalf("se esperaba un ErrorConfiguracion y se obtuvo %v", err)
+			}
+			if len(errorConfig.Errores) != len(caso.errores) {
+				t.Fatalf("se informaron %d errores, se esperaban %d: %v", len(errorConfig.Errores), len(caso.errores), errorConfig.Errores)
+			}
+			for i, fragmento := range caso.errores {
+				if !strings.Contains(errorConfig.Errores[i], fragmento) {
+					t.Errorf("el error %q no menciona %s", errorConfig.Errores[i], fragmento)
+				}
+			}
+		})
+	}
+}
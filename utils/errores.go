@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// CodigoError identifica la causa de un error entre módulos
+type CodigoError string
+
+// Códigos de error compartidos por todos los módulos
+const (
+	ErrorMemoriaSinEspacio    CodigoError = "MEMORIA_SIN_ESPACIO"
+	ErrorPIDInexistente       CodigoError = "PID_INEXISTENTE"
+	ErrorProcesoDuplicado     CodigoError = "PROCESO_DUPLICADO"
+	ErrorArchivoInexistente   CodigoError = "ARCHIVO_INEXISTENTE"
+	ErrorDireccionInvalida    CodigoError = "DIRECCION_INVALIDA"
+	ErrorEsquemaInvalido      CodigoError = "ESQUEMA_INVALIDO"
+	ErrorVersionIncompatible  CodigoError = "VERSION_INCOMPATIBLE"
+	ErrorOperacionDesconocida CodigoError = "OPERACION_DESCONOCIDA"
+	ErrorRedCaida             CodigoError = "RED_CAIDA"
+	ErrorTimeout              CodigoError = "TIMEOUT"
+	ErrorCancelado            CodigoError = "CANCELADO"
+	ErrorCircuitoAbierto      CodigoError = "CIRCUITO_ABIERTO"
+	ErrorNoListo              CodigoError = "NO_LISTO"
+	ErrorLeaseInexistente     CodigoError = "LEASE_INEXISTENTE"
+	ErrorNoAutenticado        CodigoError = "NO_AUTENTICADO"
+	ErrorNoAutorizado         CodigoError = "NO_AUTORIZADO"
+	ErrorInterno              CodigoError = "ERROR_INTERNO"
+)
+
+// definicionError indica el estado HTTP y si tiene sentido reintentar cada código
+type definicionError struct {
+	estadoHTTP   int
+	reintentable bool
+}
+
+var definicionesError = map[CodigoError]definicionError{
+	ErrorMemoriaSinEspacio:    {http.StatusInsufficientStorage, false},
+	ErrorPIDInexistente:       {http.StatusNotFound, false},
+	ErrorProcesoDuplicado:     {http.StatusConflict, false},
+	ErrorArchivoInexistente:   {http.StatusNotFound, false},
+	ErrorDireccionInvalida:    {http.StatusBadRequest, false},
+	ErrorEsquemaInvalido:      {http.StatusBadRequest, false},
+	ErrorVersionIncompatible:  {http.StatusBadRequest, false},
+	ErrorOperacionDesconocida: {http.StatusNotFound, false},
+	ErrorRedCaida:             {http.StatusServiceUnavailable, true},
+	ErrorTimeout:              {http.StatusGatewayTimeout, true},
+	ErrorCancelado:            {http.StatusRequestTimeout, false},
+	ErrorCircuitoAbierto:      {http.StatusServiceUnavailable, true},
+	ErrorNoListo:              {http.StatusServiceUnavailable, true},
+	ErrorLeaseInexistente:     {http.StatusGone, false},
+	ErrorNoAutenticado:        {http.StatusUnauthorized, false},
+	ErrorNoAutorizado:         {http.StatusForbidden, false},
+	ErrorInterno:              {http.StatusInternalServerError, false},
+}
+
+// ErrorModulo es el sobre de error que viaja entre módulos
+type ErrorModulo struct {
+	Codigo       CodigoError `json:"codigo"`
+	Mensaje      string      `json:"mensaje"`
+	Reintentable bool        `json:"reintentable"`
+	Modulo       string      `json:"modulo"`
+}
+
+// respuestaError es el cuerpo de toda respuesta HTTP fallida
+type respuestaError struct {
+	Error *ErrorModulo `json:"error"`
+}
+
+// NuevoError crea un ErrorModulo con el código indicado.
+// El módulo de origen lo completa el servidor o el cliente que lo detecta.
+func NuevoError(codigo CodigoError, formato string, args ...interface{}) *ErrorModulo {
+	return &ErrorModulo{
+		Codigo:       codigo,
+		Mensaje:      fmt.Sprintf(formato, args...),
+		Reintentable: definicionesError[codigo].reintentable,
+	}
+}
+
+func (e *ErrorModulo) Error() string {
+	if e.Modulo == "" {
+		return fmt.Sprintf("%s: %s", e.Codigo, e.Mensaje)
+	}
+	return fmt.Sprintf("%s [%s]: %s", e.Codigo, e.Modulo, e.Mensaje)
+}
+
+// EstadoHTTP devuelve el código de estado HTTP asociado al error
+func (e *ErrorModulo) EstadoHTTP() int {
+	if definicion, existe := definicionesError[e.Codigo]; existe {
+		return definicion.estadoHTTP
+	}
+	return http.StatusInternalServerError
+}
+
+// ComoErrorModulo obtiene una copia del ErrorModulo contenido en err.
+// Los errores de contexto se traducen a timeout o cancelación; el resto sin código es ErrorInterno.
+func ComoErrorModulo(err error) *ErrorModulo {
+	var errorModulo *ErrorModulo
+	switch {
+	case errors.As(err, &errorModulo):
+		copia := *errorModulo
+		return &copia
+	case errors.Is(err, context.DeadlineExceeded):
+		return NuevoError(ErrorTimeout, "%v", err)
+	case errors.Is(err, context.Canceled):
+		return NuevoError(ErrorCancelado, "%v", err)
+	default:
+		return NuevoError(ErrorInterno, "%v", err)
+	}
+}
+
+// CodigoDe devuelve el código de error de err, o "" si err es nil
+func CodigoDe(err error) CodigoError {
+	if err == nil {
+		return ""
+	}
+	return ComoErrorModulo(err).Codigo
+}
+
+// EsReintentable indica si vale la pena repetir la operación que produjo err
+func EsReintentable(err error) bool {
+	return err != nil && ComoErrorModulo(err).Reintentable
+}
+
+// errorDeRed clasifica un error de transporte como timeout, cancelación o red caída
+func errorDeRed(modulo string, err error) *ErrorModulo {
+	codigo := ErrorRedCaida
+	var errNet net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &errNet) && errNet.Timeout()) {
+		codigo = ErrorTimeout
+	} else if errors.Is(err, context.Canceled) {
+		codigo = ErrorCancelado
+	}
+
+	errorModulo := NuevoError(codigo, "%v", err)
+	errorModulo.Modulo = modulo
+	return errorModulo
+}
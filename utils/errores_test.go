@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCodigoDeYEsReintentable(t *testing.T) {
+	casos := []struct {
+		nombre       string
+		err          error
+		codigo       CodigoError
+		reintentable bool
+	}{
+		{"sin error", nil, "", false},
+		{"error de módulo", NuevoError(ErrorPIDInexistente, "pid %d", 3), ErrorPIDInexistente, false},
+		{"error reintentable", NuevoError(ErrorTimeout, "lento"), ErrorTimeout, true},
+		{"envuelto", fmt.Errorf("enviando: %w", NuevoError(ErrorRedCaida, "sin ruta")), ErrorRedCaida, true},
+		{"plazo vencido", context.DeadlineExceeded, ErrorTimeout, true},
+		{"plazo vencido envuelto", fmt.Errorf("esperando: %w", context.DeadlineExceeded), ErrorTimeout, true},
+		{"cancelado", context.Canceled, ErrorCancelado, false},
+		{"error sin código", errors.New("falló"), ErrorInterno, false},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			if codigo := CodigoDe(caso.err); codigo != caso.codigo {
+				t.Errorf("CodigoDe = %q, se esperaba %q", codigo, caso.codigo)
+			}
+			if reintentable := EsReintentable(caso.err); reintentable != caso.reintentable {
+				t.Errorf("EsReintentable = %v, se esperaba %v", reintentable, caso.reintentable)
+			}
+		})
+	}
+}
+
+func TestComoErrorModuloDevuelveCopia(t *testing.T) {
+	original := NuevoError(ErrorTimeout, "lento")
+	copia := ComoErrorModulo(original)
+	copia.Modulo = "Memoria"
+	if original.Modulo != "" {
+		t.Fatalf("ComoErrorModulo modificó el error original: %+v", original)
+	}
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// DirectorioEscenarios es donde se buscan los escenarios indicados por nombre
+var DirectorioEscenarios = filepath.Join("configs", "escenarios")
+
+// Secciones de un escenario
+const (
+	SeccionKernel  = "kernel"
+	SeccionMemoria = "memoria"
+	SeccionCPU     = "cpu"
+	SeccionIO      = "io"
+)
+
+// seccionComun tiene las claves que comparten todos los módulos del escenario
+const seccionComun = "comun"
+
+// seccionInstancias agrupa, por módulo, lo propio de cada CPU o dispositivo IO
+const seccionInstancias = "instancias"
+
+// seccionConfig es un objeto JSON de claves de configuración
+type seccionConfig map[string]json.RawMessage
+
+// Escenario reúne en un solo archivo la configuración de todos los módulos de una prueba:
+//
+//	{
+//	  "comun":   { "IP_MEMORIA": "127.0.0.1", "PUERTO_MEMORIA": 8002, ... },
+//	  "kernel":  { ... }, "memoria": { ... }, "cpu": { ... }, "io": { ... },
+//	  "instancias": { "cpu": { "CPU1": { "PUERTO_CPU": 8004 } }, "io": { "DISCO1": { ... } } }
+//	}
+//
+// Cada módulo ve comun, luego su sección y luego la de su instancia; una clave repetida
+// toma el último valor.
+type Escenario struct {
+	Comun      seccionConfig                       `json:"comun"`
+	Kernel     seccionConfig                       `json:"kernel"`
+	Memoria    seccionConfig                       `json:"memoria"`
+	CPU        seccionConfig                       `json:"cpu"`
+	IO         seccionConfig                       `json:"io"`
+	Instancias map[string]map[string]seccionConfig `json:"instancias"`
+}
+
+// ResolverConfiguracion devuelve el archivo a cargar: ruta si existe, o si no el escenario
+// DirectorioEscenarios/<ruta>.json
+func ResolverConfiguracion(ruta string) (string, error) {
+	if _, err := os.Stat(ruta); err == nil {
+		return ruta, nil
+	}
+
+	escenario := filepath.Join(DirectorioEscenarios, ruta+".json")
+	if _, err := os.Stat(escenario); err == nil {
+		return escenario, nil
+	}
+
+	return "", fmt.Errorf("no existe el archivo de configuración %s ni el escenario %s", ruta, escenario)
+}
+
+// EsEscenario indica si el archivo es un escenario y no la configuración de un solo módulo.
+// Los escenarios se reconocen por tener secciones en minúscula.
+func EsEscenario(ruta string) bool {
+	contenido, err := os.ReadFile(ruta)
+	if err != nil {
+		return false
+	}
+
+	var claves map[string]json.RawMessage
+	if err := json.Unmarshal(contenido, &claves); err != nil {
+		return false
+	}
+
+	for _, seccion := range []string{seccionComun, SeccionKernel, SeccionMemoria, SeccionCPU, SeccionIO, seccionInstancias} {
+		if _, existe := claves[seccion]; existe {
+			return true
+		}
+	}
+	return false
+}
+
+// CargarConfiguracionModulo carga la configuración de un módulo desde un archivo propio
+// o desde la sección e instancia de un escenario. Termina el programa si hay errores.
+func CargarConfiguracionModulo[T any](ruta string, seccion string, instancia string) *T {
+	if !EsEscenario(ruta) {
+		return CargarConfiguracion[T](ruta)
+	}
+
+	slog.Info("Cargando escenario", "ruta", ruta, "seccion", seccion, "instancia", instancia)
+
+	config, err := LeerConfiguracionEscenario[T](ruta, seccion, instancia)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		slog.Error("Error cargando escenario", "archivo", ruta, "seccion", seccion, "instancia", instancia)
+		os.Exit(1)
+	}
+
+	slog.Info("Configuración cargada correctamente")
+	return config
+}
+
+// LeerConfiguracionEscenario combina comun, la sección del módulo y su instancia,
+// y decodifica el resultado como LeerConfiguracion
+func LeerConfiguracionEscenario[T any](ruta string, seccion string, instancia string) (*T, error) {
+	origen := fmt.Sprintf("%s [%s]", ruta, seccion)
+	if instancia != "" {
+		origen = fmt.Sprintf("%s [%s/%s]", ruta, seccion, instancia)
+	}
+
+	contenido, err := os.ReadFile(ruta)
+	if err != nil {
+		return nil, &ErrorConfiguracion{Archivo: origen, Errores: []string{err.Error()}}
+	}
+
+	var escenario Escenario
+	if err := json.Unmarshal(contenido, &escenario); err != nil {
+		return nil, &ErrorConfiguracion{Archivo: origen, Errores: []string{describirErrorJSON(err)}}
+	}
+
+	seccionModulo, existe := escenario.seccion(seccion)
+	if !existe {
+		return nil, &ErrorConfiguracion{Archivo: origen, Errores: []string{fmt.Sprintf("el escenario no tiene sección %q", seccion)}}
+	}
+
+	combinada := make(seccionConfig)
+	clavesComunes := make(map[string]bool)
+	for clave, valor := range escenario.Comun {
+		combinada[clave] = valor
+		clavesComunes[clave] = true
+	}
+	for clave, valor := range seccionModulo {
+		combinada[clave] = valor
+	}
+
+	instancias := escenario.Instancias[seccion]
+	if seccionInstancia, existe := instancias[instancia]; existe {
+		for clave, valor := range seccionInstancia {
+			combinada[clave] = valor
+		}
+	} else if len(instancias) > 0 {
+		slog.Warn("El escenario no define la instancia, se usa la sección del módulo", "archivo", ruta, "seccion", seccion, "instancia", instancia)
+	}
+
+	contenidoCombinado, err := json.Marshal(combinada)
+	if err != nil {
+		return nil, &ErrorConfiguracion{Archivo: origen, Errores: []string{err.Error()}}
+	}
+
+	return decodificarConfiguracion[T](origen, contenidoCombinado, clavesComunes)
+}
+
+// seccion devuelve la sección del módulo indicado
+func (e *Escenario) seccion(nombre string) (seccionConfig, bool) {
+	var seccion seccionConfig
+	switch nombre {
+	case SeccionKernel:
+		seccion = e.Kernel
+	case SeccionMemoria:
+		seccion = e.Memoria
+	case SeccionCPU:
+		seccion = e.CPU
+	case SeccionIO:
+		seccion = e.IO
+	}
+	return seccion, seccion != nil || len(e.Instancias[nombre]) > 0
+}
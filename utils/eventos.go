@@ -0,0 +1,357 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Temas de los eventos que publican los módulos en /eventos
+const (
+	TemaEstadoProceso    = "proceso.estado"           // Kernel: un proceso cambió de estado
+	TemaMarcosLiberados  = "memoria.marcos_liberados" // Memoria: un proceso liberó sus marcos
+	TemaDispositivoLibre = "io.dispositivo_libre"     // IO: el dispositivo no tiene operaciones en curso
+	TemaCPULibre         = "cpu.libre"                // CPU: devolvió el proceso que ejecutaba
+)
+
+// DatosEstadoProceso acompaña a TemaEstadoProceso
+type DatosEstadoProceso struct {
+	PID      int    `json:"pid"`
+	Anterior string `json:"anterior"`
+	Nuevo    string `json:"nuevo"`
+}
+
+// DatosMarcosLiberados acompaña a TemaMarcosLiberados
+type DatosMarcosLiberados struct {
+	PID    int    `json:"pid"`
+	Marcos int    `json:"marcos"`
+	Libres int    `json:"libres"`
+	Motivo string `json:"motivo"` // "FINALIZACION" o "SUSPENSION"
+}
+
+// DatosDispositivoLibre acompaña a TemaDispositivoLibre
+type DatosDispositivoLibre struct {
+	Dispositivo string `json:"dispositivo"`
+	PID         int    `json:"pid"` // el último proceso atendido
+}
+
+// DatosCPULibre acompaña a TemaCPULibre
+type DatosCPULibre struct {
+	CPU    string `json:"cpu"`
+	PID    int    `json:"pid"`
+	Motivo string `json:"motivo,omitempty"`
+}
+
+// Evento es un cambio de estado que un módulo publica para quien quiera observarlo
+type Evento struct {
+	ID     uint64          `json:"id"`
+	Tema   string          `json:"tema"`
+	Origen string          `json:"origen"`
+	Sello  int64           `json:"sello"` // unix ms
+	Datos  json.RawMessage `json:"datos"`
+}
+
+// DatosEvento decodifica los datos del evento en el tipo de su tema
+func DatosEvento[T any](evento Evento) (T, error) {
+	var datos T
+	if err := json.Unmarshal(evento.Datos, &datos); err != nil {
+		return datos, NuevoError(ErrorEsquemaInvalido, "evento %s con formato inválido para %T: %v", evento.Tema, datos, err)
+	}
+	return datos, nil
+}
+
+const (
+	// historialEventos es cuántos eventos se guardan para reenviar a quien se reconecta
+	historialEventos = 256
+	// pendientesSuscriptor es cuántos eventos puede tener sin leer un suscriptor antes
+	// de que se corte su conexión (al reconectarse los recupera del historial)
+	pendientesSuscriptor = 64
+	// intervaloLatidoEventos mantiene abiertas las conexiones sin eventos
+	intervaloLatidoEventos = 15 * time.Second
+)
+
+// Eventos publicados por el módulo, por tema
+var eventosPublicados = NuevoContador("so_eventos_publicados_total", "Eventos publicados en /eventos.", "tema")
+
+// BusEventos reparte los eventos que publica el módulo entre los suscriptores de /eventos
+type BusEventos struct {
+	origen string
+
+	mutex        sync.Mutex
+	ultimoID     uint64
+	historial    []Evento
+	suscriptores map[*suscripcionEventos]struct{}
+	cerrado      bool
+}
+
+// suscripcionEventos es una conexión a /eventos; eventos se cierra para cortarla
+type suscripcionEventos struct {
+	temas   []string // vacío recibe todos
+	eventos chan Evento
+}
+
+// NuevoBusEventos crea el bus de eventos de un módulo
+func NuevoBusEventos(origen string) *BusEventos {
+	return &BusEventos{origen: origen, suscriptores: make(map[*suscripcionEventos]struct{})}
+}
+
+// Publicar reparte el evento sin bloquear. Un suscriptor que no da abasto pierde la
+// conexión y al reconectarse recibe del historial lo que se perdió.
+func (b *BusEventos) Publicar(tema string, datos interface{}) {
+	crudo, err := json.Marshal(datos)
+	if err != nil {
+		ErrorLog.Error("No se pudo serializar el evento", "tema", tema, "error", err)
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.ultimoID++
+	evento := Evento{ID: b.ultimoID, Tema: tema, Origen: b.origen, Sello: time.Now().UnixMilli(), Datos: crudo}
+	b.historial = append(b.historial, evento)
+	if len(b.historial) > historialEventos {
+		b.historial = slices.Delete(b.historial, 0, len(b.historial)-historialEventos)
+	}
+	eventosPublicados.Incrementar(tema)
+
+	for suscripcion := range b.suscriptores {
+		if !suscripcion.acepta(tema) {
+			continue
+		}
+		select {
+		case suscripcion.eventos <- evento:
+		default:
+			ErrorLog.Warn("Suscriptor de eventos atrasado, se corta su conexión", "tema", tema, "id", evento.ID)
+			delete(b.suscriptores, suscripcion)
+			close(suscripcion.eventos)
+		}
+	}
+}
+
+// suscribir registra una conexión y devuelve los eventos del historial posteriores a
+// desde. Con desde 0 solo recibe los nuevos; si desde es mayor al último publicado (el
+// módulo se reinició) recibe todo el historial.
+func (b *BusEventos) suscribir(temas []string, desde uint64) (*suscripcionEventos, []Evento, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.cerrado {
+		return nil, nil, NuevoError(ErrorNoListo, "%s se está deteniendo", b.origen)
+	}
+
+	suscripcion := &suscripcionEventos{temas: temas, eventos: make(chan Evento, pendientesSuscriptor)}
+	b.suscriptores[suscripcion] = struct{}{}
+
+	var anteriores []Evento
+	if desde > 0 {
+		if desde > b.ultimoID {
+			desde = 0
+		}
+		for _, evento := range b.historial {
+			if evento.ID > desde && suscripcion.acepta(evento.Tema) {
+				anteriores = append(anteriores, evento)
+			}
+		}
+		if len(b.historial) > 0 && b.historial[0].ID > desde+1 {
+			InfoLog.Warn("Eventos fuera del historial, el suscriptor no los recibirá", "desde", desde, "primero_disponible", b.historial[0].ID)
+		}
+	}
+	return suscripcion, anteriores, nil
+}
+
+// desuscribir quita la conexión si todavía estaba registrada
+func (b *BusEventos) desuscribir(suscripcion *suscripcionEventos) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, existe := b.suscriptores[suscripcion]; existe {
+		delete(b.suscriptores, suscripcion)
+		close(suscripcion.eventos)
+	}
+}
+
+// cerrar corta todas las conexiones para que el servidor pueda detenerse
+func (b *BusEventos) cerrar() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.cerrado = true
+	for suscripcion := range b.suscriptores {
+		close(suscripcion.eventos)
+	}
+	clear(b.suscriptores)
+}
+
+// acepta indica si la suscripción pidió el tema
+func (s *suscripcionEventos) acepta(tema string) bool {
+	return len(s.temas) == 0 || slices.Contains(s.temas, tema)
+}
+
+// Publicar publica un evento del módulo en su /eventos
+func (m *Modulo) Publicar(tema string, datos interface{}) {
+	m.eventos.Publicar(tema, datos)
+}
+
+// Suscribir recibe los eventos de temas (todos si está vacío) que publica el módulo al que
+// apunta c y llama a manejar con cada uno, en orden, hasta que se detenga este módulo.
+// Si la conexión se corta se reconecta y recupera los eventos que se perdió.
+func (m *Modulo) Suscribir(c *HTTPClient, temas []string, manejar func(Evento)) {
+	go func() {
+		ctx := m.Contexto()
+		var ultimo uint64
+		espera := 500 * time.Millisecond
+		conectado := false
+
+		for ctx.Err() == nil {
+			err := c.RecibirEventos(ctx, temas, ultimo, func(evento Evento) {
+				ultimo = evento.ID
+				manejar(evento)
+			}, func() {
+				InfoLog.Info("Suscripto a eventos", "destino", c.Nombre, "temas", temas, "desde", ultimo)
+				conectado = true
+			})
+			if ctx.Err() != nil {
+				return
+			}
+
+			// Se avisa solo la primera falla para no llenar el log mientras el destino está caído
+			if conectado {
+				ErrorLog.Warn("Se cortó la suscripción a eventos, reintentando", "destino", c.Nombre, "error", err)
+				espera = 500 * time.Millisecond
+			}
+			conectado = false
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(espera):
+			}
+			espera = min(2*espera, 5*time.Second)
+		}
+	}()
+}
+
+// RecibirEventos abre una conexión a /eventos del destino y llama a manejar con cada
+// evento hasta que se corte o se cancele ctx. desde es el último evento ya recibido (0
+// para recibir solo los nuevos) y alConectar se llama al establecerse la conexión.
+func (c *HTTPClient) RecibirEventos(ctx context.Context, temas []string, desde uint64, manejar func(Evento), alConectar func()) error {
+	consulta := url.Values{}
+	if len(temas) > 0 {
+		consulta.Set("temas", strings.Join(temas, ","))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/eventos?%s", c.BaseURL, consulta.Encode()), nil)
+	if err != nil {
+		return NuevoError(ErrorInterno, "error al crear solicitud: %v", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if desde > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(desde, 10))
+	}
+	firmarSolicitudHTTP(req, c.Nombre)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errorDeRed(c.Nombre, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var cuerpo [4096]byte
+		n, _ := resp.Body.Read(cuerpo[:])
+		return c.decodificarError(resp.StatusCode, cuerpo[:n])
+	}
+	if alConectar != nil {
+		alConectar()
+	}
+
+	var datos strings.Builder
+	lector := bufio.NewScanner(resp.Body)
+	lector.Buffer(make([]byte, 64<<10), 1<<20)
+	for lector.Scan() {
+		linea := lector.Text()
+		switch {
+		case strings.HasPrefix(linea, "data:"):
+			datos.WriteString(strings.TrimPrefix(strings.TrimPrefix(linea, "data:"), " "))
+		case linea == "" && datos.Len() > 0:
+			var evento Evento
+			if err := json.Unmarshal([]byte(datos.String()), &evento); err != nil {
+				ErrorLog.Error("Evento con formato inválido", "destino", c.Nombre, "error", err)
+			} else {
+				manejar(evento)
+			}
+			datos.Reset()
+		}
+	}
+	if err := lector.Err(); err != nil {
+		return errorDeRed(c.Nombre, err)
+	}
+	return NuevoError(ErrorRedCaida, "%s cerró la conexión de eventos", c.Nombre)
+}
+
+// atenderEventos mantiene abierta una conexión Server-Sent Events con los eventos que
+// publica el módulo. Filtra por ?temas=a,b y retoma desde el encabezado Last-Event-ID.
+func (s *HTTPServer) atenderEventos(w http.ResponseWriter, r *http.Request) {
+	if err := verificarSuscripcion(r); err != nil {
+		ErrorLog.Warn("Suscripción a eventos rechazada por autenticación", "remoto", r.RemoteAddr, "error", err)
+		s.escribirError(w, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.escribirError(w, NuevoError(ErrorInterno, "la conexión no admite eventos"))
+		return
+	}
+
+	var temas []string
+	if valor := r.URL.Query().Get("temas"); valor != "" {
+		temas = strings.Split(valor, ",")
+	}
+	desde, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+
+	suscripcion, anteriores, err := s.eventos.suscribir(temas, desde)
+	if err != nil {
+		s.escribirError(w, err)
+		return
+	}
+	defer s.eventos.desuscribir(suscripcion)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, evento := range anteriores {
+		escribirEvento(w, evento)
+	}
+	flusher.Flush()
+
+	latido := time.NewTicker(intervaloLatidoEventos)
+	defer latido.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case evento, abierta := <-suscripcion.eventos:
+			if !abierta {
+				return
+			}
+			escribirEvento(w, evento)
+		case <-latido.C:
+			fmt.Fprint(w, ": latido\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// escribirEvento escribe el evento en el formato de Server-Sent Events
+func escribirEvento(w http.ResponseWriter, evento Evento) {
+	crudo, _ := json.Marshal(evento)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", evento.ID, evento.Tema, crudo)
+}
@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// VentanaFirma es cuánto puede diferir el sello de un mensaje firmado del reloj del
+// receptor. Acota la repetición de mensajes capturados en la red.
+const VentanaFirma = 5 * time.Minute
+
+// LargoMinimoSecreto es el largo mínimo aceptado para SECRETO_COMPARTIDO
+const LargoMinimoSecreto = 16
+
+// secretoFirma es la clave HMAC compartida por todos los módulos; vacía no se firma
+var secretoFirma []byte
+
+// tokenAdmin autentica las solicitudes REST de personas y herramientas (osctl, curl).
+// Es distinto de secretoFirma: quien lo conoce administra el módulo por la API pero no
+// puede firmar mensajes entre módulos.
+var tokenAdmin []byte
+
+// ultimoSello es el sello del último mensaje firmado. Cada mensaje lleva uno mayor al
+// anterior, así dos mensajes legítimos del mismo emisor nunca repiten (origen, sello, firma).
+var ultimoSello atomic.Int64
+
+// firmasVistas recuerda los mensajes firmados ya aceptados hasta que su sello sale de
+// VentanaFirma, para rechazar una repetición capturada en la red
+var firmasVistas = struct {
+	sync.Mutex
+	vencimientos map[string]time.Time
+	proximaPurga time.Time
+}{vencimientos: make(map[string]time.Time)}
+
+// HabilitarFirma hace que los mensajes enviados se firmen con HMAC-SHA256 usando secreto
+// y que el servidor rechace los que no traen una firma válida. Con secreto vacío los
+// mensajes viajan sin firmar y se aceptan todos.
+func HabilitarFirma(secreto string) {
+	secretoFirma = []byte(secreto)
+	if secreto == "" {
+		slog.Warn("SECRETO_COMPARTIDO no configurado: los mensajes viajan sin firmar")
+		return
+	}
+	slog.Info("Firma de mensajes habilitada")
+}
+
+// HabilitarTokenAdmin exige token en las solicitudes REST. Sin token la API REST queda
+// abierta si los mensajes no se firman, y cerrada si hay SECRETO_COMPARTIDO: el secreto
+// de los módulos no sirve como credencial de la API.
+func HabilitarTokenAdmin(token string) {
+	tokenAdmin = []byte(token)
+	if token == "" && len(secretoFirma) > 0 {
+		slog.Warn("TOKEN_ADMIN no configurado: la API REST rechaza todas las solicitudes")
+	}
+}
+
+// firmarMensaje sella el mensaje con la hora actual y lo firma junto con datos,
+// el JSON exacto que viaja en Datos
+func firmarMensaje(msg *Mensaje, datos []byte) {
+	if len(secretoFirma) == 0 {
+		return
+	}
+	msg.Sello = nuevoSello()
+	msg.Firma = calcularFirma(msg, datos)
+}
+
+// nuevoSello devuelve la hora actual en milisegundos, o uno más que el último sello si
+// dos mensajes se firman en el mismo milisegundo
+func nuevoSello() int64 {
+	for {
+		anterior := ultimoSello.Load()
+		sello := max(time.Now().UnixMilli(), anterior+1)
+		if ultimoSello.CompareAndSwap(anterior, sello) {
+			return sello
+		}
+	}
+}
+
+// verificarFirma rechaza con ErrorNoAutenticado los mensajes sin firma, con firma
+// inválida, con un sello fuera de VentanaFirma o repetidos dentro de ella
+func verificarFirma(msg *Mensaje) error {
+	if len(secretoFirma) == 0 {
+		return nil
+	}
+	if msg.Firma == "" {
+		return NuevoError(ErrorNoAutenticado, "mensaje tipo %d de %s sin firma", msg.Tipo, msg.Origen)
+	}
+
+	if err := verificarSello(msg.Sello); err != nil {
+		return NuevoError(ErrorNoAutenticado, "mensaje tipo %d de %s: %v", msg.Tipo, msg.Origen, err)
+	}
+
+	datos := []byte(msg.datosCrudos)
+	if len(datos) == 0 {
+		datos = []byte("null")
+	}
+	if !hmac.Equal([]byte(calcularFirma(msg, datos)), []byte(msg.Firma)) {
+		return NuevoError(ErrorNoAutenticado, "firma inválida en mensaje tipo %d de %s", msg.Tipo, msg.Origen)
+	}
+	if !registrarFirma(msg.Origen, msg.Sello, msg.Firma) {
+		return NuevoError(ErrorNoAutenticado, "mensaje tipo %d de %s repetido (sello %d)", msg.Tipo, msg.Origen, msg.Sello)
+	}
+	return nil
+}
+
+// verificarSello rechaza los sellos que difieren del reloj propio en más de VentanaFirma
+func verificarSello(sello int64) error {
+	desfase := time.Since(time.UnixMilli(sello))
+	if desfase > VentanaFirma || desfase < -VentanaFirma {
+		return fmt.Errorf("sello fuera de la ventana de firma (%s)", desfase.Round(time.Second))
+	}
+	return nil
+}
+
+// registrarFirma anota un mensaje o solicitud con firma válida. Devuelve false si ya se
+// había aceptado otro con el mismo origen, sello y firma.
+func registrarFirma(origen string, sello int64, firma string) bool {
+	clave := origen + "\n" + strconv.FormatInt(sello, 10) + "\n" + firma
+	ahora := time.Now()
+
+	firmasVistas.Lock()
+	defer firmasVistas.Unlock()
+	if ahora.After(firmasVistas.proximaPurga) {
+		for vista, vencimiento := range firmasVistas.vencimientos {
+			if ahora.After(vencimiento) {
+				delete(firmasVistas.vencimientos, vista)
+			}
+		}
+		firmasVistas.proximaPurga = ahora.Add(VentanaFirma / 10)
+	}
+	if _, vista := firmasVistas.vencimientos[clave]; vista {
+		return false
+	}
+	firmasVistas.vencimientos[clave] = time.UnixMilli(sello).Add(VentanaFirma)
+	return true
+}
+
+// verificarTokenAdmin exige en las solicitudes REST el encabezado
+// "Authorization: Bearer <TOKEN_ADMIN>" (ver HabilitarTokenAdmin)
+func verificarTokenAdmin(r *http.Request) error {
+	if len(tokenAdmin) == 0 {
+		if len(secretoFirma) > 0 {
+			return NuevoError(ErrorNoAutenticado, "la API REST requiere TOKEN_ADMIN en la configuración del módulo")
+		}
+		return nil
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !hmac.Equal([]byte(token), tokenAdmin) {
+		return NuevoError(ErrorNoAutenticado, "encabezado Authorization: Bearer ausente o con un token distinto a TOKEN_ADMIN")
+	}
+	return nil
+}
+
+// firmarSolicitudHTTP autentica una solicitud HTTP que no lleva un Mensaje, como la
+// suscripción a eventos de otro módulo: firma método, ruta, origen y sello con
+// SECRETO_COMPARTIDO en X-Origen, X-Sello y X-Firma, sin que el secreto viaje
+func firmarSolicitudHTTP(r *http.Request, origen string) {
+	if len(secretoFirma) == 0 {
+		return
+	}
+	sello := nuevoSello()
+	r.Header.Set("X-Origen", origen)
+	r.Header.Set("X-Sello", strconv.FormatInt(sello, 10))
+	r.Header.Set("X-Firma", calcularFirmaHTTP(r.Method, r.URL.RequestURI(), origen, sello))
+}
+
+// verificarSuscripcion acepta las suscripciones a eventos firmadas por otro módulo (ver
+// firmarSolicitudHTTP) y las de personas y herramientas con TOKEN_ADMIN
+func verificarSuscripcion(r *http.Request) error {
+	firma := r.Header.Get("X-Firma")
+	if firma == "" || len(secretoFirma) == 0 {
+		return verificarTokenAdmin(r)
+	}
+
+	origen := r.Header.Get("X-Origen")
+	sello, err := strconv.ParseInt(r.Header.Get("X-Sello"), 10, 64)
+	if err != nil {
+		return NuevoError(ErrorNoAutenticado, "suscripción de %s con X-Sello inválido", origen)
+	}
+	if err := verificarSello(sello); err != nil {
+		return NuevoError(ErrorNoAutenticado, "suscripción de %s: %v", origen, err)
+	}
+	if !hmac.Equal([]byte(calcularFirmaHTTP(r.Method, r.URL.RequestURI(), origen, sello)), []byte(firma)) {
+		return NuevoError(ErrorNoAutenticado, "firma inválida en la suscripción de %s", origen)
+	}
+	if !registrarFirma(origen, sello, firma) {
+		return NuevoError(ErrorNoAutenticado, "suscripción de %s repetida (sello %d)", origen, sello)
+	}
+	return nil
+}
+
+// FirmarCuerpo devuelve en hexadecimal el HMAC-SHA256 de cuerpo con secreto, o "" si no
+// hay secreto. Sirve para firmar lo que el módulo envía fuera del sistema, siempre con un
+// secreto propio de ese destino y nunca con SECRETO_COMPARTIDO.
+func FirmarCuerpo(secreto string, cuerpo []byte) string {
+	if secreto == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(secreto))
+	mac.Write(cuerpo)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// calcularFirma firma todos los campos de la cabecera del mensaje y el JSON de sus datos
+func calcularFirma(msg *Mensaje, datos []byte) string {
+	mac := hmac.New(sha256.New, secretoFirma)
+	fmt.Fprintf(mac, "%d\n%s\n%s\n%d\n%s\n%s\n%s\n%d\n", msg.Tipo, msg.Operacion, msg.Origen, msg.Version,
+		msg.ClaveIdempotencia, msg.TraceID, msg.SpanPadre, msg.Sello)
+	mac.Write(datos)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// calcularFirmaHTTP firma una solicitud HTTP sin Mensaje (ver firmarSolicitudHTTP)
+func calcularFirmaHTTP(metodo string, ruta string, origen string, sello int64) string {
+	mac := hmac.New(sha256.New, secretoFirma)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d\n", metodo, ruta, origen, sello)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -2,98 +2,281 @@ package utils
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
-
 // Mensaje representa un mensaje genérico entre módulos
 type Mensaje struct {
 	Tipo      int         `json:"tipo"`
 	Operacion string      `json:"operacion"`
 	Origen    string      `json:"origen"`
+	Version   int         `json:"version,omitempty"`
 	Datos     interface{} `json:"datos"`
+
+	// ClaveIdempotencia es igual en todos los reintentos de un mismo envío
+	ClaveIdempotencia string `json:"clave_idempotencia,omitempty"`
+
+	// TraceID y SpanPadre correlacionan el mensaje con la traza del emisor (ver trazas.go)
+	TraceID   string `json:"trace_id,omitempty"`
+	SpanPadre string `json:"span_padre,omitempty"`
+
+	// Sello y Firma autentican al emisor cuando hay SECRETO_COMPARTIDO (ver firma.go)
+	Sello int64  `json:"sello,omitempty"`
+	Firma string `json:"firma,omitempty"`
+
+	// datosCrudos conserva el JSON original de Datos para la decodificación tipada
+	datosCrudos json.RawMessage
+	// ctx se cancela cuando el emisor abandona la solicitud o vence su plazo
+	ctx context.Context
+	// local indica que lo armó el propio servidor desde una solicitud ya autenticada
+	// (endpoint REST o lote firmado)
+	local bool
+}
+
+// Context devuelve el contexto de la solicitud que trajo el mensaje
+func (m *Mensaje) Context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
 }
 
 // HTTPClient representa un cliente HTTP para comunicación entre módulos
 type HTTPClient struct {
 	BaseURL string
 	Nombre  string
-	client  *http.Client
+	// Timeout es el plazo por defecto de cada envío sin deadline propio
+	Timeout time.Duration
+	// Reintentos es la política de reintentos de los envíos; nil envía una sola vez
+	Reintentos *PoliticaReintentos
+	// Circuito corta los envíos mientras el destino no responde
+	Circuito *CircuitBreaker
+	// Transporte elige entre TransporteHTTP (por defecto) y TransporteTCP
+	Transporte string
+	client     *http.Client
+	// tls es la configuración de TLS mutuo, o nil para conectarse en claro (ver tls.go)
+	tls *tls.Config
+
+	direccion string
+	tcpMutex  sync.Mutex
+	tcp       *conexionTCP
 }
 
 // NewHTTPClient crea un nuevo cliente HTTP
 func NewHTTPClient(ip string, puerto int, nombre string) *HTTPClient {
+	direccion := fmt.Sprintf("%s:%d", ip, puerto)
+	baseURL := "http://" + direccion
+	client := &http.Client{}
+	if tlsCliente != nil {
+		baseURL = "https://" + direccion
+		client.Transport = &http.Transport{TLSClientConfig: tlsCliente}
+	}
 	return &HTTPClient{
-		BaseURL: fmt.Sprintf("http://%s:%d", ip, puerto),
-		Nombre:  nombre,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		BaseURL:    baseURL,
+		Nombre:     nombre,
+		Timeout:    TimeoutPorDefecto,
+		Circuito:   NuevoCircuitBreaker(baseURL, 5, 5*time.Second),
+		Transporte: TransporteHTTP,
+		client:     client,
+		tls:        tlsCliente,
+		direccion:  direccion,
 	}
 }
 
+// ConReintentos asigna la política de reintentos del cliente y lo devuelve
+func (c *HTTPClient) ConReintentos(politica PoliticaReintentos) *HTTPClient {
+	c.Reintentos = &politica
+	return c
+}
+
 // EnviarHTTPMensaje envía un mensaje a través de HTTP
 func (c *HTTPClient) EnviarHTTPMensaje(tipo int, operacion string, datos interface{}) (interface{}, error) {
-	mensaje := Mensaje{
-		Tipo:      tipo,
-		Operacion: operacion,
-		Origen:    c.Nombre,
-		Datos:     datos,
+	cuerpo, err := c.enviar(context.Background(), tipo, operacion, datos)
+	if err != nil {
+		return nil, err
+	}
+
+	var resultado interface{}
+	if err := json.Unmarshal(cuerpo, &resultado); err != nil {
+		return nil, NuevoError(ErrorEsquemaInvalido, "error al decodificar respuesta: %v", err)
 	}
 
-	jsonData, err := json.Marshal(mensaje)
+	return resultado, nil
+}
+
+// enviar serializa el mensaje, lo envía aplicando la política de reintentos
+// y devuelve el cuerpo crudo de la respuesta
+func (c *HTTPClient) enviar(ctx context.Context, tipo int, operacion string, datos interface{}) (cuerpo []byte, err error) {
+	ctx, span := IniciarSpan(ctx, "enviar "+operacion, "destino", c.Nombre, "tipo", tipo)
+	defer func() {
+		span.RegistrarError(err)
+		span.Finalizar()
+	}()
+
+	// Datos se serializa una vez para que la firma cubra exactamente el JSON que viaja
+	datosJSON, err := json.Marshal(datos)
 	if err != nil {
 		return nil, fmt.Errorf("error al serializar mensaje: %v", err)
 	}
 
-	resp, err := c.client.Post(
-		fmt.Sprintf("%s/mensaje", c.BaseURL),
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	mensaje := Mensaje{
+		Tipo:              tipo,
+		Operacion:         operacion,
+		Origen:            c.Nombre,
+		Version:           VersionProtocolo,
+		Datos:             json.RawMessage(datosJSON),
+		ClaveIdempotencia: nuevaClaveIdempotencia(),
+		TraceID:           span.TraceID,
+		SpanPadre:         span.SpanID,
+	}
+
+	// Cada intento se firma de nuevo para que su sello no quede fuera de VentanaFirma
+	// en los reintentos largos; la clave de idempotencia se mantiene
+	intentar := func(ctx context.Context) ([]byte, error) {
+		firmarMensaje(&mensaje, datosJSON)
+		jsonData, err := json.Marshal(mensaje)
+		if err != nil {
+			return nil, fmt.Errorf("error al serializar mensaje: %v", err)
+		}
+		return c.enviarIntento(ctx, jsonData)
+	}
+
+	if c.Reintentos == nil {
+		return intentar(ctx)
+	}
+
+	err = c.Reintentos.Ejecutar(ctx, fmt.Sprintf("%s %d/%s", c.Nombre, tipo, operacion), func(ctx context.Context) error {
+		var errIntento error
+		cuerpo, errIntento = intentar(ctx)
+		return errIntento
+	})
+	return cuerpo, err
+}
+
+// enviarIntento hace un único envío pasando por el circuit breaker del destino
+func (c *HTTPClient) enviarIntento(ctx context.Context, jsonData []byte) ([]byte, error) {
+	if c.Circuito != nil {
+		if err := c.Circuito.Permitir(); err != nil {
+			return nil, err
+		}
+	}
+
+	cuerpo, err := c.post(ctx, jsonData)
+	if c.Circuito != nil {
+		c.Circuito.RegistrarResultado(err)
+	}
+	return cuerpo, err
+}
+
+// post envía el mensaje serializado por el transporte configurado
+func (c *HTTPClient) post(ctx context.Context, jsonData []byte) ([]byte, error) {
+	if strings.EqualFold(c.Transporte, TransporteTCP) {
+		return c.postTCP(ctx, jsonData)
+	}
+	return c.postHTTP(ctx, jsonData)
+}
+
+// postHTTP envía el mensaje serializado en un POST. Si ctx no tiene deadline se aplica
+// el Timeout del cliente; el plazo viaja al servidor en HeaderPlazo.
+func (c *HTTPClient) postHTTP(ctx context.Context, jsonData []byte) ([]byte, error) {
+	ctx, cancelar := c.contextoConPlazo(ctx)
+	defer cancelar()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/mensaje", c.BaseURL), bytes.NewBuffer(jsonData))
 	if err != nil {
-		return nil, fmt.Errorf("error al enviar mensaje HTTP: %v", err)
+		return nil, NuevoError(ErrorInterno, "error al crear solicitud: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if plazo, ok := ctx.Deadline(); ok {
+		req.Header.Set(HeaderPlazo, plazo.Format(time.RFC3339Nano))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errorDeRed(c.Nombre, err)
 	}
 	defer resp.Body.Close()
 
+	cuerpo, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errorDeRed(c.Nombre, err)
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("respuesta HTTP no exitosa: %d - %s", resp.StatusCode, string(bodyBytes))
+		return nil, c.decodificarError(resp.StatusCode, cuerpo)
 	}
 
-	var resultado interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&resultado); err != nil {
-		return nil, fmt.Errorf("error al decodificar respuesta: %v", err)
+	return cuerpo, nil
+}
+
+// decodificarError reconstruye el ErrorModulo enviado por el destino.
+// Si la respuesta no trae el sobre de error se informa como ErrorInterno.
+func (c *HTTPClient) decodificarError(estado int, cuerpo []byte) *ErrorModulo {
+	var sobre respuestaError
+	if err := json.Unmarshal(cuerpo, &sobre); err == nil && sobre.Error != nil && sobre.Error.Codigo != "" {
+		return sobre.Error
 	}
 
-	return resultado, nil
+	errorModulo := NuevoError(ErrorInterno, "respuesta HTTP no exitosa: %d - %s", estado, string(bytes.TrimSpace(cuerpo)))
+	errorModulo.Modulo = c.BaseURL
+	return errorModulo
 }
 
+// contextoConPlazo agrega el Timeout del cliente si ctx no trae deadline
+func (c *HTTPClient) contextoConPlazo(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, tienePlazo := ctx.Deadline(); tienePlazo || c.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.Timeout)
+}
 
-// VerificarConexion verifica si un módulo está disponible
+// VerificarConexion verifica si un módulo está disponible y terminó de inicializarse
 func (c *HTTPClient) VerificarConexion() error {
-	resp, err := c.client.Get(fmt.Sprintf("%s/health", c.BaseURL))
+	_, err := c.ConsultarSalud()
+	return err
+}
+
+// ConsultarSalud obtiene el /health del módulo. Un módulo que todavía se está
+// inicializando (o que se está deteniendo) devuelve ErrorNoListo, que es reintentable.
+func (c *HTTPClient) ConsultarSalud() (EstadoSalud, error) {
+	var estado EstadoSalud
+
+	ctx, cancelar := c.contextoConPlazo(context.Background())
+	defer cancelar()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/health", c.BaseURL), nil)
 	if err != nil {
-		return fmt.Errorf("error al verificar conexión con %s: %v", c.BaseURL, err)
+		return estado, NuevoError(ErrorInterno, "error al crear solicitud: %v", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return estado, errorDeRed(c.Nombre, fmt.Errorf("error al verificar conexión con %s: %v", c.BaseURL, err))
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("estado inesperado al verificar conexión: %d", resp.StatusCode)
+	if err := json.NewDecoder(resp.Body).Decode(&estado); err != nil {
+		return estado, fmt.Errorf("error al decodificar respuesta de verificación: %v", err)
 	}
 
-	var result map[string]string
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return fmt.Errorf("error al decodificar respuesta de verificación: %v", err)
+	switch {
+	case resp.StatusCode == http.StatusServiceUnavailable && !estado.Listo:
+		return estado, NuevoError(ErrorNoListo, "%s no está listo: %s", estado.Module, estado.Status)
+	case resp.StatusCode != http.StatusOK:
+		return estado, fmt.Errorf("estado inesperado al verificar conexión: %d", resp.StatusCode)
 	}
 
-	slog.Info("Conexión verificada", "destino", c.BaseURL, "módulo", result["module"])
-	return nil
+	slog.Info("Conexión verificada", "destino", c.BaseURL, "módulo", estado.Module, "uptime_ms", estado.UptimeMs)
+	return estado, nil
 }
 
 // EnviarHTTPOperacion envía un mensaje de operación a través de HTTP
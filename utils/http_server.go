@@ -1,11 +1,15 @@
 package utils
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
+	"sync"
+	"time"
 )
 
 // HTTPHandlerFunc es el tipo para los manejadores de mensajes HTTP
@@ -19,16 +23,35 @@ type HTTPServer struct {
 	server   *http.Server
 	handlers map[int]HTTPHandlerFunc
 	Listener net.Listener
+	// Idempotencia descarta los mensajes repetidos; nil los procesa siempre
+	Idempotencia *CacheIdempotencia
+	// tls exige TLS mutuo en ambos transportes; nil atiende en claro (ver tls.go)
+	tls *tls.Config
+	// rutas son los endpoints REST que se atienden además de /mensaje (ver rest.go)
+	rutas []rutaREST
+	// eventos es el bus que se atiende en /eventos; nil no lo expone (ver eventos.go)
+	eventos *BusEventos
+
+	salud saludServidor
+
+	// mutex protege server y las conexiones TCP abiertas, que se cierran al detener
+	mutex         sync.Mutex
+	cerrado       bool
+	conexionesTCP map[net.Conn]struct{}
 }
 
 // NewHTTPServer crea un nuevo servidor HTTP
 func NewHTTPServer(ip string, puerto int, nombre string) *HTTPServer {
-	return &HTTPServer{
-		IP:       ip,
-		Puerto:   puerto,
-		Nombre:   nombre,
-		handlers: make(map[int]HTTPHandlerFunc),
+	s := &HTTPServer{
+		IP:            ip,
+		Puerto:        puerto,
+		Nombre:        nombre,
+		handlers:      make(map[int]HTTPHandlerFunc),
+		conexionesTCP: make(map[net.Conn]struct{}),
+		tls:           tlsServidor,
 	}
+	s.salud.inicio = time.Now()
+	return s
 }
 
 // RegisterHTTPHandler registra un manejador para un tipo específico de mensaje
@@ -36,33 +59,31 @@ func (s *HTTPServer) RegisterHTTPHandler(tipoMensaje int, handler HTTPHandlerFun
 	s.handlers[tipoMensaje] = handler
 }
 
-// Start inicia el servidor HTTP
+// Start inicia el servidor. El puerto atiende HTTP y también conexiones del transporte TCP
+// (ver transporte_tcp.go), que se distinguen por el preámbulo que envía el cliente.
 func (s *HTTPServer) Start() error {
 	mux := http.NewServeMux()
 
 	// Endpoint para recibir mensajes
 	mux.HandleFunc("/mensaje", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+			s.escribirError(w, NuevoError(ErrorOperacionDesconocida, "método %s no permitido", r.Method))
 			return
 		}
 
 		var mensaje Mensaje
 		err := json.NewDecoder(r.Body).Decode(&mensaje)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error decodificando mensaje: %v", err), http.StatusBadRequest)
+			s.escribirError(w, NuevoError(ErrorEsquemaInvalido, "error decodificando mensaje: %v", err))
 			return
 		}
 
-		handler, exists := s.handlers[mensaje.Tipo]
-		if !exists {
-			http.Error(w, fmt.Sprintf("No hay manejador para el tipo de mensaje %d", mensaje.Tipo), http.StatusBadRequest)
-			return
-		}
+		ctx, cancelar := contextoDeSolicitud(r)
+		defer cancelar()
 
-		respuesta, err := handler(&mensaje)
+		respuesta, err := s.procesarMensaje(ctx, &mensaje)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error en el manejador: %v", err), http.StatusInternalServerError)
+			s.escribirError(w, err)
 			return
 		}
 
@@ -70,26 +91,172 @@ func (s *HTTPServer) Start() error {
 		json.NewEncoder(w).Encode(respuesta)
 	})
 
-	// Endpoint de healthcheck
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "ok", "module": s.Nombre})
-	})
+	// Endpoint de healthcheck: disponibilidad, tiempo activo y carga (ver salud.go)
+	mux.HandleFunc("/health", s.atenderSalud)
+
+	// Métricas en formato Prometheus (ver metricas.go)
+	mux.HandleFunc("/metrics", s.atenderMetricas)
 
-	// Si ya tiene Listener asignado (caso IO)
-	if s.Listener != nil {
-		slog.Info("Servidor HTTP escuchando", "módulo", s.Nombre, "dirección", s.Listener.Addr().String())
-		return http.Serve(s.Listener, mux)
+	// Eventos del módulo como Server-Sent Events (ver eventos.go)
+	if s.eventos != nil {
+		mux.HandleFunc("/eventos", s.atenderEventos)
 	}
 
-	// Caso normal para otros módulos
+	for _, ruta := range s.rutas {
+		mux.HandleFunc(ruta.patron, s.atenderRuta(ruta))
+	}
+
+	// Si no tiene Listener asignado se abre el puerto configurado
 	address := fmt.Sprintf("%s:%d", s.IP, s.Puerto)
+	if s.Listener == nil {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return err
+		}
+		s.Listener = listener
+	}
+
+	s.mutex.Lock()
+	if s.cerrado {
+		s.mutex.Unlock()
+		s.Listener.Close()
+		return http.ErrServerClosed
+	}
 	s.server = &http.Server{
 		Addr:    address,
 		Handler: mux,
 	}
+	s.mutex.Unlock()
 
-	slog.Info("Servidor HTTP escuchando", "módulo", s.Nombre, "dirección", address)
-	return s.server.ListenAndServe()
+	// Con TLS el cifrado va por debajo de ambos transportes, que se separan después del handshake
+	listener := s.Listener
+	if s.tls != nil {
+		listener = tls.NewListener(listener, s.tls)
+	}
+
+	slog.Info("Servidor HTTP escuchando", "módulo", s.Nombre, "dirección", s.Listener.Addr().String(), "tls", s.tls != nil)
+	return s.server.Serve(s.separarTransportes(listener))
+}
+
+// Deteniendo indica si el servidor ya no acepta mensajes nuevos
+func (s *HTTPServer) Deteniendo() bool {
+	return s.salud.deteniendo.Load()
 }
 
+// dejarDeAceptar rechaza los mensajes nuevos con ErrorNoListo; los que están en curso siguen
+func (s *HTTPServer) dejarDeAceptar() {
+	s.salud.deteniendo.Store(true)
+}
+
+// Detener cierra el puerto y espera a que terminen los mensajes en curso de ambos
+// transportes. Si ctx vence antes, cierra las conexiones igual y devuelve su error.
+func (s *HTTPServer) Detener(ctx context.Context) error {
+	s.dejarDeAceptar()
+
+	s.mutex.Lock()
+	s.cerrado = true
+	server := s.server
+	s.mutex.Unlock()
+
+	// Las conexiones de /eventos no terminan solas: se cortan para que Shutdown no las espere
+	if s.eventos != nil {
+		s.eventos.cerrar()
+	}
+
+	var err error
+	if server != nil {
+		// Cierra el listener y espera las solicitudes HTTP activas
+		err = server.Shutdown(ctx)
+	} else if s.Listener != nil {
+		s.Listener.Close()
+	}
+
+	// Los mensajes del transporte TCP no pasan por http.Server: se esperan por su contador
+	espera := time.NewTicker(10 * time.Millisecond)
+	defer espera.Stop()
+	for err == nil && s.salud.enCurso.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-espera.C:
+		}
+	}
+
+	s.mutex.Lock()
+	for conn := range s.conexionesTCP {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("quedaron mensajes sin terminar al detener %s: %w", s.Nombre, err)
+	}
+	return nil
+}
+
+// procesarMensaje valida el mensaje y lo entrega al handler de su tipo.
+// Es común a los transportes HTTP y TCP.
+func (s *HTTPServer) procesarMensaje(ctx context.Context, mensaje *Mensaje) (respuesta interface{}, err error) {
+	ctx = contextoConTrazaRemota(ctx, mensaje.TraceID, mensaje.SpanPadre)
+	ctx, span := IniciarSpan(ctx, "atender "+mensaje.Operacion, "origen", mensaje.Origen, "tipo", mensaje.Tipo)
+	s.salud.enCurso.Add(1)
+	defer func() {
+		s.salud.enCurso.Add(-1)
+		contarMensajeAtendido(mensaje, err)
+		span.RegistrarError(err)
+		span.Finalizar()
+	}()
+
+	if s.Deteniendo() {
+		return nil, NuevoError(ErrorNoListo, "%s se está deteniendo", s.Nombre)
+	}
+
+	if err := VerificarVersion(mensaje.Version); err != nil {
+		slog.Error("Mensaje rechazado", "origen", mensaje.Origen, "tipo", mensaje.Tipo, "error", err)
+		return nil, err
+	}
+
+	// Con la firma verificada el origen es confiable para la autorización por rol.
+	// Los mensajes REST no se firman: se autenticó la solicitud HTTP (ver rest.go).
+	// Tampoco los elementos de un lote: los cubre la firma del lote (ver lote.go).
+	if !mensaje.local {
+		if err := verificarFirma(mensaje); err != nil {
+			ErrorLog.Warn("Mensaje rechazado por autenticación", "origen", mensaje.Origen, "tipo", mensaje.Tipo, "operacion", mensaje.Operacion, "error", err)
+			return nil, err
+		}
+	}
+
+	mensaje.ctx = ctx
+
+	if mensaje.Tipo == MensajeLote {
+		return s.procesarLote(ctx, mensaje)
+	}
+
+	handler, exists := s.handlers[mensaje.Tipo]
+	if !exists {
+		return nil, NuevoError(ErrorOperacionDesconocida, "no hay manejador para el tipo de mensaje %d", mensaje.Tipo)
+	}
+
+	if s.Idempotencia != nil {
+		return s.Idempotencia.Procesar(mensaje, handler)
+	}
+	return handler(mensaje)
+}
+
+// sobreDeError arma el sobre de error estándar completando el módulo de origen
+func (s *HTTPServer) sobreDeError(err error) respuestaError {
+	errorModulo := ComoErrorModulo(err)
+	if errorModulo.Modulo == "" {
+		errorModulo.Modulo = s.Nombre
+	}
+	return respuestaError{Error: errorModulo}
+}
+
+// escribirError responde con el sobre de error estándar y el estado HTTP de su código
+func (s *HTTPServer) escribirError(w http.ResponseWriter, err error) {
+	sobre := s.sobreDeError(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(sobre.Error.EstadoHTTP())
+	json.NewEncoder(w).Encode(sobre)
+}
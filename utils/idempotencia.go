@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// respuestaGuardada es el resultado de un handler asociado a una clave de idempotencia
+type respuestaGuardada struct {
+	respuesta interface{}
+	err       error
+	listo     chan struct{}
+	guardada  time.Time
+}
+
+// CacheIdempotencia recuerda el resultado de cada mensaje con ClaveIdempotencia
+// para que un reintento no vuelva a aplicar la operación
+type CacheIdempotencia struct {
+	ttl      time.Duration
+	mutex    sync.Mutex
+	entradas map[string]*respuestaGuardada
+}
+
+// NuevaCacheIdempotencia crea una cache que conserva los resultados durante ttl
+func NuevaCacheIdempotencia(ttl time.Duration) *CacheIdempotencia {
+	return &CacheIdempotencia{
+		ttl:      ttl,
+		entradas: make(map[string]*respuestaGuardada),
+	}
+}
+
+// Procesar ejecuta handler una sola vez por clave. Los duplicados reciben el resultado
+// original; si llegan mientras el original se procesa, lo esperan mientras siga vigente
+// su propia solicitud. Los errores de red o cancelación no se guardan para permitir
+// reintentar, y tampoco un pánico del handler.
+func (c *CacheIdempotencia) Procesar(msg *Mensaje, handler HTTPHandlerFunc) (interface{}, error) {
+	clave := msg.ClaveIdempotencia
+	if clave == "" {
+		return handler(msg)
+	}
+
+	c.mutex.Lock()
+	c.purgar()
+	if entrada, existe := c.entradas[clave]; existe {
+		c.mutex.Unlock()
+		select {
+		case <-entrada.listo:
+		case <-msg.Context().Done():
+			return nil, NuevoError(ErrorCancelado, "esperando el resultado original de %s: %v", nombreOperacion(msg), msg.Context().Err())
+		}
+		InfoLog.Info("Mensaje duplicado, se devuelve el resultado original", "clave", clave, "origen", msg.Origen, "tipo", msg.Tipo)
+		return entrada.respuesta, entrada.err
+	}
+	entrada := &respuestaGuardada{listo: make(chan struct{})}
+	c.entradas[clave] = entrada
+	c.mutex.Unlock()
+
+	terminado := false
+	defer func() {
+		c.mutex.Lock()
+		entrada.guardada = time.Now()
+		if !terminado {
+			// El handler entró en pánico: los que esperan no reciben un resultado vacío
+			// y un reintento vuelve a ejecutarlo
+			entrada.respuesta = nil
+			entrada.err = NuevoError(ErrorInterno, "el mensaje original de %s no terminó", nombreOperacion(msg))
+			delete(c.entradas, clave)
+		} else if codigo := CodigoDe(entrada.err); codigo == ErrorCancelado || codigo == ErrorTimeout {
+			delete(c.entradas, clave)
+		}
+		c.mutex.Unlock()
+		close(entrada.listo)
+	}()
+
+	entrada.respuesta, entrada.err = handler(msg)
+	terminado = true
+	return entrada.respuesta, entrada.err
+}
+
+// purgar elimina los resultados vencidos. Debe llamarse con el mutex tomado.
+func (c *CacheIdempotencia) purgar() {
+	for clave, entrada := range c.entradas {
+		if !entrada.guardada.IsZero() && time.Since(entrada.guardada) > c.ttl {
+			delete(c.entradas, clave)
+		}
+	}
+}
+
+// nuevaClaveIdempotencia genera una clave aleatoria para un envío lógico
+func nuevaClaveIdempotencia() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
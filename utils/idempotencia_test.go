@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheIdempotencia(t *testing.T) {
+	casos := []struct {
+		nombre      string
+		clave       string
+		err         error
+		ejecuciones int32
+	}{
+		{"sin clave se ejecuta siempre", "", nil, 2},
+		{"resultado exitoso se reutiliza", "clave", nil, 1},
+		{"error definitivo se reutiliza", "clave", NuevoError(ErrorPIDInexistente, "pid 3"), 1},
+		{"cancelación se reintenta", "clave", NuevoError(ErrorCancelado, "cortado"), 2},
+		{"timeout se reintenta", "clave", NuevoError(ErrorTimeout, "lento"), 2},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			cache := NuevaCacheIdempotencia(time.Minute)
+			var ejecuciones atomic.Int32
+			handler := func(msg *Mensaje) (interface{}, error) {
+				return ejecuciones.Add(1), caso.err
+			}
+
+			for intento := 0; intento < 2; intento++ {
+				respuesta, err := cache.Procesar(&Mensaje{Tipo: 1, Origen: "CPU", ClaveIdempotencia: caso.clave}, handler)
+				if CodigoDe(err) != CodigoDe(caso.err) {
+					t.Fatalf("intento %d: error %v, se esperaba %v", intento, err, caso.err)
+				}
+				if caso.ejecuciones == 1 && respuesta != int32(1) {
+					t.Fatalf("intento %d: respuesta %v, se esperaba la original", intento, respuesta)
+				}
+			}
+			if ejecuciones.Load() != caso.ejecuciones {
+				t.Fatalf("el handler se ejecutó %d veces, se esperaban %d", ejecuciones.Load(), caso.ejecuciones)
+			}
+		})
+	}
+}
+
+func TestCacheIdempotenciaVencimiento(t *testing.T) {
+	cache := NuevaCacheIdempotencia(10 * time.Millisecond)
+	var ejecuciones atomic.Int32
+	handler := func(msg *Mensaje) (interface{}, error) {
+		return ejecuciones.Add(1), nil
+	}
+
+	cache.Procesar(&Mensaje{ClaveIdempotencia: "clave"}, handler)
+	time.Sleep(20 * time.Millisecond)
+	cache.Procesar(&Mensaje{ClaveIdempotencia: "clave"}, handler)
+	if ejecuciones.Load() != 2 {
+		t.Fatalf("el handler se ejecutó %d veces, se esperaban 2", ejecuciones.Load())
+	}
+}
+
+// procesarEnSegundoPlano lanza Procesar y devuelve el canal por el que llega su error
+func procesarEnSegundoPlano(cache *CacheIdempotencia, msg *Mensaje, handler HTTPHandlerFunc) <-chan error {
+	resultado := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recover() != nil {
+				resultado <- NuevoError(ErrorInterno, "pánico")
+			}
+		}()
+		_, err := cache.Procesar(msg, handler)
+		resultado <- err
+	}()
+	return resultado
+}
+
+// recibir espera el error de una operación lanzada en segundo plano
+func recibir(t *testing.T, resultado <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-resultado:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("la operación sigue bloqueada")
+		return nil
+	}
+}
+
+func TestCacheIdempotenciaDuplicadoEnCurso(t *testing.T) {
+	casos := []struct {
+		nombre string
+		// cancelarDuplicado corta la solicitud del duplicado mientras espera
+		cancelarDuplicado bool
+		// panico hace que el original termine en pánico
+		panico       bool
+		errOriginal  CodigoError
+		errDuplicado CodigoError
+	}{
+		{"recibe el resultado original", false, false, "", ""},
+		{"deja de esperar si se cancela", true, false, "", ErrorCancelado},
+		{"no recibe un resultado vacío si el original entra en pánico", false, true, ErrorInterno, ErrorInterno},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			cache := NuevaCacheIdempotencia(time.Minute)
+			empezo := make(chan struct{})
+			continuar := make(chan struct{})
+			var ejecuciones atomic.Int32
+			handler := func(msg *Mensaje) (interface{}, error) {
+				ejecuciones.Add(1)
+				close(empezo)
+				<-continuar
+				if caso.panico {
+					panic("falla del handler")
+				}
+				return "ok", nil
+			}
+
+			original := procesarEnSegundoPlano(cache, &Mensaje{ClaveIdempotencia: "clave"}, handler)
+			<-empezo
+
+			ctx, cancelar := context.WithCancel(context.Background())
+			defer cancelar()
+			duplicado := procesarEnSegundoPlano(cache, &Mensaje{ClaveIdempotencia: "clave", ctx: ctx}, handler)
+			// Margen para que el duplicado encuentre la entrada en curso y se ponga a esperar
+			time.Sleep(20 * time.Millisecond)
+
+			if caso.cancelarDuplicado {
+				cancelar()
+				if err := recibir(t, duplicado); CodigoDe(err) != caso.errDuplicado {
+					t.Fatalf("el duplicado devolvió %v, se esperaba %s", err, caso.errDuplicado)
+				}
+				close(continuar)
+				recibir(t, original)
+			} else {
+				close(continuar)
+				if err := recibir(t, original); CodigoDe(err) != caso.errOriginal {
+					t.Fatalf("el original devolvió %v, se esperaba %q", err, caso.errOriginal)
+				}
+				if err := recibir(t, duplicado); CodigoDe(err) != caso.errDuplicado {
+					t.Fatalf("el duplicado devolvió %v, se esperaba %q", err, caso.errDuplicado)
+				}
+			}
+			if ejecuciones.Load() != 1 {
+				t.Fatalf("el handler se ejecutó %d veces, se esperaba 1", ejecuciones.Load())
+			}
+
+			// Tras un pánico la clave se libera y un reintento vuelve a ejecutar el handler
+			if caso.panico {
+				_, err := cache.Procesar(&Mensaje{ClaveIdempotencia: "clave"}, func(msg *Mensaje) (interface{}, error) {
+					return "reintento", nil
+				})
+				if err != nil {
+					t.Fatalf("el reintento tras el pánico devolvió %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Lease es el permiso de un módulo registrado para seguir considerándose vivo.
+// Se otorga con el handshake y se renueva con cada latido.
+type Lease struct {
+	Clave        string         `json:"clave"`
+	Otorgado     time.Time      `json:"otorgado"`
+	UltimoLatido time.Time      `json:"ultimo_latido"`
+	Vence        time.Time      `json:"vence"`
+	Carga        map[string]int `json:"carga,omitempty"`
+}
+
+// RegistroLeases lleva los leases de los módulos que se registraron con handshake
+// y avisa cuando uno vence sin haber recibido latidos.
+type RegistroLeases struct {
+	duracion time.Duration
+	alVencer func(clave string)
+
+	mutex  sync.Mutex
+	leases map[string]*Lease
+}
+
+// NuevoRegistroLeases crea un registro cuyos leases duran duracion desde el último latido.
+// Con duracion 0 los leases no vencen nunca.
+func NuevoRegistroLeases(duracion time.Duration, alVencer func(clave string)) *RegistroLeases {
+	return &RegistroLeases{
+		duracion: duracion,
+		alVencer: alVencer,
+		leases:   make(map[string]*Lease),
+	}
+}
+
+// Otorgar crea (o reinicia) el lease de clave. Se llama al recibir su handshake.
+func (r *RegistroLeases) Otorgar(clave string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ahora := time.Now()
+	r.leases[clave] = &Lease{Clave: clave, Otorgado: ahora, UltimoLatido: ahora, Vence: ahora.Add(r.duracion)}
+	InfoLog.Info("Lease otorgado", "clave", clave, "duracion", r.duracion)
+}
+
+// Renovar extiende el lease de clave. Si no existe (venció o el destino se reinició)
+// devuelve ErrorLeaseInexistente para que el módulo repita el handshake.
+func (r *RegistroLeases) Renovar(clave string, carga map[string]int) (Lease, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	lease, existe := r.leases[clave]
+	if !existe {
+		return Lease{}, NuevoError(ErrorLeaseInexistente, "%s no tiene lease vigente, debe repetir el handshake", clave)
+	}
+
+	lease.UltimoLatido = time.Now()
+	lease.Vence = lease.UltimoLatido.Add(r.duracion)
+	lease.Carga = carga
+	return *lease, nil
+}
+
+// Revocar elimina el lease de clave sin avisar a alVencer
+func (r *RegistroLeases) Revocar(clave string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.leases, clave)
+}
+
+// Leases devuelve una copia de los leases vigentes ordenados por clave
+func (r *RegistroLeases) Leases() []Lease {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	leases := make([]Lease, 0, len(r.leases))
+	for _, lease := range r.leases {
+		leases = append(leases, *lease)
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i].Clave < leases[j].Clave })
+	return leases
+}
+
+// Vigilar revisa periódicamente los leases hasta que ctx se cancele y llama
+// a alVencer por cada uno que venció. Con duracion 0 no hace nada.
+func (r *RegistroLeases) Vigilar(ctx context.Context) {
+	if r.duracion <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.duracion / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ahora := <-ticker.C:
+			for _, clave := range r.vencidos(ahora) {
+				InfoLog.Warn("Lease vencido por falta de latidos", "clave", clave)
+				if r.alVencer != nil {
+					r.alVencer(clave)
+				}
+			}
+		}
+	}
+}
+
+// vencidos quita del registro los leases vencidos y devuelve sus claves
+func (r *RegistroLeases) vencidos(ahora time.Time) []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var claves []string
+	for clave, lease := range r.leases {
+		if ahora.After(lease.Vence) {
+			delete(r.leases, clave)
+			claves = append(claves, clave)
+		}
+	}
+	sort.Strings(claves)
+	return claves
+}
+
+// MantenerConexion hace el handshake con destino (ver ConectarConReintentos) y, si se
+// establece, le envía un latido cada intervalo hasta que ctx se cancele. Si el destino
+// ya no reconoce el lease se repite el handshake. Con intervalo 0 no se envían latidos.
+func MantenerConexion(ctx context.Context, c *HTTPClient, destino string, datos SolicitudHandshake, intervalo time.Duration, carga FuncionCarga) (RespuestaHandshake, error) {
+	respuesta, err := ConectarConReintentos(c, destino, datos)
+	if err != nil || intervalo <= 0 {
+		return respuesta, err
+	}
+
+	go enviarLatidos(ctx, c, destino, datos, intervalo, carga)
+	return respuesta, nil
+}
+
+// enviarLatidos renueva periódicamente el lease del módulo en destino
+func enviarLatidos(ctx context.Context, c *HTTPClient, destino string, datos SolicitudHandshake, intervalo time.Duration, carga FuncionCarga) {
+	ticker := time.NewTicker(intervalo)
+	defer ticker.Stop()
+
+	conectado := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		latido := SolicitudLatido{Identificador: datos.ClaveLease()}
+		if carga != nil {
+			latido.Carga = carga()
+		}
+
+		ctxLatido, cancelar := context.WithTimeout(ctx, intervalo)
+		_, err := EnviarConContexto[SolicitudLatido, RespuestaLatido](ctxLatido, c, MensajeLatido, "latido", latido)
+		cancelar()
+
+		switch {
+		case err == nil:
+			if !conectado {
+				InfoLog.Info("Latidos restablecidos", "destino", destino)
+			}
+			conectado = true
+		case CodigoDe(err) == ErrorLeaseInexistente:
+			InfoLog.Warn("El destino no reconoce el lease, se repite el handshake", "destino", destino)
+			if _, err := ConectarConReintentos(c, destino, datos); err == nil {
+				conectado = true
+			}
+		default:
+			// Se avisa solo la primera falla para no llenar el log mientras el destino está caído
+			if conectado {
+				ErrorLog.Warn("No se pudo enviar latido", "destino", destino, "error", err)
+			}
+			conectado = false
+		}
+	}
+}
+
+// AvisarBaja informa al destino que el módulo se detiene, para que lo quite
+// sin esperar a que venza su lease
+func AvisarBaja(ctx context.Context, c *HTTPClient, datos SolicitudHandshake) error {
+	baja := SolicitudLatido{Identificador: datos.ClaveLease()}
+	_, err := EnviarConContexto[SolicitudLatido, RespuestaEstado](ctx, c, MensajeLatido, "baja", baja)
+	return err
+}
@@ -1,20 +1,59 @@
 package utils
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 var (
 	InfoLog  *slog.Logger
 	ErrorLog *slog.Logger
+	// ObligatorioLog escribe los logs que exige el enunciado. Salen junto al resto
+	// y además en LOG_OBLIGATORIO_ARCHIVO si está configurado.
+	ObligatorioLog *slog.Logger
 )
 
-// InicializarLogger configura los loggers globales
+// OpcionesLog se embebe en la configuración de cada módulo
+type OpcionesLog struct {
+	LogArchivo            string `json:"LOG_ARCHIVO,omitempty"`             // Archivo del log completo (además de stdout); "-" no persiste
+	LogArchivoObligatorio string `json:"LOG_OBLIGATORIO_ARCHIVO,omitempty"` // Archivo solo con los logs obligatorios
+	LogFormato            string `json:"LOG_FORMATO,omitempty"`             // TEXT (por defecto) o JSON
+	LogMaxMB              int    `json:"LOG_MAX_MB,omitempty"`              // Rota al superar este tamaño
+	LogMaxHoras           int    `json:"LOG_MAX_HORAS,omitempty"`           // Rota al superar esta antigüedad
+	LogMaxArchivos        int    `json:"LOG_MAX_ARCHIVOS,omitempty"`        // Archivos rotados que se conservan
+}
+
+// directorioLogs es donde se persiste el log completo de un módulo sin LOG_ARCHIVO,
+// en <módulo>.log
+const directorioLogs = "logs"
+
+// SinArchivoLog en LOG_ARCHIVO deja el log completo solo en stdout
+const SinArchivoLog = "-"
+
+// archivosLog guarda los archivos abiertos para cerrarlos al reconfigurar el logger
+var archivosLog []io.Closer
+
+// InicializarLogger configura los loggers globales solo con stdout, para el arranque
+// antes de leer la configuración
 func InicializarLogger(logLevel string, moduleName string) {
+	if err := InicializarLoggerConOpciones(logLevel, moduleName, OpcionesLog{LogArchivo: SinArchivoLog}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error al inicializar logger: %v\n", err)
+	}
+}
+
+// InicializarLoggerConOpciones configura los loggers globales escribiendo también
+// en los archivos rotativos indicados; sin LOG_ARCHIVO el log completo va a
+// logs/<módulo>.log. Si un archivo no se puede abrir los loggers quedan configurados
+// solo con stdout y se devuelve el error.
+func InicializarLoggerConOpciones(logLevel string, moduleName string, opciones OpcionesLog) error {
 	var level slog.Level
 
-	switch logLevel {
+	switch strings.ToLower(logLevel) {
 	case "debug":
 		level = slog.LevelDebug
 	case "info":
@@ -27,12 +66,105 @@ func InicializarLogger(logLevel string, moduleName string) {
 		level = slog.LevelInfo
 	}
 
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: level,
-	})
+	for _, archivo := range archivosLog {
+		archivo.Close()
+	}
+	archivosLog = nil
+
+	nuevoHandler := func(w io.Writer, level slog.Level) slog.Handler {
+		handlerOpts := &slog.HandlerOptions{Level: level}
+		if strings.EqualFold(opciones.LogFormato, "JSON") {
+			return slog.NewJSONHandler(w, handlerOpts)
+		}
+		return slog.NewTextHandler(w, handlerOpts)
+	}
+
+	var salida io.Writer = os.Stdout
+	var errArchivos error
+
+	archivoLog := opciones.LogArchivo
+	if archivoLog == "" {
+		archivoLog = filepath.Join(directorioLogs, moduleName+".log")
+	}
+	if archivoLog != SinArchivoLog {
+		archivo, err := abrirArchivoLog(archivoLog, opciones)
+		if err != nil {
+			errArchivos = err
+		} else {
+			archivosLog = append(archivosLog, archivo)
+			salida = io.MultiWriter(os.Stdout, archivo)
+		}
+	}
+
+	handler := nuevoHandler(salida, level)
+	handlerObligatorio := handler
+
+	if opciones.LogArchivoObligatorio != "" {
+		archivo, err := abrirArchivoLog(opciones.LogArchivoObligatorio, opciones)
+		if err != nil {
+			errArchivos = err
+		} else {
+			archivosLog = append(archivosLog, archivo)
+			// El archivo obligatorio no depende de LOG_LEVEL: siempre registra los Info
+			handlerObligatorio = handlerMultiple{handler, nuevoHandler(archivo, slog.LevelInfo)}
+		}
+	}
 
 	logger := slog.New(handler).With("modulo", moduleName)
 
 	InfoLog = logger
 	ErrorLog = logger
+	ObligatorioLog = slog.New(handlerObligatorio).With("modulo", moduleName, "obligatorio", true)
+
+	return errArchivos
+}
+
+// abrirArchivoLog abre un archivo rotativo con los límites de las opciones
+func abrirArchivoLog(ruta string, opciones OpcionesLog) (*ArchivoRotativo, error) {
+	archivo, err := NuevoArchivoRotativo(ruta, opciones.LogMaxMB, opciones.LogMaxHoras, opciones.LogMaxArchivos)
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir log %s: %v", ruta, err)
+	}
+	return archivo, nil
+}
+
+// handlerMultiple reenvía cada registro a todos sus handlers
+type handlerMultiple []slog.Handler
+
+func (h handlerMultiple) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h handlerMultiple) Handle(ctx context.Context, registro slog.Record) error {
+	var primerError error
+	for _, handler := range h {
+		if !handler.Enabled(ctx, registro.Level) {
+			continue
+		}
+		if err := handler.Handle(ctx, registro.Clone()); err != nil && primerError == nil {
+			primerError = err
+		}
+	}
+	return primerError
+}
+
+func (h handlerMultiple) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(handlerMultiple, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (h handlerMultiple) WithGroup(nombre string) slog.Handler {
+	handlers := make(handlerMultiple, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(nombre)
+	}
+	return handlers
 }
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// OperacionLote es la operación de los mensajes MensajeLote
+const OperacionLote = "LOTE"
+
+// MaxElementosLote acota cuánto trabajo puede pedir una sola solicitud
+const MaxElementosLote = 256
+
+// ElementoLote es uno de los mensajes de un lote
+type ElementoLote struct {
+	Tipo      int             `json:"tipo"`
+	Operacion string          `json:"operacion"`
+	Datos     json.RawMessage `json:"datos"`
+}
+
+// SolicitudLote agrupa mensajes para el mismo destino, que los atiende en orden
+type SolicitudLote struct {
+	Elementos []ElementoLote `json:"elementos"`
+	// DetenerEnError deja sin atender los elementos que siguen al primero que falla
+	DetenerEnError bool `json:"detener_en_error,omitempty"`
+}
+
+// Validar exige entre 1 y MaxElementosLote elementos y no acepta lotes anidados
+func (s SolicitudLote) Validar() error {
+	if len(s.Elementos) == 0 || len(s.Elementos) > MaxElementosLote {
+		return NuevoError(ErrorEsquemaInvalido, "un lote debe tener entre 1 y %d mensajes, tiene %d", MaxElementosLote, len(s.Elementos))
+	}
+	for i, elemento := range s.Elementos {
+		if elemento.Tipo == MensajeLote {
+			return NuevoError(ErrorEsquemaInvalido, "el mensaje %d del lote es otro lote", i)
+		}
+	}
+	return nil
+}
+
+// ResultadoLote es la respuesta o el error de un elemento, en la misma posición del lote
+type ResultadoLote struct {
+	Respuesta json.RawMessage `json:"respuesta,omitempty"`
+	Error     *ErrorModulo    `json:"error,omitempty"`
+}
+
+// Err devuelve el error del elemento, o nil si se atendió bien
+func (r ResultadoLote) Err() error {
+	if r.Error == nil {
+		return nil
+	}
+	return r.Error
+}
+
+// RespuestaLote devuelve un resultado por elemento del lote
+type RespuestaLote struct {
+	Resultados []ResultadoLote `json:"resultados"`
+}
+
+// Lote arma varios mensajes que se envían al mismo destino en una sola solicitud
+type Lote struct {
+	solicitud SolicitudLote
+	err       error
+}
+
+// NuevoLote crea un lote vacío
+func NuevoLote() *Lote {
+	return &Lote{}
+}
+
+// Agregar suma un mensaje al lote y devuelve la posición de su resultado
+func (l *Lote) Agregar(tipo int, operacion string, datos interface{}) int {
+	crudo, err := json.Marshal(datos)
+	if err != nil && l.err == nil {
+		l.err = NuevoError(ErrorEsquemaInvalido, "error al serializar el mensaje %d del lote: %v", len(l.solicitud.Elementos), err)
+	}
+	l.solicitud.Elementos = append(l.solicitud.Elementos, ElementoLote{Tipo: tipo, Operacion: operacion, Datos: crudo})
+	return len(l.solicitud.Elementos) - 1
+}
+
+// DetenerEnError hace que el destino no atienda los mensajes que siguen al primero que falla
+func (l *Lote) DetenerEnError() *Lote {
+	l.solicitud.DetenerEnError = true
+	return l
+}
+
+// Len devuelve la cantidad de mensajes del lote
+func (l *Lote) Len() int {
+	return len(l.solicitud.Elementos)
+}
+
+// EnviarLote envía todos los mensajes del lote en una sola solicitud. Solo devuelve error
+// si falló el lote completo; el error de cada mensaje viaja en su resultado.
+func (c *HTTPClient) EnviarLote(ctx context.Context, lote *Lote) ([]ResultadoLote, error) {
+	if lote.err != nil {
+		return nil, lote.err
+	}
+
+	respuesta, err := EnviarConContexto[SolicitudLote, RespuestaLote](ctx, c, MensajeLote, OperacionLote, lote.solicitud)
+	if err != nil {
+		return nil, err
+	}
+	if len(respuesta.Resultados) != lote.Len() {
+		errorModulo := NuevoError(ErrorEsquemaInvalido, "%s devolvió %d resultados para un lote de %d mensajes", c.Nombre, len(respuesta.Resultados), lote.Len())
+		errorModulo.Modulo = c.Nombre
+		return nil, errorModulo
+	}
+	return respuesta.Resultados, nil
+}
+
+// ResultadoComo decodifica en Resp la respuesta de un elemento del lote, o devuelve su error
+func ResultadoComo[Resp any](resultado ResultadoLote) (Resp, error) {
+	var respuesta Resp
+	if err := resultado.Err(); err != nil {
+		return respuesta, err
+	}
+	if err := json.Unmarshal(resultado.Respuesta, &respuesta); err != nil {
+		return respuesta, NuevoError(ErrorEsquemaInvalido, "resultado del lote con formato inválido para %T: %v", respuesta, err)
+	}
+	return respuesta, nil
+}
+
+// procesarLote atiende en orden cada elemento como un mensaje más del mismo origen: pasa
+// por los middlewares, la autorización y la idempotencia de su tipo. La firma del lote
+// cubre a todos sus elementos.
+func (s *HTTPServer) procesarLote(ctx context.Context, lote *Mensaje) (RespuestaLote, error) {
+	solicitud, err := DecodificarDatos[SolicitudLote](lote)
+	if err != nil {
+		return RespuestaLote{}, err
+	}
+
+	resultados := make([]ResultadoLote, len(solicitud.Elementos))
+	for i, elemento := range solicitud.Elementos {
+		mensaje := &Mensaje{
+			Tipo:        elemento.Tipo,
+			Operacion:   elemento.Operacion,
+			Origen:      lote.Origen,
+			Version:     lote.Version,
+			datosCrudos: elemento.Datos,
+			local:       true,
+		}
+		if len(elemento.Datos) > 0 {
+			if err := json.Unmarshal(elemento.Datos, &mensaje.Datos); err != nil {
+				resultados[i] = s.resultadoLote(nil, NuevoError(ErrorEsquemaInvalido, "datos del mensaje %d del lote: %v", i, err))
+				continue
+			}
+		}
+		// Cada elemento tiene su propia clave para que un reintento del lote no repita los ya aplicados
+		if lote.ClaveIdempotencia != "" {
+			mensaje.ClaveIdempotencia = fmt.Sprintf("%s/%d", lote.ClaveIdempotencia, i)
+		}
+
+		respuesta, err := s.procesarMensaje(ctx, mensaje)
+		resultados[i] = s.resultadoLote(respuesta, err)
+
+		if err != nil && solicitud.DetenerEnError {
+			for j := i + 1; j < len(resultados); j++ {
+				resultados[j] = s.resultadoLote(nil, NuevoError(ErrorCancelado, "no se atendió: falló el mensaje %d del lote", i))
+			}
+			break
+		}
+	}
+	return RespuestaLote{Resultados: resultados}, nil
+}
+
+// resultadoLote serializa la respuesta de un elemento o arma su sobre de error
+func (s *HTTPServer) resultadoLote(respuesta interface{}, err error) ResultadoLote {
+	if err == nil {
+		crudo, errSerializar := json.Marshal(respuesta)
+		if errSerializar == nil {
+			return ResultadoLote{Respuesta: crudo}
+		}
+		err = NuevoError(ErrorInterno, "error serializando la respuesta: %v", errSerializar)
+	}
+	return ResultadoLote{Error: s.sobreDeError(err).Error}
+}
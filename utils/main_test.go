@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+// TestMain descarta los logs de los módulos: los tests solo miran resultados
+func TestMain(m *testing.M) {
+	descartar := slog.New(slog.NewTextHandler(io.Discard, nil))
+	slog.SetDefault(descartar)
+	InfoLog = descartar
+	ErrorLog = descartar
+	ObligatorioLog = descartar
+	os.Exit(m.Run())
+}
@@ -0,0 +1,424 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Esquemas de los datos que viajan en Mensaje.Datos
+// Los campos sin omitempty son obligatorios (ver DecodificarDatos)
+// ============================================================================
+
+// Motivos de retorno de la CPU al Kernel
+const (
+	MotivoExit            = "EXIT"
+	MotivoError           = "ERROR"
+	MotivoInterrumpido    = "INTERRUPTED"
+	MotivoSyscallIO       = "SYSCALL_IO"
+	MotivoSyscallInitProc = "SYSCALL_INIT_PROC"
+	MotivoSyscallDump     = "SYSCALL_DUMP_MEMORY"
+)
+
+// Eventos que los módulos notifican al Kernel
+const (
+	EventoIOTerminada      = "IO_TERMINADA"
+	EventoIOInterrumpida   = "IO_INTERRUMPIDA"
+	EventoSolicitudIO      = "SOLICITUD_IO"
+	EventoProcesoTerminado = "PROCESO_TERMINADO"
+	EventoRetornoCPU       = "RETORNO_CPU"
+)
+
+// === COMUNICACIÓN BÁSICA ===
+
+// SolicitudHandshake es el saludo inicial de un módulo hacia otro
+type SolicitudHandshake struct {
+	Version       int    `json:"version"`
+	Nombre        string `json:"nombre"`
+	Tipo          string `json:"tipo"`
+	IP            string `json:"ip,omitempty"`
+	Puerto        int    `json:"puerto,omitempty"`
+	Identificador string `json:"identificador,omitempty"`
+}
+
+// Validar rechaza handshakes de versiones de protocolo distintas
+func (s SolicitudHandshake) Validar() error {
+	return VerificarVersion(s.Version)
+}
+
+// RespuestaHandshake es la respuesta al handshake
+type RespuestaHandshake struct {
+	Status           string `json:"status"`
+	Version          int    `json:"version"`
+	Mensaje          string `json:"mensaje,omitempty"`
+	TamPagina        int    `json:"tam_pagina,omitempty"`
+	EntradasPorTabla int    `json:"entradas_por_tabla,omitempty"`
+	Niveles          int    `json:"niveles,omitempty"`
+}
+
+// ClaveLease identifica al módulo en el registro de leases del destino:
+// el identificador si lo tiene (CPUs) o si no su tipo (dispositivos IO)
+func (s SolicitudHandshake) ClaveLease() string {
+	if s.Identificador != "" {
+		return s.Identificador
+	}
+	return s.Tipo
+}
+
+// SolicitudLatido renueva el lease que el módulo obtuvo con su handshake
+type SolicitudLatido struct {
+	Identificador string         `json:"identificador"`
+	Carga         map[string]int `json:"carga,omitempty"`
+}
+
+// RespuestaLatido informa hasta cuándo quedó renovado el lease
+type RespuestaLatido struct {
+	VenceMs int64 `json:"vence_ms"`
+}
+
+// SinDatos se usa en los mensajes que no llevan datos
+type SinDatos struct{}
+
+// RespuestaEstado es la respuesta mínima de una operación
+type RespuestaEstado struct {
+	Status  string `json:"status"`
+	Mensaje string `json:"mensaje,omitempty"`
+}
+
+// RespuestaOK construye una RespuestaEstado exitosa
+func RespuestaOK(mensaje string) RespuestaEstado {
+	return RespuestaEstado{Status: "OK", Mensaje: mensaje}
+}
+
+// SolicitudPID identifica un proceso (finalizar, suspender, dessuspender, dump)
+type SolicitudPID struct {
+	PID int `json:"pid"`
+}
+
+// Validar verifica que el PID no sea negativo
+func (s SolicitudPID) Validar() error {
+	return validarPID(s.PID)
+}
+
+// SolicitudCrearProceso pide al Kernel crear un proceso a partir de un pseudocódigo
+type SolicitudCrearProceso struct {
+	Archivo   string `json:"archivo"`
+	Tamanio   int    `json:"tamanio"`
+	Prioridad int    `json:"prioridad,omitempty"` // 0 es la máxima
+}
+
+// Validar exige un nombre de archivo sin rutas, un tamaño y una prioridad no negativos
+func (s SolicitudCrearProceso) Validar() error {
+	if s.Archivo == "" || strings.ContainsAny(s.Archivo, `/\`) || s.Archivo == ".." {
+		return fmt.Errorf("archivo inválido: %q", s.Archivo)
+	}
+	if s.Tamanio < 0 {
+		return fmt.Errorf("tamaño inválido: %d", s.Tamanio)
+	}
+	if s.Prioridad < 0 {
+		return fmt.Errorf("prioridad inválida: %d", s.Prioridad)
+	}
+	return nil
+}
+
+// RespuestaCrearProceso informa el PID asignado al proceso creado
+type RespuestaCrearProceso struct {
+	PID int `json:"pid"`
+}
+
+// Planificadores del Kernel que se pueden pausar y reanudar
+const (
+	PlanificadorLargoPlazo = "largo"
+	PlanificadorCortoPlazo = "corto"
+	PlanificadorTodos      = "todos"
+)
+
+// SolicitudPlanificacion elige qué planificador pausar o reanudar
+type SolicitudPlanificacion struct {
+	Planificador string `json:"planificador"`
+}
+
+// Validar exige uno de los planificadores conocidos
+func (s SolicitudPlanificacion) Validar() error {
+	switch s.Planificador {
+	case PlanificadorLargoPlazo, PlanificadorCortoPlazo, PlanificadorTodos:
+		return nil
+	}
+	return fmt.Errorf("planificador inválido: %q (largo, corto o todos)", s.Planificador)
+}
+
+// RespuestaPlanificacion informa qué planificadores están tomando decisiones
+type RespuestaPlanificacion struct {
+	LargoPlazo bool   `json:"largo_plazo"`
+	CortoPlazo bool   `json:"corto_plazo"`
+	Estado     string `json:"estado"`
+}
+
+// === GESTIÓN DE PROCESOS EN MEMORIA ===
+
+// SolicitudInicializarProceso pide a Memoria crear las estructuras de un proceso
+type SolicitudInicializarProceso struct {
+	PID     int    `json:"pid"`
+	Tamanio int    `json:"tamanio"`
+	Archivo string `json:"archivo"`
+}
+
+// Validar verifica PID, tamaño y archivo
+func (s SolicitudInicializarProceso) Validar() error {
+	if err := validarPID(s.PID); err != nil {
+		return err
+	}
+	if s.Tamanio < 0 {
+		return fmt.Errorf("tamaño inválido: %d", s.Tamanio)
+	}
+	if s.Archivo == "" {
+		return fmt.Errorf("archivo de pseudocódigo vacío")
+	}
+	return nil
+}
+
+// === OPERACIONES DE MEMORIA ===
+
+// SolicitudInstruccion pide la instrucción PC de un proceso
+type SolicitudInstruccion struct {
+	PID int `json:"pid"`
+	PC  int `json:"pc"`
+}
+
+// RespuestaInstruccion devuelve la instrucción solicitada
+type RespuestaInstruccion struct {
+	Status      string `json:"status"`
+	Instruccion string `json:"instruccion"`
+}
+
+// SolicitudMarco pide el marco de una página
+type SolicitudMarco struct {
+	PID             int   `json:"pid"`
+	Pagina          int   `json:"pagina"`
+	EntradasNiveles []int `json:"entradas_niveles,omitempty"`
+	Niveles         int   `json:"niveles,omitempty"`
+}
+
+// RespuestaMarco devuelve el marco asignado a la página
+type RespuestaMarco struct {
+	Status string `json:"status"`
+	Marco  int    `json:"marco"`
+}
+
+// SolicitudLectura pide leer de memoria a partir de una dirección física o lógica
+type SolicitudLectura struct {
+	PID             int  `json:"pid"`
+	DireccionFisica *int `json:"direccion_fisica,omitempty"`
+	DireccionLogica *int `json:"direccion_logica,omitempty"`
+	Tamanio         int  `json:"tamanio,omitempty"`
+}
+
+// Validar exige alguna de las dos direcciones
+func (s SolicitudLectura) Validar() error {
+	return validarDirecciones(s.DireccionFisica, s.DireccionLogica)
+}
+
+// RespuestaLectura devuelve el valor leído
+type RespuestaLectura struct {
+	Status string `json:"status"`
+	Valor  string `json:"valor"`
+}
+
+// SolicitudEscritura pide escribir un valor a partir de una dirección física o lógica
+type SolicitudEscritura struct {
+	PID             int    `json:"pid"`
+	DireccionFisica *int   `json:"direccion_fisica,omitempty"`
+	DireccionLogica *int   `json:"direccion_logica,omitempty"`
+	Valor           string `json:"valor"`
+}
+
+// Validar exige alguna de las dos direcciones
+func (s SolicitudEscritura) Validar() error {
+	return validarDirecciones(s.DireccionFisica, s.DireccionLogica)
+}
+
+// RespuestaEspacioLibre informa el espacio libre de Memoria en bytes
+type RespuestaEspacioLibre struct {
+	Status       string `json:"status"`
+	EspacioLibre int    `json:"espacio_libre"`
+}
+
+// === EJECUCIÓN DE CPU ===
+
+// SolicitudEjecucion envía un proceso a la CPU para ejecutar la instrucción PC
+type SolicitudEjecucion struct {
+	PID int `json:"pid"`
+	PC  int `json:"pc"`
+}
+
+// ParametrosSyscall son los parámetros de la syscall que devolvió la CPU
+type ParametrosSyscall struct {
+	Dispositivo string `json:"dispositivo,omitempty"`
+	Tiempo      int    `json:"tiempo,omitempty"`
+	Archivo     string `json:"archivo,omitempty"`
+	Tamano      int    `json:"tamano,omitempty"`
+	Prioridad   int    `json:"prioridad,omitempty"`
+}
+
+// RespuestaEjecucion es el resultado de ejecutar una instrucción
+type RespuestaEjecucion struct {
+	PID           int                `json:"pid"`
+	PC            int                `json:"pc"`
+	MotivoRetorno string             `json:"motivo_retorno,omitempty"`
+	Parametros    *ParametrosSyscall `json:"parametros,omitempty"`
+}
+
+// SolicitudInterrupcion pide a la CPU desalojar un proceso
+type SolicitudInterrupcion struct {
+	PID int `json:"pid"`
+}
+
+// === ENTRADA/SALIDA ===
+
+// SolicitudIO pide a un dispositivo IO bloquear un proceso durante Tiempo ms
+type SolicitudIO struct {
+	PID    int `json:"pid"`
+	Tiempo int `json:"tiempo"`
+}
+
+// Validar verifica PID y tiempo
+func (s SolicitudIO) Validar() error {
+	if err := validarPID(s.PID); err != nil {
+		return err
+	}
+	if s.Tiempo < 0 {
+		return fmt.Errorf("tiempo de IO inválido: %d", s.Tiempo)
+	}
+	return nil
+}
+
+// NotificacionKernel es un evento informado al Kernel por otro módulo
+type NotificacionKernel struct {
+	Evento        string `json:"evento"`
+	PID           int    `json:"pid"`
+	MotivoRetorno string `json:"motivo_retorno,omitempty"`
+	Motivo        string `json:"motivo,omitempty"`
+	Dispositivo   string `json:"dispositivo,omitempty"`
+	Tiempo        int    `json:"tiempo,omitempty"`
+	Timestamp     int64  `json:"timestamp,omitempty"`
+}
+
+// Validar verifica que el evento sea conocido
+func (n NotificacionKernel) Validar() error {
+	switch n.Evento {
+	case EventoIOTerminada, EventoIOInterrumpida, EventoSolicitudIO, EventoProcesoTerminado, EventoRetornoCPU:
+		return validarPID(n.PID)
+	default:
+		return fmt.Errorf("evento desconocido: %q", n.Evento)
+	}
+}
+
+// === CONSULTAS ===
+
+// ResumenProceso describe un proceso del Kernel
+type ResumenProceso struct {
+	PID           int    `json:"pid"`
+	Estado        string `json:"estado"`
+	Archivo       string `json:"archivo"`
+	Tamanio       int    `json:"tamanio"`
+	PC            int    `json:"pc"`
+	Prioridad     int    `json:"prioridad"`
+	CPU           string `json:"cpu,omitempty"`
+	MotivoBloqueo string `json:"motivo_bloqueo,omitempty"`
+}
+
+// RespuestaProcesos lista los procesos del Kernel ordenados por PID
+type RespuestaProcesos struct {
+	Procesos []ResumenProceso `json:"procesos"`
+}
+
+// DetalleProceso agrega al resumen los datos de planificación del proceso
+type DetalleProceso struct {
+	ResumenProceso
+	EstimacionRafagaMs float64   `json:"estimacion_rafaga_ms"`
+	UltimaRafagaMs     float64   `json:"ultima_rafaga_ms"`
+	Ejecuciones        int       `json:"ejecuciones"`
+	TiempoEjecucionMs  float64   `json:"tiempo_ejecucion_ms"`
+	VecesReady         int       `json:"veces_ready"`
+	TiempoReadyMs      float64   `json:"tiempo_ready_ms"`
+	EnSwap             bool      `json:"en_swap"`
+	Creado             time.Time `json:"creado"`
+}
+
+// SolicitudListarProcesos pide los procesos del Kernel; con Finalizados incluye los que ya terminaron
+type SolicitudListarProcesos struct {
+	Finalizados bool `json:"finalizados,omitempty"`
+}
+
+// MetricasProceso son las veces y los segundos que un proceso pasó en cada estado
+type MetricasProceso struct {
+	VecesNew        int     `json:"veces_new"`
+	SegundosNew     float64 `json:"segundos_new"`
+	VecesReady      int     `json:"veces_ready"`
+	SegundosReady   float64 `json:"segundos_ready"`
+	VecesExec       int     `json:"veces_exec"`
+	SegundosExec    float64 `json:"segundos_exec"`
+	VecesBlocked    int     `json:"veces_blocked"`
+	SegundosBlocked float64 `json:"segundos_blocked"`
+}
+
+// RespuestaMetricasProceso son las métricas de estado de un proceso, hasta ahora si no finalizó
+type RespuestaMetricasProceso struct {
+	PID    int    `json:"pid"`
+	Estado string `json:"estado"`
+	MetricasProceso
+}
+
+// RespuestaColas lista los PIDs de cada cola del Kernel en el orden en que están encolados
+type RespuestaColas struct {
+	Colas map[string][]int `json:"colas"`
+	// Exec indica el PID que ejecuta cada CPU
+	Exec                   map[string]int `json:"exec"`
+	Multiprogramacion      int            `json:"multiprogramacion"`
+	GradoMultiprogramacion int            `json:"grado_multiprogramacion"`
+}
+
+// EstadoMarco describe un marco de memoria principal; PID es -1 si está libre
+type EstadoMarco struct {
+	Marco int  `json:"marco"`
+	Libre bool `json:"libre"`
+	PID   int  `json:"pid"`
+}
+
+// RespuestaMarcos describe la ocupación de memoria principal
+type RespuestaMarcos struct {
+	Total     int           `json:"total"`
+	Libres    int           `json:"libres"`
+	TamPagina int           `json:"tam_pagina"`
+	Marcos    []EstadoMarco `json:"marcos"`
+}
+
+// EntradaTLB es una traducción de página a marco guardada en la TLB
+type EntradaTLB struct {
+	PID    int `json:"pid"`
+	Pagina int `json:"pagina"`
+	Marco  int `json:"marco"`
+}
+
+// RespuestaTLB describe el contenido de la TLB de una CPU
+type RespuestaTLB struct {
+	CPU       string       `json:"cpu"`
+	Capacidad int          `json:"capacidad"`
+	Algoritmo string       `json:"algoritmo"`
+	Entradas  []EntradaTLB `json:"entradas"`
+}
+
+func validarPID(pid int) error {
+	if pid < 0 {
+		return fmt.Errorf("PID inválido: %d", pid)
+	}
+	return nil
+}
+
+func validarDirecciones(fisica *int, logica *int) error {
+	if fisica == nil && logica == nil {
+		return fmt.Errorf("falta direccion_fisica o direccion_logica")
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tipos de métrica del formato de texto de Prometheus
+const (
+	MetricaContador = "counter"
+	MetricaMedidor  = "gauge"
+)
+
+// Muestra es un valor de un medidor junto con sus etiquetas
+type Muestra struct {
+	Etiquetas map[string]string
+	Valor     float64
+}
+
+// FuncionMuestras calcula los valores de un medidor en el momento de la consulta
+type FuncionMuestras func() []Muestra
+
+// familiaMetrica agrupa los valores de una métrica con distintas etiquetas
+type familiaMetrica struct {
+	nombre    string
+	ayuda     string
+	tipo      string
+	etiquetas []string
+	valores   map[string]*valorMetrica // clave: valores de las etiquetas unidos
+	calcular  FuncionMuestras          // solo medidores
+}
+
+type valorMetrica struct {
+	etiquetas []string
+	valor     float64
+}
+
+// registroMetricas son las métricas del proceso que exponen sus servidores en /metrics
+var registroMetricas = struct {
+	mutex    sync.Mutex
+	familias map[string]*familiaMetrica
+}{familias: make(map[string]*familiaMetrica)}
+
+// Mensajes atendidos por el servidor, por tipo, operación y resultado
+var mensajesAtendidos = NuevoContador("so_mensajes_atendidos_total", "Mensajes atendidos por el servidor.", "tipo", "operacion", "resultado")
+
+// Contador es una métrica que solo aumenta, con un valor por combinación de etiquetas
+type Contador struct {
+	familia *familiaMetrica
+}
+
+// NuevoContador registra un contador con las etiquetas indicadas. Registrar dos veces
+// el mismo nombre devuelve el contador existente.
+func NuevoContador(nombre, ayuda string, etiquetas ...string) *Contador {
+	contador := &Contador{familia: registrarFamilia(nombre, ayuda, MetricaContador, etiquetas, nil)}
+	if len(etiquetas) == 0 {
+		// Sin etiquetas el contador se expone en 0 desde el principio
+		contador.Sumar(0)
+	}
+	return contador
+}
+
+// Incrementar suma uno al valor de las etiquetas indicadas, en el orden en que se declararon
+func (c *Contador) Incrementar(valores ...string) {
+	c.Sumar(1, valores...)
+}
+
+// Sumar agrega delta al valor de las etiquetas indicadas
+func (c *Contador) Sumar(delta float64, valores ...string) {
+	if len(valores) != len(c.familia.etiquetas) {
+		ErrorLog.Error("Cantidad de etiquetas incorrecta", "metrica", c.familia.nombre, "esperadas", c.familia.etiquetas, "recibidas", valores)
+		return
+	}
+	clave := strings.Join(valores, "\xff")
+
+	registroMetricas.mutex.Lock()
+	defer registroMetricas.mutex.Unlock()
+	valor, existe := c.familia.valores[clave]
+	if !existe {
+		valor = &valorMetrica{etiquetas: slices.Clone(valores)}
+		c.familia.valores[clave] = valor
+	}
+	valor.valor += delta
+}
+
+// NuevoMedidor registra un medidor cuyos valores se calculan en cada consulta a /metrics
+func NuevoMedidor(nombre, ayuda string, calcular FuncionMuestras) {
+	registrarFamilia(nombre, ayuda, MetricaMedidor, nil, calcular)
+}
+
+// registrarFamilia agrega la métrica al registro o devuelve la que ya tenía ese nombre
+func registrarFamilia(nombre, ayuda, tipo string, etiquetas []string, calcular FuncionMuestras) *familiaMetrica {
+	registroMetricas.mutex.Lock()
+	defer registroMetricas.mutex.Unlock()
+
+	if familia, existe := registroMetricas.familias[nombre]; existe {
+		if familia.tipo != tipo {
+			ErrorLog.Error("Métrica registrada con otro tipo", "metrica", nombre, "tipo", familia.tipo, "nuevo_tipo", tipo)
+		}
+		if calcular != nil {
+			familia.calcular = calcular
+		}
+		return familia
+	}
+
+	familia := &familiaMetrica{
+		nombre:    nombre,
+		ayuda:     ayuda,
+		tipo:      tipo,
+		etiquetas: etiquetas,
+		valores:   make(map[string]*valorMetrica),
+		calcular:  calcular,
+	}
+	registroMetricas.familias[nombre] = familia
+	return familia
+}
+
+// EscribirMetricas escribe todas las métricas registradas en el formato de texto de Prometheus
+func EscribirMetricas(w io.Writer) {
+	registroMetricas.mutex.Lock()
+	familias := make([]*familiaMetrica, 0, len(registroMetricas.familias))
+	for _, familia := range registroMetricas.familias {
+		familias = append(familias, familia)
+	}
+	registroMetricas.mutex.Unlock()
+
+	slices.SortFunc(familias, func(a, b *familiaMetrica) int { return strings.Compare(a.nombre, b.nombre) })
+
+	for _, familia := range familias {
+		// Los medidores se calculan fuera del registro: suelen tomar los mutex del módulo
+		var muestras []Muestra
+		if familia.calcular != nil {
+			muestras = familia.calcular()
+		} else {
+			muestras = familia.muestras()
+		}
+		escribirFamilia(w, familia.nombre, familia.ayuda, familia.tipo, muestras)
+	}
+}
+
+// muestras copia los valores de un contador ordenados por etiquetas
+func (f *familiaMetrica) muestras() []Muestra {
+	registroMetricas.mutex.Lock()
+	defer registroMetricas.mutex.Unlock()
+
+	claves := make([]string, 0, len(f.valores))
+	for clave := range f.valores {
+		claves = append(claves, clave)
+	}
+	slices.Sort(claves)
+
+	muestras := make([]Muestra, 0, len(claves))
+	for _, clave := range claves {
+		valor := f.valores[clave]
+		etiquetas := make(map[string]string, len(f.etiquetas))
+		for i, nombre := range f.etiquetas {
+			etiquetas[nombre] = valor.etiquetas[i]
+		}
+		muestras = append(muestras, Muestra{Etiquetas: etiquetas, Valor: valor.valor})
+	}
+	return muestras
+}
+
+// escribirFamilia escribe la ayuda, el tipo y una línea por muestra
+func escribirFamilia(w io.Writer, nombre, ayuda, tipo string, muestras []Muestra) {
+	fmt.Fprintf(w, "# HELP %s %s\n", nombre, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(ayuda))
+	fmt.Fprintf(w, "# TYPE %s %s\n", nombre, tipo)
+	for _, muestra := range muestras {
+		fmt.Fprintf(w, "%s%s %s\n", nombre, formatearEtiquetas(muestra.Etiquetas), strconv.FormatFloat(muestra.Valor, 'g', -1, 64))
+	}
+}
+
+// formatearEtiquetas arma {a="1",b="2"} con las etiquetas ordenadas y escapadas
+func formatearEtiquetas(etiquetas map[string]string) string {
+	if len(etiquetas) == 0 {
+		return ""
+	}
+	nombres := make([]string, 0, len(etiquetas))
+	for nombre := range etiquetas {
+		nombres = append(nombres, nombre)
+	}
+	slices.Sort(nombres)
+
+	escapar := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pares := make([]string, 0, len(nombres))
+	for _, nombre := range nombres {
+		pares = append(pares, fmt.Sprintf(`%s="%s"`, nombre, escapar.Replace(etiquetas[nombre])))
+	}
+	return "{" + strings.Join(pares, ",") + "}"
+}
+
+// contarMensajeAtendido suma el mensaje a so_mensajes_atendidos_total con su resultado:
+// "ok" o el código del error
+func contarMensajeAtendido(mensaje *Mensaje, err error) {
+	resultado := "ok"
+	if err != nil {
+		resultado = string(CodigoDe(err))
+	}
+	mensajesAtendidos.Incrementar(strconv.Itoa(mensaje.Tipo), mensaje.Operacion, resultado)
+}
+
+// atenderMetricas responde /metrics con el estado del servidor y las métricas del módulo
+func (s *HTTPServer) atenderMetricas(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	modulo := map[string]string{"modulo": s.Nombre}
+	listo := 0.0
+	if s.EstaListo() && !s.Deteniendo() {
+		listo = 1
+	}
+	escribirFamilia(w, "so_listo", "1 si el módulo está listo para atender mensajes.", MetricaMedidor,
+		[]Muestra{{Etiquetas: modulo, Valor: listo}})
+	escribirFamilia(w, "so_tiempo_activo_segundos", "Tiempo desde que se creó el servidor.", MetricaMedidor,
+		[]Muestra{{Etiquetas: modulo, Valor: time.Since(s.salud.inicio).Seconds()}})
+	escribirFamilia(w, "so_mensajes_en_curso", "Mensajes que el servidor está atendiendo.", MetricaMedidor,
+		[]Muestra{{Etiquetas: modulo, Valor: float64(s.salud.enCurso.Load())}})
+
+	EscribirMetricas(w)
+}
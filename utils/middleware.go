@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Middleware envuelve a un handler para agregarle comportamiento común
+// (recuperación de pánicos, tiempos, auditoría, autorización, retardos)
+type Middleware func(siguiente HTTPHandlerFunc) HTTPHandlerFunc
+
+// Usar agrega middlewares a la cadena que atraviesa todo mensaje recibido por el módulo.
+// El primero es el más externo. Debe llamarse antes de Iniciar.
+func (m *Modulo) Usar(middlewares ...Middleware) {
+	m.middlewares = append(m.middlewares, middlewares...)
+}
+
+// encadenar aplica la cadena de middlewares del módulo al handler
+func (m *Modulo) encadenar(handler HTTPHandlerFunc) HTTPHandlerFunc {
+	for i := len(m.middlewares) - 1; i >= 0; i-- {
+		handler = m.middlewares[i](handler)
+	}
+	return handler
+}
+
+// Recuperacion convierte un pánico dentro del handler en un ErrorInterno, dejando
+// en el log el mensaje que lo provocó y el stack
+func Recuperacion() Middleware {
+	return func(siguiente HTTPHandlerFunc) HTTPHandlerFunc {
+		return func(msg *Mensaje) (respuesta interface{}, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					ErrorLog.Error("Pánico atendiendo mensaje", "tipo", msg.Tipo, "operacion", msg.Operacion, "origen", msg.Origen, "panico", r, "stack", string(debug.Stack()))
+					respuesta = nil
+					err = NuevoError(ErrorInterno, "pánico atendiendo %s: %v", nombreOperacion(msg), r)
+				}
+			}()
+			return siguiente(msg)
+		}
+	}
+}
+
+// Temporizacion mide cuánto tarda cada mensaje y avisa los que superan umbral.
+// Con umbral 0 solo se registra en DEBUG.
+func Temporizacion(umbral time.Duration) Middleware {
+	return func(siguiente HTTPHandlerFunc) HTTPHandlerFunc {
+		return func(msg *Mensaje) (interface{}, error) {
+			inicio := time.Now()
+			respuesta, err := siguiente(msg)
+			duracion := time.Since(inicio)
+
+			if umbral > 0 && duracion > umbral {
+				InfoLog.Warn("Mensaje lento", "operacion", nombreOperacion(msg), "origen", msg.Origen, "duracion", duracion, "umbral", umbral)
+			} else {
+				InfoLog.Debug("Mensaje atendido", "operacion", nombreOperacion(msg), "origen", msg.Origen, "duracion", duracion)
+			}
+			return respuesta, err
+		}
+	}
+}
+
+// Auditoria deja una línea por mensaje con quién lo envió, qué pidió y cómo terminó
+func Auditoria() Middleware {
+	return func(siguiente HTTPHandlerFunc) HTTPHandlerFunc {
+		return func(msg *Mensaje) (interface{}, error) {
+			respuesta, err := siguiente(msg)
+
+			if err != nil {
+				InfoLog.Info("Auditoría", "auditoria", true, "origen", msg.Origen, "tipo", msg.Tipo, "operacion", msg.Operacion, "resultado", "error", "codigo", CodigoDe(err))
+			} else {
+				InfoLog.Info("Auditoría", "auditoria", true, "origen", msg.Origen, "tipo", msg.Tipo, "operacion", msg.Operacion, "resultado", "ok")
+			}
+			return respuesta, err
+		}
+	}
+}
+
+// Permisos indica qué roles pueden enviar cada tipo de mensaje. Los tipos que no
+// figuran los puede enviar cualquiera.
+type Permisos map[int][]string
+
+// RolOrigen devuelve el módulo que envió el mensaje ("CPU" para "CPU->Memoria")
+func RolOrigen(msg *Mensaje) string {
+	rol, _, _ := strings.Cut(msg.Origen, "->")
+	return rol
+}
+
+// Autorizacion rechaza con ErrorNoAutorizado los mensajes que el rol de origen no
+// tiene permitido enviar
+func Autorizacion(permisos Permisos) Middleware {
+	return func(siguiente HTTPHandlerFunc) HTTPHandlerFunc {
+		return func(msg *Mensaje) (interface{}, error) {
+			roles, restringido := permisos[msg.Tipo]
+			if restringido && !slices.Contains(roles, RolOrigen(msg)) {
+				ErrorLog.Warn("Mensaje no autorizado", "origen", msg.Origen, "tipo", msg.Tipo, "operacion", msg.Operacion, "roles_permitidos", roles)
+				return nil, NuevoError(ErrorNoAutorizado, "%s no puede enviar %s", RolOrigen(msg), nombreOperacion(msg))
+			}
+			return siguiente(msg)
+		}
+	}
+}
+
+// SinPlazo atiende el mensaje sin el plazo del emisor: al vencer, el emisor deja de
+// esperar la respuesta pero el trabajo sigue. Una cancelación anterior al plazo sí lo
+// corta. Debe ir antes de Retardo para que el retardo tampoco dependa del plazo.
+func SinPlazo() Middleware {
+	return func(siguiente HTTPHandlerFunc) HTTPHandlerFunc {
+		return func(msg *Mensaje) (interface{}, error) {
+			ctx, cancelar := sinPlazo(msg.Context())
+			defer cancelar()
+			msg.ctx = ctx
+			return siguiente(msg)
+		}
+	}
+}
+
+// Retardo aplica el retardo configurado para el tipo de mensaje antes de atenderlo:
+// porTipo si el tipo figura, si no porDefecto. Se interrumpe si el mensaje se cancela.
+func Retardo(porDefecto int, porTipo map[int]int) Middleware {
+	return func(siguiente HTTPHandlerFunc) HTTPHandlerFunc {
+		return func(msg *Mensaje) (interface{}, error) {
+			retardo, existe := porTipo[msg.Tipo]
+			if !existe {
+				retardo = porDefecto
+			}
+			if retardo > 0 {
+				if err := AplicarRetardoConContexto(msg.Context(), nombreOperacion(msg), retardo); err != nil {
+					return nil, NuevoError(ErrorCancelado, "%s cancelado durante el retardo: %v", nombreOperacion(msg), err)
+				}
+			}
+			return siguiente(msg)
+		}
+	}
+}
+
+// nombreOperacion identifica al mensaje en los logs y errores de los middlewares
+func nombreOperacion(msg *Mensaje) string {
+	if msg.Operacion == "" || msg.Operacion == "default" {
+		return fmt.Sprintf("mensaje tipo %d", msg.Tipo)
+	}
+	return msg.Operacion
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// desconexionTardia es una solicitud HTTP cuyo cliente se desconecta al vencer el plazo,
+// antes de que el servidor vea el vencimiento: informa el plazo pero termina cancelada
+type desconexionTardia struct {
+	context.Context
+	plazo time.Time
+}
+
+func (c desconexionTardia) Deadline() (time.Time, bool) {
+	return c.plazo, true
+}
+
+func TestSinPlazo(t *testing.T) {
+	casos := []struct {
+		nombre string
+		// plazo del emisor y, si no es cero, cuándo cancela la solicitud
+		plazo    time.Duration
+		cancelar time.Duration
+		// tardia cancela sin que venza el contexto, como una desconexión al vencer
+		tardia   bool
+		retardo  int
+		tiempo   int
+		esperado CodigoError
+	}{
+		{"la operación termina aunque venza el plazo", 20 * time.Millisecond, 0, false, 0, 50, ""},
+		{"el retardo también puede pasarse del plazo", 20 * time.Millisecond, 0, false, 30, 10, ""},
+		{"la desconexión al vencer no la corta", 20 * time.Millisecond, 30 * time.Millisecond, true, 0, 50, ""},
+		{"una cancelación antes del plazo la corta", time.Second, 10 * time.Millisecond, false, 0, 500, ErrorCancelado},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			base, desconectar := context.WithCancel(context.Background())
+			defer desconectar()
+			var ctx context.Context = desconexionTardia{base, time.Now().Add(caso.plazo)}
+			if !caso.tardia {
+				var vencer context.CancelFunc
+				ctx, vencer = context.WithTimeout(base, caso.plazo)
+				defer vencer()
+			}
+			if caso.cancelar > 0 {
+				time.AfterFunc(caso.cancelar, desconectar)
+			}
+
+			handler := func(msg *Mensaje) (interface{}, error) {
+				return nil, AplicarRetardoConContexto(msg.Context(), "io_operacion", caso.tiempo)
+			}
+			cadena := SinPlazo()(Retardo(caso.retardo, nil)(handler))
+
+			_, err := cadena(&Mensaje{Tipo: MensajeOperacion, Origen: "Kernel", ctx: ctx})
+			if CodigoDe(err) != caso.esperado {
+				t.Fatalf("error %v, se esperaba %q", err, caso.esperado)
+			}
+		})
+	}
+}
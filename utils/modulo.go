@@ -1,12 +1,11 @@
 package utils
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"log/slog"
-	"os"
-	"path/filepath"
 	"strconv"
+	"sync"
+	"time"
 )
 
 // Modulo representa un módulo genérico del sistema
@@ -16,15 +15,34 @@ type Modulo struct {
 	Clientes    map[string]*HTTPClient
 	ConfigPath  string
 	HandlerFunc map[string]map[string]HTTPHandlerFunc
+
+	idempotencia *CacheIdempotencia
+	carga        FuncionCarga
+	middlewares  []Middleware
+	rutas        []rutaREST
+	eventos      *BusEventos
+
+	// Ciclo de vida (ver ciclo_vida.go)
+	ctx              context.Context
+	cancelar         context.CancelFunc
+	errServidor      chan error
+	ganchosDetener   []ganchoRegistrado
+	ganchosFinalizar []ganchoRegistrado
+	detenerUnaVez    sync.Once
 }
 
 // NuevoModulo crea una nueva instancia de un módulo
 func NuevoModulo(nombre string, configPath string) *Modulo {
+	ctx, cancelar := context.WithCancel(context.Background())
 	return &Modulo{
 		Nombre:      nombre,
 		Clientes:    make(map[string]*HTTPClient),
 		ConfigPath:  configPath,
 		HandlerFunc: make(map[string]map[string]HTTPHandlerFunc),
+		eventos:     NuevoBusEventos(nombre),
+		ctx:         ctx,
+		cancelar:    cancelar,
+		errServidor: make(chan error, 1),
 	}
 }
 
@@ -36,11 +54,48 @@ func (m *Modulo) RegistrarHandler(tipo string, operacion string, handler HTTPHan
 	m.HandlerFunc[tipo][operacion] = handler
 }
 
-// IniciarServidor crea e inicializa el servidor HTTP del módulo
-func (m *Modulo) IniciarServidor(ip string, puerto int) {
-	m.Server = NewHTTPServer(ip, puerto, m.Nombre)
+// HabilitarIdempotencia hace que el servidor aplique una sola vez cada ClaveIdempotencia
+// recibida durante ttl. Debe llamarse antes de Iniciar.
+func (m *Modulo) HabilitarIdempotencia(ttl time.Duration) {
+	m.idempotencia = NuevaCacheIdempotencia(ttl)
+}
+
+// ReportarCarga define la carga que el módulo informa en /health y en sus latidos
+func (m *Modulo) ReportarCarga(carga FuncionCarga) {
+	m.carga = carga
+	if m.Server != nil {
+		m.Server.ReportarCarga(carga)
+	}
+}
+
+// Carga devuelve la carga propia del módulo junto con los mensajes que está atendiendo
+func (m *Modulo) Carga() map[string]int {
+	carga := make(map[string]int)
+	if m.carga != nil {
+		for clave, valor := range m.carga() {
+			carga[clave] = valor
+		}
+	}
+	if m.Server != nil {
+		carga["en_curso"] = int(m.Server.salud.enCurso.Load())
+	}
+	return carga
+}
+
+// MarcarListo indica en /health que el módulo terminó de inicializarse.
+// Debe llamarse después de Iniciar.
+func (m *Modulo) MarcarListo() {
+	if m.Server == nil {
+		slog.Error("MarcarListo antes de iniciar el servidor", "módulo", m.Nombre)
+		return
+	}
+	m.Server.MarcarListo()
+	slog.Info("Módulo listo", "módulo", m.Nombre)
+}
 
-	// Registrar handlers para el servidor HTTP
+// registrarHandlersEnServidor enruta cada tipo de mensaje del servidor a los handlers
+// registrados para sus operaciones
+func (m *Modulo) registrarHandlersEnServidor() {
 	for tipoStr, handlersPorOperacion := range m.HandlerFunc {
 		tipo, err := strconv.Atoi(tipoStr)
 		if err != nil {
@@ -48,7 +103,7 @@ func (m *Modulo) IniciarServidor(ip string, puerto int) {
 			continue
 		}
 
-		m.Server.RegisterHTTPHandler(tipo, func(msg *Mensaje) (interface{}, error) {
+		m.Server.RegisterHTTPHandler(tipo, m.encadenar(func(msg *Mensaje) (interface{}, error) {
 			operacion := msg.Operacion
 			if operacion == "" {
 				operacion = "default"
@@ -59,63 +114,16 @@ func (m *Modulo) IniciarServidor(ip string, puerto int) {
 				handler, existe = handlersPorOperacion["default"]
 				if !existe {
 					slog.Error("No hay handler para operación", "tipo", tipo, "operacion", operacion)
-					return nil, fmt.Errorf("no hay handler para operación %s", operacion)
+					return nil, NuevoError(ErrorOperacionDesconocida, "no hay handler para operación %s", operacion)
 				}
 			}
 
 			return handler(msg)
-		})
+		}))
 	}
-
-	go func() {
-		err := m.Server.Start()
-		if err != nil {
-			slog.Error("Error al iniciar servidor HTTP", "error", err)
-			os.Exit(1)
-		}
-	}()
-
-	slog.Info("Servidor HTTP iniciado", "módulo", m.Nombre, "dirección", fmt.Sprintf("%s:%d", ip, puerto))
 }
 
 
-func CargarConfiguracion[T any](ruta string) *T {
-	slog.Info("Cargando configuración", "ruta", ruta)
-
-	// Crear directorio si no existe
-	dir := filepath.Dir(ruta)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		slog.Error("Error al crear directorio de configuración", "error", err)
-		os.Exit(1)
-	}
-
-	// Obtener ruta absoluta
-	absPath, err := filepath.Abs(ruta)
-	if err != nil {
-		slog.Error("Error obteniendo ruta absoluta", "error", err, "ruta", ruta)
-		os.Exit(1)
-	}
-
-	// Abrir archivo
-	file, err := os.Open(absPath)
-	if err != nil {
-		slog.Error("Error abriendo archivo de configuración", "error", err, "archivo", absPath)
-		os.Exit(1)
-	}
-	defer file.Close()
-
-	// Decodificar JSON directamente al tipo genérico
-	var config T
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&config); err != nil {
-		slog.Error("Error decodificando configuración", "error", err, "archivo", absPath)
-		os.Exit(1)
-	}
-
-	slog.Info("Configuración cargada correctamente")
-	return &config
-}
-
 // ============================================================================
 // Constantes para tipos de mensajes entre módulos
 // ============================================================================
@@ -123,6 +131,8 @@ const (
     // === COMUNICACIÓN BÁSICA (1-9) ===
     MensajeHandshake = 1  // Conexión inicial
     MensajeOperacion = 2  // Operaciones genéricas
+    MensajeLatido    = 3  // Renovación de lease
+    MensajeLote      = 4  // Varios mensajes en una solicitud
     
     // === OPERACIONES DE MEMORIA (10-19) ===
     MensajeLeer         = 10  // Leer datos
@@ -137,9 +147,19 @@ const (
     MensajeFinalizarProceso    = 21  // Terminar proceso
     MensajeSuspenderProceso    = 22  // Suspender proceso
     MensajeDessuspenderProceso = 23  // Reactivar proceso
+    MensajeCrearProceso        = 24  // Crear proceso desde un pseudocódigo (Kernel)
+    MensajePlanificacion       = 25  // Pausar o reanudar los planificadores (Kernel)
     
     // === EJECUCIÓN DE CPU (30-39) ===
     MensajeEjecutar           = 30  // Ejecutar en CPU
     MensajeObtenerInstruccion = 31  // Obtener instrucción
     MensajeInterrupcion       = 32  // Interrumpir CPU
+
+    // === CONSULTAS (40-49) ===
+    MensajeListarProcesos   = 40  // Procesos del Kernel
+    MensajeConsultarProceso = 41  // Detalle de un proceso
+    MensajeListarMarcos     = 42  // Marcos de Memoria
+    MensajeConsultarTLB     = 43  // Entradas de la TLB de una CPU
+    MensajeListarColas      = 44  // Colas de planificación del Kernel
+    MensajeMetricasProceso  = 45  // Métricas de estado de un proceso
 )
\ No newline at end of file
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"log/slog"
 	"time"
 )
@@ -12,6 +13,23 @@ func AplicarRetardo(operacion string, duracionMs int) {
 	slog.Info("Retardo completado", "operación", operacion)
 }
 
+// AplicarRetardoConContexto aplica un retardo que se interrumpe si ctx se cancela o vence
+func AplicarRetardoConContexto(ctx context.Context, operacion string, duracionMs int) error {
+	slog.Info("Aplicando retardo", "operación", operacion, "duración_ms", duracionMs)
+
+	timer := time.NewTimer(time.Duration(duracionMs) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		slog.Info("Retardo completado", "operación", operacion)
+		return nil
+	case <-ctx.Done():
+		slog.Info("Retardo interrumpido", "operación", operacion, "motivo", ctx.Err())
+		return ctx.Err()
+	}
+}
+
 // ExtraerRetardo extrae el retardo de una operación del mensaje
 func ExtraerRetardo(msg *Mensaje, valorPorDefecto int) int {
 	if datosMap, ok := msg.Datos.(map[string]interface{}); ok {
@@ -31,13 +49,3 @@ func ObtenerTipoOperacion(msg *Mensaje, valorPorDefecto string) string {
 	}
 	return valorPorDefecto
 }
-
-// HandlerGenerico es un handler genérico para tratar operaciones con retardo
-func HandlerGenerico(msg *Mensaje, retardoPorDefecto int, procesador func(msg *Mensaje) (interface{}, error)) (interface{}, error) {
-	slog.Info("Operación recibida", "origen", msg.Origen, "tipo", msg.Tipo)
-
-	retardo := ExtraerRetardo(msg, retardoPorDefecto)
-	AplicarRetardo("procesamiento", retardo)
-
-	return procesador(msg)
-}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TimeoutPorDefecto es el plazo de un envío cuando el destino no tiene uno configurado
+const TimeoutPorDefecto = 10 * time.Second
+
+// HeaderPlazo transporta el deadline del emisor para que el servidor lo respete
+const HeaderPlazo = "X-Plazo"
+
+// TimeoutsPorDestino asocia el nombre del módulo destino (MEMORIA, CPU, IO, KERNEL)
+// con su timeout en milisegundos. Se carga desde la clave TIMEOUTS de la configuración.
+type TimeoutsPorDestino map[string]int
+
+// Para devuelve el timeout configurado para el destino o TimeoutPorDefecto
+func (t TimeoutsPorDestino) Para(destino string) time.Duration {
+	if ms, existe := t[strings.ToUpper(destino)]; existe && ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return TimeoutPorDefecto
+}
+
+// contextoDeSolicitud deriva el contexto del handler: se cancela si el cliente
+// se desconecta y vence con el plazo que haya enviado en HeaderPlazo
+func contextoDeSolicitud(r *http.Request) (context.Context, context.CancelFunc) {
+	if valor := r.Header.Get(HeaderPlazo); valor != "" {
+		if plazo, err := time.Parse(time.RFC3339Nano, valor); err == nil {
+			return context.WithDeadline(r.Context(), plazo)
+		}
+	}
+	return context.WithCancel(r.Context())
+}
+
+// sinPlazo deriva de ctx un contexto sin su deadline. Solo se cancela si ctx se cancela
+// antes de vencer: la desconexión que llega con el vencimiento es el emisor que dejó de
+// esperar la respuesta, no un pedido de abortar.
+func sinPlazo(ctx context.Context) (context.Context, context.CancelFunc) {
+	plazo, tienePlazo := ctx.Deadline()
+	derivado, cancelar := context.WithCancel(context.WithoutCancel(ctx))
+	detener := context.AfterFunc(ctx, func() {
+		if errors.Is(ctx.Err(), context.Canceled) && (!tienePlazo || time.Now().Before(plazo)) {
+			cancelar()
+		}
+	})
+	return derivado, func() {
+		detener()
+		cancelar()
+	}
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// VersionProtocolo es la versión del esquema de mensajes entre módulos.
+// Debe incrementarse cada vez que cambie algún tipo de mensajes.go o los campos de
+// Mensaje: la decodificación estricta rechaza los campos que no conoce, y es mejor que
+// un despliegue mezclado falle por versión que por esquema.
+// 2: ClaveIdempotencia en Mensaje.
+// 3: TraceID y SpanPadre en Mensaje.
+// 4: mensajes de latido y baja de leases.
+// 5: Sello y Firma en Mensaje.
+// 6: consultas de procesos, marcos y TLB.
+// 7: mensajes en lote.
+// 8: prioridad de los procesos.
+const VersionProtocolo = 8
+
+// Validable lo implementan los tipos de mensaje con reglas propias además de los campos requeridos
+type Validable interface {
+	Validar() error
+}
+
+// UnmarshalJSON decodifica el mensaje conservando el JSON crudo de Datos
+func (m *Mensaje) UnmarshalJSON(b []byte) error {
+	type mensajeAlias Mensaje
+	var aux struct {
+		mensajeAlias
+		Datos json.RawMessage `json:"datos"`
+	}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	*m = Mensaje(aux.mensajeAlias)
+	m.datosCrudos = aux.Datos
+	m.Datos = nil
+	if len(aux.Datos) > 0 {
+		if err := json.Unmarshal(aux.Datos, &m.Datos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerificarVersion controla que la versión recibida sea la propia. Una versión 0 es un
+// emisor que no la informa y también se rechaza.
+func VerificarVersion(version int) error {
+	if version != VersionProtocolo {
+		return NuevoError(ErrorVersionIncompatible, "versión de protocolo incompatible: recibida %d, esperada %d", version, VersionProtocolo)
+	}
+	return nil
+}
+
+// DecodificarDatos convierte los datos de un mensaje al tipo indicado.
+// Todos los campos JSON sin omitempty son obligatorios y no se aceptan campos desconocidos.
+func DecodificarDatos[T any](msg *Mensaje) (T, error) {
+	var resultado T
+
+	crudo := msg.datosCrudos
+	if len(crudo) == 0 {
+		var err error
+		if crudo, err = json.Marshal(msg.Datos); err != nil {
+			return resultado, NuevoError(ErrorEsquemaInvalido, "error serializando datos del mensaje: %v", err)
+		}
+	}
+
+	if err := decodificarEstricto(crudo, &resultado); err != nil {
+		if errorModulo, ok := err.(*ErrorModulo); ok {
+			return resultado, errorModulo
+		}
+		return resultado, NuevoError(ErrorEsquemaInvalido, "mensaje tipo %d (%s) de %s: %v", msg.Tipo, msg.Operacion, msg.Origen, err)
+	}
+	return resultado, nil
+}
+
+// decodificarEstricto decodifica JSON en destino verificando campos requeridos y desconocidos
+func decodificarEstricto(crudo []byte, destino interface{}) error {
+	valor := reflect.ValueOf(destino).Elem()
+
+	if valor.Kind() == reflect.Struct {
+		if bytes.Equal(bytes.TrimSpace(crudo), []byte("null")) {
+			if faltantes := camposFaltantes(valor.Type(), nil); len(faltantes) > 0 {
+				return fmt.Errorf("datos ausentes, se esperaba %s", valor.Type().Name())
+			}
+			crudo = []byte("{}")
+		}
+
+		var campos map[string]json.RawMessage
+		if err := json.Unmarshal(crudo, &campos); err != nil {
+			return fmt.Errorf("datos con formato inválido para %s: %v", valor.Type().Name(), err)
+		}
+		if faltantes := camposFaltantes(valor.Type(), campos); len(faltantes) > 0 {
+			return fmt.Errorf("faltan campos requeridos en %s: %s", valor.Type().Name(), strings.Join(faltantes, ", "))
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(crudo))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(destino); err != nil {
+		return fmt.Errorf("datos con formato inválido para %s: %v", valor.Type().Name(), err)
+	}
+
+	if v, ok := destino.(Validable); ok {
+		if err := v.Validar(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// camposFaltantes devuelve los campos JSON obligatorios que no están presentes
+func camposFaltantes(t reflect.Type, presentes map[string]json.RawMessage) []string {
+	var faltantes []string
+	for i := 0; i < t.NumField(); i++ {
+		campo := t.Field(i)
+		if !campo.IsExported() {
+			continue
+		}
+
+		tag := campo.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		partes := strings.Split(tag, ",")
+		nombre := partes[0]
+		if nombre == "" {
+			nombre = campo.Name
+		}
+
+		opcional := false
+		for _, opcion := range partes[1:] {
+			if opcion == "omitempty" {
+				opcional = true
+			}
+		}
+		if opcional {
+			continue
+		}
+
+		if _, existe := presentes[nombre]; !existe {
+			faltantes = append(faltantes, nombre)
+		}
+	}
+	return faltantes
+}
+
+// Enviar envía un mensaje tipado con el Timeout del cliente y decodifica la respuesta en Resp
+func Enviar[Req any, Resp any](c *HTTPClient, tipo int, operacion string, req Req) (Resp, error) {
+	return EnviarConContexto[Req, Resp](context.Background(), c, tipo, operacion, req)
+}
+
+// EnviarConContexto envía un mensaje tipado respetando el deadline y la cancelación de ctx
+func EnviarConContexto[Req any, Resp any](ctx context.Context, c *HTTPClient, tipo int, operacion string, req Req) (Resp, error) {
+	var respuesta Resp
+
+	cuerpo, err := c.enviar(ctx, tipo, operacion, req)
+	if err != nil {
+		return respuesta, err
+	}
+
+	if err := json.Unmarshal(cuerpo, &respuesta); err != nil {
+		errorModulo := NuevoError(ErrorEsquemaInvalido, "respuesta de %s con formato inválido para %T: %v", c.BaseURL, respuesta, err)
+		errorModulo.Modulo = c.Nombre
+		return respuesta, errorModulo
+	}
+	return respuesta, nil
+}
+
+// RegistrarHandlerTipado registra un handler que recibe los datos ya decodificados en Req
+func RegistrarHandlerTipado[Req any, Resp any](m *Modulo, tipo int, operacion string, handler func(*Mensaje, Req) (Resp, error)) {
+	m.RegistrarHandler(strconv.Itoa(tipo), operacion, func(msg *Mensaje) (interface{}, error) {
+		req, err := DecodificarDatos[Req](msg)
+		if err != nil {
+			ErrorLog.Error("Mensaje rechazado por esquema inválido", "tipo", msg.Tipo, "operacion", msg.Operacion, "origen", msg.Origen, "error", err)
+			return nil, err
+		}
+		return handler(msg, req)
+	})
+}
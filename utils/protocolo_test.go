@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestVerificarVersion(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		version int
+		acepta  bool
+	}{
+		{"versión propia", VersionProtocolo, true},
+		{"emisor sin versión", 0, false},
+		{"versión anterior", VersionProtocolo - 1, false},
+		{"versión posterior", VersionProtocolo + 1, false},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			if err := VerificarVersion(caso.version); (err == nil) != caso.acepta {
+				t.Fatalf("VerificarVersion(%d) = %v", caso.version, err)
+			}
+		})
+	}
+}
+
+func TestDecodificarDatos(t *testing.T) {
+	casos := []struct {
+		nombre string
+		datos  string
+		// error es un fragmento del error esperado, "" si se acepta
+		error string
+	}{
+		{"completo", `{"pid": 3, "tamanio": 256, "archivo": "proceso1"}`, ""},
+		{"falta un campo requerido", `{"pid": 3, "archivo": "proceso1"}`, "tamanio"},
+		{"campo desconocido", `{"pid": 3, "tamanio": 256, "archivo": "proceso1", "prioridad": 1}`, "prioridad"},
+		{"tipo incorrecto", `{"pid": "3", "tamanio": 256, "archivo": "proceso1"}`, "pid"},
+		{"datos ausentes", `null`, "datos ausentes"},
+		{"no cumple Validar", `{"pid": 3, "tamanio": -1, "archivo": "proceso1"}`, "tamaño inválido"},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			var msg Mensaje
+			crudo := `{"tipo": 5, "operacion": "default", "origen": "Kernel", "version": 1, "datos": ` + caso.datos + `}`
+			if err := json.Unmarshal([]byte(crudo), &msg); err != nil {
+				t.Fatal(err)
+			}
+
+			solicitud, err := DecodificarDatos[SolicitudInicializarProceso](&msg)
+			if caso.error == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if solicitud != (SolicitudInicializarProceso{PID: 3, Tamanio: 256, Archivo: "proceso1"}) {
+					t.Fatalf("se decodificó %+v", solicitud)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), caso.error) {
+				t.Fatalf("error %v, se esperaba uno que mencione %q", err, caso.error)
+			}
+		})
+	}
+}
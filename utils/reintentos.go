@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// PoliticaReintentos define cuántas veces y con qué espera se repite una operación fallida.
+// Solo se reintentan los errores reintentables (ver EsReintentable).
+type PoliticaReintentos struct {
+	MaxIntentos   int           // 0 reintenta indefinidamente
+	EsperaInicial time.Duration // espera antes del segundo intento
+	EsperaMaxima  time.Duration // tope de la espera exponencial
+	Factor        float64       // multiplicador de la espera entre intentos
+	Jitter        float64       // fracción aleatoria (0 a 1) que se suma o resta a la espera
+}
+
+// PoliticaPorDefecto es la política de las operaciones entre módulos
+var PoliticaPorDefecto = PoliticaReintentos{
+	MaxIntentos:   5,
+	EsperaInicial: 200 * time.Millisecond,
+	EsperaMaxima:  5 * time.Second,
+	Factor:        2,
+	Jitter:        0.2,
+}
+
+// PoliticaConexion reintenta sin límite, se usa para los handshakes de arranque
+var PoliticaConexion = PoliticaReintentos{
+	EsperaInicial: 500 * time.Millisecond,
+	EsperaMaxima:  3 * time.Second,
+	Factor:        2,
+	Jitter:        0.2,
+}
+
+// Espera devuelve cuánto esperar después del intento indicado (empezando en 1)
+func (p PoliticaReintentos) Espera(intento int) time.Duration {
+	factor := p.Factor
+	if factor < 1 {
+		factor = 1
+	}
+
+	espera := float64(p.EsperaInicial) * math.Pow(factor, float64(intento-1))
+	if p.EsperaMaxima > 0 && espera > float64(p.EsperaMaxima) {
+		espera = float64(p.EsperaMaxima)
+	}
+	if p.Jitter > 0 {
+		espera += espera * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(espera)
+}
+
+// Ejecutar llama a operacion hasta que tenga éxito, devuelva un error no reintentable,
+// se agoten los intentos o se cancele ctx. Devuelve el último error obtenido.
+func (p PoliticaReintentos) Ejecutar(ctx context.Context, nombre string, operacion func(ctx context.Context) error) error {
+	for intento := 1; ; intento++ {
+		err := operacion(ctx)
+		if err == nil || !EsReintentable(err) {
+			return err
+		}
+
+		if p.MaxIntentos > 0 && intento >= p.MaxIntentos {
+			ErrorLog.Error("Se agotaron los reintentos", "operacion", nombre, "intentos", intento, "error", err)
+			return err
+		}
+
+		espera := p.Espera(intento)
+		InfoLog.Warn("Operación fallida, reintentando",
+			"operacion", nombre,
+			"intento", intento,
+			"codigo", CodigoDe(err),
+			"próximo_en", espera)
+
+		timer := time.NewTimer(espera)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// ConectarConReintentos realiza el handshake con un módulo siguiendo PoliticaConexion.
+// Solo abandona si el destino rechaza el handshake con un error no reintentable.
+func ConectarConReintentos(c *HTTPClient, destino string, datos SolicitudHandshake) (RespuestaHandshake, error) {
+	InfoLog.Info("Iniciando conexión", "destino", destino)
+
+	var respuesta RespuestaHandshake
+	err := PoliticaConexion.Ejecutar(context.Background(), "handshake con "+destino, func(ctx context.Context) error {
+		var err error
+		respuesta, err = EnviarConContexto[SolicitudHandshake, RespuestaHandshake](ctx, c, MensajeHandshake, "handshake", datos)
+		return err
+	})
+	if err != nil {
+		ErrorLog.Error("Handshake rechazado", "destino", destino, "error", err)
+		return respuesta, err
+	}
+
+	InfoLog.Info("Conexión establecida", "destino", destino)
+	return respuesta, nil
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// maxCuerpoREST acota el cuerpo JSON que se acepta en un endpoint REST
+const maxCuerpoREST = 1 << 20
+
+// RolREST es el rol de origen de los mensajes que llegan por los endpoints REST
+const RolREST = "REST"
+
+// ArmarDatos obtiene de la solicitud HTTP (ruta, query) los datos del mensaje
+type ArmarDatos func(r *http.Request) (interface{}, error)
+
+// rutaREST asocia un endpoint REST a un tipo y operación de mensaje
+type rutaREST struct {
+	patron    string
+	tipo      int
+	operacion string
+	armar     ArmarDatos
+}
+
+// RegistrarRuta expone como endpoint REST al handler registrado para tipo y operación.
+// patron sigue la sintaxis de net/http ("GET /procesos/{pid}") y armar arma los datos
+// del mensaje a partir de la solicitud (nil si no lleva). Debe llamarse antes de Iniciar.
+func (m *Modulo) RegistrarRuta(patron string, tipo int, operacion string, armar ArmarDatos) {
+	m.rutas = append(m.rutas, rutaREST{patron: patron, tipo: tipo, operacion: operacion, armar: armar})
+}
+
+// DatosPID arma una SolicitudPID con el parámetro {pid} de la ruta
+func DatosPID(r *http.Request) (interface{}, error) {
+	pid, err := strconv.Atoi(r.PathValue("pid"))
+	if err != nil || pid < 0 {
+		return nil, NuevoError(ErrorEsquemaInvalido, "PID inválido: %q", r.PathValue("pid"))
+	}
+	return SolicitudPID{PID: pid}, nil
+}
+
+// DatosCuerpo usa como datos del mensaje el cuerpo JSON de la solicitud, que el handler
+// decodifica y valida como cualquier otro mensaje
+func DatosCuerpo(r *http.Request) (interface{}, error) {
+	crudo, err := io.ReadAll(io.LimitReader(r.Body, maxCuerpoREST+1))
+	if err != nil {
+		return nil, NuevoError(ErrorEsquemaInvalido, "no se pudo leer el cuerpo: %v", err)
+	}
+	if len(crudo) > maxCuerpoREST {
+		return nil, NuevoError(ErrorEsquemaInvalido, "el cuerpo supera los %d bytes", maxCuerpoREST)
+	}
+	if !json.Valid(crudo) {
+		return nil, NuevoError(ErrorEsquemaInvalido, "el cuerpo no es JSON válido")
+	}
+	return json.RawMessage(crudo), nil
+}
+
+// atenderRuta convierte la solicitud REST en un mensaje y lo atiende como cualquier otro:
+// pasa por la cadena de middlewares del módulo y responde JSON con el estado HTTP del error
+func (s *HTTPServer) atenderRuta(ruta rutaREST) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := verificarTokenAdmin(r); err != nil {
+			ErrorLog.Warn("Solicitud REST rechazada por autenticación", "ruta", ruta.patron, "remoto", r.RemoteAddr, "error", err)
+			s.escribirError(w, err)
+			return
+		}
+
+		var datos interface{}
+		if ruta.armar != nil {
+			var err error
+			if datos, err = ruta.armar(r); err != nil {
+				s.escribirError(w, err)
+				return
+			}
+		}
+		crudo, err := json.Marshal(datos)
+		if err != nil {
+			s.escribirError(w, NuevoError(ErrorInterno, "error serializando datos de %s: %v", ruta.patron, err))
+			return
+		}
+
+		mensaje := &Mensaje{
+			Tipo:        ruta.tipo,
+			Operacion:   ruta.operacion,
+			Origen:      RolREST + "->" + s.Nombre,
+			Version:     VersionProtocolo,
+			Datos:       datos,
+			datosCrudos: crudo,
+			local:       true,
+		}
+
+		ctx, cancelar := contextoDeSolicitud(r)
+		defer cancelar()
+
+		respuesta, err := s.procesarMensaje(ctx, mensaje)
+		if err != nil {
+			s.escribirError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(respuesta)
+	}
+}
@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// archivosRetenidosPorDefecto es la cantidad de archivos rotados que se conservan si no se configura
+const archivosRetenidosPorDefecto = 5
+
+// formatoRotacion es la fecha que se agrega al nombre de un archivo rotado. Si ya existe
+// uno con la misma fecha se le agrega un contador: <base>-<fecha>-<n><extensión>.
+const formatoRotacion = "20060102-150405.000"
+
+// esperaTrasFalloRotacion es cuánto se sigue escribiendo en el archivo activo antes de
+// volver a intentar una rotación que falló
+const esperaTrasFalloRotacion = time.Minute
+
+// ArchivoRotativo es un io.Writer que renombra el archivo al superar un tamaño
+// o una antigüedad y conserva solo los últimos archivos rotados.
+type ArchivoRotativo struct {
+	ruta          string
+	maxBytes      int64
+	maxAntiguedad time.Duration
+	maxArchivos   int
+
+	mutex    sync.Mutex
+	archivo  *os.File
+	tamanio  int64
+	apertura time.Time
+	// reintentarRotacion es cuándo se puede volver a rotar después de un fallo
+	reintentarRotacion time.Time
+}
+
+// NuevoArchivoRotativo abre ruta para agregar líneas. maxMB y maxHoras en 0
+// desactivan la rotación por tamaño y por antigüedad respectivamente.
+func NuevoArchivoRotativo(ruta string, maxMB int, maxHoras int, maxArchivos int) (*ArchivoRotativo, error) {
+	if maxArchivos <= 0 {
+		maxArchivos = archivosRetenidosPorDefecto
+	}
+
+	a := &ArchivoRotativo{
+		ruta:          ruta,
+		maxBytes:      int64(maxMB) * 1024 * 1024,
+		maxAntiguedad: time.Duration(maxHoras) * time.Hour,
+		maxArchivos:   maxArchivos,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ruta), 0755); err != nil {
+		return nil, err
+	}
+	if err := a.abrir(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Write escribe p rotando antes el archivo si corresponde
+func (a *ArchivoRotativo) Write(p []byte) (int, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.archivo == nil {
+		return 0, os.ErrClosed
+	}
+
+	if a.debeRotar(len(p)) {
+		if err := a.rotar(); err != nil {
+			fmt.Fprintf(os.Stderr, "No se pudo rotar %s: %v\n", a.ruta, err)
+			a.reintentarRotacion = time.Now().Add(esperaTrasFalloRotacion)
+			if a.archivo == nil {
+				return 0, err
+			}
+		}
+	}
+
+	n, err := a.archivo.Write(p)
+	a.tamanio += int64(n)
+	return n, err
+}
+
+// Close cierra el archivo actual
+func (a *ArchivoRotativo) Close() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.archivo == nil {
+		return nil
+	}
+	err := a.archivo.Close()
+	a.archivo = nil
+	return err
+}
+
+// abrir abre (o crea) el archivo activo y toma su tamaño y fecha actuales
+func (a *ArchivoRotativo) abrir() error {
+	archivo, err := os.OpenFile(a.ruta, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := archivo.Stat()
+	if err != nil {
+		archivo.Close()
+		return err
+	}
+
+	a.archivo = archivo
+	a.tamanio = info.Size()
+	a.apertura = time.Now()
+	if info.Size() > 0 {
+		a.apertura = info.ModTime()
+	}
+	return nil
+}
+
+func (a *ArchivoRotativo) debeRotar(bytesNuevos int) bool {
+	if a.tamanio == 0 || time.Now().Before(a.reintentarRotacion) {
+		return false
+	}
+	if a.maxBytes > 0 && a.tamanio+int64(bytesNuevos) > a.maxBytes {
+		return true
+	}
+	return a.maxAntiguedad > 0 && time.Since(a.apertura) > a.maxAntiguedad
+}
+
+// rotar renombra el archivo activo agregándole la fecha, abre uno nuevo
+// y borra los rotados que exceden maxArchivos. Si algo falla se vuelve a abrir
+// el archivo activo para no perder los logs que siguen.
+func (a *ArchivoRotativo) rotar() error {
+	errCierre := a.archivo.Close()
+	a.archivo = nil
+
+	extension := filepath.Ext(a.ruta)
+	base := strings.TrimSuffix(a.ruta, extension)
+	var errRenombrar error
+	if errCierre == nil {
+		errRenombrar = os.Rename(a.ruta, nombreRotado(base, extension, time.Now()))
+	}
+
+	if err := a.abrir(); err != nil {
+		return errors.Join(errCierre, errRenombrar, err)
+	}
+	if errCierre != nil || errRenombrar != nil {
+		return errors.Join(errCierre, errRenombrar)
+	}
+
+	a.limpiarRotados(base, extension)
+	return nil
+}
+
+// nombreRotado arma el nombre del archivo rotado. Dos rotaciones en el mismo milisegundo
+// no se pisan: la segunda lleva un contador.
+func nombreRotado(base string, extension string, fecha time.Time) string {
+	sello := fecha.Format(formatoRotacion)
+	rotado := fmt.Sprintf("%s-%s%s", base, sello, extension)
+	for n := 1; ; n++ {
+		if _, err := os.Stat(rotado); errors.Is(err, os.ErrNotExist) {
+			return rotado
+		}
+		rotado = fmt.Sprintf("%s-%s-%d%s", base, sello, n, extension)
+	}
+}
+
+// ordenRotado devuelve la fecha y el contador de un archivo rotado, o false si el nombre
+// no corresponde a una rotación
+func ordenRotado(ruta string, base string, extension string) (time.Time, int, bool) {
+	sello := strings.TrimSuffix(strings.TrimPrefix(ruta, base+"-"), extension)
+	if fecha, err := time.Parse(formatoRotacion, sello); err == nil {
+		return fecha, 0, true
+	}
+	corte := strings.LastIndex(sello, "-")
+	if corte < 0 {
+		return time.Time{}, 0, false
+	}
+	fecha, err := time.Parse(formatoRotacion, sello[:corte])
+	contador, errContador := strconv.Atoi(sello[corte+1:])
+	if err != nil || errContador != nil || contador <= 0 {
+		return time.Time{}, 0, false
+	}
+	return fecha, contador, true
+}
+
+// limpiarRotados borra los archivos rotados más viejos, según la fecha y el contador
+// de su nombre
+func (a *ArchivoRotativo) limpiarRotados(base string, extension string) {
+	candidatos, err := filepath.Glob(base + "-*" + extension)
+	if err != nil {
+		return
+	}
+
+	// Solo cuentan los que tienen la fecha de rotación, no otros logs con el mismo prefijo
+	type rotado struct {
+		ruta     string
+		fecha    time.Time
+		contador int
+	}
+	var rotados []rotado
+	for _, ruta := range candidatos {
+		if fecha, contador, ok := ordenRotado(ruta, base, extension); ok {
+			rotados = append(rotados, rotado{ruta, fecha, contador})
+		}
+	}
+	if len(rotados) <= a.maxArchivos {
+		return
+	}
+
+	sort.Slice(rotados, func(i, j int) bool {
+		if !rotados[i].fecha.Equal(rotados[j].fecha) {
+			return rotados[i].fecha.Before(rotados[j].fecha)
+		}
+		return rotados[i].contador < rotados[j].contador
+	})
+	for _, r := range rotados[:len(rotados)-a.maxArchivos] {
+		os.Remove(r.ruta)
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Estados informados por /health
+const (
+	SaludOK         = "ok"
+	SaludIniciando  = "iniciando"
+	SaludDeteniendo = "deteniendo"
+)
+
+// EstadoSalud es la respuesta de /health
+type EstadoSalud struct {
+	Status   string         `json:"status"`
+	Module   string         `json:"module"`
+	Listo    bool           `json:"listo"`
+	UptimeMs int64          `json:"uptime_ms"`
+	EnCurso  int64          `json:"en_curso"`
+	Carga    map[string]int `json:"carga,omitempty"`
+}
+
+// FuncionCarga informa la carga propia del módulo (procesos en cola, marcos libres, etc.)
+type FuncionCarga func() map[string]int
+
+// saludServidor lleva el estado que el servidor expone en /health
+type saludServidor struct {
+	inicio     time.Time
+	listo      atomic.Bool
+	deteniendo atomic.Bool
+	enCurso    atomic.Int64
+	carga      atomic.Pointer[FuncionCarga]
+}
+
+// MarcarListo indica que el módulo terminó de inicializarse y puede atender pedidos
+func (s *HTTPServer) MarcarListo() {
+	s.salud.listo.Store(true)
+}
+
+// EstaListo indica si el módulo ya fue marcado como listo
+func (s *HTTPServer) EstaListo() bool {
+	return s.salud.listo.Load()
+}
+
+// ReportarCarga define la función que completa la carga en /health
+func (s *HTTPServer) ReportarCarga(carga FuncionCarga) {
+	s.salud.carga.Store(&carga)
+}
+
+// Salud devuelve el estado actual del servidor
+func (s *HTTPServer) Salud() EstadoSalud {
+	estado := EstadoSalud{
+		Status:   SaludIniciando,
+		Module:   s.Nombre,
+		Listo:    s.salud.listo.Load(),
+		UptimeMs: time.Since(s.salud.inicio).Milliseconds(),
+		EnCurso:  s.salud.enCurso.Load(),
+	}
+	switch {
+	case s.salud.deteniendo.Load():
+		estado.Status = SaludDeteniendo
+		estado.Listo = false
+	case estado.Listo:
+		estado.Status = SaludOK
+	}
+	if carga := s.salud.carga.Load(); carga != nil && *carga != nil {
+		estado.Carga = (*carga)()
+	}
+	return estado
+}
+
+// atenderSalud responde /health: 200 si el módulo está listo, 503 mientras se inicializa o se detiene
+func (s *HTTPServer) atenderSalud(w http.ResponseWriter, r *http.Request) {
+	estado := s.Salud()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !estado.Listo {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(estado)
+}
@@ -1,8 +1,25 @@
 package utils
 
-// Semaforo implementa un semáforo contador con canales
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Semaforo implementa un semáforo contador de capacidad variable. Los Acquire bloqueados
+// se atienden en orden de llegada y se pueden cancelar con su contexto.
 type Semaforo struct {
-	c chan struct{}
+	mutex     sync.Mutex
+	capacidad int
+	enUso     int
+	esperando list.List // de *esperaSemaforo, en orden de llegada
+}
+
+// esperaSemaforo es un Acquire bloqueado; listo se cierra cuando se le otorgan sus unidades
+type esperaSemaforo struct {
+	n     int
+	listo chan struct{}
 }
 
 // NewSemaforo crea un semáforo con capacidad inicial
@@ -10,31 +27,113 @@ func NewSemaforo(capacidad int) *Semaforo {
 	if capacidad <= 0 {
 		capacidad = 1
 	}
-	return &Semaforo{
-		c: make(chan struct{}, capacidad),
-	}
+	return &Semaforo{capacidad: capacidad}
 }
 
-// Wait (P) decrementa el semáforo, bloquea si es 0
-func (s *Semaforo) Wait() {
-	s.c <- struct{}{}
-}
+// Acquire (P) toma n unidades, bloqueando hasta que estén disponibles o se cancele ctx.
+// Si ctx se cancela no toma ninguna y devuelve ErrorCancelado. Pedir n <= 0 o más que
+// la capacidad es un error: como la cola es en orden de llegada, esa espera trabaría a
+// todos los que vienen detrás.
+func (s *Semaforo) Acquire(ctx context.Context, n int) error {
+	s.mutex.Lock()
+	if n <= 0 || n > s.capacidad {
+		capacidad := s.capacidad
+		s.mutex.Unlock()
+		return fmt.Errorf("no se pueden tomar %d unidades de un semáforo de capacidad %d", n, capacidad)
+	}
+	if s.esperando.Len() == 0 && s.enUso+n <= s.capacidad {
+		s.enUso += n
+		s.mutex.Unlock()
+		return nil
+	}
+
+	espera := &esperaSemaforo{n: n, listo: make(chan struct{})}
+	elemento := s.esperando.PushBack(espera)
+	s.mutex.Unlock()
 
-// Signal (V) incrementa el semáforo
-func (s *Semaforo) Signal() {
 	select {
-	case <-s.c:
-	default:
-		// Capacidad completa, no hace nada para prevenir incremento excesivo
+	case <-espera.listo:
+		return nil
+	case <-ctx.Done():
+		s.mutex.Lock()
+		select {
+		case <-espera.listo:
+			// Se otorgó justo al cancelarse: se devuelve para no perder las unidades
+			s.enUso -= n
+			s.despertar()
+		default:
+			s.esperando.Remove(elemento)
+			// Si era el primero, los siguientes pueden entrar ahora
+			s.despertar()
+		}
+		s.mutex.Unlock()
+		return NuevoError(ErrorCancelado, "espera del semáforo cancelada: %v", ctx.Err())
 	}
 }
 
-// TryWait intenta decrementar sin bloquear
-func (s *Semaforo) TryWait() bool {
-	select {
-	case s.c <- struct{}{}:
+// TryAcquire toma n unidades solo si están disponibles sin esperar
+func (s *Semaforo) TryAcquire(n int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if n > 0 && s.esperando.Len() == 0 && s.enUso+n <= s.capacidad {
+		s.enUso += n
 		return true
-	default:
-		return false
 	}
-}
\ No newline at end of file
+	return false
+}
+
+// Release (V) devuelve n unidades y despierta a los que esperan en orden de llegada.
+// Devolver más de las tomadas es un error del llamador: se registra y se ignora el exceso.
+func (s *Semaforo) Release(n int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if n > s.enUso {
+		ErrorLog.Error("Release de semáforo sin Acquire previo", "liberadas", n, "en_uso", s.enUso)
+		n = s.enUso
+	}
+	s.enUso -= n
+	s.despertar()
+}
+
+// Resize cambia la capacidad. Si baja de las unidades en uso no se quita ninguna: los
+// nuevos Acquire esperan hasta que se liberen las suficientes.
+func (s *Semaforo) Resize(capacidad int) {
+	if capacidad <= 0 {
+		capacidad = 1
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.capacidad = capacidad
+	s.despertar()
+}
+
+// Capacidad devuelve la cantidad máxima de unidades que se pueden tomar a la vez
+func (s *Semaforo) Capacidad() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.capacidad
+}
+
+// EnUso devuelve la cantidad de unidades tomadas
+func (s *Semaforo) EnUso() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.enUso
+}
+
+// despertar otorga unidades a los que esperan mientras alcancen, respetando el orden de
+// llegada. Se llama con el mutex tomado.
+func (s *Semaforo) despertar() {
+	for elemento := s.esperando.Front(); elemento != nil; elemento = s.esperando.Front() {
+		espera := elemento.Value.(*esperaSemaforo)
+		if s.enUso+espera.n > s.capacidad {
+			return
+		}
+		s.enUso += espera.n
+		s.esperando.Remove(elemento)
+		close(espera.listo)
+	}
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// esperarEnCola espera a que haya n Acquire bloqueados en el semáforo
+func esperarEnCola(t *testing.T, s *Semaforo, n int) {
+	t.Helper()
+	limite := time.Now().Add(time.Second)
+	for time.Now().Before(limite) {
+		s.mutex.Lock()
+		enCola := s.esperando.Len()
+		s.mutex.Unlock()
+		if enCola == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("no hay %d esperas en la cola del semáforo", n)
+}
+
+// acquireEnSegundoPlano lanza un Acquire y devuelve el canal por el que llega su resultado
+func acquireEnSegundoPlano(ctx context.Context, s *Semaforo, n int) <-chan error {
+	resultado := make(chan error, 1)
+	go func() { resultado <- s.Acquire(ctx, n) }()
+	return resultado
+}
+
+func sigueBloqueado(t *testing.T, resultado <-chan error) {
+	t.Helper()
+	select {
+	case err := <-resultado:
+		t.Fatalf("el Acquire debía seguir bloqueado y terminó con %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestSemaforoAcquireRango(t *testing.T) {
+	casos := []struct {
+		nombre string
+		n      int
+		valido bool
+	}{
+		{"una unidad", 1, true},
+		{"toda la capacidad", 3, true},
+		{"cero", 0, false},
+		{"negativo", -1, false},
+		{"más que la capacidad", 4, false},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			s := NewSemaforo(3)
+			err := s.Acquire(context.Background(), caso.n)
+			if (err == nil) != caso.valido {
+				t.Fatalf("Acquire(%d) = %v, se esperaba válido=%v", caso.n, err, caso.valido)
+			}
+			if !caso.valido && s.EnUso() != 0 {
+				t.Fatalf("un Acquire inválido tomó %d unidades", s.EnUso())
+			}
+			if s.TryAcquire(caso.n) && !caso.valido {
+				t.Fatalf("TryAcquire(%d) tomó unidades inválidas", caso.n)
+			}
+		})
+	}
+}
+
+func TestSemaforoOrdenDeLlegada(t *testing.T) {
+	s := NewSemaforo(2)
+	if err := s.Acquire(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	primero := acquireEnSegundoPlano(context.Background(), s, 2)
+	esperarEnCola(t, s, 1)
+	segundo := acquireEnSegundoPlano(context.Background(), s, 1)
+	esperarEnCola(t, s, 2)
+
+	// Con una unidad libre el segundo podría entrar, pero está detrás del primero
+	s.Release(1)
+	sigueBloqueado(t, primero)
+	sigueBloqueado(t, segundo)
+	if s.TryAcquire(1) {
+		t.Fatal("TryAcquire se adelantó a los que esperan")
+	}
+
+	s.Release(1)
+	if err := recibir(t, primero); err != nil {
+		t.Fatal(err)
+	}
+	sigueBloqueado(t, segundo)
+
+	s.Release(2)
+	if err := recibir(t, segundo); err != nil {
+		t.Fatal(err)
+	}
+	if s.EnUso() != 1 {
+		t.Fatalf("EnUso = %d, se esperaba 1", s.EnUso())
+	}
+}
+
+func TestSemaforoCancelacion(t *testing.T) {
+	s := NewSemaforo(1)
+	if err := s.Acquire(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancelar := context.WithCancel(context.Background())
+	cancelado := acquireEnSegundoPlano(ctx, s, 1)
+	esperarEnCola(t, s, 1)
+	siguiente := acquireEnSegundoPlano(context.Background(), s, 1)
+	esperarEnCola(t, s, 2)
+
+	cancelar()
+	if err := recibir(t, cancelado); CodigoDe(err) != ErrorCancelado {
+		t.Fatalf("Acquire cancelado devolvió %v, se esperaba %s", err, ErrorCancelado)
+	}
+	esperarEnCola(t, s, 1)
+
+	// La unidad liberada es para el siguiente, no para el cancelado
+	s.Release(1)
+	if err := recibir(t, siguiente); err != nil {
+		t.Fatal(err)
+	}
+	if s.EnUso() != 1 {
+		t.Fatalf("EnUso = %d, se esperaba 1", s.EnUso())
+	}
+}
+
+func TestSemaforoResize(t *testing.T) {
+	casos := []struct {
+		nombre       string
+		capacidad    int
+		tomadas      int
+		nueva        int
+		liberadas    int
+		pedido       int
+		capacidadFin int
+		obtiene      bool
+	}{
+		{"crecer libera lugar", 1, 1, 3, 0, 2, 3, true},
+		{"achicar no quita unidades tomadas", 3, 3, 1, 2, 1, 1, false},
+		{"achicar y liberar todo", 3, 3, 1, 3, 1, 1, true},
+		{"capacidad inválida queda en 1", 2, 0, 0, 0, 1, 1, true},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			s := NewSemaforo(caso.capacidad)
+			if caso.tomadas > 0 && !s.TryAcquire(caso.tomadas) {
+				t.Fatalf("no se pudieron tomar %d unidades", caso.tomadas)
+			}
+			s.Resize(caso.nueva)
+			s.Release(caso.liberadas)
+
+			if s.Capacidad() != caso.capacidadFin {
+				t.Fatalf("Capacidad = %d, se esperaba %d", s.Capacidad(), caso.capacidadFin)
+			}
+			if obtiene := s.TryAcquire(caso.pedido); obtiene != caso.obtiene {
+				t.Fatalf("TryAcquire(%d) = %v, se esperaba %v (en uso %d)", caso.pedido, obtiene, caso.obtiene, s.EnUso())
+			}
+		})
+	}
+}
+
+func TestSemaforoResizeDespiertaEsperas(t *testing.T) {
+	s := NewSemaforo(1)
+	if err := s.Acquire(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	espera := acquireEnSegundoPlano(context.Background(), s, 1)
+	esperarEnCola(t, s, 1)
+
+	s.Resize(2)
+	if err := recibir(t, espera); err != nil {
+		t.Fatal(err)
+	}
+	if s.EnUso() != 2 {
+		t.Fatalf("EnUso = %d, se esperaba 2", s.EnUso())
+	}
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// OpcionesTLS son las claves de configuración de TLS mutuo entre módulos.
+// Se embeben en la configuración de cada módulo; sin valores el tráfico va en claro.
+type OpcionesTLS struct {
+	TLSCA          string `json:"TLS_CA,omitempty"`          // Certificado de la CA que firmó a todos los módulos
+	TLSCertificado string `json:"TLS_CERTIFICADO,omitempty"` // Certificado propio, como servidor y como cliente
+	TLSClave       string `json:"TLS_CLAVE,omitempty"`       // Clave privada del certificado propio
+}
+
+// Habilitado indica si se configuró TLS
+func (o OpcionesTLS) Habilitado() bool {
+	return o.TLSCA != "" || o.TLSCertificado != "" || o.TLSClave != ""
+}
+
+// OpcionesTLS verifica que TLS esté completo y que sus archivos existan
+func (r *ReporteConfig) OpcionesTLS(opciones OpcionesTLS) {
+	if !opciones.Habilitado() {
+		return
+	}
+	archivos := []struct{ clave, ruta string }{
+		{"TLS_CA", opciones.TLSCA},
+		{"TLS_CERTIFICADO", opciones.TLSCertificado},
+		{"TLS_CLAVE", opciones.TLSClave},
+	}
+	for _, archivo := range archivos {
+		clave, ruta := archivo.clave, archivo.ruta
+		if ruta == "" {
+			r.Errorf(clave, "es obligatorio cuando se configura TLS")
+			continue
+		}
+		if _, err := os.Stat(ruta); err != nil {
+			r.Errorf(clave, "no se puede leer %s: %v", ruta, err)
+		}
+	}
+}
+
+// Configuraciones TLS de los servidores y clientes que se creen; nil usa HTTP en claro
+var (
+	tlsServidor *tls.Config
+	tlsCliente  *tls.Config
+)
+
+// HabilitarTLS carga los certificados y hace que los servidores y clientes creados desde
+// entonces usen TLS mutuo: el servidor exige certificado de cliente y ambos lados solo
+// aceptan certificados firmados por la CA configurada. Debe llamarse antes de crearlos.
+func HabilitarTLS(opciones OpcionesTLS) error {
+	if !opciones.Habilitado() {
+		return nil
+	}
+
+	certificado, err := tls.LoadX509KeyPair(opciones.TLSCertificado, opciones.TLSClave)
+	if err != nil {
+		return fmt.Errorf("no se pudo cargar el certificado %s: %w", opciones.TLSCertificado, err)
+	}
+
+	pem, err := os.ReadFile(opciones.TLSCA)
+	if err != nil {
+		return fmt.Errorf("no se pudo leer la CA %s: %w", opciones.TLSCA, err)
+	}
+	ca := x509.NewCertPool()
+	if !ca.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("%s no contiene certificados PEM", opciones.TLSCA)
+	}
+
+	tlsServidor = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{certificado},
+		ClientCAs:    ca,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	tlsCliente = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{certificado},
+		RootCAs:      ca,
+	}
+
+	slog.Info("TLS mutuo habilitado", "ca", opciones.TLSCA, "certificado", opciones.TLSCertificado)
+	return nil
+}
+
+// ConfigTLSCliente devuelve la configuración de los clientes creados con HabilitarTLS,
+// o nil si el tráfico va en claro. Sirve a las herramientas que hablan HTTP directamente.
+func ConfigTLSCliente() *tls.Config {
+	return tlsCliente
+}
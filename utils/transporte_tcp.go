@@ -0,0 +1,563 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Transportes disponibles para los envíos de un HTTPClient (clave TRANSPORTE de la configuración).
+// El servidor de cada módulo acepta ambos en el mismo puerto.
+const (
+	TransporteHTTP = "HTTP"
+	TransporteTCP  = "TCP"
+)
+
+// preambuloTCP lo envía el cliente al abrir una conexión del transporte TCP
+const preambuloTCP = "SO-TCP/1\n"
+
+// Tipos de trama del transporte TCP
+const (
+	tramaSolicitud   byte = 1
+	tramaRespuesta   byte = 2
+	tramaCancelacion byte = 3
+)
+
+// maxTamanioTrama limita el tamaño de una trama para no reservar memoria por datos corruptos.
+// Los mensajes más grandes (lotes de páginas, dumps) quedan muy por debajo.
+const maxTamanioTrama = 4 << 20
+
+// plazoAvisoCancelacion limita la escritura del aviso de cancelación, que se envía cuando
+// el contexto de la solicitud ya terminó
+const plazoAvisoCancelacion = time.Second
+
+// maxSolicitudesPorConexion acota las solicitudes en curso de una conexión TCP: las que
+// exceden se rechazan con ErrorNoListo en lugar de lanzar una goroutine más
+const maxSolicitudesPorConexion = 64
+
+// trama es la unidad del transporte TCP. Formato (big endian):
+//
+//	longitud uint32 (de lo que sigue) | tipo byte | id uint64 | contenido
+//
+// El contenido depende del tipo:
+//
+//	solicitud:   plazo int64 (unix nano, 0 sin plazo) | Mensaje en JSON
+//	respuesta:   estado uint16 (mismo código que en HTTP) | cuerpo en JSON
+//	cancelación: vacío
+type trama struct {
+	tipo   byte
+	id     uint64
+	plazo  int64
+	estado uint16
+	cuerpo []byte
+}
+
+// cabeceraTrama devuelve cuántos bytes ocupan tipo, id y los campos propios del tipo
+func cabeceraTrama(tipo byte) int {
+	cabecera := 1 + 8
+	switch tipo {
+	case tramaSolicitud:
+		cabecera += 8
+	case tramaRespuesta:
+		cabecera += 2
+	}
+	return cabecera
+}
+
+// excedeTrama indica si t supera maxTamanioTrama: el otro extremo cortaría la conexión al leerla
+func excedeTrama(t trama) bool {
+	return cabeceraTrama(t.tipo)+len(t.cuerpo) > maxTamanioTrama
+}
+
+// escribirTrama serializa t en w con un único Write
+func escribirTrama(w io.Writer, t trama) error {
+	cabecera := cabeceraTrama(t.tipo)
+
+	buffer := make([]byte, 4+cabecera+len(t.cuerpo))
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(cabecera+len(t.cuerpo)))
+	buffer[4] = t.tipo
+	binary.BigEndian.PutUint64(buffer[5:13], t.id)
+
+	switch t.tipo {
+	case tramaSolicitud:
+		binary.BigEndian.PutUint64(buffer[13:21], uint64(t.plazo))
+	case tramaRespuesta:
+		binary.BigEndian.PutUint16(buffer[13:15], t.estado)
+	}
+	copy(buffer[4+cabecera:], t.cuerpo)
+
+	_, err := w.Write(buffer)
+	return err
+}
+
+// leerTrama lee la próxima trama completa de r
+func leerTrama(r io.Reader) (trama, error) {
+	var t trama
+
+	var longitud [4]byte
+	if _, err := io.ReadFull(r, longitud[:]); err != nil {
+		return t, err
+	}
+	tamanio := binary.BigEndian.Uint32(longitud[:])
+	if tamanio < 9 || tamanio > maxTamanioTrama {
+		return t, fmt.Errorf("trama con longitud inválida: %d", tamanio)
+	}
+
+	contenido := make([]byte, tamanio)
+	if _, err := io.ReadFull(r, contenido); err != nil {
+		return t, err
+	}
+
+	t.tipo = contenido[0]
+	t.id = binary.BigEndian.Uint64(contenido[1:9])
+	resto := contenido[9:]
+
+	switch t.tipo {
+	case tramaSolicitud:
+		if len(resto) < 8 {
+			return t, fmt.Errorf("trama de solicitud incompleta")
+		}
+		t.plazo = int64(binary.BigEndian.Uint64(resto[:8]))
+		t.cuerpo = resto[8:]
+	case tramaRespuesta:
+		if len(resto) < 2 {
+			return t, fmt.Errorf("trama de respuesta incompleta")
+		}
+		t.estado = binary.BigEndian.Uint16(resto[:2])
+		t.cuerpo = resto[2:]
+	case tramaCancelacion:
+	default:
+		return t, fmt.Errorf("tipo de trama desconocido: %d", t.tipo)
+	}
+	return t, nil
+}
+
+// ============================================================================
+// Lado servidor
+// ============================================================================
+
+// listenerHTTP entrega al servidor HTTP solo las conexiones que no son del transporte TCP
+type listenerHTTP struct {
+	net.Listener
+	conexiones chan net.Conn
+	errores    chan error
+	cerrado    chan struct{}
+	cierre     sync.Once
+}
+
+func (l *listenerHTTP) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conexiones:
+		return conn, nil
+	case err := <-l.errores:
+		return nil, err
+	case <-l.cerrado:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *listenerHTTP) Close() error {
+	l.cierre.Do(func() { close(l.cerrado) })
+	return l.Listener.Close()
+}
+
+// conexionLeida es una conexión de la que ya se leyeron bytes para clasificarla
+type conexionLeida struct {
+	net.Conn
+	lector *bufio.Reader
+}
+
+func (c *conexionLeida) Read(b []byte) (int, error) {
+	return c.lector.Read(b)
+}
+
+// separarTransportes acepta las conexiones del listener, atiende las del transporte TCP
+// y devuelve un listener con las conexiones HTTP restantes
+func (s *HTTPServer) separarTransportes(listener net.Listener) net.Listener {
+	listenerHTTP := &listenerHTTP{
+		Listener:   listener,
+		conexiones: make(chan net.Conn),
+		errores:    make(chan error, 1),
+		cerrado:    make(chan struct{}),
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				listenerHTTP.errores <- err
+				return
+			}
+			go s.clasificarConexion(conn, listenerHTTP)
+		}
+	}()
+
+	return listenerHTTP
+}
+
+// clasificarConexion mira el inicio de la conexión para decidir qué transporte la atiende
+func (s *HTTPServer) clasificarConexion(conn net.Conn, listenerHTTP *listenerHTTP) {
+	// Un cliente sin certificado válido se descarta antes de llegar a cualquier transporte
+	if connTLS, ok := conn.(*tls.Conn); ok {
+		ctx, cancelar := context.WithTimeout(context.Background(), TimeoutPorDefecto)
+		err := connTLS.HandshakeContext(ctx)
+		cancelar()
+		if err != nil {
+			slog.Warn("Conexión TLS rechazada", "módulo", s.Nombre, "remoto", conn.RemoteAddr().String(), "error", err)
+			conn.Close()
+			return
+		}
+	}
+
+	lector := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(time.Now().Add(TimeoutPorDefecto))
+	inicio, err := lector.Peek(len(preambuloTCP))
+	conn.SetReadDeadline(time.Time{})
+
+	if err == nil && string(inicio) == preambuloTCP {
+		lector.Discard(len(preambuloTCP))
+		s.servirTCP(conn, lector)
+		return
+	}
+
+	select {
+	case listenerHTTP.conexiones <- &conexionLeida{Conn: conn, lector: lector}:
+	case <-listenerHTTP.cerrado:
+		conn.Close()
+	}
+}
+
+// servirTCP atiende las solicitudes de una conexión TCP. Cada solicitud se procesa en su
+// propia goroutine, hasta maxSolicitudesPorConexion a la vez, y las respuestas se
+// devuelven en el orden en que terminan.
+func (s *HTTPServer) servirTCP(conn net.Conn, lector *bufio.Reader) {
+	s.mutex.Lock()
+	if s.cerrado {
+		s.mutex.Unlock()
+		conn.Close()
+		return
+	}
+	s.conexionesTCP[conn] = struct{}{}
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conexionesTCP, conn)
+		s.mutex.Unlock()
+		conn.Close()
+	}()
+	slog.Info("Conexión TCP aceptada", "módulo", s.Nombre, "remoto", conn.RemoteAddr().String())
+
+	ctxConexion, cancelarConexion := context.WithCancel(context.Background())
+	defer cancelarConexion()
+
+	var escrituraMutex sync.Mutex
+	var pendientesMutex sync.Mutex
+	pendientes := make(map[uint64]context.CancelFunc)
+	enCurso := make(chan struct{}, maxSolicitudesPorConexion)
+	responder := func(id uint64, estado uint16, cuerpo []byte) {
+		escrituraMutex.Lock()
+		defer escrituraMutex.Unlock()
+		if err := escribirTrama(conn, trama{tipo: tramaRespuesta, id: id, estado: estado, cuerpo: cuerpo}); err != nil {
+			slog.Warn("No se pudo enviar la respuesta TCP", "módulo", s.Nombre, "error", err)
+		}
+	}
+
+	for {
+		t, err := leerTrama(lector)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				slog.Warn("Conexión TCP cerrada por error", "módulo", s.Nombre, "remoto", conn.RemoteAddr().String(), "error", err)
+			}
+			return
+		}
+
+		switch t.tipo {
+		case tramaCancelacion:
+			pendientesMutex.Lock()
+			if cancelar, existe := pendientes[t.id]; existe {
+				cancelar()
+			}
+			pendientesMutex.Unlock()
+
+		case tramaSolicitud:
+			select {
+			case enCurso <- struct{}{}:
+			default:
+				sobre := s.sobreDeError(NuevoError(ErrorNoListo, "%s tiene %d solicitudes en curso en la conexión", s.Nombre, maxSolicitudesPorConexion))
+				cuerpo, _ := json.Marshal(sobre)
+				responder(t.id, uint16(sobre.Error.EstadoHTTP()), cuerpo)
+				continue
+			}
+
+			var ctx context.Context
+			var cancelar context.CancelFunc
+			if t.plazo > 0 {
+				ctx, cancelar = context.WithDeadline(ctxConexion, time.Unix(0, t.plazo))
+			} else {
+				ctx, cancelar = context.WithCancel(ctxConexion)
+			}
+
+			pendientesMutex.Lock()
+			pendientes[t.id] = cancelar
+			pendientesMutex.Unlock()
+
+			go func(solicitud trama) {
+				defer func() {
+					pendientesMutex.Lock()
+					delete(pendientes, solicitud.id)
+					pendientesMutex.Unlock()
+					cancelar()
+					<-enCurso
+				}()
+
+				estado, cuerpo := s.responderTCP(ctx, solicitud.cuerpo)
+				responder(solicitud.id, estado, cuerpo)
+			}(t)
+		}
+	}
+}
+
+// responderTCP procesa un Mensaje recibido por TCP y devuelve estado y cuerpo de la respuesta
+func (s *HTTPServer) responderTCP(ctx context.Context, datos []byte) (uint16, []byte) {
+	var mensaje Mensaje
+	var respuesta interface{}
+
+	err := json.Unmarshal(datos, &mensaje)
+	if err != nil {
+		err = NuevoError(ErrorEsquemaInvalido, "error decodificando mensaje: %v", err)
+	} else {
+		respuesta, err = s.procesarMensaje(ctx, &mensaje)
+	}
+
+	estado := http.StatusOK
+	if err != nil {
+		sobre := s.sobreDeError(err)
+		estado = sobre.Error.EstadoHTTP()
+		respuesta = sobre
+	}
+
+	cuerpo, err := json.Marshal(respuesta)
+	if err != nil {
+		sobre := s.sobreDeError(NuevoError(ErrorInterno, "error serializando respuesta: %v", err))
+		estado = sobre.Error.EstadoHTTP()
+		cuerpo, _ = json.Marshal(sobre)
+	} else if excedeTrama(trama{tipo: tramaRespuesta, cuerpo: cuerpo}) {
+		sobre := s.sobreDeError(NuevoError(ErrorEsquemaInvalido, "la respuesta de %d bytes no entra en una trama", len(cuerpo)))
+		estado = sobre.Error.EstadoHTTP()
+		cuerpo, _ = json.Marshal(sobre)
+	}
+	return uint16(estado), cuerpo
+}
+
+// ============================================================================
+// Lado cliente
+// ============================================================================
+
+// conexionTCP es una conexión persistente que multiplexa solicitudes por id de trama
+type conexionTCP struct {
+	conn           net.Conn
+	escrituraMutex sync.Mutex
+
+	mutex      sync.Mutex
+	pendientes map[uint64]chan trama
+	proximoID  uint64
+	err        error // distinto de nil cuando la conexión se cerró
+}
+
+// abrirConexionTCP conecta con el servidor y envía el preámbulo del transporte.
+// Con configTLS la conexión se cifra antes de enviar el preámbulo.
+func abrirConexionTCP(ctx context.Context, direccion string, configTLS *tls.Config) (*conexionTCP, error) {
+	var conn net.Conn
+	var err error
+	if configTLS != nil {
+		dialer := tls.Dialer{Config: configTLS}
+		conn, err = dialer.DialContext(ctx, "tcp", direccion)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", direccion)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.WriteString(conn, preambuloTCP); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conexion := &conexionTCP{
+		conn:       conn,
+		pendientes: make(map[uint64]chan trama),
+	}
+	go conexion.leerRespuestas()
+	return conexion, nil
+}
+
+// leerRespuestas entrega cada respuesta a la solicitud que la espera
+func (c *conexionTCP) leerRespuestas() {
+	lector := bufio.NewReader(c.conn)
+	for {
+		t, err := leerTrama(lector)
+		if err != nil {
+			c.cerrar(err)
+			return
+		}
+
+		c.mutex.Lock()
+		espera, existe := c.pendientes[t.id]
+		delete(c.pendientes, t.id)
+		c.mutex.Unlock()
+
+		if existe {
+			espera <- t
+		}
+	}
+}
+
+// cerrar marca la conexión como caída y libera a todas las solicitudes pendientes
+func (c *conexionTCP) cerrar(err error) {
+	c.mutex.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	pendientes := c.pendientes
+	c.pendientes = make(map[uint64]chan trama)
+	c.mutex.Unlock()
+
+	c.conn.Close()
+	for _, espera := range pendientes {
+		close(espera)
+	}
+}
+
+// cerrada indica si la conexión ya no puede usarse
+func (c *conexionTCP) cerrada() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err != nil
+}
+
+// escribir envía t sin pasarse del plazo de ctx, para que un servidor que no lee no retenga
+// escrituraMutex. Una trama a medio escribir deja la conexión inservible: se cierra.
+func (c *conexionTCP) escribir(ctx context.Context, t trama) error {
+	c.escrituraMutex.Lock()
+	defer c.escrituraMutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	plazo, _ := ctx.Deadline()
+	c.conn.SetWriteDeadline(plazo)
+	if err := escribirTrama(c.conn, t); err != nil {
+		c.cerrar(err)
+		return err
+	}
+	return nil
+}
+
+// solicitar envía un Mensaje serializado y espera su respuesta o la cancelación de ctx
+func (c *conexionTCP) solicitar(ctx context.Context, datos []byte) (trama, error) {
+	solicitud := trama{tipo: tramaSolicitud, cuerpo: datos}
+	if excedeTrama(solicitud) {
+		return trama{}, NuevoError(ErrorEsquemaInvalido, "el mensaje de %d bytes no entra en una trama", len(datos))
+	}
+	if plazo, ok := ctx.Deadline(); ok {
+		solicitud.plazo = plazo.UnixNano()
+	}
+
+	c.mutex.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mutex.Unlock()
+		return trama{}, err
+	}
+	c.proximoID++
+	id := c.proximoID
+	espera := make(chan trama, 1)
+	c.pendientes[id] = espera
+	c.mutex.Unlock()
+
+	solicitud.id = id
+	if err := c.escribir(ctx, solicitud); err != nil {
+		c.mutex.Lock()
+		delete(c.pendientes, id)
+		c.mutex.Unlock()
+		return trama{}, err
+	}
+
+	select {
+	case respuesta, ok := <-espera:
+		if !ok {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+			return trama{}, c.err
+		}
+		return respuesta, nil
+	case <-ctx.Done():
+		c.mutex.Lock()
+		delete(c.pendientes, id)
+		c.mutex.Unlock()
+
+		// Avisar al servidor para que cancele el contexto del handler
+		ctxAviso, cancelarAviso := context.WithTimeout(context.Background(), plazoAvisoCancelacion)
+		c.escribir(ctxAviso, trama{tipo: tramaCancelacion, id: id})
+		cancelarAviso()
+		return trama{}, ctx.Err()
+	}
+}
+
+// conexionTCP devuelve la conexión persistente del cliente, abriéndola si hace falta
+func (c *HTTPClient) conexionTCP(ctx context.Context) (*conexionTCP, error) {
+	c.tcpMutex.Lock()
+	defer c.tcpMutex.Unlock()
+
+	if c.tcp != nil && !c.tcp.cerrada() {
+		return c.tcp, nil
+	}
+
+	conexion, err := abrirConexionTCP(ctx, c.direccion, c.tls)
+	if err != nil {
+		return nil, err
+	}
+	c.tcp = conexion
+	return conexion, nil
+}
+
+// postTCP envía el mensaje serializado por la conexión TCP persistente
+func (c *HTTPClient) postTCP(ctx context.Context, jsonData []byte) ([]byte, error) {
+	ctx, cancelar := c.contextoConPlazo(ctx)
+	defer cancelar()
+
+	conexion, err := c.conexionTCP(ctx)
+	if err != nil {
+		return nil, errorDeRed(c.Nombre, err)
+	}
+
+	respuesta, err := conexion.solicitar(ctx, jsonData)
+	var errorModulo *ErrorModulo
+	if errors.As(err, &errorModulo) {
+		// El mensaje no salió: no es un problema de la red
+		errorModulo.Modulo = c.Nombre
+		return nil, errorModulo
+	}
+	if err != nil {
+		return nil, errorDeRed(c.Nombre, err)
+	}
+
+	if respuesta.estado != http.StatusOK {
+		return nil, c.decodificarError(int(respuesta.estado), respuesta.cuerpo)
+	}
+	return respuesta.cuerpo, nil
+}
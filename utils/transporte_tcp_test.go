@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTramaIdaYVuelta(t *testing.T) {
+	casos := []struct {
+		nombre string
+		trama  trama
+	}{
+		{"solicitud con plazo", trama{tipo: tramaSolicitud, id: 7, plazo: time.Now().UnixNano(), cuerpo: []byte(`{"tipo":1}`)}},
+		{"solicitud sin plazo", trama{tipo: tramaSolicitud, id: 1 << 40, cuerpo: []byte(`{}`)}},
+		{"respuesta", trama{tipo: tramaRespuesta, id: 7, estado: http.StatusOK, cuerpo: []byte(`{"ok":true}`)}},
+		{"respuesta de error sin cuerpo", trama{tipo: tramaRespuesta, id: 8, estado: http.StatusServiceUnavailable}},
+		{"cancelación", trama{tipo: tramaCancelacion, id: 9}},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := escribirTrama(&buffer, caso.trama); err != nil {
+				t.Fatal(err)
+			}
+			leida, err := leerTrama(&buffer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if leida.tipo != caso.trama.tipo || leida.id != caso.trama.id || leida.plazo != caso.trama.plazo ||
+				leida.estado != caso.trama.estado || !bytes.Equal(leida.cuerpo, caso.trama.cuerpo) {
+				t.Fatalf("se leyó %+v, se esperaba %+v", leida, caso.trama)
+			}
+			if buffer.Len() != 0 {
+				t.Fatalf("quedaron %d bytes sin leer", buffer.Len())
+			}
+		})
+	}
+}
+
+// tramaCruda arma una trama con la longitud indicada y el contenido tal cual
+func tramaCruda(longitud uint32, contenido []byte) []byte {
+	buffer := binary.BigEndian.AppendUint32(nil, longitud)
+	return append(buffer, contenido...)
+}
+
+func TestLeerTramaInvalida(t *testing.T) {
+	cabecera := func(tipo byte) []byte {
+		return append([]byte{tipo}, make([]byte, 8)...)
+	}
+
+	casos := []struct {
+		nombre string
+		datos  []byte
+	}{
+		{"vacía", nil},
+		{"longitud corta", tramaCruda(8, make([]byte, 8))},
+		{"longitud excesiva", tramaCruda(maxTamanioTrama+1, nil)},
+		{"contenido truncado", tramaCruda(20, cabecera(tramaSolicitud))},
+		{"solicitud sin plazo", tramaCruda(12, append(cabecera(tramaSolicitud), 0, 0, 0))},
+		{"respuesta sin estado", tramaCruda(10, append(cabecera(tramaRespuesta), 0))},
+		{"tipo desconocido", tramaCruda(9, cabecera(42))},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			if leida, err := leerTrama(bytes.NewReader(caso.datos)); err == nil {
+				t.Fatalf("se aceptó la trama %+v", leida)
+			}
+		})
+	}
+}
+
+func TestClasificarConexion(t *testing.T) {
+	solicitud, err := json.Marshal(Mensaje{Tipo: 1, Operacion: "eco", Origen: "test", Version: VersionProtocolo, Datos: "hola"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tramaTCP bytes.Buffer
+	tramaTCP.WriteString(preambuloTCP)
+	escribirTrama(&tramaTCP, trama{tipo: tramaSolicitud, id: 5, cuerpo: solicitud})
+
+	casos := []struct {
+		nombre string
+		inicio []byte
+		esTCP  bool
+	}{
+		{"preámbulo TCP", tramaTCP.Bytes(), true},
+		{"solicitud HTTP", []byte("POST /mensaje HTTP/1.1\r\nHost: kernel\r\n\r\n"), false},
+		{"preámbulo de otra versión", []byte("SO-TCP/2\nxxxxxxxx"), false},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			servidor := NewHTTPServer("127.0.0.1", 0, "Test")
+			servidor.RegisterHTTPHandler(1, func(msg *Mensaje) (interface{}, error) {
+				return map[string]interface{}{"eco": msg.Datos}, nil
+			})
+			listener := &listenerHTTP{conexiones: make(chan net.Conn, 1), cerrado: make(chan struct{})}
+
+			cliente, conexion := net.Pipe()
+			defer cliente.Close()
+			cliente.SetDeadline(time.Now().Add(2 * time.Second))
+			go servidor.clasificarConexion(conexion, listener)
+			go cliente.Write(caso.inicio)
+
+			if caso.esTCP {
+				respuesta, err := leerTrama(cliente)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if respuesta.tipo != tramaRespuesta || respuesta.id != 5 || respuesta.estado != http.StatusOK {
+					t.Fatalf("respuesta inesperada %+v (%s)", respuesta, respuesta.cuerpo)
+				}
+				if !bytes.Contains(respuesta.cuerpo, []byte(`"eco":"hola"`)) {
+					t.Fatalf("cuerpo inesperado %s", respuesta.cuerpo)
+				}
+				return
+			}
+
+			select {
+			case conexionHTTP := <-listener.conexiones:
+				// Lo que se miró para clasificarla tiene que llegar intacto al servidor HTTP
+				leido := make([]byte, len(caso.inicio))
+				if _, err := io.ReadFull(conexionHTTP, leido); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(leido, caso.inicio) {
+					t.Fatalf("el servidor HTTP leyó %q, se esperaba %q", leido, caso.inicio)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("la conexión no se entregó al servidor HTTP")
+			}
+		})
+	}
+}
+
+func TestSolicitarSinBloquearLaConexion(t *testing.T) {
+	casos := []struct {
+		nombre string
+		datos  []byte
+		// cerrada indica si la conexión queda inservible después del error
+		cerrada  bool
+		esperado CodigoError
+	}{
+		{"mensaje que no entra en una trama", make([]byte, maxTamanioTrama), false, ErrorEsquemaInvalido},
+		{"servidor que no lee", []byte(`{"tipo":1}`), true, ErrorTimeout},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			// Nadie lee del otro extremo del pipe: cualquier escritura queda trabada
+			cliente, servidor := net.Pipe()
+			defer servidor.Close()
+			conexion := &conexionTCP{conn: cliente, pendientes: make(map[uint64]chan trama)}
+
+			ctx, cancelar := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancelar()
+			_, err := conexion.solicitar(ctx, caso.datos)
+			codigo := CodigoDe(err)
+			if caso.cerrada {
+				// Los errores de escritura los clasifica postTCP
+				codigo = errorDeRed("Memoria", err).Codigo
+			}
+			if codigo != caso.esperado {
+				t.Fatalf("error %v, se esperaba %q", err, caso.esperado)
+			}
+			if conexion.cerrada() != caso.cerrada {
+				t.Fatalf("conexión cerrada = %v, se esperaba %v", conexion.cerrada(), caso.cerrada)
+			}
+			if len(conexion.pendientes) != 0 {
+				t.Fatalf("quedaron %d solicitudes pendientes", len(conexion.pendientes))
+			}
+		})
+	}
+}
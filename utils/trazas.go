@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Span es un tramo de trabajo de un módulo dentro de una traza.
+// Los spans se escriben como una línea JSON al finalizar.
+type Span struct {
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	SpanPadre  string                 `json:"span_padre,omitempty"`
+	Nombre     string                 `json:"nombre"`
+	Modulo     string                 `json:"modulo"`
+	InicioUs   int64                  `json:"inicio_us"`
+	DuracionUs int64                  `json:"duracion_us"`
+	Atributos  map[string]interface{} `json:"atributos,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+
+	inicio time.Time
+}
+
+// claveSpan identifica el span activo dentro de un context.Context
+type claveSpan struct{}
+
+// registroTrazas es el archivo JSONL donde el módulo vuelca sus spans
+type registroTrazas struct {
+	mutex   sync.Mutex
+	modulo  string
+	archivo *os.File
+	encoder *json.Encoder
+}
+
+var trazas *registroTrazas
+
+// InicializarTrazas abre (o crea) el archivo de spans del módulo.
+// Sin ruta las trazas se propagan entre módulos pero no se registran.
+func InicializarTrazas(modulo string, ruta string) error {
+	if ruta == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ruta), 0755); err != nil {
+		return fmt.Errorf("error al crear directorio de trazas: %v", err)
+	}
+
+	archivo, err := os.OpenFile(ruta, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error al abrir archivo de trazas: %v", err)
+	}
+
+	trazas = &registroTrazas{
+		modulo:  modulo,
+		archivo: archivo,
+		encoder: json.NewEncoder(archivo),
+	}
+	InfoLog.Info("Trazas habilitadas", "archivo", ruta)
+	return nil
+}
+
+// IniciarSpan crea un span hijo del que tenga ctx (o la raíz de una traza nueva)
+// y devuelve el contexto que lo lleva. Los atributos son pares clave, valor como en slog.
+func IniciarSpan(ctx context.Context, nombre string, atributos ...interface{}) (context.Context, *Span) {
+	ahora := time.Now()
+	span := &Span{
+		SpanID:   nuevoIDTraza(8),
+		Nombre:   nombre,
+		InicioUs: ahora.UnixMicro(),
+		inicio:   ahora,
+	}
+
+	if padre := SpanDe(ctx); padre != nil {
+		span.TraceID = padre.TraceID
+		span.SpanPadre = padre.SpanID
+	} else {
+		span.TraceID = nuevoIDTraza(16)
+	}
+
+	for i := 0; i+1 < len(atributos); i += 2 {
+		span.Atributo(fmt.Sprint(atributos[i]), atributos[i+1])
+	}
+
+	return context.WithValue(ctx, claveSpan{}, span), span
+}
+
+// SpanDe devuelve el span activo de ctx, o nil si no hay ninguno
+func SpanDe(ctx context.Context) *Span {
+	span, _ := ctx.Value(claveSpan{}).(*Span)
+	return span
+}
+
+// contextoConTrazaRemota continúa en ctx la traza que indicó el emisor del mensaje
+func contextoConTrazaRemota(ctx context.Context, traceID string, spanPadre string) context.Context {
+	if traceID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, claveSpan{}, &Span{TraceID: traceID, SpanID: spanPadre})
+}
+
+// Atributo agrega un dato al span
+func (s *Span) Atributo(clave string, valor interface{}) {
+	if s.Atributos == nil {
+		s.Atributos = make(map[string]interface{})
+	}
+	s.Atributos[clave] = valor
+}
+
+// RegistrarError marca el span como fallido; err nil no tiene efecto
+func (s *Span) RegistrarError(err error) {
+	if err != nil {
+		s.Error = err.Error()
+	}
+}
+
+// Finalizar cierra el span y lo escribe en el archivo de trazas del módulo
+func (s *Span) Finalizar() {
+	s.DuracionUs = time.Since(s.inicio).Microseconds()
+
+	registro := trazas
+	if registro == nil {
+		return
+	}
+
+	registro.mutex.Lock()
+	defer registro.mutex.Unlock()
+
+	s.Modulo = registro.modulo
+	if err := registro.encoder.Encode(s); err != nil {
+		ErrorLog.Error("Error escribiendo span", "span", s.Nombre, "error", err)
+	}
+}
+
+// nuevoIDTraza genera un identificador hexadecimal aleatorio de n bytes
+func nuevoIDTraza(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}